@@ -2,11 +2,30 @@ package api
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
 	"net/http"
+	"time"
 
+	"github.com/akshtrikha/golang-ecomm/config"
+	"github.com/akshtrikha/golang-ecomm/services/auth"
+	"github.com/akshtrikha/golang-ecomm/services/cart"
+	"github.com/akshtrikha/golang-ecomm/services/category"
+	"github.com/akshtrikha/golang-ecomm/services/currency"
+	"github.com/akshtrikha/golang-ecomm/services/idempotency"
+	"github.com/akshtrikha/golang-ecomm/services/order"
+	"github.com/akshtrikha/golang-ecomm/services/payment"
 	"github.com/akshtrikha/golang-ecomm/services/product"
+	"github.com/akshtrikha/golang-ecomm/services/promotion"
+	"github.com/akshtrikha/golang-ecomm/services/refund"
+	"github.com/akshtrikha/golang-ecomm/services/returns"
+	"github.com/akshtrikha/golang-ecomm/services/search"
+	"github.com/akshtrikha/golang-ecomm/services/session"
+	"github.com/akshtrikha/golang-ecomm/services/shipping"
+	"github.com/akshtrikha/golang-ecomm/services/tax"
 	"github.com/akshtrikha/golang-ecomm/services/user"
+	"github.com/akshtrikha/golang-ecomm/services/webhook"
+	"github.com/akshtrikha/golang-ecomm/utils"
 	"github.com/gorilla/mux"
 )
 
@@ -29,28 +48,129 @@ func (s *APIServer) Run() error {
 	// Create a mux router
 	router := mux.NewRouter()
 
+	// identify every request in the responses and in the logs
+	// of its errors, the unknown routes get problem responses too
+	router.Use(utils.RequestIDMiddleware)
+	router.NotFoundHandler = utils.RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("no route matches %s %s", r.Method, r.URL.Path))
+	}))
+	router.MethodNotAllowedHandler = utils.RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		utils.WriteError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed on %s", r.Method, r.URL.Path))
+	}))
+
+	// refuse to start without the keys the jwt tokens are signed with
+	if _, err := auth.LoadKeySet(config.Envs); err != nil {
+		return err
+	}
+
+	// refuse to start without the secret the payment webhooks are signed with
+	if config.Envs.PaymentWebhookSecret == "" {
+		return fmt.Errorf("PAYMENT_WEBHOOK_SECRET is required to verify the payment webhooks")
+	}
+
+	// publish the public keys the jwt tokens can be verified with
+	router.HandleFunc("/.well-known/jwks.json", auth.HandleJWKS).Methods("GET")
+
 	// create a subrouter out of the router
 	// the main router routes all the /api/v1 apis.
 	// this helps use to do versioning of the api endpoints.
 	subrouter := router.PathPrefix("/api/v1").Subrouter()
 
+	// verify the jwt of every api request and place the
+	// authenticated user in the request context
+	subrouter.Use(auth.JWTMiddleware)
+
 	// create a dao for user
 	userStore := user.NewStore(s.db)
 	productStore := product.NewStore(s.db)
+	orderStore := order.NewStore(s.db)
+	cartStore := cart.NewStore(s.db)
+	sessionStore := session.NewStore(s.db)
+	categoryStore := category.NewStore(s.db)
+	searchStore := search.NewStore(s.db)
+	currencyStore := currency.NewStore(s.db)
+	promotionStore := promotion.NewStore(s.db)
+	taxStore := tax.NewStore(s.db)
+	shippingStore := shipping.NewStore(s.db)
+	paymentStore := payment.NewStore(s.db)
+	webhookStore := webhook.NewStore(s.db)
+	creditNoteStore := refund.NewStore(s.db)
+	returnStore := returns.NewStore(s.db)
+	idempotencyStore := idempotency.NewStore(s.db)
+
+	// the payments are made with the gateway selected by the configuration
+	gateway, err := payment.NewGateway(config.Envs)
+	if err != nil {
+		return err
+	}
 
 	// this is used to create a handler of the user service.
 	// the user handler will help us handle routes related to the user.
 	// here we are injecting the userStore dependency to the handler.
 	// this will allow the handler to do everything with the user.
 	// from routing to handing user data
-	userHandler := user.NewHandler(userStore)
-	productHandler := product.NewHandler(productStore)
+	userHandler := user.NewHandler(userStore, cartStore, sessionStore, userStore)
+	productHandler := product.NewHandler(productStore, productStore)
+	orderHandler := order.NewHandler(orderStore, userStore)
+	cartHandler := cart.NewHandler(cartStore, productStore, productStore, promotionStore, shippingStore)
+	sessionHandler := session.NewHandler(sessionStore, userStore)
+	categoryHandler := category.NewHandler(categoryStore, productStore)
+	searchHandler := search.NewHandler(search.NewMemoryIndex(), searchStore, productStore)
+	currencyHandler := currency.NewHandler(currencyStore)
+	promotionHandler := promotion.NewHandler(promotionStore)
+	taxHandler := tax.NewHandler(taxStore)
+	shippingHandler := shipping.NewHandler(shippingStore)
+	paymentHandler := payment.NewHandler(paymentStore, orderStore, gateway)
+	refunder := refund.NewRefunder(creditNoteStore, orderStore, gateway)
+	webhookProcessor := webhook.NewProcessor(webhookStore, paymentStore, orderStore, refunder)
+	webhookHandler := webhook.NewHandler(webhookStore, webhookProcessor, gateway.Name())
+	refundHandler := refund.NewHandler(creditNoteStore, orderStore, refunder)
+	returnHandler := returns.NewHandler(returnStore, orderStore, refunder)
+	idempotencyHandler := idempotency.NewHandler(idempotencyStore)
 
 	// pass the subrouter to this function
 	// to delegeate the route management
 	// for user service
 	userHandler.RegisterRoutes(subrouter)
 	productHandler.RegisterRoutes(subrouter)
+	orderHandler.RegisterRoutes(subrouter)
+	cartHandler.RegisterRoutes(subrouter)
+	sessionHandler.RegisterRoutes(subrouter)
+	categoryHandler.RegisterRoutes(subrouter)
+	searchHandler.RegisterRoutes(subrouter)
+	currencyHandler.RegisterRoutes(subrouter)
+	promotionHandler.RegisterRoutes(subrouter)
+	taxHandler.RegisterRoutes(subrouter)
+	shippingHandler.RegisterRoutes(subrouter)
+	paymentHandler.RegisterRoutes(subrouter)
+	webhookHandler.RegisterRoutes(subrouter)
+	refundHandler.RegisterRoutes(subrouter)
+	returnHandler.RegisterRoutes(subrouter)
+
+	// replay the responses of the POST requests retried with the same
+	// Idempotency-Key, the keys are scoped to the authenticated user
+	subrouter.Use(idempotencyHandler.Middleware)
+
+	// price the responses in the currency selected by the
+	// currency query parameter or the X-Currency header
+	subrouter.Use(currencyHandler.Middleware)
+
+	// fill the in-process search index and keep it fresh,
+	// the server still starts if the first build fails
+	if err := searchHandler.Reindex(); err != nil {
+		log.Printf("Error building the search index, error: %+v", err)
+	}
+	go searchHandler.ReindexEvery(time.Second * time.Duration(config.Envs.SearchReindexIntervalInSeconds))
+
+	// apply the payment webhooks in the background, starting
+	// with the events left pending by the previous run
+	if err := webhookProcessor.Requeue(); err != nil {
+		log.Printf("Error requeuing the pending webhook events, error: %+v", err)
+	}
+	go webhookProcessor.Run()
+
+	// remove the expired idempotency keys
+	go idempotencyHandler.PurgeEvery(time.Hour)
 
 	log.Println("Listening on", s.addr)
 
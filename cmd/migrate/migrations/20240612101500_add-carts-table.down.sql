@@ -0,0 +1 @@
+DROP TABLE IF EXISTS `carts`;
\ No newline at end of file
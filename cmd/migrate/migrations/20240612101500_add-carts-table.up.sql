@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS `carts` (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    `userId` INT UNSIGNED NULL UNIQUE,
+    `token` CHAR(64) NOT NULL UNIQUE,
+    `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `updatedAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (`userId`) REFERENCES users(`id`)
+);
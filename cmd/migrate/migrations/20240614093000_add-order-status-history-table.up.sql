@@ -0,0 +1,18 @@
+ALTER TABLE `orders` MODIFY `status` ENUM('pending', 'completed', 'paid', 'fulfilled', 'shipped', 'delivered', 'cancelled', 'refunded') NOT NULL DEFAULT 'pending';
+
+UPDATE `orders` SET `status` = 'delivered' WHERE `status` = 'completed';
+
+ALTER TABLE `orders` MODIFY `status` ENUM('pending', 'paid', 'fulfilled', 'shipped', 'delivered', 'cancelled', 'refunded') NOT NULL DEFAULT 'pending';
+
+CREATE TABLE IF NOT EXISTS `order_status_history` (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    `orderId` INT UNSIGNED NOT NULL,
+    `fromStatus` VARCHAR(32) NULL,
+    `toStatus` VARCHAR(32) NOT NULL,
+    `changedBy` INT UNSIGNED NULL,
+    `note` TEXT NOT NULL,
+    `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (`orderId`) REFERENCES orders(`id`),
+    FOREIGN KEY (`changedBy`) REFERENCES users(`id`)
+);
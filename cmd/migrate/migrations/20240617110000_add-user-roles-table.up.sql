@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS `user_roles` (
+    `userId` INT UNSIGNED NOT NULL,
+    `role` ENUM('admin', 'customer') NOT NULL,
+    `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (`userId`, `role`),
+    FOREIGN KEY (`userId`) REFERENCES users(`id`) ON DELETE CASCADE
+);
+
+INSERT IGNORE INTO `user_roles` (`userId`, `role`) SELECT `id`, 'customer' FROM `users`;
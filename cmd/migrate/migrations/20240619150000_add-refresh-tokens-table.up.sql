@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS `refresh_tokens` (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    `userId` INT UNSIGNED NOT NULL,
+    `familyId` CHAR(32) NOT NULL,
+    `tokenHash` CHAR(64) NOT NULL UNIQUE,
+    `expiresAt` TIMESTAMP NOT NULL,
+    `revokedAt` TIMESTAMP NULL DEFAULT NULL,
+    `replacedBy` INT UNSIGNED NULL DEFAULT NULL,
+    `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    INDEX `refresh_tokens_family` (`familyId`),
+    FOREIGN KEY (`userId`) REFERENCES users(`id`) ON DELETE CASCADE
+);
@@ -0,0 +1 @@
+ALTER TABLE `products` DROP INDEX `products_deleted_at`, DROP COLUMN `deletedAt`;
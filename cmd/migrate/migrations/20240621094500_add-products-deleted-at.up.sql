@@ -0,0 +1 @@
+ALTER TABLE `products` ADD COLUMN `deletedAt` TIMESTAMP NULL DEFAULT NULL, ADD INDEX `products_deleted_at` (`deletedAt`);
@@ -0,0 +1 @@
+ALTER TABLE `products` DROP INDEX `products_price`, DROP INDEX `products_name`, DROP INDEX `products_create_at`;
@@ -0,0 +1 @@
+ALTER TABLE `products` ADD INDEX `products_price` (`price`, `id`), ADD INDEX `products_name` (`name`, `id`), ADD INDEX `products_create_at` (`createAt`, `id`);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS `categories` (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    `name` VARCHAR(255) NOT NULL,
+    `parentId` INT UNSIGNED NULL DEFAULT NULL,
+    `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE KEY `category_parent_name` (`parentId`, `name`),
+    FOREIGN KEY (`parentId`) REFERENCES categories(`id`)
+);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS `product_options` (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    `productId` INT UNSIGNED NOT NULL,
+    `name` VARCHAR(64) NOT NULL,
+    `values` JSON NOT NULL,
+    `position` INT UNSIGNED NOT NULL,
+
+    UNIQUE KEY `product_option_name` (`productId`, `name`),
+    FOREIGN KEY (`productId`) REFERENCES products(`id`) ON DELETE CASCADE
+);
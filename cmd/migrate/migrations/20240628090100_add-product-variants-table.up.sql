@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS `product_variants` (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    `productId` INT UNSIGNED NOT NULL,
+    `sku` VARCHAR(64) NOT NULL,
+    `options` JSON NOT NULL,
+    `price` DECIMAL(10, 2) NULL DEFAULT NULL,
+    `quantity` INT UNSIGNED NOT NULL,
+    `image` VARCHAR(255) NOT NULL DEFAULT '',
+    `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `deletedAt` TIMESTAMP NULL DEFAULT NULL,
+
+    UNIQUE KEY `product_variant_sku` (`sku`),
+    KEY `product_variants_product` (`productId`, `deletedAt`),
+    FOREIGN KEY (`productId`) REFERENCES products(`id`)
+);
@@ -0,0 +1,8 @@
+ALTER TABLE `order_items` DROP FOREIGN KEY `order_items_variant`, DROP COLUMN `sku`, DROP COLUMN `variantId`;
+
+DELETE FROM `cart_items` WHERE `sku` <> '';
+
+ALTER TABLE `cart_items`
+    ADD UNIQUE KEY `cart_product` (`cartId`, `productId`),
+    DROP INDEX `cart_product_sku`,
+    DROP COLUMN `sku`;
@@ -0,0 +1,9 @@
+ALTER TABLE `cart_items`
+    ADD COLUMN `sku` VARCHAR(64) NOT NULL DEFAULT '' AFTER `productId`,
+    ADD UNIQUE KEY `cart_product_sku` (`cartId`, `productId`, `sku`),
+    DROP INDEX `cart_product`;
+
+ALTER TABLE `order_items`
+    ADD COLUMN `variantId` INT UNSIGNED NULL DEFAULT NULL AFTER `productId`,
+    ADD COLUMN `sku` VARCHAR(64) NULL DEFAULT NULL AFTER `variantId`,
+    ADD CONSTRAINT `order_items_variant` FOREIGN KEY (`variantId`) REFERENCES product_variants(`id`);
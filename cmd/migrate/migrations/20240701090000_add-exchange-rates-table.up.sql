@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS `exchange_rates` (
+    `currency` CHAR(3) NOT NULL PRIMARY KEY,
+    `rate` DECIMAL(18, 8) NOT NULL,
+    `updatedAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
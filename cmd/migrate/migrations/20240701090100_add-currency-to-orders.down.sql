@@ -0,0 +1,6 @@
+ALTER TABLE `order_items` MODIFY `price` DECIMAL(10, 2) NOT NULL;
+
+ALTER TABLE `orders`
+    DROP COLUMN `exchangeRate`,
+    DROP COLUMN `currency`,
+    MODIFY `total` DECIMAL(10, 2) NOT NULL;
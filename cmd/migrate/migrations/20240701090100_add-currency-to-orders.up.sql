@@ -0,0 +1,6 @@
+ALTER TABLE `orders`
+    MODIFY `total` DECIMAL(13, 3) NOT NULL,
+    ADD COLUMN `currency` CHAR(3) NOT NULL DEFAULT 'USD' AFTER `total`,
+    ADD COLUMN `exchangeRate` DECIMAL(18, 8) NOT NULL DEFAULT 1 AFTER `currency`;
+
+ALTER TABLE `order_items` MODIFY `price` DECIMAL(13, 3) NOT NULL;
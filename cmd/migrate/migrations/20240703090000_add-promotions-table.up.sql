@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS `promotions` (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    `code` VARCHAR(64) NOT NULL UNIQUE,
+    `description` VARCHAR(255) NOT NULL DEFAULT '',
+    `type` ENUM('percentage', 'fixed', 'free_shipping', 'buy_x_get_y') NOT NULL,
+    `percent` DECIMAL(5, 2) NULL,
+    `amount` DECIMAL(10, 2) NULL,
+    `productId` INT UNSIGNED NULL,
+    `buyQuantity` INT UNSIGNED NOT NULL DEFAULT 0,
+    `getQuantity` INT UNSIGNED NOT NULL DEFAULT 0,
+    `minOrderValue` DECIMAL(10, 2) NULL,
+    `usageLimit` INT UNSIGNED NULL,
+    `perUserLimit` INT UNSIGNED NULL,
+    `usageCount` INT UNSIGNED NOT NULL DEFAULT 0,
+    `stackable` BOOLEAN NOT NULL DEFAULT FALSE,
+    `active` BOOLEAN NOT NULL DEFAULT TRUE,
+    `startsAt` TIMESTAMP NULL DEFAULT NULL,
+    `endsAt` TIMESTAMP NULL DEFAULT NULL,
+    `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (`productId`) REFERENCES products(`id`)
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS `order_promotions`;
+
+ALTER TABLE `orders` DROP COLUMN `discount`;
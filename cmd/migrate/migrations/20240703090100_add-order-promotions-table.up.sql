@@ -0,0 +1,14 @@
+ALTER TABLE `orders` ADD COLUMN `discount` DECIMAL(13, 3) NOT NULL DEFAULT 0 AFTER `total`;
+
+CREATE TABLE IF NOT EXISTS `order_promotions` (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    `orderId` INT UNSIGNED NOT NULL,
+    `promotionId` INT UNSIGNED NOT NULL,
+    `code` VARCHAR(64) NOT NULL,
+    `type` VARCHAR(32) NOT NULL,
+    `amount` DECIMAL(13, 3) NOT NULL,
+
+    KEY `order_promotions_promotion` (`promotionId`),
+    FOREIGN KEY (`orderId`) REFERENCES orders(`id`),
+    FOREIGN KEY (`promotionId`) REFERENCES promotions(`id`)
+);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS `cart_coupons` (
+    `cartId` INT UNSIGNED NOT NULL,
+    `code` VARCHAR(64) NOT NULL,
+    `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (`cartId`, `code`),
+    FOREIGN KEY (`cartId`) REFERENCES carts(`id`) ON DELETE CASCADE
+);
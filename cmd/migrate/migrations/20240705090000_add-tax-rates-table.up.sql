@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS `tax_rates` (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    `country` CHAR(2) NOT NULL,
+    `region` VARCHAR(64) NOT NULL DEFAULT '',
+    `taxClass` VARCHAR(32) NOT NULL DEFAULT 'standard',
+    `name` VARCHAR(64) NOT NULL,
+    `rate` DECIMAL(7, 4) NOT NULL,
+    `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE KEY `tax_rates_destination_class` (`country`, `region`, `taxClass`)
+);
@@ -0,0 +1,16 @@
+ALTER TABLE `order_items`
+    DROP COLUMN `tax`,
+    DROP COLUMN `taxRate`,
+    DROP COLUMN `discount`,
+    DROP COLUMN `taxClass`;
+
+ALTER TABLE `orders`
+    DROP COLUMN `region`,
+    DROP COLUMN `country`,
+    DROP COLUMN `taxExempt`,
+    DROP COLUMN `taxInclusive`,
+    DROP COLUMN `tax`;
+
+ALTER TABLE `users` DROP COLUMN `taxExempt`;
+
+ALTER TABLE `products` DROP COLUMN `taxClass`;
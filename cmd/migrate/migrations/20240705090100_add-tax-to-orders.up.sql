@@ -0,0 +1,16 @@
+ALTER TABLE `products` ADD COLUMN `taxClass` VARCHAR(32) NOT NULL DEFAULT 'standard' AFTER `quantity`;
+
+ALTER TABLE `users` ADD COLUMN `taxExempt` BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE `orders`
+    ADD COLUMN `tax` DECIMAL(13, 3) NOT NULL DEFAULT 0 AFTER `discount`,
+    ADD COLUMN `taxInclusive` BOOLEAN NOT NULL DEFAULT FALSE AFTER `tax`,
+    ADD COLUMN `taxExempt` BOOLEAN NOT NULL DEFAULT FALSE AFTER `taxInclusive`,
+    ADD COLUMN `country` CHAR(2) NOT NULL DEFAULT '' AFTER `address`,
+    ADD COLUMN `region` VARCHAR(64) NOT NULL DEFAULT '' AFTER `country`;
+
+ALTER TABLE `order_items`
+    ADD COLUMN `taxClass` VARCHAR(32) NOT NULL DEFAULT 'standard',
+    ADD COLUMN `discount` DECIMAL(13, 3) NOT NULL DEFAULT 0,
+    ADD COLUMN `taxRate` DECIMAL(7, 4) NOT NULL DEFAULT 0,
+    ADD COLUMN `tax` DECIMAL(13, 3) NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS `shipping_methods`;
+DROP TABLE IF EXISTS `shipping_zone_regions`;
+DROP TABLE IF EXISTS `shipping_zones`;
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS `shipping_zones` (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    `name` VARCHAR(64) NOT NULL,
+    `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS `shipping_zone_regions` (
+    `zoneId` INT UNSIGNED NOT NULL,
+    `country` CHAR(2) NOT NULL,
+    `region` VARCHAR(64) NOT NULL DEFAULT '',
+
+    PRIMARY KEY (`country`, `region`),
+    KEY `shipping_zone_regions_zone` (`zoneId`),
+    FOREIGN KEY (`zoneId`) REFERENCES shipping_zones(`id`) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS `shipping_methods` (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    `zoneId` INT UNSIGNED NOT NULL,
+    `name` VARCHAR(64) NOT NULL,
+    `type` ENUM('flat', 'weight', 'price') NOT NULL,
+    `amount` DECIMAL(10, 2) NULL,
+    `tiers` JSON NULL,
+    `freeOver` DECIMAL(10, 2) NULL,
+    `active` BOOLEAN NOT NULL DEFAULT TRUE,
+    `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (`zoneId`) REFERENCES shipping_zones(`id`) ON DELETE CASCADE
+);
@@ -0,0 +1,10 @@
+ALTER TABLE `orders`
+    DROP COLUMN `shippingMethod`,
+    DROP COLUMN `shippingMethodId`,
+    DROP COLUMN `shipping`;
+
+ALTER TABLE `products`
+    DROP COLUMN `height`,
+    DROP COLUMN `width`,
+    DROP COLUMN `length`,
+    DROP COLUMN `weight`;
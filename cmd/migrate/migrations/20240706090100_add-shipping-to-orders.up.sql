@@ -0,0 +1,10 @@
+ALTER TABLE `products`
+    ADD COLUMN `weight` INT UNSIGNED NOT NULL DEFAULT 0 AFTER `taxClass`,
+    ADD COLUMN `length` INT UNSIGNED NOT NULL DEFAULT 0 AFTER `weight`,
+    ADD COLUMN `width` INT UNSIGNED NOT NULL DEFAULT 0 AFTER `length`,
+    ADD COLUMN `height` INT UNSIGNED NOT NULL DEFAULT 0 AFTER `width`;
+
+ALTER TABLE `orders`
+    ADD COLUMN `shipping` DECIMAL(13, 3) NOT NULL DEFAULT 0 AFTER `tax`,
+    ADD COLUMN `shippingMethodId` INT UNSIGNED NULL AFTER `shipping`,
+    ADD COLUMN `shippingMethod` VARCHAR(64) NOT NULL DEFAULT '' AFTER `shippingMethodId`;
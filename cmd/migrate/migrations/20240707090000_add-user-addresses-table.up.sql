@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS `user_addresses` (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    `userId` INT UNSIGNED NOT NULL,
+    `name` VARCHAR(128) NOT NULL,
+    `line1` VARCHAR(255) NOT NULL,
+    `line2` VARCHAR(255) NOT NULL DEFAULT '',
+    `city` VARCHAR(128) NOT NULL,
+    `region` VARCHAR(64) NOT NULL DEFAULT '',
+    `postalCode` VARCHAR(32) NOT NULL DEFAULT '',
+    `country` CHAR(2) NOT NULL,
+    `phone` VARCHAR(32) NOT NULL DEFAULT '',
+    `isDefaultShipping` BOOLEAN NOT NULL DEFAULT FALSE,
+    `isDefaultBilling` BOOLEAN NOT NULL DEFAULT FALSE,
+    `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    KEY `user_addresses_user` (`userId`),
+    FOREIGN KEY (`userId`) REFERENCES users(`id`) ON DELETE CASCADE
+);
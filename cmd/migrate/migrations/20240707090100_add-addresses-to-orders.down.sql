@@ -0,0 +1,3 @@
+ALTER TABLE `orders`
+    DROP COLUMN `billingAddress`,
+    DROP COLUMN `shippingAddress`;
@@ -0,0 +1,3 @@
+ALTER TABLE `orders`
+    ADD COLUMN `shippingAddress` JSON NULL AFTER `region`,
+    ADD COLUMN `billingAddress` JSON NULL AFTER `shippingAddress`;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS `payments` (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    `orderId` INT UNSIGNED NOT NULL,
+    `gateway` VARCHAR(32) NOT NULL,
+    `reference` VARCHAR(128) NOT NULL DEFAULT '',
+    `status` ENUM('requires_action', 'authorized', 'captured', 'partially_refunded', 'refunded', 'voided', 'declined') NOT NULL,
+    `amount` DECIMAL(13,3) NOT NULL,
+    `capturedAmount` DECIMAL(13,3) NOT NULL DEFAULT 0,
+    `refundedAmount` DECIMAL(13,3) NOT NULL DEFAULT 0,
+    `currency` CHAR(3) NOT NULL,
+    `actionUrl` VARCHAR(255) NOT NULL DEFAULT '',
+    `declineReason` VARCHAR(255) NOT NULL DEFAULT '',
+    `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `updatedAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    KEY `payments_order` (`orderId`),
+    KEY `payments_reference` (`gateway`, `reference`),
+    FOREIGN KEY (`orderId`) REFERENCES orders(`id`)
+);
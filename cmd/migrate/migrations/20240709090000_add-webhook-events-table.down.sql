@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS `webhook_events`;
+
+UPDATE `orders` SET `status` = 'pending' WHERE `status` = 'payment_failed';
+
+ALTER TABLE `orders` MODIFY `status` ENUM('pending', 'paid', 'fulfilled', 'shipped', 'delivered', 'cancelled', 'refunded') NOT NULL DEFAULT 'pending';
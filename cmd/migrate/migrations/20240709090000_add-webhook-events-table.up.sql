@@ -0,0 +1,17 @@
+ALTER TABLE `orders` MODIFY `status` ENUM('pending', 'payment_failed', 'paid', 'fulfilled', 'shipped', 'delivered', 'cancelled', 'refunded') NOT NULL DEFAULT 'pending';
+
+CREATE TABLE IF NOT EXISTS `webhook_events` (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    `provider` VARCHAR(32) NOT NULL,
+    `eventId` VARCHAR(128) NOT NULL,
+    `type` VARCHAR(64) NOT NULL,
+    `payload` JSON NOT NULL,
+    `status` ENUM('pending', 'processed', 'failed') NOT NULL DEFAULT 'pending',
+    `attempts` INT UNSIGNED NOT NULL DEFAULT 0,
+    `lastError` TEXT NULL,
+    `receivedAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `processedAt` TIMESTAMP NULL,
+
+    UNIQUE KEY `webhook_events_event` (`provider`, `eventId`),
+    KEY `webhook_events_status` (`status`)
+);
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS `credit_note_numbers`;
+DROP TABLE IF EXISTS `credit_note_lines`;
+DROP TABLE IF EXISTS `credit_notes`;
+
+ALTER TABLE `order_items` DROP COLUMN `refundedQuantity`;
+
+ALTER TABLE `orders` DROP COLUMN `refunded`;
@@ -0,0 +1,45 @@
+ALTER TABLE `orders`
+    ADD COLUMN `refunded` DECIMAL(13,3) NOT NULL DEFAULT 0 AFTER `shipping`;
+
+ALTER TABLE `order_items`
+    ADD COLUMN `refundedQuantity` INT UNSIGNED NOT NULL DEFAULT 0 AFTER `quantity`;
+
+CREATE TABLE IF NOT EXISTS `credit_notes` (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    `number` VARCHAR(32) NOT NULL,
+    `orderId` INT UNSIGNED NOT NULL,
+    `paymentId` INT UNSIGNED NULL,
+    `shipping` DECIMAL(13,3) NOT NULL DEFAULT 0,
+    `tax` DECIMAL(13,3) NOT NULL DEFAULT 0,
+    `total` DECIMAL(13,3) NOT NULL,
+    `currency` CHAR(3) NOT NULL,
+    `restock` BOOLEAN NOT NULL DEFAULT FALSE,
+    `reason` VARCHAR(255) NOT NULL DEFAULT '',
+    `createdBy` INT UNSIGNED NULL,
+    `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE KEY `credit_notes_number` (`number`),
+    KEY `credit_notes_order` (`orderId`),
+    FOREIGN KEY (`orderId`) REFERENCES orders(`id`),
+    FOREIGN KEY (`paymentId`) REFERENCES payments(`id`),
+    FOREIGN KEY (`createdBy`) REFERENCES users(`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `credit_note_lines` (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    `creditNoteId` INT UNSIGNED NOT NULL,
+    `orderItemId` INT UNSIGNED NOT NULL,
+    `quantity` INT UNSIGNED NOT NULL,
+    `amount` DECIMAL(13,3) NOT NULL,
+    `tax` DECIMAL(13,3) NOT NULL DEFAULT 0,
+
+    FOREIGN KEY (`creditNoteId`) REFERENCES credit_notes(`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`orderItemId`) REFERENCES order_items(`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `credit_note_numbers` (
+    `id` TINYINT UNSIGNED NOT NULL PRIMARY KEY,
+    `last` INT UNSIGNED NOT NULL
+);
+
+INSERT INTO `credit_note_numbers` (`id`, `last`) VALUES (1, 0);
@@ -0,0 +1,42 @@
+CREATE TABLE IF NOT EXISTS `returns` (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    `orderId` INT UNSIGNED NOT NULL,
+    `userId` INT UNSIGNED NOT NULL,
+    `status` ENUM('requested', 'approved', 'rejected', 'received', 'refunded', 'cancelled') NOT NULL DEFAULT 'requested',
+    `note` VARCHAR(1000) NOT NULL DEFAULT '',
+    `creditNoteId` INT UNSIGNED NULL,
+    `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `updatedAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    KEY `returns_order` (`orderId`),
+    KEY `returns_user` (`userId`),
+    KEY `returns_status` (`status`),
+    FOREIGN KEY (`orderId`) REFERENCES orders(`id`),
+    FOREIGN KEY (`userId`) REFERENCES users(`id`),
+    FOREIGN KEY (`creditNoteId`) REFERENCES credit_notes(`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `return_items` (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    `returnId` INT UNSIGNED NOT NULL,
+    `orderItemId` INT UNSIGNED NOT NULL,
+    `quantity` INT UNSIGNED NOT NULL,
+    `reason` ENUM('damaged', 'defective', 'wrong_item', 'not_as_described', 'no_longer_needed', 'other') NOT NULL,
+    `resolution` ENUM('restock', 'write_off') NULL,
+
+    FOREIGN KEY (`returnId`) REFERENCES returns(`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`orderItemId`) REFERENCES order_items(`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `return_status_history` (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    `returnId` INT UNSIGNED NOT NULL,
+    `fromStatus` VARCHAR(32) NULL,
+    `toStatus` VARCHAR(32) NOT NULL,
+    `changedBy` INT UNSIGNED NULL,
+    `note` TEXT NOT NULL,
+    `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (`returnId`) REFERENCES returns(`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`changedBy`) REFERENCES users(`id`)
+);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS `idempotency_keys` (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    `userId` INT UNSIGNED NOT NULL DEFAULT 0,
+    `idempotencyKey` VARCHAR(255) NOT NULL,
+    `fingerprint` CHAR(64) NOT NULL,
+    `responseStatus` SMALLINT UNSIGNED NOT NULL DEFAULT 0,
+    `contentType` VARCHAR(255) NOT NULL DEFAULT '',
+    `responseBody` MEDIUMBLOB NULL,
+    `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `expiresAt` TIMESTAMP NOT NULL,
+
+    UNIQUE KEY `idempotency_keys_key` (`userId`, `idempotencyKey`),
+    KEY `idempotency_keys_expires` (`expiresAt`)
+);
@@ -0,0 +1 @@
+ALTER TABLE `credit_notes` DROP FOREIGN KEY `credit_notes_return_fk`, DROP INDEX `credit_notes_return`, DROP COLUMN `returnId`;
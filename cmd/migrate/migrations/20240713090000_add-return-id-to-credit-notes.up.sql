@@ -0,0 +1,8 @@
+ALTER TABLE `credit_notes`
+    ADD COLUMN `returnId` INT UNSIGNED NULL DEFAULT NULL AFTER `paymentId`,
+    ADD UNIQUE KEY `credit_notes_return` (`returnId`),
+    ADD CONSTRAINT `credit_notes_return_fk` FOREIGN KEY (`returnId`) REFERENCES returns(`id`);
+
+UPDATE `credit_notes` n
+    JOIN `returns` r ON r.`creditNoteId` = n.`id`
+    SET n.`returnId` = r.`id`;
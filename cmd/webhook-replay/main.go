@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/akshtrikha/golang-ecomm/config"
+	"github.com/akshtrikha/golang-ecomm/db"
+	"github.com/akshtrikha/golang-ecomm/services/webhook"
+	"github.com/akshtrikha/golang-ecomm/types"
+	"github.com/go-sql-driver/mysql"
+)
+
+// webhook-replay sends the stored webhook events to the webhook endpoint again,
+// signed with the configured secret as the payment provider would.
+//
+//	go run cmd/webhook-replay/main.go -id 12
+//	go run cmd/webhook-replay/main.go -status failed
+//	go run cmd/webhook-replay/main.go -id 12 -force
+func main() {
+	id := flag.Int("id", 0, "the id of the event to replay")
+	status := flag.String("status", string(types.WebhookEventFailed), "replay the events in this status when no id is given")
+	force := flag.Bool("force", false, "move the events back to pending so the processed ones are applied again")
+	url := flag.String("url", "http://localhost:9000/api/v1/webhooks/payments/"+config.Envs.PaymentGateway, "the webhook endpoint")
+	flag.Parse()
+
+	if config.Envs.PaymentWebhookSecret == "" {
+		log.Fatal("PAYMENT_WEBHOOK_SECRET is required to sign the webhook events")
+	}
+
+	db, err := db.NewMySQLStorage(mysql.Config{
+		User:                 config.Envs.DBUser,
+		Passwd:               config.Envs.DBPassword,
+		Addr:                 config.Envs.DBAddress,
+		DBName:               config.Envs.DBName,
+		Net:                  "tcp",
+		AllowNativePasswords: true,
+		ParseTime:            true,
+	})
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	store := webhook.NewStore(db)
+
+	var events []types.WebhookEvent
+	if *id != 0 {
+		e, err := store.GetWebhookEventByID(*id)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		events = append(events, *e)
+	} else {
+		events, err = store.GetWebhookEventsByStatus(types.WebhookEventStatus(*status))
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	log.Printf("Replaying %d webhook events to %s", len(events), *url)
+
+	client := &http.Client{Timeout: time.Second * 10}
+	for _, e := range events {
+		if *force {
+			if err := store.UpdateWebhookEventStatus(e.ID, types.WebhookEventPending, ""); err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		if err := send(client, *url, e); err != nil {
+			log.Printf("Error replaying the webhook event %v, error: %+v", e.ID, err)
+		}
+	}
+}
+
+// send posts the payload of the event signed at the current time
+func send(client *http.Client, url string, e types.WebhookEvent) error {
+	body := []byte(e.Payload)
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhook.SignatureHeader, webhook.Sign(config.Envs.PaymentWebhookSecret, time.Now(), body))
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	response, _ := io.ReadAll(res.Body)
+	log.Printf("Webhook event %v (%s %s): %s %s", e.ID, e.EventID, e.Type, res.Status, bytes.TrimSpace(response))
+
+	return nil
+}
@@ -19,6 +19,34 @@ type Config struct {
 	DBName                 string
 	JWTSecret              string
 	JWTExpirationInSeconds int64
+	// refresh tokens outlive the short lived access tokens
+	RefreshTokenExpirationInSeconds int64
+	// HS256, RS256 or EdDSA, the asymmetric methods sign with the PEM private key
+	JWTSigningMethod  string
+	JWTPrivateKeyPath string
+	JWTKeyID          string
+	// comma separated kid=path pairs of PEM public keys still accepted during a rotation
+	JWTVerificationKeys string
+	JWTIssuer           string
+	JWTAudience         string
+	// the in-process search index is rebuilt from the database on this interval
+	SearchReindexIntervalInSeconds int64
+	// the catalog prices include the taxes, they are added to the prices otherwise
+	PricesIncludeTax bool
+	// the gateway the payments are made with, only the fake gateway is available
+	PaymentGateway string
+	// succeed, decline or action, what the fake gateway does with unknown cards
+	FakeGatewayMode string
+	// capture the payments as soon as they are authorized
+	PaymentAutoCapture bool
+	// the secret the payment provider signs its webhooks with
+	PaymentWebhookSecret string
+	// how old or early the timestamp of a signed webhook can be
+	WebhookToleranceInSeconds int64
+	// how long after their delivery the items of an order can be returned
+	ReturnWindowInDays int64
+	// how long the responses of the requests made with an idempotency key are replayed
+	IdempotencyKeyTTLInSeconds int64
 }
 
 // Envs global variable to hold Environment variables
@@ -28,14 +56,30 @@ func initConfig() Config {
 	godotenv.Load()
 
 	return Config{
-		PublicHost:             getEnv("PUBLIC_HOST", "http://localhost"),
-		Port:                   getEnv("PORT", "5000"),
-		DBUser:                 getEnv("DBUser", "user"),
-		DBPassword:             getEnv("DBPassword", "password"),
-		DBAddress:              fmt.Sprintf("%s:%s", getEnv("DB_HOST", "127.0.0.1"), getEnv("DB_PORT", "3306")),
-		DBName:                 getEnv("DBName", "mysql"),
-		JWTSecret: getEnv("JWT_SECRET", "your-secret-key"),
-		JWTExpirationInSeconds: getEnvInt64("JWT_EXP", 3600*24*7),
+		PublicHost:                      getEnv("PUBLIC_HOST", "http://localhost"),
+		Port:                            getEnv("PORT", "5000"),
+		DBUser:                          getEnv("DBUser", "user"),
+		DBPassword:                      getEnv("DBPassword", "password"),
+		DBAddress:                       fmt.Sprintf("%s:%s", getEnv("DB_HOST", "127.0.0.1"), getEnv("DB_PORT", "3306")),
+		DBName:                          getEnv("DBName", "mysql"),
+		JWTSecret:                       getEnv("JWT_SECRET", ""),
+		JWTExpirationInSeconds:          getEnvInt64("JWT_EXP", 60*15),
+		RefreshTokenExpirationInSeconds: getEnvInt64("REFRESH_TOKEN_EXP", 3600*24*30),
+		JWTSigningMethod:                getEnv("JWT_SIGNING_METHOD", "HS256"),
+		JWTPrivateKeyPath:               getEnv("JWT_PRIVATE_KEY", ""),
+		JWTKeyID:                        getEnv("JWT_KEY_ID", ""),
+		JWTVerificationKeys:             getEnv("JWT_VERIFICATION_KEYS", ""),
+		JWTIssuer:                       getEnv("JWT_ISSUER", "golang-ecomm"),
+		JWTAudience:                     getEnv("JWT_AUDIENCE", "golang-ecomm"),
+		SearchReindexIntervalInSeconds:  getEnvInt64("SEARCH_REINDEX_INTERVAL", 60*5),
+		PricesIncludeTax:                getEnvBool("PRICES_INCLUDE_TAX", false),
+		PaymentGateway:                  getEnv("PAYMENT_GATEWAY", "fake"),
+		FakeGatewayMode:                 getEnv("FAKE_GATEWAY_MODE", "succeed"),
+		PaymentAutoCapture:              getEnvBool("PAYMENT_AUTO_CAPTURE", true),
+		PaymentWebhookSecret:            getEnv("PAYMENT_WEBHOOK_SECRET", ""),
+		WebhookToleranceInSeconds:       getEnvInt64("WEBHOOK_TOLERANCE", 60*5),
+		ReturnWindowInDays:              getEnvInt64("RETURN_WINDOW_DAYS", 30),
+		IdempotencyKeyTTLInSeconds:      getEnvInt64("IDEMPOTENCY_KEY_TTL", 3600*24),
 	}
 }
 
@@ -59,3 +103,16 @@ func getEnvInt64(key string, fallback int64) int64 {
 
 	return fallback
 }
+
+func getEnvBool(key string, fallback bool) bool {
+	if value, ok := os.LookupEnv(key); ok {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fallback
+		}
+
+		return b
+	}
+
+	return fallback
+}
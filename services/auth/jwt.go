@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"time"
@@ -9,64 +10,110 @@ import (
 	"github.com/golang-jwt/jwt"
 )
 
-// GenerateJWT function generates and returns the jwt token for the key provided
-func GenerateJWT(secret string, userID int) (string, error) {
-    expiration := time.Second * time.Duration(config.Envs.JWTExpirationInSeconds)
+// GenerateJWT function generates and returns the jwt token for the user
+// signed with the current signing key of the key set.
+// The token holds the registered sub, exp, iat, iss, aud and jti claims
+// and the roles of the user.
+func GenerateJWT(userID int, roles []string) (string, error) {
+	return Keys().GenerateJWT(userID, roles)
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-        "userID": strconv.Itoa(userID),
-        "expiredAt": time.Now().Add(expiration).Unix(),
-    })
+// GenerateJWT function generates and returns the jwt token for the user
+// signed with the signing key of the key set
+func (ks *KeySet) GenerateJWT(userID int, roles []string) (string, error) {
+	now := time.Now()
+	expiration := time.Second * time.Duration(config.Envs.JWTExpirationInSeconds)
 
-	tokenString, err := token.SignedString([]byte(secret))
+	jti, err := randomHex(16)
 	if err != nil {
 		return "", err
 	}
 
-	return tokenString, nil
+	token := jwt.NewWithClaims(ks.Signing.Method, jwt.MapClaims{
+		"sub":   strconv.Itoa(userID),
+		"exp":   now.Add(expiration).Unix(),
+		"iat":   now.Unix(),
+		"iss":   config.Envs.JWTIssuer,
+		"aud":   config.Envs.JWTAudience,
+		"jti":   jti,
+		"roles": roles,
+	})
+	token.Header["kid"] = ks.Signing.ID
+
+	return token.SignedString(ks.Signing.Key)
 }
 
 // VerifyToken Function
-func VerifyToken(tokenString string, secretKey string) (*jwt.Token, error) {
-    token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-        // Check the signing method to make sure it's what you expect:
-        if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-            return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-        }
-
-        // Return your secret key:
-        return []byte(secretKey), nil
-    })
-    return token, err
+func VerifyToken(tokenString string) (*jwt.Token, error) {
+	return Keys().VerifyToken(tokenString)
+}
+
+// VerifyToken Function verifies the signature of the token with the
+// verification key matching its kid header
+func (ks *KeySet) VerifyToken(tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+
+		key, ok := ks.VerificationKey(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown key id: %v", kid)
+		}
+
+		// Check the signing method to make sure it's the one of the key
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+
+		return key.Key, nil
+	})
 }
 
 // VerifyTokenAndClaims function
-func VerifyTokenAndClaims(tokenString string, secretKey string) (jwt.MapClaims, error) {
-    token, err := VerifyToken(tokenString, secretKey)
-    if err != nil {
-        return nil, err
-    }
-
-    // Check if token is valid
-    if !token.Valid {
-        return nil, fmt.Errorf("invalid token")
-    }
-
-    claims, ok := token.Claims.(jwt.MapClaims)
-    if !ok {
-        return nil, fmt.Errorf("invalid claims format")
-    }
-
-    // Validate expiration time
-    exp, ok := claims["expiredAt"].(float64)
-    if !ok {
-        return nil, fmt.Errorf("missing expiration time claim")
-    }
-    if int64(exp) < time.Now().Unix() {
-        return nil, fmt.Errorf("token expired")
-    }
-
-    // ... other custom claim validations ...
-
-    return claims, nil
+func VerifyTokenAndClaims(tokenString string) (jwt.MapClaims, error) {
+	return Keys().VerifyTokenAndClaims(tokenString)
+}
+
+// VerifyTokenAndClaims function verifies the token and its registered claims
+// exp, iat and nbf are validated while parsing the token
+func (ks *KeySet) VerifyTokenAndClaims(tokenString string) (jwt.MapClaims, error) {
+	token, err := ks.VerifyToken(tokenString)
+	var validationErr *jwt.ValidationError
+	if errors.As(err, &validationErr) && validationErr.Errors&jwt.ValidationErrorExpired != 0 {
+		return nil, fmt.Errorf("token expired")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if token is valid
+	if !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, fmt.Errorf("invalid claims format")
+	}
+
+	if _, ok := claims["exp"]; !ok {
+		return nil, fmt.Errorf("missing expiration time claim")
+	}
+
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, fmt.Errorf("token expired")
+	}
+
+	if !claims.VerifyIssuer(config.Envs.JWTIssuer, true) {
+		return nil, fmt.Errorf("invalid issuer")
+	}
+
+	if !claims.VerifyAudience(config.Envs.JWTAudience, true) {
+		return nil, fmt.Errorf("invalid audience")
+	}
+
+	if _, ok := claims["sub"].(string); !ok {
+		return nil, fmt.Errorf("missing subject claim")
+	}
+
+	return claims, nil
 }
@@ -0,0 +1,253 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/akshtrikha/golang-ecomm/config"
+	"github.com/akshtrikha/golang-ecomm/utils"
+	"github.com/golang-jwt/jwt"
+)
+
+// Key holds a key used to sign or verify tokens along with its key id
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+	// Key is the secret for HS256, the private key for signing
+	// and the public key for verifying with RS256 and EdDSA
+	Key interface{}
+}
+
+// KeySet holds the key tokens are signed with and every key
+// tokens are verified with. Keeping the previous public keys in the
+// set lets tokens signed before a rotation be verified until they expire.
+type KeySet struct {
+	Signing      Key
+	Verification map[string]Key
+}
+
+var (
+	keySet     *KeySet
+	keySetOnce sync.Once
+)
+
+// testSecret signs the tokens of the tests when JWT_SECRET is not set
+const testSecret = "test-secret"
+
+// Keys returns the key set loaded from the configuration
+func Keys() *KeySet {
+	keySetOnce.Do(func() {
+		var err error
+
+		// the tests sign their tokens with a throwaway secret
+		cfg := config.Envs
+		if testing.Testing() && cfg.JWTSecret == "" {
+			cfg.JWTSecret = testSecret
+		}
+
+		keySet, err = LoadKeySet(cfg)
+		if err != nil {
+			log.Fatal(err)
+		}
+	})
+
+	return keySet
+}
+
+// LoadKeySet function loads the signing and verification keys from the configuration
+func LoadKeySet(cfg config.Config) (*KeySet, error) {
+	ks := &KeySet{Verification: map[string]Key{}}
+
+	switch cfg.JWTSigningMethod {
+	case jwt.SigningMethodHS256.Alg():
+		// there is no default secret, the tokens could be forged with a known one
+		if cfg.JWTSecret == "" {
+			return nil, fmt.Errorf("JWT_SECRET is required to sign the tokens with HS256")
+		}
+
+		id := cfg.JWTKeyID
+		if id == "" {
+			id = "hs256"
+		}
+
+		ks.Signing = Key{ID: id, Method: jwt.SigningMethodHS256, Key: []byte(cfg.JWTSecret)}
+		ks.Verification[id] = ks.Signing
+
+	case jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg():
+		pem, err := os.ReadFile(cfg.JWTPrivateKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("reading the jwt private key: %w", err)
+		}
+
+		signing, public, err := parsePrivateKey(cfg.JWTSigningMethod, pem)
+		if err != nil {
+			return nil, err
+		}
+
+		id := cfg.JWTKeyID
+		if id == "" {
+			id, err = keyThumbprint(public.Key)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		signing.ID, public.ID = id, id
+		ks.Signing = signing
+		ks.Verification[id] = public
+
+	default:
+		return nil, fmt.Errorf("unsupported jwt signing method: %s", cfg.JWTSigningMethod)
+	}
+
+	// public keys of the previous signing keys
+	for _, entry := range strings.Split(cfg.JWTVerificationKeys, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, path, found := strings.Cut(entry, "=")
+		if !found {
+			id, path = "", entry
+		}
+
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading the jwt verification key %s: %w", path, err)
+		}
+
+		key, err := parsePublicKey(pem)
+		if err != nil {
+			return nil, fmt.Errorf("parsing the jwt verification key %s: %w", path, err)
+		}
+
+		if id == "" {
+			id, err = keyThumbprint(key.Key)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		key.ID = id
+		ks.Verification[id] = key
+	}
+
+	return ks, nil
+}
+
+// VerificationKey returns the key to verify a token signed with the key id
+// tokens without a key id are verified with the current signing key
+func (ks *KeySet) VerificationKey(id string) (Key, bool) {
+	if id == "" {
+		id = ks.Signing.ID
+	}
+
+	key, ok := ks.Verification[id]
+	return key, ok
+}
+
+func parsePrivateKey(method string, pem []byte) (Key, Key, error) {
+	if method == jwt.SigningMethodRS256.Alg() {
+		private, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+		if err != nil {
+			return Key{}, Key{}, fmt.Errorf("parsing the jwt private key: %w", err)
+		}
+
+		return Key{Method: jwt.SigningMethodRS256, Key: private}, Key{Method: jwt.SigningMethodRS256, Key: &private.PublicKey}, nil
+	}
+
+	private, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+	if err != nil {
+		return Key{}, Key{}, fmt.Errorf("parsing the jwt private key: %w", err)
+	}
+
+	public := private.(ed25519.PrivateKey).Public()
+	return Key{Method: jwt.SigningMethodEdDSA, Key: private}, Key{Method: jwt.SigningMethodEdDSA, Key: public}, nil
+}
+
+func parsePublicKey(pem []byte) (Key, error) {
+	if public, err := jwt.ParseRSAPublicKeyFromPEM(pem); err == nil {
+		return Key{Method: jwt.SigningMethodRS256, Key: public}, nil
+	}
+
+	public, err := jwt.ParseEdPublicKeyFromPEM(pem)
+	if err != nil {
+		return Key{}, fmt.Errorf("expected an RSA or Ed25519 public key")
+	}
+
+	return Key{Method: jwt.SigningMethodEdDSA, Key: public}, nil
+}
+
+// keyThumbprint derives a key id from the public key
+func keyThumbprint(public interface{}) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(der)
+	return base64.RawURLEncoding.EncodeToString(sum[:12]), nil
+}
+
+// JWK struct to hold a public key in the JSON Web Key format
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+// JWKS returns the public verification keys of the set
+// HMAC secrets are never published
+func (ks *KeySet) JWKS() []JWK {
+	keys := []JWK{}
+
+	for id, key := range ks.Verification {
+		switch public := key.Key.(type) {
+		case *rsa.PublicKey:
+			keys = append(keys, JWK{
+				KeyType:   "RSA",
+				KeyID:     id,
+				Use:       "sig",
+				Algorithm: key.Method.Alg(),
+				N:         base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			keys = append(keys, JWK{
+				KeyType:   "OKP",
+				KeyID:     id,
+				Use:       "sig",
+				Algorithm: key.Method.Alg(),
+				Curve:     "Ed25519",
+				X:         base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+
+	return keys
+}
+
+// HandleJWKS serves the public verification keys so other
+// services can verify the tokens issued by this service
+func HandleJWKS(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle /.well-known/jwks.json endpoint hit")
+
+	utils.WriteJSON(w, http.StatusOK, map[string][]JWK{"keys": Keys().JWKS()})
+}
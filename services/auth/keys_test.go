@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/akshtrikha/golang-ecomm/config"
+	"github.com/golang-jwt/jwt"
+)
+
+func writePEM(t *testing.T, name string, blockType string, der []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func writeKeyPair(t *testing.T, private interface{}, public interface{}) (string, string) {
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return writePEM(t, "private.pem", "PRIVATE KEY", privateDER), writePEM(t, "public.pem", "PUBLIC KEY", publicDER)
+}
+
+// TestKeySet function to test signing and verifying with the key sets
+func TestKeySet(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPrivate, rsaPublic := writeKeyPair(t, rsaKey, &rsaKey.PublicKey)
+
+	edPublicKey, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPrivate, edPublic := writeKeyPair(t, edKey, edPublicKey)
+
+	for _, method := range []struct {
+		name string
+		path string
+	}{
+		{"RS256", rsaPrivate},
+		{"EdDSA", edPrivate},
+	} {
+		t.Run("Should sign and verify with "+method.name, func(t *testing.T) {
+			cfg := config.Envs
+			cfg.JWTSigningMethod = method.name
+			cfg.JWTPrivateKeyPath = method.path
+
+			ks, err := LoadKeySet(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			token, err := ks.GenerateJWT(7, []string{RoleCustomer})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			claims, err := ks.VerifyTokenAndClaims(token)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, claim := range []string{"sub", "exp", "iat", "iss", "aud", "jti"} {
+				if _, ok := claims[claim]; !ok {
+					t.Errorf("Expected the %s claim in the token", claim)
+				}
+			}
+
+			if jwks := ks.JWKS(); len(jwks) != 1 || jwks[0].KeyID != ks.Signing.ID {
+				t.Errorf("Expected the public key in the jwks, got %+v", jwks)
+			}
+		})
+	}
+
+	t.Run("Should verify tokens of the previous key after a rotation", func(t *testing.T) {
+		cfg := config.Envs
+		cfg.JWTSigningMethod = "RS256"
+		cfg.JWTPrivateKeyPath = rsaPrivate
+		cfg.JWTKeyID = "old"
+
+		old, err := LoadKeySet(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		token, err := old.GenerateJWT(7, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cfg.JWTSigningMethod = "EdDSA"
+		cfg.JWTPrivateKeyPath = edPrivate
+		cfg.JWTKeyID = "new"
+		cfg.JWTVerificationKeys = "old=" + rsaPublic
+
+		rotated, err := LoadKeySet(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := rotated.VerifyTokenAndClaims(token); err != nil {
+			t.Errorf("Expected the token of the previous key to verify, got %v", err)
+		}
+
+		if len(rotated.JWKS()) != 2 {
+			t.Errorf("Expected both public keys in the jwks, got %+v", rotated.JWKS())
+		}
+
+		cfg.JWTVerificationKeys = "other=" + edPublic
+
+		withoutOld, err := LoadKeySet(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := withoutOld.VerifyTokenAndClaims(token); err == nil {
+			t.Error("Expected the token of a removed key to be rejected")
+		}
+	})
+
+	t.Run("Should require the JWT secret", func(t *testing.T) {
+		cfg := config.Envs
+		cfg.JWTSecret = ""
+
+		if _, err := LoadKeySet(cfg); err == nil {
+			t.Error("Expected the key set to need a secret")
+		}
+	})
+
+	t.Run("Should not publish the HMAC secret", func(t *testing.T) {
+		cfg := config.Envs
+		cfg.JWTSecret = testSecret
+
+		ks, err := LoadKeySet(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(ks.JWKS()) != 0 {
+			t.Errorf("Expected no keys in the jwks, got %+v", ks.JWKS())
+		}
+	})
+
+	t.Run("Should tell an expired token from a token without expiration", func(t *testing.T) {
+		cfg := config.Envs
+		cfg.JWTSecret = testSecret
+
+		ks, err := LoadKeySet(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sign := func(claims jwt.MapClaims) string {
+			token := jwt.NewWithClaims(ks.Signing.Method, claims)
+			token.Header["kid"] = ks.Signing.ID
+
+			signed, err := token.SignedString(ks.Signing.Key)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			return signed
+		}
+
+		claims := jwt.MapClaims{"sub": "7", "iss": cfg.JWTIssuer, "aud": cfg.JWTAudience}
+		if _, err := ks.VerifyTokenAndClaims(sign(claims)); err == nil || err.Error() != "missing expiration time claim" {
+			t.Errorf("Expected the missing expiration to be reported, got %v", err)
+		}
+
+		claims["exp"] = time.Now().Add(-time.Minute).Unix()
+		if _, err := ks.VerifyTokenAndClaims(sign(claims)); err == nil || err.Error() != "token expired" {
+			t.Errorf("Expected the token to be reported as expired, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/akshtrikha/golang-ecomm/utils"
+)
+
+// Principal holds the authenticated user of a request
+type Principal struct {
+	UserID int
+	Roles  []string
+}
+
+type contextKey string
+
+const principalKey contextKey = "principal"
+
+// WithPrincipal returns a copy of the context holding the principal
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey, p)
+}
+
+// PrincipalFromContext returns the authenticated principal of the request
+// ok is false for anonymous requests
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey).(Principal)
+	return p, ok
+}
+
+// UserIDFromContext returns the id of the authenticated user of the request
+// ok is false for anonymous requests
+func UserIDFromContext(ctx context.Context) (int, bool) {
+	p, ok := PrincipalFromContext(ctx)
+	if !ok {
+		return 0, false
+	}
+
+	return p.UserID, true
+}
+
+// JWTMiddleware verifies the token in the Authorization header and
+// places the principal it was issued to in the request context.
+// Requests with an invalid token are rejected, requests without a token
+// are passed on anonymously so public routes keep working.
+// Both "Bearer <token>" and the bare token are accepted.
+func JWTMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token := header
+		if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+			token = strings.TrimSpace(header[7:])
+		}
+
+		p, err := principalFromToken(token)
+		if err != nil {
+			log.Printf("token verification failed, error: %+v", err)
+			utils.WriteError(w, http.StatusUnauthorized, utils.NewError(http.StatusUnauthorized, "invalid_token", "Invalid Token"))
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+	})
+}
+
+// RequireAuth rejects anonymous requests to the handler
+func RequireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := PrincipalFromContext(r.Context()); !ok {
+			log.Println("jwt token not found in the headers")
+			utils.WriteError(w, http.StatusUnauthorized, fmt.Errorf("Please register/login first"))
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+func principalFromToken(token string) (Principal, error) {
+	claims, err := VerifyTokenAndClaims(token)
+	if err != nil {
+		return Principal{}, err
+	}
+
+	id, err := strconv.Atoi(claims["sub"].(string))
+	if err != nil {
+		return Principal{}, fmt.Errorf("invalid user id claim")
+	}
+
+	p := Principal{UserID: id}
+
+	if roles, ok := claims["roles"].([]interface{}); ok {
+		for _, role := range roles {
+			if name, ok := role.(string); ok {
+				p.Roles = append(p.Roles, name)
+			}
+		}
+	}
+
+	return p, nil
+}
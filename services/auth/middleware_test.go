@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestJWTMiddleware function to test the authentication middleware
+func TestJWTMiddleware(t *testing.T) {
+	token, err := GenerateJWT(42, []string{RoleCustomer})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var gotUserID int
+	var gotAuthenticated bool
+
+	handler := JWTMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUserID, gotAuthenticated = UserIDFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(authorization string) *httptest.ResponseRecorder {
+		gotUserID, gotAuthenticated = 0, false
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	t.Run("Should place the user in the context for a bearer token", func(t *testing.T) {
+		rr := serve("Bearer " + token)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		if !gotAuthenticated || gotUserID != 42 {
+			t.Errorf("Expected user 42 in the context, got %v (authenticated: %v)", gotUserID, gotAuthenticated)
+		}
+	})
+
+	t.Run("Should reject an invalid token", func(t *testing.T) {
+		rr := serve("Bearer invalid-token")
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status code %d, got %d", http.StatusUnauthorized, rr.Code)
+		}
+	})
+
+	t.Run("Should pass anonymous requests on", func(t *testing.T) {
+		rr := serve("")
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		if gotAuthenticated {
+			t.Error("Expected no user in the context")
+		}
+	})
+
+	t.Run("Should require authentication", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+		RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})(rr, req)
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status code %d, got %d", http.StatusUnauthorized, rr.Code)
+		}
+	})
+}
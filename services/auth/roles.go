@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/akshtrikha/golang-ecomm/utils"
+)
+
+// Roles a user can be assigned
+const (
+	RoleAdmin    = "admin"
+	RoleCustomer = "customer"
+)
+
+// HasRole reports whether the principal was assigned the role
+func (p Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsAdmin reports whether the principal is an admin
+func (p Principal) IsAdmin() bool {
+	return p.HasRole(RoleAdmin)
+}
+
+// RequireRole rejects requests to the handler from users without the role
+// It is meant to be used in RegisterRoutes to declare the permission of a route
+func RequireRole(role string, next http.HandlerFunc) http.HandlerFunc {
+	return RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+		p, _ := PrincipalFromContext(r.Context())
+		if !p.HasRole(role) {
+			log.Printf("user %v is missing the %s role", p.UserID, role)
+			utils.WriteError(w, http.StatusForbidden, fmt.Errorf("You are not allowed to perform this action"))
+			return
+		}
+
+		next(w, r)
+	})
+}
@@ -0,0 +1,550 @@
+package cart
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akshtrikha/golang-ecomm/services/auth"
+	"github.com/akshtrikha/golang-ecomm/services/currency"
+	"github.com/akshtrikha/golang-ecomm/services/product"
+	"github.com/akshtrikha/golang-ecomm/services/promotion"
+	"github.com/akshtrikha/golang-ecomm/services/shipping"
+	"github.com/akshtrikha/golang-ecomm/services/tax"
+	"github.com/akshtrikha/golang-ecomm/types"
+	"github.com/akshtrikha/golang-ecomm/utils"
+	"github.com/gorilla/mux"
+)
+
+// API Endpoints to view the cart, add, update and remove cart items,
+// add and remove coupon codes and quote the shipping of the cart
+
+// TokenHeader is the header used to identify anonymous (guest) carts
+const TokenHeader = "X-Cart-Token"
+
+// Handler to the cart store which will deal
+// with the database regarding carts
+type Handler struct {
+	store          types.CartStore
+	productStore   types.ProductStore
+	variantStore   types.VariantStore
+	promotionStore types.PromotionStore
+	shippingStore  types.ShippingStore
+}
+
+// NewHandler constructor takes CartStore, ProductStore, VariantStore, PromotionStore and ShippingStore as dependencies
+// ProductStore and VariantStore are used to verify the products added to the cart,
+// PromotionStore is used to apply the coupons of the cart and ShippingStore to quote its shipping
+func NewHandler(store types.CartStore, productStore types.ProductStore, variantStore types.VariantStore, promotionStore types.PromotionStore, shippingStore types.ShippingStore) *Handler {
+	return &Handler{store: store, productStore: productStore, variantStore: variantStore, promotionStore: promotionStore, shippingStore: shippingStore}
+}
+
+// RegisterRoutes func for cart
+// the variant of a cart item is selected with the sku query parameter,
+// the destination of the shipping quotes with the country and region ones
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/cart", h.handleGetCart).Methods("GET")
+	router.HandleFunc("/cart/items", h.handleAddCartItem).Methods("POST")
+	router.HandleFunc("/cart/items/{productID:[0-9]+}", h.handleUpdateCartItem).Methods("PUT")
+	router.HandleFunc("/cart/items/{productID:[0-9]+}", h.handleRemoveCartItem).Methods("DELETE")
+	router.HandleFunc("/cart/coupons", h.handleAddCartCoupon).Methods("POST")
+	router.HandleFunc("/cart/coupons/{code}", h.handleRemoveCartCoupon).Methods("DELETE")
+	router.HandleFunc("/cart/shipping-quotes", h.handleGetShippingQuotes).Methods("GET")
+}
+
+func (h *Handler) handleGetCart(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle GET /cart endpoint hit")
+
+	c, status, err := h.getOrCreateCart(r)
+	if err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	h.writeCart(w, r, http.StatusOK, c)
+}
+
+func (h *Handler) handleAddCartItem(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle POST /cart/items endpoint hit")
+
+	// get the json payload
+	var payload types.CartItem
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		log.Println("Payload parsing met with an error")
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// validate the json payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.ValidationError("Invalid payload", err))
+		return
+	}
+
+	c, status, err := h.getOrCreateCart(r)
+	if err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	// the quantity already in the cart counts against the stock
+	quantity := payload.Quantity
+	for _, line := range c.Lines {
+		if line.ProductID == payload.ProductID && line.SKU == payload.SKU {
+			quantity += line.Quantity
+		}
+	}
+
+	if status, err := h.checkStock(payload.ProductID, payload.SKU, quantity); err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	if err := h.store.AddCartItem(c.ID, payload.ProductID, payload.SKU, payload.Quantity); err != nil {
+		log.Println("Error adding the item to the cart")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.refreshAndWriteCart(w, r, http.StatusOK, c)
+}
+
+func (h *Handler) handleUpdateCartItem(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle PUT /cart/items/{productID} endpoint hit")
+
+	productID, err := strconv.Atoi(mux.Vars(r)["productID"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid product id"))
+		return
+	}
+
+	// get the json payload
+	var payload types.UpdateCartItemPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		log.Println("Payload parsing met with an error")
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// validate the json payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.ValidationError("Invalid payload", err))
+		return
+	}
+
+	c, status, err := h.getOrCreateCart(r)
+	if err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	sku := r.URL.Query().Get("sku")
+	if !hasProduct(c, productID, sku) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("product %d not found in the cart", productID))
+		return
+	}
+
+	if status, err := h.checkStock(productID, sku, payload.Quantity); err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	if err := h.store.UpdateCartItem(c.ID, productID, sku, payload.Quantity); err != nil {
+		log.Println("Error updating the cart item")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.refreshAndWriteCart(w, r, http.StatusOK, c)
+}
+
+func (h *Handler) handleRemoveCartItem(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle DELETE /cart/items/{productID} endpoint hit")
+
+	productID, err := strconv.Atoi(mux.Vars(r)["productID"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid product id"))
+		return
+	}
+
+	c, status, err := h.getOrCreateCart(r)
+	if err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	err = h.store.RemoveCartItem(c.ID, productID, r.URL.Query().Get("sku"))
+	if errors.Is(err, ErrItemNotFound) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("product with id: %v not found in the cart", productID))
+		return
+	}
+	if err != nil {
+		log.Printf("Error removing the product %v from the cart %v, error: %+v", productID, c.ID, err)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.refreshAndWriteCart(w, r, http.StatusOK, c)
+}
+
+func (h *Handler) handleAddCartCoupon(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle POST /cart/coupons endpoint hit")
+
+	// get the json payload
+	var payload types.ApplyCouponPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		log.Println("Payload parsing met with an error")
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// validate the json payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.ValidationError("Invalid payload", err))
+		return
+	}
+
+	c, status, err := h.getOrCreateCart(r)
+	if err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	// the coupon is only added if it applies to the cart along with its other coupons
+	code := promotion.NormalizeCode(payload.Code)
+
+	codes, err := h.store.GetCartCoupons(c.ID)
+	if err != nil {
+		log.Println("Error fetching the cart coupons from the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := h.priceCart(c, append(codes, code), currency.RateFromContext(r.Context())); err != nil {
+		log.Println("Error computing the cart total")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	for _, invalid := range c.InvalidCoupons {
+		if invalid.Code == code {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("coupon %s %s", code, invalid.Reason))
+			return
+		}
+	}
+
+	if err := h.store.AddCartCoupon(c.ID, code); err != nil {
+		log.Println("Error adding the coupon to the cart")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.writeCart(w, r, http.StatusOK, c)
+}
+
+func (h *Handler) handleRemoveCartCoupon(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle DELETE /cart/coupons/{code} endpoint hit")
+
+	c, status, err := h.getOrCreateCart(r)
+	if err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	code := promotion.NormalizeCode(mux.Vars(r)["code"])
+	err = h.store.RemoveCartCoupon(c.ID, code)
+	if errors.Is(err, ErrCouponNotFound) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("coupon %v not found in the cart", code))
+		return
+	}
+	if err != nil {
+		log.Printf("Error removing the coupon %v from the cart %v, error: %+v", code, c.ID, err)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.writeCart(w, r, http.StatusOK, c)
+}
+
+func (h *Handler) handleGetShippingQuotes(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle GET /cart/shipping-quotes endpoint hit")
+
+	// country codes are case insensitive
+	country := strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("country")))
+	region := tax.NormalizeRegion(r.URL.Query().Get("region"))
+
+	if err := utils.Validate.Var(country, "required,iso3166_1_alpha2"); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid country %q", country))
+		return
+	}
+
+	c, status, err := h.getOrCreateCart(r)
+	if err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	codes, err := h.store.GetCartCoupons(c.ID)
+	if err != nil {
+		log.Println("Error fetching the cart coupons from the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	rate := currency.RateFromContext(r.Context())
+	if err := h.priceCart(c, codes, rate); err != nil {
+		log.Println("Error computing the cart total")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	methods, err := h.shippingStore.GetShippingMethodsFor(country, region)
+	if err != nil {
+		log.Println("Error fetching the shipping methods from the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	for i := range methods {
+		if methods[i], err = shipping.Convert(rate, methods[i]); err != nil {
+			log.Println("Error converting the shipping amounts")
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	weight := 0
+	for _, line := range c.Lines {
+		weight += line.Weight * line.Quantity
+	}
+
+	// the thresholds apply to the total after the discounts, the coupons
+	// giving free shipping make every method free
+	quotes, err := shipping.Quote(methods, weight, c.Total, c.FreeShipping)
+	if err != nil {
+		log.Println("Error computing the shipping quotes")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if c.UserID == nil {
+		w.Header().Set(TokenHeader, c.Token)
+	}
+
+	utils.WriteJSON(w, http.StatusOK, quotes)
+}
+
+// getOrCreateCart resolves the cart of the request along with its lines.
+// Logged in users get their own cart, anonymous users get the cart
+// identified by the cart token header. A new cart is created if none exists.
+// The returned status code is meant to be used when an error is returned.
+func (h *Handler) getOrCreateCart(r *http.Request) (*types.Cart, int, error) {
+	userID, _ := auth.UserIDFromContext(r.Context())
+
+	var c *types.Cart
+	var err error
+	if userID != 0 {
+		c, err = h.store.GetCartByUserID(userID)
+	} else if token := r.Header.Get(TokenHeader); token != "" {
+		c, err = h.store.GetCartByToken(token)
+		if err == nil && c.UserID != nil {
+			// a user cart is only accessible to its owner
+			return nil, http.StatusUnauthorized, fmt.Errorf("Please register/login first")
+		}
+	}
+
+	if err != nil && !errors.Is(err, ErrCartNotFound) {
+		log.Println("Error fetching the cart from the database")
+		return nil, http.StatusInternalServerError, err
+	}
+
+	if c == nil {
+		c, err = h.createCart(userID)
+		if err != nil {
+			log.Println("Error creating the cart")
+			return nil, http.StatusInternalServerError, err
+		}
+	}
+
+	c.Lines, err = h.store.GetCartLines(c.ID)
+	if err != nil {
+		log.Println("Error fetching the cart items from the database")
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return c, http.StatusOK, nil
+}
+
+func (h *Handler) createCart(userID int) (*types.Cart, error) {
+	token, err := generateCartToken()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &types.Cart{Token: token}
+	if userID != 0 {
+		c.UserID = &userID
+	}
+
+	c.ID, err = h.store.CreateCart(*c)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// checkStock verifies the product exists and has the requested quantity available.
+// Products with variants are only sold through the sku of one of their variants
+func (h *Handler) checkStock(productID int, sku string, quantity int) (int, error) {
+	products, err := h.productStore.GetProductsByIDs([]int{productID})
+	if err != nil {
+		log.Println("Error fetching the product from the database")
+		return http.StatusInternalServerError, err
+	}
+
+	if len(products) == 0 {
+		return http.StatusNotFound, fmt.Errorf("product %d not found", productID)
+	}
+
+	name, available := products[0].Name, products[0].Quantity
+
+	if sku != "" {
+		v, err := h.variantStore.GetVariantBySKU(sku)
+		if errors.Is(err, product.ErrVariantNotFound) || (err == nil && (v.ProductID != productID || v.DeletedAt != nil)) {
+			return http.StatusNotFound, fmt.Errorf("variant %s of product %d not found", sku, productID)
+		}
+		if err != nil {
+			log.Println("Error fetching the variant from the database")
+			return http.StatusInternalServerError, err
+		}
+
+		name, available = fmt.Sprintf("%s (%s)", name, sku), v.Quantity
+	} else {
+		variants, err := h.variantStore.GetVariantsByProductID(productID)
+		if err != nil {
+			log.Println("Error fetching the product variants from the database")
+			return http.StatusInternalServerError, err
+		}
+
+		if len(variants) > 0 {
+			return http.StatusBadRequest, fmt.Errorf("product %s requires the sku of a variant", name)
+		}
+	}
+
+	if available < quantity {
+		return http.StatusConflict, fmt.Errorf("product %s has only %d left", name, available)
+	}
+
+	return http.StatusOK, nil
+}
+
+func (h *Handler) refreshAndWriteCart(w http.ResponseWriter, r *http.Request, status int, c *types.Cart) {
+	lines, err := h.store.GetCartLines(c.ID)
+	if err != nil {
+		log.Println("Error fetching the cart items from the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Lines = lines
+	h.writeCart(w, r, status, c)
+}
+
+// writeCart prices the cart in the selected currency with its coupons and
+// writes the cart in the response. The cart token is only exposed for guest carts.
+func (h *Handler) writeCart(w http.ResponseWriter, r *http.Request, status int, c *types.Cart) {
+	codes, err := h.store.GetCartCoupons(c.ID)
+	if err != nil {
+		log.Println("Error fetching the cart coupons from the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := h.priceCart(c, codes, currency.RateFromContext(r.Context())); err != nil {
+		log.Println("Error computing the cart total")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if c.UserID != nil {
+		c.Token = ""
+	} else {
+		w.Header().Set(TokenHeader, c.Token)
+	}
+
+	utils.WriteJSON(w, status, c)
+}
+
+// priceCart converts the unit prices of the lines to the currency of the rate,
+// computes the line totals from the converted prices and applies the coupons.
+// Coupons that do not apply are left out of the total and listed in the cart
+func (h *Handler) priceCart(c *types.Cart, codes []string, rate types.ExchangeRate) error {
+	lines := make([]types.DiscountLine, len(c.Lines))
+	for i := range c.Lines {
+		price, err := currency.Convert(rate, c.Lines[i].Price)
+		if err != nil {
+			return err
+		}
+
+		lineTotal, err := price.Mul(int64(c.Lines[i].Quantity))
+		if err != nil {
+			return err
+		}
+
+		c.Lines[i].Price, c.Lines[i].LineTotal = price, lineTotal
+		lines[i] = types.DiscountLine{ProductID: c.Lines[i].ProductID, Price: price, Quantity: c.Lines[i].Quantity}
+	}
+
+	// the per user limits are checked against the orders of the owner of the cart
+	userID := 0
+	if c.UserID != nil {
+		userID = *c.UserID
+	}
+
+	promotions, invalid, err := promotion.Resolve(h.promotionStore, codes, userID, rate, time.Now())
+	if err != nil {
+		return err
+	}
+
+	result, notApplicable, err := promotion.ApplyValid(promotions, lines, rate.Currency)
+	if err != nil {
+		return err
+	}
+
+	total, err := result.Subtotal.Sub(result.Discount)
+	if err != nil {
+		return err
+	}
+
+	c.Subtotal, c.Discount, c.Total = result.Subtotal, result.Discount, total
+	c.FreeShipping, c.Promotions = result.FreeShipping, result.Applied
+	c.InvalidCoupons = append(invalid, notApplicable...)
+
+	return nil
+}
+
+func hasProduct(c *types.Cart, productID int, sku string) bool {
+	for _, line := range c.Lines {
+		if line.ProductID == productID && line.SKU == sku {
+			return true
+		}
+	}
+
+	return false
+}
+
+func generateCartToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,472 @@
+package cart
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/akshtrikha/golang-ecomm/services/auth"
+	"github.com/akshtrikha/golang-ecomm/services/product"
+	"github.com/akshtrikha/golang-ecomm/types"
+	"github.com/gorilla/mux"
+)
+
+// mockCartStore keeps the carts in memory
+type mockCartStore struct {
+	carts   map[int]*types.Cart
+	items   map[int]map[cartLineKey]int
+	coupons map[int][]string
+}
+
+type cartLineKey struct {
+	productID int
+	sku       string
+}
+
+func newMockCartStore() *mockCartStore {
+	return &mockCartStore{
+		carts:   map[int]*types.Cart{},
+		items:   map[int]map[cartLineKey]int{},
+		coupons: map[int][]string{},
+	}
+}
+
+func (m *mockCartStore) GetCartByToken(token string) (*types.Cart, error) {
+	for _, c := range m.carts {
+		if c.Token == token {
+			copied := *c
+			return &copied, nil
+		}
+	}
+
+	return nil, ErrCartNotFound
+}
+
+func (m *mockCartStore) GetCartByUserID(userID int) (*types.Cart, error) {
+	for _, c := range m.carts {
+		if c.UserID != nil && *c.UserID == userID {
+			copied := *c
+			return &copied, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: user %v", ErrCartNotFound, userID)
+}
+
+func (m *mockCartStore) CreateCart(c types.Cart) (int, error) {
+	c.ID = len(m.carts) + 1
+	m.carts[c.ID] = &c
+	m.items[c.ID] = map[cartLineKey]int{}
+
+	return c.ID, nil
+}
+
+func (m *mockCartStore) GetCartLines(cartID int) ([]types.CartLine, error) {
+	lines := []types.CartLine{}
+	for key, quantity := range m.items[cartID] {
+		lines = append(lines, types.CartLine{
+			ProductID: key.productID,
+			SKU:       key.sku,
+			Price:     types.NewMoney(1000, types.DefaultCurrency),
+			Quantity:  quantity,
+			LineTotal: types.NewMoney(1000*int64(quantity), types.DefaultCurrency),
+			Weight:    500,
+		})
+	}
+
+	return lines, nil
+}
+
+func (m *mockCartStore) AddCartItem(cartID int, productID int, sku string, quantity int) error {
+	m.items[cartID][cartLineKey{productID, sku}] += quantity
+	return nil
+}
+
+func (m *mockCartStore) UpdateCartItem(cartID int, productID int, sku string, quantity int) error {
+	m.items[cartID][cartLineKey{productID, sku}] = quantity
+	return nil
+}
+
+func (m *mockCartStore) RemoveCartItem(cartID int, productID int, sku string) error {
+	key := cartLineKey{productID, sku}
+	if _, ok := m.items[cartID][key]; !ok {
+		return fmt.Errorf("%w: product %d", ErrItemNotFound, productID)
+	}
+
+	delete(m.items[cartID], key)
+	return nil
+}
+
+func (m *mockCartStore) MergeGuestCart(token string, userID int) error {
+	return nil
+}
+
+func (m *mockCartStore) GetCartCoupons(cartID int) ([]string, error) {
+	return append([]string{}, m.coupons[cartID]...), nil
+}
+
+func (m *mockCartStore) AddCartCoupon(cartID int, code string) error {
+	for _, c := range m.coupons[cartID] {
+		if c == code {
+			return nil
+		}
+	}
+
+	m.coupons[cartID] = append(m.coupons[cartID], code)
+	return nil
+}
+
+func (m *mockCartStore) RemoveCartCoupon(cartID int, code string) error {
+	for i, c := range m.coupons[cartID] {
+		if c == code {
+			m.coupons[cartID] = append(m.coupons[cartID][:i], m.coupons[cartID][i+1:]...)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrCouponNotFound, code)
+}
+
+// mockPromotionStore holds a 10% coupon, a coupon of 5.00
+// for orders of at least 100.00 and a free shipping coupon
+type mockPromotionStore struct {
+	types.PromotionStore
+}
+
+func (m *mockPromotionStore) GetPromotionsByCodes(codes []string) ([]types.Promotion, error) {
+	amount := types.NewMoney(500, types.DefaultCurrency)
+	minOrderValue := types.NewMoney(10000, types.DefaultCurrency)
+
+	promotions := []types.Promotion{}
+	for _, code := range codes {
+		switch code {
+		case "SAVE10":
+			promotions = append(promotions, types.Promotion{ID: 1, Code: code, Type: types.PromotionPercentage, Percent: "10", Stackable: true, Active: true})
+		case "BIG":
+			promotions = append(promotions, types.Promotion{ID: 2, Code: code, Type: types.PromotionFixed, Amount: &amount, MinOrderValue: &minOrderValue, Stackable: true, Active: true})
+		case "FREESHIP":
+			promotions = append(promotions, types.Promotion{ID: 3, Code: code, Type: types.PromotionFreeShipping, Stackable: true, Active: true})
+		}
+	}
+
+	return promotions, nil
+}
+
+// mockShippingStore ships to Germany with a flat rate of 4.99, free
+// over 50.00, and an express rate of 9.99 up to 1kg and 14.99 up to 5kg
+type mockShippingStore struct {
+	types.ShippingStore
+}
+
+func (m *mockShippingStore) GetShippingMethodsFor(country string, region string) ([]types.ShippingMethod, error) {
+	if country != "DE" {
+		return []types.ShippingMethod{}, nil
+	}
+
+	flat := types.NewMoney(499, types.DefaultCurrency)
+	freeOver := types.NewMoney(5000, types.DefaultCurrency)
+
+	return []types.ShippingMethod{
+		{ID: 2, Name: "Express", Type: types.ShippingWeight, Active: true, Tiers: []types.ShippingTier{
+			{MaxWeight: 1000, Amount: types.NewMoney(999, types.DefaultCurrency)},
+			{MaxWeight: 5000, Amount: types.NewMoney(1499, types.DefaultCurrency)},
+		}},
+		{ID: 1, Name: "Standard", Type: types.ShippingFlat, Amount: &flat, FreeOver: &freeOver, Active: true},
+	}, nil
+}
+
+type mockProductStore struct {
+	types.ProductStore
+}
+
+func (m *mockProductStore) GetProductsByIDs(ids []int) ([]types.Product, error) {
+	switch ids[0] {
+	case 1:
+		return []types.Product{{ID: 1, Name: "test", Price: types.NewMoney(1000, types.DefaultCurrency), Quantity: 5}}, nil
+	case 3:
+		return []types.Product{{ID: 3, Name: "shirt", Price: types.NewMoney(1000, types.DefaultCurrency)}}, nil
+	}
+
+	return nil, nil
+}
+
+// mockVariantStore holds the variants of the product 3
+type mockVariantStore struct {
+	types.VariantStore
+}
+
+func (m *mockVariantStore) GetVariantsByProductID(productID int) ([]types.ProductVariant, error) {
+	if productID != 3 {
+		return []types.ProductVariant{}, nil
+	}
+
+	return []types.ProductVariant{{ID: 1, ProductID: 3, SKU: "SHIRT-M", Quantity: 2}}, nil
+}
+
+func (m *mockVariantStore) GetVariantBySKU(sku string) (*types.ProductVariant, error) {
+	if sku != "SHIRT-M" {
+		return nil, fmt.Errorf("%w: %v", product.ErrVariantNotFound, sku)
+	}
+
+	return &types.ProductVariant{ID: 1, ProductID: 3, SKU: "SHIRT-M", Quantity: 2}, nil
+}
+
+// TestCartServiceHandlers function to implement testing
+func TestCartServiceHandlers(t *testing.T) {
+	store := newMockCartStore()
+	handler := NewHandler(store, &mockProductStore{}, &mockVariantStore{}, &mockPromotionStore{}, &mockShippingStore{})
+
+	serve := func(method, path string, payload any, headers map[string]string) *httptest.ResponseRecorder {
+		marshalled, _ := json.Marshal(payload)
+
+		req, err := http.NewRequest(method, path, bytes.NewBuffer(marshalled))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+		router.Use(auth.JWTMiddleware)
+
+		handler.RegisterRoutes(router)
+		router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	var guestToken string
+
+	t.Run("Should create a guest cart when adding an item", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/cart/items", types.CartItem{ProductID: 1, Quantity: 2}, nil)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		guestToken = rr.Header().Get(TokenHeader)
+		if guestToken == "" {
+			t.Fatal("Expected a cart token in the response headers")
+		}
+
+		var c types.Cart
+		json.NewDecoder(rr.Body).Decode(&c)
+
+		if c.Total != types.NewMoney(2000, types.DefaultCurrency) {
+			t.Errorf("Expected total %v, got %v", "20.00 USD", c.Total)
+		}
+	})
+
+	t.Run("Should add to the same guest cart with the cart token", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/cart/items", types.CartItem{ProductID: 1, Quantity: 1}, map[string]string{TokenHeader: guestToken})
+
+		var c types.Cart
+		json.NewDecoder(rr.Body).Decode(&c)
+
+		if len(c.Lines) != 1 || c.Lines[0].Quantity != 3 {
+			t.Errorf("Expected a single line with quantity 3, got %+v", c.Lines)
+		}
+	})
+
+	t.Run("Should apply a coupon to the cart", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/cart/coupons", types.ApplyCouponPayload{Code: "save10"}, map[string]string{TokenHeader: guestToken})
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var c types.Cart
+		json.NewDecoder(rr.Body).Decode(&c)
+
+		if c.Subtotal != types.NewMoney(3000, types.DefaultCurrency) || c.Discount != types.NewMoney(300, types.DefaultCurrency) || c.Total != types.NewMoney(2700, types.DefaultCurrency) {
+			t.Errorf("Expected a subtotal of 30.00, a discount of 3.00 and a total of 27.00, got %v, %v and %v", c.Subtotal, c.Discount, c.Total)
+		}
+
+		if len(c.Promotions) != 1 || c.Promotions[0].Code != "SAVE10" {
+			t.Errorf("Expected the SAVE10 promotion, got %+v", c.Promotions)
+		}
+	})
+
+	t.Run("Should reject a coupon below its minimum order value", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/cart/coupons", types.ApplyCouponPayload{Code: "BIG"}, map[string]string{TokenHeader: guestToken})
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("Should reject a coupon that does not exist", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/cart/coupons", types.ApplyCouponPayload{Code: "UNKNOWN"}, map[string]string{TokenHeader: guestToken})
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("Should remove the coupon from the cart", func(t *testing.T) {
+		rr := serve(http.MethodDelete, "/cart/coupons/save10", nil, map[string]string{TokenHeader: guestToken})
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var c types.Cart
+		json.NewDecoder(rr.Body).Decode(&c)
+
+		if c.Total != types.NewMoney(3000, types.DefaultCurrency) || len(c.Promotions) != 0 {
+			t.Errorf("Expected the total of 30.00 without promotions, got %v and %+v", c.Total, c.Promotions)
+		}
+	})
+
+	t.Run("Should quote the shipping methods of the destination", func(t *testing.T) {
+		rr := serve(http.MethodGet, "/cart/shipping-quotes?country=de", nil, map[string]string{TokenHeader: guestToken})
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var quotes []types.ShippingQuote
+		json.NewDecoder(rr.Body).Decode(&quotes)
+
+		// the cart of 30.00 weighs 1.5kg
+		if len(quotes) != 2 || quotes[0].MethodID != 1 || quotes[0].Amount != types.NewMoney(499, types.DefaultCurrency) || quotes[1].Amount != types.NewMoney(1499, types.DefaultCurrency) {
+			t.Errorf("Expected the quotes 4.99 and 14.99, got %+v", quotes)
+		}
+
+		rr = serve(http.MethodGet, "/cart/shipping-quotes?country=FR", nil, map[string]string{TokenHeader: guestToken})
+		if rr.Code != http.StatusOK || strings.TrimSpace(rr.Body.String()) != "[]" {
+			t.Errorf("Expected no shipping methods, got %d %s", rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("Should quote free shipping with a free shipping coupon", func(t *testing.T) {
+		if rr := serve(http.MethodPost, "/cart/coupons", types.ApplyCouponPayload{Code: "FREESHIP"}, map[string]string{TokenHeader: guestToken}); rr.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		rr := serve(http.MethodGet, "/cart/shipping-quotes?country=DE", nil, map[string]string{TokenHeader: guestToken})
+
+		var quotes []types.ShippingQuote
+		json.NewDecoder(rr.Body).Decode(&quotes)
+
+		if len(quotes) != 2 || !quotes[0].Amount.IsZero() || !quotes[1].Amount.IsZero() {
+			t.Errorf("Expected free shipping, got %+v", quotes)
+		}
+
+		serve(http.MethodDelete, "/cart/coupons/FREESHIP", nil, map[string]string{TokenHeader: guestToken})
+	})
+
+	t.Run("Should fail to quote the shipping without a valid country", func(t *testing.T) {
+		rr := serve(http.MethodGet, "/cart/shipping-quotes?country=XX", nil, map[string]string{TokenHeader: guestToken})
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("Should conflict if the cart exceeds the stock", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/cart/items", types.CartItem{ProductID: 1, Quantity: 3}, map[string]string{TokenHeader: guestToken})
+
+		if rr.Code != http.StatusConflict {
+			t.Errorf("Expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+	})
+
+	t.Run("Should fail if the product does not exist", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/cart/items", types.CartItem{ProductID: 2, Quantity: 1}, map[string]string{TokenHeader: guestToken})
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("Should fail to update a product not in the cart", func(t *testing.T) {
+		rr := serve(http.MethodPut, "/cart/items/2", types.UpdateCartItemPayload{Quantity: 1}, map[string]string{TokenHeader: guestToken})
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("Should require the sku of a product with variants", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/cart/items", types.CartItem{ProductID: 3, Quantity: 1}, map[string]string{TokenHeader: guestToken})
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("Should fail if the variant belongs to another product", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/cart/items", types.CartItem{ProductID: 1, SKU: "SHIRT-M", Quantity: 1}, map[string]string{TokenHeader: guestToken})
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("Should add the variant as its own line", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/cart/items", types.CartItem{ProductID: 3, SKU: "SHIRT-M", Quantity: 2}, map[string]string{TokenHeader: guestToken})
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var c types.Cart
+		json.NewDecoder(rr.Body).Decode(&c)
+
+		if len(c.Lines) != 2 {
+			t.Errorf("Expected the variant on a separate line, got %+v", c.Lines)
+		}
+	})
+
+	t.Run("Should check the stock of the variant", func(t *testing.T) {
+		rr := serve(http.MethodPut, "/cart/items/3?sku=SHIRT-M", types.UpdateCartItemPayload{Quantity: 3}, map[string]string{TokenHeader: guestToken})
+
+		if rr.Code != http.StatusConflict {
+			t.Errorf("Expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+	})
+
+	t.Run("Should remove the variant from the cart", func(t *testing.T) {
+		rr := serve(http.MethodDelete, "/cart/items/3?sku=SHIRT-M", nil, map[string]string{TokenHeader: guestToken})
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+	})
+
+	t.Run("Should remove the item from the cart", func(t *testing.T) {
+		rr := serve(http.MethodDelete, "/cart/items/1", nil, map[string]string{TokenHeader: guestToken})
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+	})
+
+	t.Run("Should not expose a user cart to its token", func(t *testing.T) {
+		token, err := auth.GenerateJWT(1, []string{auth.RoleCustomer})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		serve(http.MethodGet, "/cart", nil, map[string]string{"Authorization": token})
+
+		c, err := store.GetCartByUserID(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := serve(http.MethodGet, "/cart", nil, map[string]string{TokenHeader: c.Token})
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status code %d, got %d", http.StatusUnauthorized, rr.Code)
+		}
+	})
+}
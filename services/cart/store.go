@@ -0,0 +1,318 @@
+package cart
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/akshtrikha/golang-ecomm/services/shipping"
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// Store struct to hold the database object
+// This will be used to handle the database queries
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore function to return a reference to the Store struct
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// ErrCartNotFound is returned when a cart does not exist
+var ErrCartNotFound = errors.New("cart not found")
+
+// ErrItemNotFound is returned when a product is not in the cart
+var ErrItemNotFound = errors.New("item not found in the cart")
+
+// ErrCouponNotFound is returned when a coupon code is not in the cart
+var ErrCouponNotFound = errors.New("coupon not found in the cart")
+
+// GetCartByToken function to find a cart by its cart token
+func (s *Store) GetCartByToken(token string) (*types.Cart, error) {
+	rows, err := s.db.Query("SELECT id, userId, token, createdAt, updatedAt FROM carts WHERE token = ?", token)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	c := new(types.Cart)
+	for rows.Next() {
+		c, err = scanRowIntoCart(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.ID == 0 {
+		return nil, ErrCartNotFound
+	}
+
+	return c, nil
+}
+
+// GetCartByUserID function to find the cart of the user
+func (s *Store) GetCartByUserID(userID int) (*types.Cart, error) {
+	rows, err := s.db.Query("SELECT id, userId, token, createdAt, updatedAt FROM carts WHERE userId = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	c := new(types.Cart)
+	for rows.Next() {
+		c, err = scanRowIntoCart(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.ID == 0 {
+		return nil, fmt.Errorf("%w: user %v", ErrCartNotFound, userID)
+	}
+
+	return c, nil
+}
+
+// CreateCart function to create a new cart
+func (s *Store) CreateCart(c types.Cart) (int, error) {
+	result, err := s.db.Exec("INSERT INTO carts (userId, token) VALUES (?, ?)", c.UserID, c.Token)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// GetCartLines function to get the lines of the cart priced with the
+// current product prices, or the variant prices when they override them,
+// and the weight the products are shipped as.
+// Lines of archived products or deleted variants are left out.
+func (s *Store) GetCartLines(cartID int) ([]types.CartLine, error) {
+	rows, err := s.db.Query(`
+		SELECT p.id, ci.sku, v.options, p.name, COALESCE(NULLIF(v.image, ''), p.image), COALESCE(v.price, p.price), ci.quantity,
+			p.weight, p.length, p.width, p.height
+		FROM cart_items ci
+		JOIN products p ON p.id = ci.productId AND p.deletedAt IS NULL
+		LEFT JOIN product_variants v ON v.productId = ci.productId AND v.sku = ci.sku AND v.deletedAt IS NULL
+		WHERE ci.cartId = ? AND (ci.sku = '' OR v.id IS NOT NULL)
+		ORDER BY ci.id`, cartID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := []types.CartLine{}
+	for rows.Next() {
+		var line types.CartLine
+		var options []byte
+		var p types.Product
+
+		err := rows.Scan(
+			&line.ProductID,
+			&line.SKU,
+			&options,
+			&line.Name,
+			&line.Image,
+			&line.Price,
+			&line.Quantity,
+			&p.Weight,
+			&p.Length,
+			&p.Width,
+			&p.Height,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if options != nil {
+			if err := json.Unmarshal(options, &line.Options); err != nil {
+				return nil, err
+			}
+		}
+
+		if line.LineTotal, err = line.Price.Mul(int64(line.Quantity)); err != nil {
+			return nil, err
+		}
+		line.Weight = shipping.ChargeableWeight(p)
+		lines = append(lines, line)
+	}
+
+	return lines, rows.Err()
+}
+
+// AddCartItem function to add the quantity of the product variant to the cart
+// the quantity is added to the existing line if the variant is already present.
+// sku is empty for products without variants
+func (s *Store) AddCartItem(cartID int, productID int, sku string, quantity int) error {
+	_, err := s.db.Exec(`
+		INSERT INTO cart_items (cartId, productId, sku, quantity) VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE quantity = quantity + VALUES(quantity)`, cartID, productID, sku, quantity)
+
+	return err
+}
+
+// UpdateCartItem function to set the quantity of a product variant already in the cart
+func (s *Store) UpdateCartItem(cartID int, productID int, sku string, quantity int) error {
+	_, err := s.db.Exec("UPDATE cart_items SET quantity = ? WHERE cartId = ? AND productId = ? AND sku = ?", quantity, cartID, productID, sku)
+
+	return err
+}
+
+// RemoveCartItem function to remove a product variant from the cart
+func (s *Store) RemoveCartItem(cartID int, productID int, sku string) error {
+	result, err := s.db.Exec("DELETE FROM cart_items WHERE cartId = ? AND productId = ? AND sku = ?", cartID, productID, sku)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w: product %d", ErrItemNotFound, productID)
+	}
+
+	return nil
+}
+
+// GetCartCoupons function to get the coupon codes added to the cart
+// in the order they were added
+func (s *Store) GetCartCoupons(cartID int) ([]string, error) {
+	rows, err := s.db.Query("SELECT code FROM cart_coupons WHERE cartId = ? ORDER BY createdAt, code", cartID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	codes := []string{}
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			return nil, err
+		}
+
+		codes = append(codes, code)
+	}
+
+	return codes, rows.Err()
+}
+
+// AddCartCoupon function to add the coupon code to the cart
+// adding a code already in the cart does nothing
+func (s *Store) AddCartCoupon(cartID int, code string) error {
+	_, err := s.db.Exec("INSERT IGNORE INTO cart_coupons (cartId, code) VALUES (?, ?)", cartID, code)
+
+	return err
+}
+
+// RemoveCartCoupon function to remove the coupon code from the cart
+func (s *Store) RemoveCartCoupon(cartID int, code string) error {
+	result, err := s.db.Exec("DELETE FROM cart_coupons WHERE cartId = ? AND code = ?", cartID, code)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w: %s", ErrCouponNotFound, code)
+	}
+
+	return nil
+}
+
+// MergeGuestCart function to merge the guest cart with the given token
+// into the cart of the user. If the user has no cart yet the guest cart
+// is simply assigned to the user, otherwise the lines and the coupons are
+// added to the user cart and the guest cart is deleted.
+func (s *Store) MergeGuestCart(token string, userID int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	var guestCartID int
+	err = tx.QueryRow("SELECT id FROM carts WHERE token = ? AND userId IS NULL FOR UPDATE", token).Scan(&guestCartID)
+	if err == sql.ErrNoRows {
+		// nothing to merge
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var userCartID int
+	err = tx.QueryRow("SELECT id FROM carts WHERE userId = ? FOR UPDATE", userID).Scan(&userCartID)
+	if err == sql.ErrNoRows {
+		// the guest cart becomes the cart of the user
+		if _, err := tx.Exec("UPDATE carts SET userId = ? WHERE id = ?", userID, guestCartID); err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO cart_items (cartId, productId, sku, quantity)
+		SELECT ?, productId, sku, quantity FROM cart_items WHERE cartId = ?
+		ON DUPLICATE KEY UPDATE quantity = cart_items.quantity + VALUES(quantity)`, userCartID, guestCartID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT IGNORE INTO cart_coupons (cartId, code, createdAt)
+		SELECT ?, code, createdAt FROM cart_coupons WHERE cartId = ?`, userCartID, guestCartID)
+	if err != nil {
+		return err
+	}
+
+	// the cart items and coupons of the guest cart are deleted with the cart
+	if _, err := tx.Exec("DELETE FROM carts WHERE id = ?", guestCartID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func scanRowIntoCart(rows *sql.Rows) (*types.Cart, error) {
+	cart := new(types.Cart)
+
+	var userID sql.NullInt64
+	err := rows.Scan(
+		&cart.ID,
+		&userID,
+		&cart.Token,
+		&cart.CreatedAt,
+		&cart.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if userID.Valid {
+		id := int(userID.Int64)
+		cart.UserID = &id
+	}
+
+	return cart, nil
+}
@@ -0,0 +1,415 @@
+package category
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/akshtrikha/golang-ecomm/services/auth"
+	"github.com/akshtrikha/golang-ecomm/services/currency"
+	"github.com/akshtrikha/golang-ecomm/services/product"
+	"github.com/akshtrikha/golang-ecomm/types"
+	"github.com/akshtrikha/golang-ecomm/utils"
+	"github.com/gorilla/mux"
+)
+
+// API Endpoints to manage the category tree, assign products
+// to categories and browse the products of a category
+
+// Handler to the category store which will deal
+// with the database regarding categories
+type Handler struct {
+	store        types.CategoryStore
+	productStore types.ProductStore
+}
+
+// NewHandler constructor takes CategoryStore and ProductStore as dependencies
+// ProductStore is used to list and verify the products of the categories
+func NewHandler(store types.CategoryStore, productStore types.ProductStore) *Handler {
+	return &Handler{store: store, productStore: productStore}
+}
+
+// RegisterRoutes func for categories
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/categories", auth.RequireAuth(h.handleGetCategories)).Methods("GET")
+	router.HandleFunc("/categories", auth.RequireRole(auth.RoleAdmin, h.handleCreateCategory)).Methods("POST")
+	router.HandleFunc("/categories/{id:[0-9]+}", auth.RequireRole(auth.RoleAdmin, h.handleUpdateCategory)).Methods("PATCH")
+	router.HandleFunc("/categories/{id:[0-9]+}", auth.RequireRole(auth.RoleAdmin, h.handleDeleteCategory)).Methods("DELETE")
+	router.HandleFunc("/categories/{id:[0-9]+}/products", auth.RequireAuth(h.handleBrowseCategory)).Methods("GET")
+
+	router.HandleFunc("/products/{id:[0-9]+}/categories", auth.RequireAuth(h.handleGetProductCategories)).Methods("GET")
+	router.HandleFunc("/products/{id:[0-9]+}/categories", auth.RequireRole(auth.RoleAdmin, h.handleSetProductCategories)).Methods("PUT")
+}
+
+func (h *Handler) handleGetCategories(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle GET /categories endpoint hit")
+
+	tree, err := h.loadTree()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, tree.Roots())
+}
+
+func (h *Handler) handleCreateCategory(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle POST /categories endpoint hit")
+
+	// get the json payload
+	var payload types.CategoryPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		log.Println("Payload parsing met with an error")
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// validate the json payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.ValidationError("Invalid payload", err))
+		return
+	}
+
+	tree, err := h.loadTree()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	category := types.Category{Name: strings.TrimSpace(payload.Name), ParentID: payload.ParentID}
+	if status, err := validatePlacement(tree, category); err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	payload.Name = category.Name
+	categoryID, err := h.store.CreateCategory(payload)
+	if err != nil {
+		log.Println("Error adding the category to the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	log.Printf("Category Added %v", categoryID)
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]int{"id": categoryID})
+}
+
+func (h *Handler) handleUpdateCategory(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle PATCH /categories/{id} endpoint hit")
+
+	categoryID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid category id"))
+		return
+	}
+
+	// get the json payload
+	var payload types.UpdateCategoryPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		log.Println("Payload parsing met with an error")
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// validate the json payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.ValidationError("Invalid payload", err))
+		return
+	}
+
+	if payload.MoveToRoot && payload.ParentID != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("parentId can not be set when moving to the root"))
+		return
+	}
+
+	tree, err := h.loadTree()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	category, ok := tree.Get(categoryID)
+	if !ok {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("category with id: %v not found", categoryID))
+		return
+	}
+
+	// only update the fields present in the payload
+	if payload.Name != nil {
+		category.Name = strings.TrimSpace(*payload.Name)
+	}
+	if payload.ParentID != nil {
+		category.ParentID = payload.ParentID
+	}
+	if payload.MoveToRoot {
+		category.ParentID = nil
+	}
+
+	if status, err := validatePlacement(tree, category); err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	if err := h.store.UpdateCategory(category); err != nil {
+		log.Println("Error updating the category in the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	log.Printf("Category Updated %v", categoryID)
+
+	utils.WriteJSON(w, http.StatusOK, category)
+}
+
+func (h *Handler) handleDeleteCategory(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle DELETE /categories/{id} endpoint hit")
+
+	categoryID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid category id"))
+		return
+	}
+
+	tree, err := h.loadTree()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if _, ok := tree.Get(categoryID); !ok {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("category with id: %v not found", categoryID))
+		return
+	}
+
+	// the children have to be moved or deleted first
+	if tree.HasChildren(categoryID) {
+		utils.WriteError(w, http.StatusConflict, fmt.Errorf("category with id: %v has child categories", categoryID))
+		return
+	}
+
+	if err := h.store.DeleteCategory(categoryID); err != nil {
+		log.Println("Error deleting the category from the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	log.Printf("Category Deleted %v", categoryID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) handleBrowseCategory(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle GET /categories/{id}/products endpoint hit")
+
+	categoryID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid category id"))
+		return
+	}
+
+	// the listing accepts the pagination, sorting and filters of /products
+	q, err := product.ParseListQuery(r.URL.Query(), currency.RateFromContext(r.Context()))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// verify the query
+	if err := utils.Validate.Struct(q); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.ValidationError("Invalid query", err))
+		return
+	}
+
+	tree, err := h.loadTree()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if _, ok := tree.Get(categoryID); !ok {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("category with id: %v not found", categoryID))
+		return
+	}
+
+	// products of the descendant categories belong to the category too
+	q.CategoryIDs = tree.DescendantIDs(categoryID)
+
+	page, err := h.productStore.ListProducts(q)
+	if errors.Is(err, product.ErrInvalidCursor) {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		log.Println("Error fetching the products from the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	product.LinkNextPage(r, page, q.Sort)
+
+	// show the prices in the selected currency
+	if err := currency.ConvertProducts(currency.RateFromContext(r.Context()), page.Items); err != nil {
+		log.Println("Error converting the prices")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, types.CategoryProductsResponse{
+		Category:   tree.Nested(categoryID),
+		Breadcrumb: tree.Breadcrumb(categoryID),
+		Products:   page,
+	})
+}
+
+func (h *Handler) handleGetProductCategories(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle GET /products/{id}/categories endpoint hit")
+
+	productID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid product id"))
+		return
+	}
+
+	_, err = h.productStore.GetProductByID(productID)
+	if errors.Is(err, product.ErrProductNotFound) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("product with id: %v not found", productID))
+		return
+	}
+	if err != nil {
+		log.Printf("Error finding the product %v, error: %+v", productID, err)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.writeProductCategories(w, productID)
+}
+
+func (h *Handler) handleSetProductCategories(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle PUT /products/{id}/categories endpoint hit")
+
+	productID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid product id"))
+		return
+	}
+
+	// get the json payload
+	var payload types.ProductCategoriesPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		log.Println("Payload parsing met with an error")
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// validate the json payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.ValidationError("Invalid payload", err))
+		return
+	}
+
+	p, err := h.productStore.GetProductByID(productID)
+	if errors.Is(err, product.ErrProductNotFound) || (err == nil && p.DeletedAt != nil) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("product with id: %v not found", productID))
+		return
+	}
+	if err != nil {
+		log.Printf("Error finding the product %v, error: %+v", productID, err)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	tree, err := h.loadTree()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	for _, categoryID := range payload.CategoryIDs {
+		if _, ok := tree.Get(categoryID); !ok {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("category with id: %v not found", categoryID))
+			return
+		}
+	}
+
+	if err := h.store.SetProductCategories(productID, payload.CategoryIDs); err != nil {
+		log.Println("Error assigning the product categories")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.writeProductCategories(w, productID)
+}
+
+// writeProductCategories writes the categories of the product
+// each with the path from the root of the tree
+func (h *Handler) writeProductCategories(w http.ResponseWriter, productID int) {
+	tree, err := h.loadTree()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	ids, err := h.store.GetProductCategoryIDs(productID)
+	if err != nil {
+		log.Println("Error fetching the product categories")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	categories := []types.ProductCategory{}
+	for _, id := range ids {
+		c, ok := tree.Get(id)
+		if !ok {
+			continue
+		}
+
+		categories = append(categories, types.ProductCategory{Category: c, Breadcrumb: tree.Breadcrumb(id)})
+	}
+
+	utils.WriteJSON(w, http.StatusOK, categories)
+}
+
+func (h *Handler) loadTree() (*Tree, error) {
+	categories, err := h.store.GetCategories()
+	if err != nil {
+		log.Println("Error fetching the categories from the database")
+		return nil, err
+	}
+
+	return NewTree(categories), nil
+}
+
+// validatePlacement checks the parent of the category exists, is not
+// nested in the category itself and has no other child with the same name
+func validatePlacement(tree *Tree, c types.Category) (int, error) {
+	if c.Name == "" {
+		return http.StatusBadRequest, fmt.Errorf("category name is required")
+	}
+
+	var siblings []types.Category
+	if c.ParentID == nil {
+		siblings = tree.Roots()
+	} else {
+		if _, ok := tree.Get(*c.ParentID); !ok {
+			return http.StatusBadRequest, fmt.Errorf("parent category with id: %v not found", *c.ParentID)
+		}
+
+		if c.ID != 0 && tree.IsDescendant(*c.ParentID, c.ID) {
+			return http.StatusConflict, fmt.Errorf("category can not be moved under itself or its descendants")
+		}
+
+		siblings = tree.Nested(*c.ParentID).Children
+	}
+
+	for _, sibling := range siblings {
+		if sibling.ID != c.ID && strings.EqualFold(sibling.Name, c.Name) {
+			return http.StatusConflict, fmt.Errorf("category %q already exists", c.Name)
+		}
+	}
+
+	return 0, nil
+}
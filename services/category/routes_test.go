@@ -0,0 +1,234 @@
+package category
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/akshtrikha/golang-ecomm/services/auth"
+	"github.com/akshtrikha/golang-ecomm/services/product"
+	"github.com/akshtrikha/golang-ecomm/types"
+	"github.com/gorilla/mux"
+)
+
+// mockCategoryStore keeps the categories in memory
+type mockCategoryStore struct {
+	categories        []types.Category
+	productCategories map[int][]int
+}
+
+func (m *mockCategoryStore) GetCategories() ([]types.Category, error) {
+	return m.categories, nil
+}
+
+func (m *mockCategoryStore) CreateCategory(c types.CategoryPayload) (int, error) {
+	id := len(m.categories) + 1
+	m.categories = append(m.categories, types.Category{ID: id, Name: c.Name, ParentID: c.ParentID})
+
+	return id, nil
+}
+
+func (m *mockCategoryStore) UpdateCategory(c types.Category) error {
+	m.categories[c.ID-1] = c
+	return nil
+}
+
+func (m *mockCategoryStore) DeleteCategory(id int) error {
+	return nil
+}
+
+func (m *mockCategoryStore) GetProductCategoryIDs(productID int) ([]int, error) {
+	return m.productCategories[productID], nil
+}
+
+func (m *mockCategoryStore) SetProductCategories(productID int, categoryIDs []int) error {
+	m.productCategories[productID] = categoryIDs
+	return nil
+}
+
+// mockProductStore records the listing query of the browse endpoint
+type mockProductStore struct {
+	types.ProductStore
+	query types.ProductListQuery
+}
+
+func (m *mockProductStore) ListProducts(q types.ProductListQuery) (*types.ProductPage, error) {
+	m.query = q
+
+	// the store refuses the cursors of another sort
+	if q.After != nil && q.After.Sort != q.Sort {
+		return nil, product.ErrInvalidCursor
+	}
+
+	return &types.ProductPage{Items: []types.Product{}, Limit: q.Limit}, nil
+}
+
+func (m *mockProductStore) GetProductByID(id int) (*types.Product, error) {
+	if id != 1 {
+		return nil, fmt.Errorf("%w: %v", product.ErrProductNotFound, id)
+	}
+
+	return &types.Product{ID: id, Name: "phone"}, nil
+}
+
+// TestCategoryServiceHandlers function to implement testing
+func TestCategoryServiceHandlers(t *testing.T) {
+	store := &mockCategoryStore{productCategories: map[int][]int{}}
+	productStore := &mockProductStore{}
+	handler := NewHandler(store, productStore)
+
+	adminToken, err := auth.GenerateJWT(1, []string{auth.RoleAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	customerToken, err := auth.GenerateJWT(2, []string{auth.RoleCustomer})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(method, path string, payload any, token string) *httptest.ResponseRecorder {
+		var body []byte
+		if payload != nil {
+			body, _ = json.Marshal(payload)
+		}
+
+		req, err := http.NewRequest(method, path, bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+		router.Use(auth.JWTMiddleware)
+
+		handler.RegisterRoutes(router)
+		router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	parent := func(id int) *int { return &id }
+
+	t.Run("Should not allow customers to create categories", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/categories", types.CategoryPayload{Name: "Electronics"}, customerToken)
+
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("Expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	t.Run("Should create the category tree", func(t *testing.T) {
+		for _, c := range []types.CategoryPayload{
+			{Name: "Electronics"},
+			{Name: "Phones", ParentID: parent(1)},
+			{Name: "Android", ParentID: parent(2)},
+			{Name: "Books"},
+		} {
+			rr := serve(http.MethodPost, "/categories", c, adminToken)
+
+			if rr.Code != http.StatusCreated {
+				t.Fatalf("Expected status code %d, got %d", http.StatusCreated, rr.Code)
+			}
+		}
+
+		rr := serve(http.MethodGet, "/categories", nil, customerToken)
+
+		var roots []types.Category
+		json.NewDecoder(rr.Body).Decode(&roots)
+
+		if len(roots) != 2 || roots[0].Children[0].Children[0].Name != "Android" {
+			t.Errorf("Expected the nested tree, got %+v", roots)
+		}
+	})
+
+	t.Run("Should fail to create a category with an unknown parent", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/categories", types.CategoryPayload{Name: "Tablets", ParentID: parent(99)}, adminToken)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("Should fail to create a duplicate sibling", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/categories", types.CategoryPayload{Name: "phones", ParentID: parent(1)}, adminToken)
+
+		if rr.Code != http.StatusConflict {
+			t.Errorf("Expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+	})
+
+	t.Run("Should fail to move a category under its descendant", func(t *testing.T) {
+		rr := serve(http.MethodPatch, "/categories/1", types.UpdateCategoryPayload{ParentID: parent(3)}, adminToken)
+
+		if rr.Code != http.StatusConflict {
+			t.Errorf("Expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+	})
+
+	t.Run("Should fail to delete a category with children", func(t *testing.T) {
+		rr := serve(http.MethodDelete, "/categories/2", nil, adminToken)
+
+		if rr.Code != http.StatusConflict {
+			t.Errorf("Expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+	})
+
+	t.Run("Should browse the products of the category and its descendants", func(t *testing.T) {
+		rr := serve(http.MethodGet, "/categories/2/products?sort=price", nil, customerToken)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		if !reflect.DeepEqual(productStore.query.CategoryIDs, []int{2, 3}) || productStore.query.Sort != "price" {
+			t.Errorf("Expected the listing of categories [2 3] sorted by price, got %+v", productStore.query)
+		}
+
+		var response types.CategoryProductsResponse
+		json.NewDecoder(rr.Body).Decode(&response)
+
+		if len(response.Breadcrumb) != 2 || response.Breadcrumb[0].Name != "Electronics" || response.Breadcrumb[1].Name != "Phones" {
+			t.Errorf("Expected the breadcrumb Electronics > Phones, got %+v", response.Breadcrumb)
+		}
+	})
+
+	t.Run("Should fail to browse the products with the cursor of another sort", func(t *testing.T) {
+		cursor := product.EncodeCursor(types.Product{ID: 1, Name: "phone"}, "name")
+
+		rr := serve(http.MethodGet, "/categories/2/products?sort=price&cursor="+cursor, nil, customerToken)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("Should fail to assign a product to an unknown category", func(t *testing.T) {
+		rr := serve(http.MethodPut, "/products/1/categories", types.ProductCategoriesPayload{CategoryIDs: []int{3, 99}}, adminToken)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("Should assign the product to the categories", func(t *testing.T) {
+		rr := serve(http.MethodPut, "/products/1/categories", types.ProductCategoriesPayload{CategoryIDs: []int{3, 4}}, adminToken)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var categories []types.ProductCategory
+		json.NewDecoder(rr.Body).Decode(&categories)
+
+		if len(categories) != 2 || len(categories[0].Breadcrumb) != 3 || len(categories[1].Breadcrumb) != 1 {
+			t.Errorf("Expected the categories with their breadcrumbs, got %+v", categories)
+		}
+	})
+}
@@ -0,0 +1,151 @@
+package category
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// Store struct to hold the database object
+// This will be used to handle the database queries
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore function to return a reference to the Store struct
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// GetCategories function to get every category as a flat list
+// the tree is built from the ParentID of the categories
+func (s *Store) GetCategories() ([]types.Category, error) {
+	rows, err := s.db.Query("SELECT id, name, parentId, createdAt FROM categories ORDER BY name, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []types.Category
+
+	// scan the rows
+	for rows.Next() {
+		c, err := scanRowIntoCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		categories = append(categories, *c)
+	}
+
+	return categories, rows.Err()
+}
+
+// CreateCategory function to add a category under its parent
+func (s *Store) CreateCategory(c types.CategoryPayload) (int, error) {
+	result, err := s.db.Exec("INSERT INTO categories (name, parentId) VALUES (?, ?)", c.Name, c.ParentID)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// UpdateCategory function to rename the category or move it to another parent
+func (s *Store) UpdateCategory(c types.Category) error {
+	_, err := s.db.Exec("UPDATE categories SET name = ?, parentId = ? WHERE id = ?", c.Name, c.ParentID, c.ID)
+
+	return err
+}
+
+// DeleteCategory function to delete a category without children
+// the products of the category are unassigned from it
+func (s *Store) DeleteCategory(id int) error {
+	result, err := s.db.Exec("DELETE FROM categories WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("category with id: %v not found", id)
+	}
+
+	return nil
+}
+
+// GetProductCategoryIDs function to get the ids of the categories of the product
+func (s *Store) GetProductCategoryIDs(productID int) ([]int, error) {
+	rows, err := s.db.Query("SELECT categoryId FROM product_categories WHERE productId = ? ORDER BY categoryId", productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// SetProductCategories function to replace the categories of the product
+func (s *Store) SetProductCategories(productID int, categoryIDs []int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM product_categories WHERE productId = ?", productID); err != nil {
+		return err
+	}
+
+	for _, categoryID := range categoryIDs {
+		_, err := tx.Exec("INSERT IGNORE INTO product_categories (productId, categoryId) VALUES (?, ?)", productID, categoryID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func scanRowIntoCategory(rows *sql.Rows) (*types.Category, error) {
+	c := new(types.Category)
+
+	var parentID sql.NullInt64
+	err := rows.Scan(
+		&c.ID,
+		&c.Name,
+		&parentID,
+		&c.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		c.ParentID = &id
+	}
+
+	return c, nil
+}
@@ -0,0 +1,106 @@
+package category
+
+import "github.com/akshtrikha/golang-ecomm/types"
+
+// Tree indexes the flat list of categories by id and by parent
+// categories are small enough to be loaded at once for every request
+type Tree struct {
+	byID     map[int]types.Category
+	children map[int][]int
+	roots    []int
+}
+
+// NewTree function builds the tree out of the categories
+// keeping the order of the list among siblings
+func NewTree(categories []types.Category) *Tree {
+	t := &Tree{
+		byID:     make(map[int]types.Category, len(categories)),
+		children: make(map[int][]int),
+	}
+
+	for _, c := range categories {
+		c.Children = nil
+		t.byID[c.ID] = c
+	}
+
+	for _, c := range categories {
+		if c.ParentID == nil {
+			t.roots = append(t.roots, c.ID)
+			continue
+		}
+
+		t.children[*c.ParentID] = append(t.children[*c.ParentID], c.ID)
+	}
+
+	return t
+}
+
+// Get returns the category without its children
+func (t *Tree) Get(id int) (types.Category, bool) {
+	c, ok := t.byID[id]
+	return c, ok
+}
+
+// HasChildren reports whether other categories are nested in the category
+func (t *Tree) HasChildren(id int) bool {
+	return len(t.children[id]) > 0
+}
+
+// Roots returns the root categories with their children nested
+func (t *Tree) Roots() []types.Category {
+	roots := []types.Category{}
+	for _, id := range t.roots {
+		roots = append(roots, t.Nested(id))
+	}
+
+	return roots
+}
+
+// Nested returns the category with its descendants nested in Children
+func (t *Tree) Nested(id int) types.Category {
+	c := t.byID[id]
+	for _, childID := range t.children[id] {
+		c.Children = append(c.Children, t.Nested(childID))
+	}
+
+	return c
+}
+
+// DescendantIDs returns the id of the category followed
+// by the ids of every category nested in it
+func (t *Tree) DescendantIDs(id int) []int {
+	ids := []int{id}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, t.children[ids[i]]...)
+	}
+
+	return ids
+}
+
+// IsDescendant reports whether the category is the ancestor or nested in it
+func (t *Tree) IsDescendant(id, ancestor int) bool {
+	for _, descendant := range t.DescendantIDs(ancestor) {
+		if descendant == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Breadcrumb returns the path from the root of the tree to the category
+func (t *Tree) Breadcrumb(id int) []types.Category {
+	var path []types.Category
+
+	c, ok := t.byID[id]
+	for ok && len(path) <= len(t.byID) {
+		path = append([]types.Category{c}, path...)
+		if c.ParentID == nil {
+			break
+		}
+
+		c, ok = t.byID[*c.ParentID]
+	}
+
+	return path
+}
@@ -0,0 +1,146 @@
+package currency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/akshtrikha/golang-ecomm/types"
+	"github.com/akshtrikha/golang-ecomm/utils"
+)
+
+// Header selects the currency of the prices in the response,
+// the currency query parameter takes precedence over it
+const Header = "X-Currency"
+
+type contextKey string
+
+const rateKey contextKey = "exchangeRate"
+
+// WithRate returns a copy of the context holding the exchange rate
+func WithRate(ctx context.Context, rate types.ExchangeRate) context.Context {
+	return context.WithValue(ctx, rateKey, rate)
+}
+
+// RateFromContext returns the exchange rate of the currency selected
+// for the request, the base currency when none was selected
+func RateFromContext(ctx context.Context) types.ExchangeRate {
+	if rate, ok := ctx.Value(rateKey).(types.ExchangeRate); ok {
+		return rate
+	}
+
+	return types.BaseExchangeRate
+}
+
+// Middleware resolves the currency selected with the currency query
+// parameter or the X-Currency header and places its exchange rate in
+// the request context. Requests for a currency without a rate are rejected
+// with a 400, the failures to read the rate are internal errors
+func (h *Handler) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		currency := r.URL.Query().Get("currency")
+		if currency == "" {
+			currency = r.Header.Get(Header)
+		}
+
+		currency = strings.ToUpper(strings.TrimSpace(currency))
+		if currency == "" || currency == types.DefaultCurrency {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rate, err := h.store.GetExchangeRate(currency)
+		if errors.Is(err, ErrRateNotFound) {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("unsupported currency: %v", currency))
+			return
+		}
+		if err != nil {
+			log.Printf("exchange rate lookup failed, error: %+v", err)
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithRate(r.Context(), *rate)))
+	})
+}
+
+// Convert returns the amount in the currency of the rate,
+// amounts already in that currency are returned as they are
+func Convert(rate types.ExchangeRate, m types.Money) (types.Money, error) {
+	if m.Currency == rate.Currency {
+		return m, nil
+	}
+
+	if m.Currency != types.DefaultCurrency {
+		return m, types.ErrCurrencyMismatch
+	}
+
+	r, err := types.ParseRate(rate.Rate)
+	if err != nil {
+		return m, err
+	}
+
+	return m.Convert(rate.Currency, r)
+}
+
+// ConvertToBase returns the amount of the currency of the rate in the base
+// currency rounded with the mode, amounts already in the base currency are
+// returned as they are
+func ConvertToBase(rate types.ExchangeRate, m types.Money, mode types.RoundingMode) (types.Money, error) {
+	if m.Currency == types.DefaultCurrency {
+		return m, nil
+	}
+
+	if m.Currency != rate.Currency {
+		return m, types.ErrCurrencyMismatch
+	}
+
+	r, err := types.ParseRate(rate.Rate)
+	if err != nil {
+		return m, err
+	}
+
+	return m.ConvertRounded(types.DefaultCurrency, new(big.Rat).Inv(r), mode)
+}
+
+// ConvertProduct converts the price of the product and of its variants
+func ConvertProduct(rate types.ExchangeRate, p *types.Product) error {
+	var err error
+	if p.Price, err = Convert(rate, p.Price); err != nil {
+		return err
+	}
+
+	return ConvertVariants(rate, p.Variants)
+}
+
+// ConvertProducts converts the prices of the products and of their variants
+func ConvertProducts(rate types.ExchangeRate, products []types.Product) error {
+	for i := range products {
+		if err := ConvertProduct(rate, &products[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ConvertVariants converts the price overrides of the variants
+func ConvertVariants(rate types.ExchangeRate, variants []types.ProductVariant) error {
+	for i := range variants {
+		if variants[i].Price == nil {
+			continue
+		}
+
+		price, err := Convert(rate, *variants[i].Price)
+		if err != nil {
+			return err
+		}
+		variants[i].Price = &price
+	}
+
+	return nil
+}
@@ -0,0 +1,193 @@
+package currency
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/akshtrikha/golang-ecomm/services/auth"
+	"github.com/akshtrikha/golang-ecomm/types"
+	"github.com/akshtrikha/golang-ecomm/utils"
+	"github.com/gorilla/mux"
+)
+
+// API Endpoints to list the currencies and to maintain the exchange rates
+
+// maxImportSize limits the size of an exchange rates CSV file
+const maxImportSize = 1 << 20
+
+// Handler to the exchange rate store which will deal
+// with the database regarding currencies
+type Handler struct {
+	store types.ExchangeRateStore
+}
+
+// NewHandler constructor takes ExchangeRateStore as a dependency
+func NewHandler(store types.ExchangeRateStore) *Handler {
+	return &Handler{store: store}
+}
+
+// RegisterRoutes func for currencies
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/currencies", auth.RequireAuth(h.handleGetCurrencies)).Methods("GET")
+	router.HandleFunc("/admin/exchange-rates/import", auth.RequireRole(auth.RoleAdmin, h.handleImportExchangeRates)).Methods("POST")
+	router.HandleFunc("/admin/exchange-rates/{currency:[A-Za-z]{3}}", auth.RequireRole(auth.RoleAdmin, h.handleSetExchangeRate)).Methods("PUT")
+	router.HandleFunc("/admin/exchange-rates/{currency:[A-Za-z]{3}}", auth.RequireRole(auth.RoleAdmin, h.handleDeleteExchangeRate)).Methods("DELETE")
+}
+
+func (h *Handler) handleGetCurrencies(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle GET /currencies endpoint hit")
+
+	rates, err := h.store.GetExchangeRates()
+	if err != nil {
+		log.Println("Error fetching the exchange rates from the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, types.CurrenciesResponse{Base: types.DefaultCurrency, Rates: rates})
+}
+
+func (h *Handler) handleSetExchangeRate(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle PUT /admin/exchange-rates/{currency} endpoint hit")
+
+	// get the json payload
+	var payload types.SetExchangeRatePayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		log.Println("Payload parsing met with an error")
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// validate the json payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.ValidationError("Invalid payload", err))
+		return
+	}
+
+	rate, err := parseExchangeRate(mux.Vars(r)["currency"], payload.Rate)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.SetExchangeRates([]types.ExchangeRate{rate}); err != nil {
+		log.Println("Error saving the exchange rate")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	saved, err := h.store.GetExchangeRate(rate.Currency)
+	if err != nil {
+		log.Println("Error fetching the exchange rate from the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, saved)
+}
+
+// handleImportExchangeRates replaces the rates of the currencies of a CSV
+// file with currency,rate records, the header row is optional.
+// Nothing is saved when any of the records is invalid
+func (h *Handler) handleImportExchangeRates(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle POST /admin/exchange-rates/import endpoint hit")
+
+	rates, err := parseExchangeRatesCSV(http.MaxBytesReader(w, r.Body, maxImportSize))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.store.SetExchangeRates(rates); err != nil {
+		log.Println("Error saving the exchange rates")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	log.Printf("Imported %d exchange rates", len(rates))
+
+	utils.WriteJSON(w, http.StatusOK, map[string]int{"imported": len(rates)})
+}
+
+func (h *Handler) handleDeleteExchangeRate(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle DELETE /admin/exchange-rates/{currency} endpoint hit")
+
+	currency := strings.ToUpper(mux.Vars(r)["currency"])
+	err := h.store.DeleteExchangeRate(currency)
+	if errors.Is(err, ErrRateNotFound) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("exchange rate of %v not found", currency))
+		return
+	}
+	if err != nil {
+		log.Printf("Error deleting the exchange rate of %v, error: %+v", currency, err)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseExchangeRate validates the currency code and the rate,
+// the rate of the base currency is always 1 and can not be set
+func parseExchangeRate(currency string, rate string) (types.ExchangeRate, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if err := utils.Validate.Var(currency, "iso4217"); err != nil {
+		return types.ExchangeRate{}, fmt.Errorf("invalid currency: %v", currency)
+	}
+
+	if currency == types.DefaultCurrency {
+		return types.ExchangeRate{}, fmt.Errorf("the rate of the base currency %v can not be changed", currency)
+	}
+
+	if _, err := types.ParseRate(rate); err != nil {
+		return types.ExchangeRate{}, err
+	}
+
+	return types.ExchangeRate{Currency: currency, Rate: strings.TrimSpace(rate)}, nil
+}
+
+func parseExchangeRatesCSV(body io.Reader) ([]types.ExchangeRate, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+
+	rates := []types.ExchangeRate{}
+	seen := make(map[string]bool)
+
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid csv: %v", err)
+		}
+
+		if line == 1 && strings.EqualFold(record[0], "currency") {
+			continue
+		}
+
+		rate, err := parseExchangeRate(record[0], record[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		if seen[rate.Currency] {
+			return nil, fmt.Errorf("line %d: duplicate currency %v", line, rate.Currency)
+		}
+		seen[rate.Currency] = true
+
+		rates = append(rates, rate)
+	}
+
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("no exchange rates in the file")
+	}
+
+	return rates, nil
+}
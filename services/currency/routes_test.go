@@ -0,0 +1,257 @@
+package currency
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/akshtrikha/golang-ecomm/services/auth"
+	"github.com/akshtrikha/golang-ecomm/types"
+	"github.com/akshtrikha/golang-ecomm/utils"
+	"github.com/gorilla/mux"
+)
+
+// mockExchangeRateStore keeps the exchange rates in memory
+type mockExchangeRateStore struct {
+	rates map[string]string
+	err   error
+}
+
+func (m *mockExchangeRateStore) GetExchangeRates() ([]types.ExchangeRate, error) {
+	rates := []types.ExchangeRate{}
+	for currency, rate := range m.rates {
+		rates = append(rates, types.ExchangeRate{Currency: currency, Rate: rate})
+	}
+
+	return rates, nil
+}
+
+func (m *mockExchangeRateStore) GetExchangeRate(currency string) (*types.ExchangeRate, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	rate, ok := m.rates[currency]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrRateNotFound, currency)
+	}
+
+	return &types.ExchangeRate{Currency: currency, Rate: rate}, nil
+}
+
+func (m *mockExchangeRateStore) SetExchangeRates(rates []types.ExchangeRate) error {
+	for _, rate := range rates {
+		m.rates[rate.Currency] = rate.Rate
+	}
+
+	return nil
+}
+
+func (m *mockExchangeRateStore) DeleteExchangeRate(currency string) error {
+	if _, ok := m.rates[currency]; !ok {
+		return fmt.Errorf("%w: %v", ErrRateNotFound, currency)
+	}
+
+	delete(m.rates, currency)
+	return nil
+}
+
+// TestCurrencyServiceHandlers function to implement testing
+func TestCurrencyServiceHandlers(t *testing.T) {
+	store := &mockExchangeRateStore{rates: map[string]string{}}
+	handler := NewHandler(store)
+
+	adminToken, err := auth.GenerateJWT(1, []string{auth.RoleAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	customerToken, err := auth.GenerateJWT(2, []string{auth.RoleCustomer})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(method, path string, body string, token string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+		router.Use(auth.JWTMiddleware)
+
+		handler.RegisterRoutes(router)
+		router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	t.Run("Should not allow customers to set exchange rates", func(t *testing.T) {
+		rr := serve(http.MethodPut, "/admin/exchange-rates/EUR", `{"rate":"0.9215"}`, customerToken)
+
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("Expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	t.Run("Should fail if the rate is invalid", func(t *testing.T) {
+		for _, rate := range []string{"0", "-1", "abc", "1/3", "0.123456789"} {
+			rr := serve(http.MethodPut, "/admin/exchange-rates/EUR", `{"rate":"`+rate+`"}`, adminToken)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("Expected status code %d for rate %s, got %d", http.StatusBadRequest, rate, rr.Code)
+			}
+		}
+	})
+
+	t.Run("Should fail to set the rate of the base currency", func(t *testing.T) {
+		rr := serve(http.MethodPut, "/admin/exchange-rates/usd", `{"rate":"2"}`, adminToken)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("Should set the exchange rate", func(t *testing.T) {
+		rr := serve(http.MethodPut, "/admin/exchange-rates/eur", `{"rate":"0.9215"}`, adminToken)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		if store.rates["EUR"] != "0.9215" {
+			t.Errorf("Expected the EUR rate 0.9215, got %+v", store.rates)
+		}
+	})
+
+	t.Run("Should not import a file with an invalid record", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/admin/exchange-rates/import", "currency,rate\nGBP,0.79\nXYZ1,2\n", adminToken)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+
+		if _, ok := store.rates["GBP"]; ok {
+			t.Errorf("Expected no rate to be imported, got %+v", store.rates)
+		}
+	})
+
+	t.Run("Should import the exchange rates", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/admin/exchange-rates/import", "currency,rate\nGBP,0.79\njpy, 151.5\n", adminToken)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		if store.rates["GBP"] != "0.79" || store.rates["JPY"] != "151.5" {
+			t.Errorf("Expected the GBP and JPY rates, got %+v", store.rates)
+		}
+	})
+
+	t.Run("Should list the currencies", func(t *testing.T) {
+		rr := serve(http.MethodGet, "/currencies", "", customerToken)
+
+		var response types.CurrenciesResponse
+		json.NewDecoder(rr.Body).Decode(&response)
+
+		if response.Base != types.DefaultCurrency || len(response.Rates) != 3 {
+			t.Errorf("Expected the base currency and 3 rates, got %+v", response)
+		}
+	})
+
+	t.Run("Should fail to delete an unknown exchange rate", func(t *testing.T) {
+		rr := serve(http.MethodDelete, "/admin/exchange-rates/CHF", "", adminToken)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
+
+// TestCurrencyMiddleware function to test the selection of the currency of a request
+func TestCurrencyMiddleware(t *testing.T) {
+	store := &mockExchangeRateStore{rates: map[string]string{"EUR": "0.9215", "JPY": "151.5"}}
+	handler := NewHandler(store)
+
+	serve := func(path string, header string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if header != "" {
+			req.Header.Set(Header, header)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+		router.Use(handler.Middleware)
+
+		// prices a product of 19.99 in the base currency
+		router.HandleFunc("/price", func(w http.ResponseWriter, r *http.Request) {
+			products := []types.Product{{ID: 1, Price: types.NewMoney(1999, types.DefaultCurrency)}}
+			if err := ConvertProducts(RateFromContext(r.Context()), products); err != nil {
+				utils.WriteError(w, http.StatusInternalServerError, err)
+				return
+			}
+
+			utils.WriteJSON(w, http.StatusOK, products[0].Price)
+		})
+		router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	price := func(rr *httptest.ResponseRecorder) types.Money {
+		var m types.Money
+		if err := json.NewDecoder(rr.Body).Decode(&m); err != nil {
+			t.Fatal(err)
+		}
+
+		return m
+	}
+
+	t.Run("Should price in the base currency by default", func(t *testing.T) {
+		if m := price(serve("/price", "")); m != types.NewMoney(1999, types.DefaultCurrency) {
+			t.Errorf("Expected 19.99 USD, got %v", m)
+		}
+	})
+
+	t.Run("Should price in the currency of the query", func(t *testing.T) {
+		if m := price(serve("/price?currency=eur", "JPY")); m != types.NewMoney(1842, "EUR") {
+			t.Errorf("Expected 18.42 EUR, got %v", m)
+		}
+	})
+
+	t.Run("Should price in the currency of the header", func(t *testing.T) {
+		if m := price(serve("/price", "JPY")); m != types.NewMoney(3028, "JPY") {
+			t.Errorf("Expected 3028 JPY, got %v", m)
+		}
+	})
+
+	t.Run("Should fail for a currency without a rate", func(t *testing.T) {
+		rr := serve("/price?currency=CHF", "")
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("Should fail with an internal error when the rate can not be read", func(t *testing.T) {
+		store.err = errors.New("connection refused")
+		defer func() { store.err = nil }()
+
+		rr := serve("/price?currency=EUR", "")
+
+		if rr.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status code %d, got %d", http.StatusInternalServerError, rr.Code)
+		}
+	})
+}
@@ -0,0 +1,128 @@
+package currency
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// Store struct to hold the database object
+// This will be used to handle the database queries
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore function to return a reference to the Store struct
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// ErrRateNotFound is returned when a currency does not have an exchange rate
+var ErrRateNotFound = errors.New("exchange rate not found")
+
+// GetExchangeRates function to get the rates of every currency
+func (s *Store) GetExchangeRates() ([]types.ExchangeRate, error) {
+	rows, err := s.db.Query("SELECT currency, rate, updatedAt FROM exchange_rates ORDER BY currency")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := []types.ExchangeRate{}
+	for rows.Next() {
+		rate, err := scanRowIntoExchangeRate(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		rates = append(rates, *rate)
+	}
+
+	return rates, rows.Err()
+}
+
+// GetExchangeRate function to find the rate of the currency
+func (s *Store) GetExchangeRate(currency string) (*types.ExchangeRate, error) {
+	rows, err := s.db.Query("SELECT currency, rate, updatedAt FROM exchange_rates WHERE currency = ?", currency)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rate := new(types.ExchangeRate)
+	for rows.Next() {
+		rate, err = scanRowIntoExchangeRate(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if rate.Currency == "" {
+		return nil, fmt.Errorf("%w: %v", ErrRateNotFound, currency)
+	}
+
+	return rate, nil
+}
+
+// SetExchangeRates function to add or replace the rates of the currencies
+// all of them are saved in a single transaction
+func (s *Store) SetExchangeRates(rates []types.ExchangeRate) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	for _, rate := range rates {
+		_, err := tx.Exec(
+			"INSERT INTO exchange_rates (currency, rate) VALUES (?, ?) ON DUPLICATE KEY UPDATE rate = VALUES(rate)",
+			rate.Currency, rate.Rate,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DeleteExchangeRate function to remove the rate of the currency
+func (s *Store) DeleteExchangeRate(currency string) error {
+	result, err := s.db.Exec("DELETE FROM exchange_rates WHERE currency = ?", currency)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w: %v", ErrRateNotFound, currency)
+	}
+
+	return nil
+}
+
+func scanRowIntoExchangeRate(rows *sql.Rows) (*types.ExchangeRate, error) {
+	rate := new(types.ExchangeRate)
+
+	err := rows.Scan(
+		&rate.Currency,
+		&rate.Rate,
+		&rate.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// the column keeps 8 decimal places, "0.92150000" reads as "0.9215"
+	rate.Rate = types.TrimDecimal(rate.Rate)
+
+	return rate, nil
+}
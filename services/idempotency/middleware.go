@@ -0,0 +1,211 @@
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/akshtrikha/golang-ecomm/config"
+	"github.com/akshtrikha/golang-ecomm/services/auth"
+	"github.com/akshtrikha/golang-ecomm/services/cart"
+	"github.com/akshtrikha/golang-ecomm/services/currency"
+	"github.com/akshtrikha/golang-ecomm/types"
+	"github.com/akshtrikha/golang-ecomm/utils"
+)
+
+// Header holds the key the client identifies a request with, the retries
+// of the request are sent with the same key and get the same response
+const Header = "Idempotency-Key"
+
+// ReplayedHeader is set on the responses replayed for a repeated key
+const ReplayedHeader = "Idempotent-Replayed"
+
+// maxKeyLength is the length of the longest key accepted
+const maxKeyLength = 255
+
+// Handler to the idempotency key store which will deal with
+// the database regarding the keys of the requests
+type Handler struct {
+	store types.IdempotencyKeyStore
+	ttl   time.Duration
+}
+
+// NewHandler constructor takes IdempotencyKeyStore as a dependency
+func NewHandler(store types.IdempotencyKeyStore) *Handler {
+	return &Handler{
+		store: store,
+		ttl:   time.Second * time.Duration(config.Envs.IdempotencyKeyTTLInSeconds),
+	}
+}
+
+// Middleware honours the Idempotency-Key header of the POST requests. The first
+// request of a key is handled and its response is saved, the requests repeating
+// the key get the saved response until the key expires. A key reused with a
+// different request is rejected with 422, and with 409 while the first request
+// is still being handled. The server errors and the responses that must not be
+// stored, like the ones holding tokens, are not saved so they can be retried.
+// It has to run after the jwt middleware as the keys are scoped to the users,
+// the keys of the anonymous requests are scoped to the request they come with
+func (h *Handler) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(Header)
+		if r.Method != http.MethodPost || key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(key) > maxKeyLength {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("%s must be at most %d characters", Header, maxKeyLength))
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		fingerprint := Fingerprint(r, body)
+		userID, ok := auth.UserIDFromContext(r.Context())
+		if !ok {
+			key = anonymousKey(r, key, fingerprint)
+		}
+
+		stored, created, err := h.store.ReserveIdempotencyKey(types.IdempotencyKey{
+			UserID:      userID,
+			Key:         key,
+			Fingerprint: fingerprint,
+			ExpiresAt:   time.Now().Add(h.ttl),
+		})
+		if err != nil {
+			log.Printf("Error reserving the idempotency key, error: %+v", err)
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		if !created {
+			replay(w, r, body, stored)
+			return
+		}
+
+		rec := &recorder{ResponseWriter: w}
+		saved := false
+
+		// release the key when the response is not saved, like when
+		// the handler panics, so the request can be retried
+		defer func() {
+			if saved {
+				return
+			}
+
+			if err := h.store.DeleteIdempotencyKey(stored.ID); err != nil {
+				log.Printf("Error releasing the idempotency key %v, error: %+v", stored.ID, err)
+			}
+		}()
+
+		next.ServeHTTP(rec, r)
+
+		status := rec.statusCode()
+		if status >= http.StatusInternalServerError || strings.Contains(rec.Header().Get("Cache-Control"), "no-store") {
+			return
+		}
+
+		if err := h.store.SaveIdempotencyResponse(stored.ID, status, rec.Header().Get("Content-Type"), rec.body.Bytes()); err != nil {
+			log.Printf("Error saving the response of the idempotency key %v, error: %+v", stored.ID, err)
+			return
+		}
+		saved = true
+	})
+}
+
+// PurgeEvery removes the expired keys on the interval until the server stops,
+// the expired keys are also replaced when they are used again
+func (h *Handler) PurgeEvery(interval time.Duration) {
+	for range time.Tick(interval) {
+		if _, err := h.store.DeleteExpiredIdempotencyKeys(); err != nil {
+			log.Printf("Error removing the expired idempotency keys, error: %+v", err)
+		}
+	}
+}
+
+// Fingerprint identifies the request a key is used with by its method, its uri,
+// the currency of its prices and its body
+func Fingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	hash.Write([]byte(strings.ToUpper(strings.TrimSpace(r.Header.Get(currency.Header))) + "\n"))
+	hash.Write(body)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// anonymousKey scopes the key of an anonymous request to the request and to
+// its cart token. The anonymous clients all share the user 0, a client only
+// gets the response of the very request it sent with the key
+func anonymousKey(r *http.Request, key string, fingerprint string) string {
+	hash := sha256.New()
+	hash.Write([]byte(key + "\n" + fingerprint + "\n" + r.Header.Get(cart.TokenHeader)))
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// replay writes the saved response of the key when the request is the one the key was used with
+func replay(w http.ResponseWriter, r *http.Request, body []byte, stored *types.IdempotencyKey) {
+	if stored.Fingerprint != Fingerprint(r, body) {
+		utils.WriteError(w, http.StatusUnprocessableEntity, fmt.Errorf("%s was already used with a different request", Header))
+		return
+	}
+
+	if stored.ResponseStatus == 0 {
+		utils.WriteError(w, http.StatusConflict, fmt.Errorf("the request of the %s is still being processed", Header))
+		return
+	}
+
+	log.Printf("Replaying the response of the idempotency key %v", stored.ID)
+
+	if stored.ContentType != "" {
+		w.Header().Set("Content-Type", stored.ContentType)
+	}
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(stored.ResponseStatus)
+	w.Write(stored.ResponseBody)
+}
+
+// recorder keeps a copy of the response written to the client
+type recorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(b)
+
+	return rec.ResponseWriter.Write(b)
+}
+
+// statusCode returns the status of the response, 200 when nothing was written
+func (rec *recorder) statusCode() int {
+	if rec.status == 0 {
+		return http.StatusOK
+	}
+
+	return rec.status
+}
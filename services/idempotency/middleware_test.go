@@ -0,0 +1,273 @@
+package idempotency
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akshtrikha/golang-ecomm/services/auth"
+	"github.com/akshtrikha/golang-ecomm/services/cart"
+	"github.com/akshtrikha/golang-ecomm/services/currency"
+	"github.com/akshtrikha/golang-ecomm/types"
+	"github.com/akshtrikha/golang-ecomm/utils"
+	"github.com/gorilla/mux"
+)
+
+// mockIdempotencyKeyStore keeps the keys in memory
+type mockIdempotencyKeyStore struct {
+	keys map[string]types.IdempotencyKey
+}
+
+func (m *mockIdempotencyKeyStore) ReserveIdempotencyKey(k types.IdempotencyKey) (*types.IdempotencyKey, bool, error) {
+	id := fmt.Sprintf("%d/%s", k.UserID, k.Key)
+
+	if stored, ok := m.keys[id]; ok && stored.ExpiresAt.After(time.Now()) {
+		return &stored, false, nil
+	}
+
+	k.ID = len(m.keys) + 1
+	m.keys[id] = k
+
+	return &k, true, nil
+}
+
+func (m *mockIdempotencyKeyStore) SaveIdempotencyResponse(id int, status int, contentType string, body []byte) error {
+	for i, k := range m.keys {
+		if k.ID == id {
+			k.ResponseStatus, k.ContentType, k.ResponseBody = status, contentType, body
+			m.keys[i] = k
+		}
+	}
+
+	return nil
+}
+
+func (m *mockIdempotencyKeyStore) DeleteIdempotencyKey(id int) error {
+	for i, k := range m.keys {
+		if k.ID == id {
+			delete(m.keys, i)
+		}
+	}
+
+	return nil
+}
+
+func (m *mockIdempotencyKeyStore) DeleteExpiredIdempotencyKeys() (int64, error) {
+	return 0, nil
+}
+
+// TestIdempotencyMiddleware function to implement testing
+func TestIdempotencyMiddleware(t *testing.T) {
+	store := &mockIdempotencyKeyStore{keys: map[string]types.IdempotencyKey{}}
+	handler := NewHandler(store)
+
+	// the handler creates a resource per request it handles,
+	// the requests for the broken path fail
+	created := 0
+	create := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) == `{"broken":true}` {
+			utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("database is down"))
+			return
+		}
+
+		created++
+		utils.WriteJSON(w, http.StatusCreated, map[string]any{"id": created, "body": string(body)})
+	}
+
+	customerToken, err := auth.GenerateJWT(2, []string{auth.RoleCustomer})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherToken, err := auth.GenerateJWT(3, []string{auth.RoleCustomer})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the tokens are issued for every request and must not be stored
+	issued := 0
+	issue := func(w http.ResponseWriter, r *http.Request) {
+		issued++
+		w.Header().Set("Cache-Control", "no-store")
+		utils.WriteJSON(w, http.StatusOK, map[string]string{"token": fmt.Sprintf("token-%d", issued)})
+	}
+
+	newRequest := func(method, path, body, key, token string) *http.Request {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if key != "" {
+			req.Header.Set(Header, key)
+		}
+
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		return req
+	}
+
+	handle := func(req *http.Request) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+		router.Use(auth.JWTMiddleware)
+		router.Use(handler.Middleware)
+
+		router.HandleFunc("/products", create).Methods("POST", "PUT")
+		router.HandleFunc("/register", create).Methods("POST")
+		router.HandleFunc("/refresh", issue).Methods("POST")
+		router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	serve := func(method, body, key, token string) *httptest.ResponseRecorder {
+		return handle(newRequest(method, "/products", body, key, token))
+	}
+
+	t.Run("Should handle the requests without a key every time", func(t *testing.T) {
+		before := created
+		serve(http.MethodPost, `{"name":"a"}`, "", customerToken)
+		serve(http.MethodPost, `{"name":"a"}`, "", customerToken)
+		serve(http.MethodPut, `{"name":"a"}`, "key-put", customerToken)
+		serve(http.MethodPut, `{"name":"a"}`, "key-put", customerToken)
+
+		if created-before != 4 || len(store.keys) != 0 {
+			t.Errorf("Expected 4 requests handled without a key saved, got %d and %d keys", created-before, len(store.keys))
+		}
+	})
+
+	t.Run("Should replay the response of a repeated key", func(t *testing.T) {
+		first := serve(http.MethodPost, `{"name":"b"}`, "key-1", customerToken)
+		if first.Code != http.StatusCreated {
+			t.Fatalf("Expected status code %d, got %d", http.StatusCreated, first.Code)
+		}
+
+		before := created
+		rr := serve(http.MethodPost, `{"name":"b"}`, "key-1", customerToken)
+
+		if rr.Code != http.StatusCreated {
+			t.Errorf("Expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		if created != before || rr.Body.String() != first.Body.String() || rr.Header().Get(ReplayedHeader) != "true" || rr.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("Expected the first response to be replayed, got %q with headers %v", rr.Body.String(), rr.Header())
+		}
+	})
+
+	t.Run("Should fail if the key is reused with a different request", func(t *testing.T) {
+		rr := serve(http.MethodPost, `{"name":"c"}`, "key-1", customerToken)
+
+		if rr.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Expected status code %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+		}
+	})
+
+	t.Run("Should scope the keys to the users", func(t *testing.T) {
+		before := created
+		if rr := serve(http.MethodPost, `{"name":"b"}`, "key-1", otherToken); rr.Code != http.StatusCreated || created != before+1 {
+			t.Errorf("Expected the request of the other user to be handled, got %d", rr.Code)
+		}
+
+		// the anonymous clients can't be told apart by their user,
+		// the same key sent with other requests does not collide
+		if rr := serve(http.MethodPost, `{"name":"b"}`, "key-1", ""); rr.Code != http.StatusCreated || created != before+2 {
+			t.Errorf("Expected the anonymous request to be handled, got %d", rr.Code)
+		}
+
+		if rr := serve(http.MethodPost, `{"name":"c"}`, "key-1", ""); rr.Code != http.StatusCreated || created != before+3 {
+			t.Errorf("Expected the other anonymous request to be handled, got %d", rr.Code)
+		}
+
+		req := newRequest(http.MethodPost, "/products", `{"name":"b"}`, "key-1", "")
+		req.Header.Set(cart.TokenHeader, "guest-cart")
+		if rr := handle(req); rr.Code != http.StatusCreated || created != before+4 {
+			t.Errorf("Expected the request of the other cart to be handled, got %d", rr.Code)
+		}
+	})
+
+	t.Run("Should replay the retries of the anonymous requests", func(t *testing.T) {
+		first := handle(newRequest(http.MethodPost, "/register", `{"email":"a@b.com"}`, "key-6", ""))
+		if first.Code != http.StatusCreated {
+			t.Fatalf("Expected status code %d, got %d", http.StatusCreated, first.Code)
+		}
+
+		before := created
+		rr := handle(newRequest(http.MethodPost, "/register", `{"email":"a@b.com"}`, "key-6", ""))
+
+		if created != before || rr.Body.String() != first.Body.String() || rr.Header().Get(ReplayedHeader) != "true" {
+			t.Errorf("Expected the first response to be replayed, got %q with headers %v", rr.Body.String(), rr.Header())
+		}
+	})
+
+	t.Run("Should tell the requests apart by their currency", func(t *testing.T) {
+		req := newRequest(http.MethodPost, "/products", `{"name":"f"}`, "key-4", customerToken)
+		if rr := handle(req); rr.Code != http.StatusCreated {
+			t.Fatalf("Expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		req = newRequest(http.MethodPost, "/products", `{"name":"f"}`, "key-4", customerToken)
+		req.Header.Set(currency.Header, "EUR")
+		if rr := handle(req); rr.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Expected status code %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+		}
+	})
+
+	t.Run("Should not save the responses holding tokens", func(t *testing.T) {
+		first := handle(newRequest(http.MethodPost, "/refresh", `{"refreshToken":"a"}`, "key-5", customerToken))
+		rr := handle(newRequest(http.MethodPost, "/refresh", `{"refreshToken":"a"}`, "key-5", customerToken))
+
+		if issued != 2 || rr.Body.String() == first.Body.String() || rr.Header().Get(ReplayedHeader) != "" {
+			t.Errorf("Expected both requests to be handled, got %d issued", issued)
+		}
+
+		if _, ok := store.keys["2/key-5"]; ok {
+			t.Errorf("Expected the key to be released")
+		}
+	})
+
+	t.Run("Should fail while the first request is being processed", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/products", nil)
+		store.keys["2/key-2"] = types.IdempotencyKey{ID: 100, UserID: 2, Key: "key-2", Fingerprint: Fingerprint(req, []byte(`{"name":"d"}`)), ExpiresAt: time.Now().Add(time.Hour)}
+
+		if rr := serve(http.MethodPost, `{"name":"d"}`, "key-2", customerToken); rr.Code != http.StatusConflict {
+			t.Errorf("Expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+	})
+
+	t.Run("Should handle the request again once the key expired", func(t *testing.T) {
+		k := store.keys["2/key-1"]
+		k.ExpiresAt = time.Now().Add(-time.Second)
+		store.keys["2/key-1"] = k
+
+		before := created
+		if rr := serve(http.MethodPost, `{"name":"c"}`, "key-1", customerToken); rr.Code != http.StatusCreated || created != before+1 {
+			t.Errorf("Expected the request to be handled again, got %d", rr.Code)
+		}
+	})
+
+	t.Run("Should not save the server errors", func(t *testing.T) {
+		if rr := serve(http.MethodPost, `{"broken":true}`, "key-3", customerToken); rr.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status code %d, got %d", http.StatusInternalServerError, rr.Code)
+		}
+
+		if _, ok := store.keys["2/key-3"]; ok {
+			t.Errorf("Expected the key to be released")
+		}
+	})
+
+	t.Run("Should fail if the key is too long", func(t *testing.T) {
+		rr := serve(http.MethodPost, `{"name":"e"}`, strings.Repeat("k", maxKeyLength+1), customerToken)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+}
@@ -0,0 +1,112 @@
+package idempotency
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// keyColumns is the list of columns scanned by scanRowIntoKey
+const keyColumns = "id, userId, idempotencyKey, fingerprint, responseStatus, contentType, responseBody, createdAt, expiresAt"
+
+// Store struct to hold the database object
+// This will be used to handle the database queries
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore function to return a reference to the Store struct
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// ReserveIdempotencyKey function to record the first use of a key by the user.
+// An expired key is reserved again, a key still in use is not: the stored key
+// is returned with false in that case
+func (s *Store) ReserveIdempotencyKey(k types.IdempotencyKey) (*types.IdempotencyKey, bool, error) {
+	if _, err := s.db.Exec("DELETE FROM idempotency_keys WHERE userId = ? AND idempotencyKey = ? AND expiresAt <= ?", k.UserID, k.Key, time.Now()); err != nil {
+		return nil, false, err
+	}
+
+	result, err := s.db.Exec(
+		"INSERT IGNORE INTO idempotency_keys (userId, idempotencyKey, fingerprint, expiresAt) VALUES (?, ?, ?, ?)",
+		k.UserID, k.Key, k.Fingerprint, k.ExpiresAt,
+	)
+	if err != nil {
+		return nil, false, err
+	}
+
+	created, err := result.RowsAffected()
+	if err != nil {
+		return nil, false, err
+	}
+
+	rows, err := s.db.Query("SELECT "+keyColumns+" FROM idempotency_keys WHERE userId = ? AND idempotencyKey = ?", k.UserID, k.Key)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	stored := new(types.IdempotencyKey)
+	for rows.Next() {
+		stored, err = scanRowIntoKey(rows)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	if stored.ID == 0 {
+		return nil, false, fmt.Errorf("idempotency key %q not found", k.Key)
+	}
+
+	return stored, created == 1, rows.Err()
+}
+
+// SaveIdempotencyResponse function to record the response given to the request of the key
+func (s *Store) SaveIdempotencyResponse(id int, status int, contentType string, body []byte) error {
+	_, err := s.db.Exec("UPDATE idempotency_keys SET responseStatus = ?, contentType = ?, responseBody = ? WHERE id = ?", status, contentType, body, id)
+
+	return err
+}
+
+// DeleteIdempotencyKey function to release the key so the request can be retried
+func (s *Store) DeleteIdempotencyKey(id int) error {
+	_, err := s.db.Exec("DELETE FROM idempotency_keys WHERE id = ?", id)
+
+	return err
+}
+
+// DeleteExpiredIdempotencyKeys function to remove the expired keys,
+// the number of keys removed is returned
+func (s *Store) DeleteExpiredIdempotencyKeys() (int64, error) {
+	result, err := s.db.Exec("DELETE FROM idempotency_keys WHERE expiresAt <= ?", time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func scanRowIntoKey(rows *sql.Rows) (*types.IdempotencyKey, error) {
+	k := new(types.IdempotencyKey)
+
+	err := rows.Scan(
+		&k.ID,
+		&k.UserID,
+		&k.Key,
+		&k.Fingerprint,
+		&k.ResponseStatus,
+		&k.ContentType,
+		&k.ResponseBody,
+		&k.CreatedAt,
+		&k.ExpiresAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
@@ -0,0 +1,346 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/akshtrikha/golang-ecomm/config"
+	"github.com/akshtrikha/golang-ecomm/services/auth"
+	"github.com/akshtrikha/golang-ecomm/services/currency"
+	"github.com/akshtrikha/golang-ecomm/services/promotion"
+	"github.com/akshtrikha/golang-ecomm/services/shipping"
+	"github.com/akshtrikha/golang-ecomm/services/tax"
+	"github.com/akshtrikha/golang-ecomm/types"
+	"github.com/akshtrikha/golang-ecomm/utils"
+	"github.com/gorilla/mux"
+)
+
+// API Endpoints to place an order, get an order and list the user's orders
+
+// Handler to the order store which will deal
+// with the database regarding orders
+type Handler struct {
+	store        types.OrderStore
+	addressStore types.AddressStore
+}
+
+// NewHandler constructor takes OrderStore and AddressStore as dependencies
+// AddressStore is used to find the addresses of the orders in the address book
+func NewHandler(store types.OrderStore, addressStore types.AddressStore) *Handler {
+	return &Handler{store: store, addressStore: addressStore}
+}
+
+// RegisterRoutes func for orders
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/orders", auth.RequireAuth(h.handlePlaceOrder)).Methods("POST")
+	router.HandleFunc("/orders", auth.RequireAuth(h.handleListOrders)).Methods("GET")
+	router.HandleFunc("/orders/{id:[0-9]+}", auth.RequireAuth(h.handleGetOrder)).Methods("GET")
+	router.HandleFunc("/orders/{id:[0-9]+}/status", auth.RequireAuth(h.handleUpdateOrderStatus)).Methods("PATCH")
+	router.HandleFunc("/orders/{id:[0-9]+}/history", auth.RequireAuth(h.handleGetOrderStatusHistory)).Methods("GET")
+	router.HandleFunc("/admin/orders", auth.RequireRole(auth.RoleAdmin, h.handleListAllOrders)).Methods("GET")
+}
+
+func (h *Handler) handlePlaceOrder(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle POST /orders endpoint hit")
+
+	userID, _ := auth.UserIDFromContext(r.Context())
+
+	// get the json payload
+	var payload types.PlaceOrderPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		log.Println("Payload parsing met with an error")
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// country codes are case insensitive
+	payload.Country = strings.ToUpper(strings.TrimSpace(payload.Country))
+
+	// validate the json payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.ValidationError("Invalid payload", err))
+		return
+	}
+
+	shippingAddress, billingAddress, status, err := h.resolveAddresses(userID, payload)
+	if err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	// the destination of an address of the address book replaces the free text one
+	address, country, region := payload.Address, payload.Country, tax.NormalizeRegion(payload.Region)
+	if shippingAddress != nil {
+		address, country, region = formatAddress(*shippingAddress), shippingAddress.Country, shippingAddress.Region
+	}
+
+	// checkout the items in the selected currency with the coupons and the
+	// taxes of the destination, this verifies and decrements the stock
+	o, err := h.store.PlaceOrder(types.Checkout{
+		UserID:       userID,
+		Address:      address,
+		Country:      country,
+		Region:       region,
+		Items:        payload.Items,
+		Rate:         currency.RateFromContext(r.Context()),
+		Coupons:      promotion.NormalizeCodes(payload.Coupons),
+		TaxInclusive: config.Envs.PricesIncludeTax,
+
+		ShippingAddress:  shippingAddress,
+		BillingAddress:   billingAddress,
+		ShippingMethodID: payload.ShippingMethodID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInsufficientStock):
+			utils.WriteError(w, http.StatusConflict, err)
+		case errors.Is(err, ErrProductNotFound), errors.Is(err, ErrVariantRequired), errors.Is(err, promotion.ErrNotApplicable),
+			errors.Is(err, shipping.ErrMethodRequired), errors.Is(err, shipping.ErrMethodUnavailable):
+			utils.WriteError(w, http.StatusBadRequest, err)
+		default:
+			log.Println("Error placing the order")
+			utils.WriteError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	log.Printf("Order placed %v", o.ID)
+
+	utils.WriteJSON(w, http.StatusCreated, types.PlaceOrderResponse{ID: o.ID, Discount: o.Discount, Tax: o.Tax, Shipping: o.Shipping, Total: o.Total})
+}
+
+func (h *Handler) handleListOrders(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle GET /orders endpoint hit")
+
+	userID, _ := auth.UserIDFromContext(r.Context())
+
+	orders, err := h.store.GetOrdersByUserID(userID)
+	if err != nil {
+		log.Println("Error fetching the orders from the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, orders)
+}
+
+func (h *Handler) handleGetOrder(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle GET /orders/{id} endpoint hit")
+
+	p, _ := auth.PrincipalFromContext(r.Context())
+
+	orderID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid order id"))
+		return
+	}
+
+	o, err := h.store.GetOrderByID(orderID)
+	if errors.Is(err, ErrOrderNotFound) || (err == nil && !canAccessOrder(p, o)) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("order with id: %v not found", orderID))
+		return
+	}
+	if err != nil {
+		log.Printf("Error finding the order %v, error: %+v", orderID, err)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	o.Items, err = h.store.GetOrderItems(o.ID)
+	if err != nil {
+		log.Println("Error fetching the order items from the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	o.Promotions, err = h.store.GetOrderPromotions(o.ID)
+	if err != nil {
+		log.Println("Error fetching the order promotions from the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, o)
+}
+
+func (h *Handler) handleUpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle PATCH /orders/{id}/status endpoint hit")
+
+	p, _ := auth.PrincipalFromContext(r.Context())
+
+	orderID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid order id"))
+		return
+	}
+
+	// get the json payload
+	var payload types.UpdateOrderStatusPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		log.Println("Payload parsing met with an error")
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// validate the json payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.ValidationError("Invalid payload", err))
+		return
+	}
+
+	o, err := h.store.GetOrderByID(orderID)
+	if errors.Is(err, ErrOrderNotFound) || (err == nil && !canAccessOrder(p, o)) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("order with id: %v not found", orderID))
+		return
+	}
+	if err != nil {
+		log.Printf("Error finding the order %v, error: %+v", orderID, err)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// customers can only cancel their own orders awaiting payment,
+	// every other transition is an admin operation
+	if !p.IsAdmin() && (payload.Status != types.OrderStatusCancelled || !IsAwaitingPayment(o.Status)) {
+		utils.WriteError(w, http.StatusForbidden, fmt.Errorf("You are not allowed to perform this action"))
+		return
+	}
+
+	o, err = h.store.UpdateOrderStatus(orderID, payload.Status, p.UserID, payload.Note)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrOrderNotFound):
+			utils.WriteError(w, http.StatusNotFound, fmt.Errorf("order with id: %v not found", orderID))
+		case errors.Is(err, ErrUnknownStatus):
+			utils.WriteError(w, http.StatusBadRequest, err)
+		case errors.Is(err, ErrInvalidTransition), errors.Is(err, ErrActivePayment):
+			utils.WriteError(w, http.StatusConflict, err)
+		default:
+			log.Println("Error updating the order status")
+			utils.WriteError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	log.Printf("Order %v moved to %v", o.ID, o.Status)
+
+	utils.WriteJSON(w, http.StatusOK, o)
+}
+
+func (h *Handler) handleGetOrderStatusHistory(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle GET /orders/{id}/history endpoint hit")
+
+	p, _ := auth.PrincipalFromContext(r.Context())
+
+	orderID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid order id"))
+		return
+	}
+
+	o, err := h.store.GetOrderByID(orderID)
+	if errors.Is(err, ErrOrderNotFound) || (err == nil && !canAccessOrder(p, o)) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("order with id: %v not found", orderID))
+		return
+	}
+	if err != nil {
+		log.Printf("Error finding the order %v, error: %+v", orderID, err)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	history, err := h.store.GetOrderStatusHistory(orderID)
+	if err != nil {
+		log.Println("Error fetching the order status history from the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, history)
+}
+
+func (h *Handler) handleListAllOrders(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle GET /admin/orders endpoint hit")
+
+	orders, err := h.store.GetAllOrders()
+	if err != nil {
+		log.Println("Error fetching the orders from the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, orders)
+}
+
+// resolveAddresses finds the addresses the order is shipped and billed to in the
+// address book of the user, the addresses of the other users are not found.
+// The shipping address is nil for the orders with a free text address.
+// The returned status code is meant to be used when an error is returned.
+func (h *Handler) resolveAddresses(userID int, payload types.PlaceOrderPayload) (*types.PostalAddress, *types.PostalAddress, int, error) {
+	addresses, err := h.addressStore.GetAddressesByUserID(userID)
+	if err != nil {
+		log.Println("Error fetching the addresses from the database")
+		return nil, nil, http.StatusInternalServerError, err
+	}
+
+	find := func(match func(types.Address) bool) *types.PostalAddress {
+		for i := range addresses {
+			if match(addresses[i]) {
+				return &addresses[i].PostalAddress
+			}
+		}
+		return nil
+	}
+
+	var shippingAddress *types.PostalAddress
+	switch {
+	case payload.ShippingAddressID != nil:
+		shippingAddress = find(func(a types.Address) bool { return a.ID == *payload.ShippingAddressID })
+		if shippingAddress == nil {
+			return nil, nil, http.StatusNotFound, fmt.Errorf("address with id: %v not found", *payload.ShippingAddressID)
+		}
+	case payload.Address != "":
+		if payload.Country == "" {
+			return nil, nil, http.StatusBadRequest, fmt.Errorf("the country of the address is required")
+		}
+	default:
+		shippingAddress = find(func(a types.Address) bool { return a.DefaultShipping })
+		if shippingAddress == nil {
+			return nil, nil, http.StatusBadRequest, fmt.Errorf("a shipping address is required")
+		}
+	}
+
+	var billingAddress *types.PostalAddress
+	if payload.BillingAddressID != nil {
+		billingAddress = find(func(a types.Address) bool { return a.ID == *payload.BillingAddressID })
+		if billingAddress == nil {
+			return nil, nil, http.StatusNotFound, fmt.Errorf("address with id: %v not found", *payload.BillingAddressID)
+		}
+	} else if billingAddress = find(func(a types.Address) bool { return a.DefaultBilling }); billingAddress == nil {
+		billingAddress = shippingAddress
+	}
+
+	return shippingAddress, billingAddress, http.StatusOK, nil
+}
+
+// formatAddress writes the address on a single line for the free text address of the order
+func formatAddress(a types.PostalAddress) string {
+	var parts []string
+	for _, part := range []string{a.Name, a.Line1, a.Line2, a.PostalCode + " " + a.City, a.Region, a.Country} {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// canAccessOrder reports whether the principal can see the order
+// customers can only see their own orders while admins can see every order
+func canAccessOrder(p auth.Principal, o *types.Order) bool {
+	return p.IsAdmin() || o.UserID == p.UserID
+}
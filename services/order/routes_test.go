@@ -0,0 +1,478 @@
+package order
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/akshtrikha/golang-ecomm/services/auth"
+	"github.com/akshtrikha/golang-ecomm/services/promotion"
+	"github.com/akshtrikha/golang-ecomm/services/shipping"
+	"github.com/akshtrikha/golang-ecomm/types"
+	"github.com/gorilla/mux"
+)
+
+// mockOrderStore records the last checkout, the checkouts fail with err when it is set
+type mockOrderStore struct {
+	checkout types.Checkout
+	err      error
+}
+
+func (m *mockOrderStore) PlaceOrder(checkout types.Checkout) (*types.Order, error) {
+	m.checkout = checkout
+
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	return &types.Order{
+		ID:       1,
+		UserID:   checkout.UserID,
+		Total:    types.NewMoney(2000, checkout.Rate.Currency),
+		Currency: checkout.Rate.Currency,
+		Status:   types.OrderStatusPending,
+		Address:  checkout.Address,
+		Country:  checkout.Country,
+		Region:   checkout.Region,
+	}, nil
+}
+
+func (m *mockOrderStore) GetOrderByID(id int) (*types.Order, error) {
+	if id == 2 {
+		return nil, fmt.Errorf("connection refused")
+	}
+
+	if id != 1 {
+		return nil, fmt.Errorf("%w: %v", ErrOrderNotFound, id)
+	}
+
+	return &types.Order{ID: 1, UserID: 1, Status: "pending"}, nil
+}
+
+func (m *mockOrderStore) GetOrdersByUserID(userID int) ([]types.Order, error) {
+	return []types.Order{}, nil
+}
+
+func (m *mockOrderStore) GetAllOrders() ([]types.Order, error) {
+	return []types.Order{}, nil
+}
+
+func (m *mockOrderStore) GetOrderItems(orderID int) ([]types.OrderItem, error) {
+	return []types.OrderItem{}, nil
+}
+
+func (m *mockOrderStore) UpdateOrderStatus(orderID int, status types.OrderStatus, changedBy int, note string) (*types.Order, error) {
+	o, err := m.GetOrderByID(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ValidateTransition(o.Status, status); err != nil {
+		return nil, err
+	}
+
+	o.Status = status
+	return o, nil
+}
+
+func (m *mockOrderStore) GetOrderPromotions(orderID int) ([]types.AppliedPromotion, error) {
+	return []types.AppliedPromotion{}, nil
+}
+
+func (m *mockOrderStore) GetOrderStatusHistory(orderID int) ([]types.OrderStatusChange, error) {
+	return []types.OrderStatusChange{}, nil
+}
+
+// mockAddressStore holds a default address in Germany and an address in
+// California for the user 1 and an address that is not a default for the user 2
+type mockAddressStore struct {
+	types.AddressStore
+}
+
+func (m *mockAddressStore) GetAddressesByUserID(userID int) ([]types.Address, error) {
+	addresses := []types.Address{
+		{ID: 1, UserID: 1, DefaultShipping: true, DefaultBilling: true, PostalAddress: types.PostalAddress{
+			Name: "Test User", Line1: "Hauptstrasse 1", City: "Berlin", PostalCode: "10115", Country: "DE",
+		}},
+		{ID: 2, UserID: 1, PostalAddress: types.PostalAddress{
+			Name: "Test User", Line1: "1 Market St", City: "San Francisco", Region: "CA", PostalCode: "94105", Country: "US",
+		}},
+		{ID: 3, UserID: 2, PostalAddress: types.PostalAddress{
+			Name: "Other User", Line1: "1 Main St", City: "Austin", Region: "TX", Country: "US",
+		}},
+	}
+
+	owned := []types.Address{}
+	for _, a := range addresses {
+		if a.UserID == userID {
+			owned = append(owned, a)
+		}
+	}
+
+	return owned, nil
+}
+
+// TestOrderServiceHandlers function to implement testing
+func TestOrderServiceHandlers(t *testing.T) {
+	store := &mockOrderStore{}
+	handler := NewHandler(store, &mockAddressStore{})
+
+	token, err := auth.GenerateJWT(1, []string{auth.RoleCustomer})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adminToken, err := auth.GenerateJWT(3, []string{auth.RoleAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(method, path string, payload any, token string) *httptest.ResponseRecorder {
+		marshalled, _ := json.Marshal(payload)
+
+		req, err := http.NewRequest(method, path, bytes.NewBuffer(marshalled))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if token != "" {
+			req.Header.Set("Authorization", token)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+		router.Use(auth.JWTMiddleware)
+
+		handler.RegisterRoutes(router)
+		router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	t.Run("Should fail if the token is missing", func(t *testing.T) {
+		rr := serve(http.MethodGet, "/orders", nil, "")
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status code %d, got %d", http.StatusUnauthorized, rr.Code)
+		}
+	})
+
+	t.Run("Should fail if the order payload is invalid", func(t *testing.T) {
+		payload := types.PlaceOrderPayload{
+			Items:   []types.CartItem{},
+			Address: "",
+		}
+
+		rr := serve(http.MethodPost, "/orders", payload, token)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("Should report the checkout errors of the store", func(t *testing.T) {
+		defer func() { store.err = nil }()
+
+		for _, c := range []struct {
+			err    error
+			status int
+		}{
+			{fmt.Errorf("%w: product test has only 0 left", ErrInsufficientStock), http.StatusConflict},
+			{fmt.Errorf("%w: product 2", ErrProductNotFound), http.StatusBadRequest},
+			{fmt.Errorf("%w: product shirt", ErrVariantRequired), http.StatusBadRequest},
+			{&promotion.NotApplicableError{Code: "UNKNOWN", Reason: "does not exist"}, http.StatusBadRequest},
+			{shipping.ErrMethodRequired, http.StatusBadRequest},
+			{fmt.Errorf("%w: method 7", shipping.ErrMethodUnavailable), http.StatusBadRequest},
+			{fmt.Errorf("connection reset"), http.StatusInternalServerError},
+		} {
+			store.err = c.err
+
+			rr := serve(http.MethodPost, "/orders", types.PlaceOrderPayload{
+				Items:   []types.CartItem{{ProductID: 1, Quantity: 1}},
+				Address: "test address",
+				Country: "US",
+			}, token)
+
+			if rr.Code != c.status {
+				t.Errorf("%v: expected status code %d, got %d", c.err, c.status, rr.Code)
+			}
+		}
+	})
+
+	t.Run("Should correctly place the order", func(t *testing.T) {
+		payload := types.PlaceOrderPayload{
+			Items:   []types.CartItem{{ProductID: 1, Quantity: 2}},
+			Address: "test address",
+			Country: "US",
+		}
+
+		rr := serve(http.MethodPost, "/orders", payload, token)
+
+		if rr.Code != http.StatusCreated {
+			t.Errorf("Expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		var response types.PlaceOrderResponse
+		json.NewDecoder(rr.Body).Decode(&response)
+
+		if response.ID != 1 || response.Total != types.NewMoney(2000, types.DefaultCurrency) {
+			t.Errorf("Expected the order 1 of %v, got %+v", "20.00 USD", response)
+		}
+
+		checkout := store.checkout
+		if checkout.UserID != 1 || len(checkout.Items) != 1 || checkout.Items[0].Quantity != 2 || checkout.Rate != types.BaseExchangeRate {
+			t.Errorf("Expected the items of the user 1 in the base currency, got %+v", checkout)
+		}
+	})
+
+	t.Run("Should normalize the destination and the coupons of the checkout", func(t *testing.T) {
+		methodID := 1
+		rr := serve(http.MethodPost, "/orders", types.PlaceOrderPayload{
+			Items:            []types.CartItem{{ProductID: 1, Quantity: 2}},
+			Address:          "test address",
+			Country:          "us",
+			Region:           " ca",
+			Coupons:          []string{" save10", "SAVE10"},
+			ShippingMethodID: &methodID,
+		}, token)
+
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		checkout := store.checkout
+		if checkout.Country != "US" || checkout.Region != "CA" {
+			t.Errorf("Expected to ship to US CA, got %q %q", checkout.Country, checkout.Region)
+		}
+
+		if len(checkout.Coupons) != 1 || checkout.Coupons[0] != "SAVE10" {
+			t.Errorf("Expected the coupon SAVE10 once, got %v", checkout.Coupons)
+		}
+
+		if checkout.ShippingMethodID == nil || *checkout.ShippingMethodID != methodID {
+			t.Errorf("Expected the shipping method %d, got %v", methodID, checkout.ShippingMethodID)
+		}
+	})
+
+	t.Run("Should ship to the address of the address book", func(t *testing.T) {
+		addressID := 2
+		rr := serve(http.MethodPost, "/orders", types.PlaceOrderPayload{
+			Items:             []types.CartItem{{ProductID: 1, Quantity: 2}},
+			ShippingAddressID: &addressID,
+		}, token)
+
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		// taxed in California and billed to the default billing address
+		checkout := store.checkout
+		if checkout.Country != "US" || checkout.Region != "CA" {
+			t.Errorf("Expected the destination of California, got %q %q", checkout.Country, checkout.Region)
+		}
+
+		if checkout.ShippingAddress == nil || checkout.ShippingAddress.City != "San Francisco" || checkout.BillingAddress == nil || checkout.BillingAddress.City != "Berlin" {
+			t.Errorf("Expected to ship to San Francisco and bill to Berlin, got %+v and %+v", checkout.ShippingAddress, checkout.BillingAddress)
+		}
+
+		if checkout.Address != "Test User, 1 Market St, 94105 San Francisco, CA, US" {
+			t.Errorf("Expected the address on a single line, got %q", checkout.Address)
+		}
+	})
+
+	t.Run("Should ship to the default address without an address", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/orders", types.PlaceOrderPayload{
+			Items: []types.CartItem{{ProductID: 1, Quantity: 2}},
+		}, token)
+
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		if store.checkout.Country != "DE" || store.checkout.ShippingAddress == nil || store.checkout.ShippingAddress.City != "Berlin" {
+			t.Errorf("Expected to ship to Berlin, got %+v", store.checkout.ShippingAddress)
+		}
+	})
+
+	t.Run("Should not ship to the address of another user", func(t *testing.T) {
+		addressID := 3
+		rr := serve(http.MethodPost, "/orders", types.PlaceOrderPayload{
+			Items:             []types.CartItem{{ProductID: 1, Quantity: 1}},
+			ShippingAddressID: &addressID,
+		}, token)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("Should fail without an address and a default address", func(t *testing.T) {
+		otherToken, err := auth.GenerateJWT(2, []string{auth.RoleCustomer})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := serve(http.MethodPost, "/orders", types.PlaceOrderPayload{
+			Items: []types.CartItem{{ProductID: 1, Quantity: 1}},
+		}, otherToken)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+
+		// a free text address needs its country
+		rr = serve(http.MethodPost, "/orders", types.PlaceOrderPayload{
+			Items:   []types.CartItem{{ProductID: 1, Quantity: 1}},
+			Address: "test address",
+		}, otherToken)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("Should fail if the country is invalid", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/orders", types.PlaceOrderPayload{
+			Items:   []types.CartItem{{ProductID: 1, Quantity: 1}},
+			Address: "test address",
+			Country: "XX",
+		}, token)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("Should not allow customers to mark an order as paid", func(t *testing.T) {
+		payload := types.UpdateOrderStatusPayload{Status: types.OrderStatusPaid}
+
+		rr := serve(http.MethodPatch, "/orders/1/status", payload, token)
+
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("Expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	t.Run("Should allow admins to mark an order as paid", func(t *testing.T) {
+		payload := types.UpdateOrderStatusPayload{Status: types.OrderStatusPaid}
+
+		rr := serve(http.MethodPatch, "/orders/1/status", payload, adminToken)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+	})
+
+	t.Run("Should only list all the orders for admins", func(t *testing.T) {
+		if rr := serve(http.MethodGet, "/admin/orders", nil, token); rr.Code != http.StatusForbidden {
+			t.Errorf("Expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+
+		if rr := serve(http.MethodGet, "/admin/orders", nil, adminToken); rr.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+	})
+
+	t.Run("Should reject an illegal status transition", func(t *testing.T) {
+		payload := types.UpdateOrderStatusPayload{Status: types.OrderStatusDelivered}
+
+		rr := serve(http.MethodPatch, "/orders/1/status", payload, adminToken)
+
+		if rr.Code != http.StatusConflict {
+			t.Errorf("Expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+	})
+
+	t.Run("Should reject an unknown status", func(t *testing.T) {
+		payload := types.UpdateOrderStatusPayload{Status: "lost"}
+
+		rr := serve(http.MethodPatch, "/orders/1/status", payload, adminToken)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("Should cancel a pending order", func(t *testing.T) {
+		payload := types.UpdateOrderStatusPayload{Status: types.OrderStatusCancelled, Note: "changed my mind"}
+
+		rr := serve(http.MethodPatch, "/orders/1/status", payload, token)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+	})
+
+	t.Run("Should not return the order of another user", func(t *testing.T) {
+		otherToken, err := auth.GenerateJWT(2, []string{auth.RoleCustomer})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := serve(http.MethodGet, "/orders/1", nil, otherToken)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("Should only answer not found for the missing orders", func(t *testing.T) {
+		rr := serve(http.MethodGet, "/orders/3", nil, token)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+
+		rr = serve(http.MethodGet, "/orders/2", nil, token)
+
+		if rr.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status code %d, got %d", http.StatusInternalServerError, rr.Code)
+		}
+	})
+}
+
+// TestConvertOrderItems function to test the pricing of the orders in another currency
+func TestConvertOrderItems(t *testing.T) {
+	items := func() []types.OrderItem {
+		return []types.OrderItem{
+			{ProductID: 1, Quantity: 3, Price: types.NewMoney(1999, types.DefaultCurrency)},
+			{ProductID: 2, Quantity: 1, Price: types.NewMoney(500, types.DefaultCurrency)},
+		}
+	}
+
+	t.Run("Should keep the prices in the base currency", func(t *testing.T) {
+		total, err := convertOrderItems(items(), types.BaseExchangeRate)
+		if err != nil || total != types.NewMoney(6497, types.DefaultCurrency) {
+			t.Errorf("Expected total %v, got %v, error: %v", "64.97 USD", total, err)
+		}
+	})
+
+	t.Run("Should convert the unit prices before adding up the total", func(t *testing.T) {
+		converted := items()
+		total, err := convertOrderItems(converted, types.ExchangeRate{Currency: "EUR", Rate: "0.9215"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// 19.99 * 0.9215 = 18.420785 and 5.00 * 0.9215 = 4.6075
+		if converted[0].Price != types.NewMoney(1842, "EUR") || converted[1].Price != types.NewMoney(461, "EUR") {
+			t.Errorf("Expected the prices 18.42 EUR and 4.61 EUR, got %v and %v", converted[0].Price, converted[1].Price)
+		}
+
+		if total != types.NewMoney(5987, "EUR") {
+			t.Errorf("Expected total %v, got %v", "59.87 EUR", total)
+		}
+	})
+
+	t.Run("Should convert to a currency without minor units", func(t *testing.T) {
+		total, err := convertOrderItems(items(), types.ExchangeRate{Currency: "JPY", Rate: "151.5"})
+
+		// 19.99 * 151.5 = 3028.485 and 5.00 * 151.5 = 757.5 rounded to 758
+		if err != nil || total != types.NewMoney(3028*3+758, "JPY") {
+			t.Errorf("Expected total %v, got %v, error: %v", "9842 JPY", total, err)
+		}
+	})
+}
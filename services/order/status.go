@@ -0,0 +1,88 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// ErrInvalidTransition is returned when an order status change
+// is not allowed by the order state machine
+var ErrInvalidTransition = errors.New("invalid order status transition")
+
+// ErrActivePayment is returned when an order is cancelled while one of its payments
+// holds or took the money of the customer, the payment is voided or refunded first
+var ErrActivePayment = errors.New("order has an active payment")
+
+// ErrUnknownStatus is returned for a status that is not part of the state machine
+var ErrUnknownStatus = errors.New("unknown order status")
+
+// transitions holds the state machine of an order.
+// Each status maps to the statuses it can be moved to.
+//
+//	pending -> paid -> fulfilled -> shipped -> delivered
+//	pending -> payment_failed -> paid
+//	pending, payment_failed -> cancelled
+//	paid, fulfilled, shipped, delivered -> refunded
+//
+// The orders that were paid are not cancelled, the money is given back with
+// the refunds which move them to refunded once everything paid is given back.
+var transitions = map[types.OrderStatus][]types.OrderStatus{
+	types.OrderStatusPending:       {types.OrderStatusPaid, types.OrderStatusPaymentFailed, types.OrderStatusCancelled},
+	types.OrderStatusPaymentFailed: {types.OrderStatusPaid, types.OrderStatusCancelled},
+	types.OrderStatusPaid:          {types.OrderStatusFulfilled, types.OrderStatusRefunded},
+	types.OrderStatusFulfilled:     {types.OrderStatusShipped, types.OrderStatusRefunded},
+	types.OrderStatusShipped:       {types.OrderStatusDelivered, types.OrderStatusRefunded},
+	types.OrderStatusDelivered:     {types.OrderStatusRefunded},
+	types.OrderStatusCancelled:     {},
+	types.OrderStatusRefunded:      {},
+}
+
+// IsAwaitingPayment reports whether the order can still be paid,
+// the orders whose payment failed can be paid again
+func IsAwaitingPayment(status types.OrderStatus) bool {
+	return status == types.OrderStatusPending || status == types.OrderStatusPaymentFailed
+}
+
+// IsValidStatus reports whether the status is part of the state machine
+func IsValidStatus(status types.OrderStatus) bool {
+	_, ok := transitions[status]
+	return ok
+}
+
+// CanTransition reports whether an order can be moved from one status to another
+func CanTransition(from, to types.OrderStatus) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValidateTransition returns a descriptive error when
+// the order cannot be moved from one status to another
+func ValidateTransition(from, to types.OrderStatus) error {
+	if !IsValidStatus(to) {
+		return fmt.Errorf("%w: %q", ErrUnknownStatus, to)
+	}
+
+	if CanTransition(from, to) {
+		return nil
+	}
+
+	allowed := transitions[from]
+	if len(allowed) == 0 {
+		return fmt.Errorf("%w: order is %s and can no longer change status", ErrInvalidTransition, from)
+	}
+
+	names := make([]string, len(allowed))
+	for i, status := range allowed {
+		names[i] = string(status)
+	}
+
+	return fmt.Errorf("%w: cannot move order from %s to %s, allowed: %s", ErrInvalidTransition, from, to, strings.Join(names, ", "))
+}
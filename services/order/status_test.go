@@ -0,0 +1,44 @@
+package order
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// TestValidateTransition function to test the order state machine
+func TestValidateTransition(t *testing.T) {
+	tests := []struct {
+		from types.OrderStatus
+		to   types.OrderStatus
+		err  error
+	}{
+		{types.OrderStatusPending, types.OrderStatusPaid, nil},
+		{types.OrderStatusPending, types.OrderStatusCancelled, nil},
+		{types.OrderStatusPending, types.OrderStatusPaymentFailed, nil},
+		{types.OrderStatusPaymentFailed, types.OrderStatusPaid, nil},
+		{types.OrderStatusPaymentFailed, types.OrderStatusCancelled, nil},
+		{types.OrderStatusPaid, types.OrderStatusFulfilled, nil},
+		{types.OrderStatusFulfilled, types.OrderStatusShipped, nil},
+		{types.OrderStatusShipped, types.OrderStatusDelivered, nil},
+		{types.OrderStatusDelivered, types.OrderStatusRefunded, nil},
+		{types.OrderStatusPending, types.OrderStatusShipped, ErrInvalidTransition},
+		{types.OrderStatusPaid, types.OrderStatusCancelled, ErrInvalidTransition},
+		{types.OrderStatusFulfilled, types.OrderStatusCancelled, ErrInvalidTransition},
+		{types.OrderStatusShipped, types.OrderStatusCancelled, ErrInvalidTransition},
+		{types.OrderStatusCancelled, types.OrderStatusPending, ErrInvalidTransition},
+		{types.OrderStatusRefunded, types.OrderStatusPaid, ErrInvalidTransition},
+		{types.OrderStatusPaid, types.OrderStatusPaid, ErrInvalidTransition},
+		{types.OrderStatusPaid, types.OrderStatusPaymentFailed, ErrInvalidTransition},
+		{types.OrderStatusPending, "completed", ErrUnknownStatus},
+	}
+
+	for _, test := range tests {
+		err := ValidateTransition(test.from, test.to)
+
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s -> %s: expected error %v, got %v", test.from, test.to, test.err, err)
+		}
+	}
+}
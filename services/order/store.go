@@ -0,0 +1,940 @@
+package order
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/akshtrikha/golang-ecomm/services/currency"
+	"github.com/akshtrikha/golang-ecomm/services/promotion"
+	"github.com/akshtrikha/golang-ecomm/services/shipping"
+	"github.com/akshtrikha/golang-ecomm/services/tax"
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// orderColumns lists the columns scanned by scanRowIntoOrder in order
+const orderColumns = "id, userId, total, discount, tax, shipping, refunded, shippingMethodId, shippingMethod, taxInclusive, taxExempt, currency, exchangeRate, status, address, country, region, shippingAddress, billingAddress, createAt"
+
+// Store struct to hold the database object
+// This will be used to handle the database queries
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore function to return a reference to the Store struct
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// ErrInsufficientStock is returned when a product does not have
+// enough quantity left to fulfil the requested order line
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// ErrProductNotFound is returned when an order line references
+// a product or a variant that does not exist
+var ErrProductNotFound = errors.New("product not found")
+
+// ErrOrderNotFound is returned when an order does not exist
+var ErrOrderNotFound = errors.New("order not found")
+
+// ErrVariantRequired is returned when an order line references
+// a product with variants without the sku of one of them
+var ErrVariantRequired = errors.New("variant sku required")
+
+// PlaceOrder function to checkout the items for the user.
+// In a single transaction it locks the product and variant rows, verifies
+// the stock, decrements the quantity of the product, or of the variant for
+// lines with a sku, and inserts the order and its items priced in the
+// currency of the exchange rate, which is recorded on the order.
+// The coupons are locked too so their usage limits hold under concurrent
+// checkouts, the discounts are recorded on the order. The items are taxed at
+// the rates of the destination unless the user is tax exempt, the shipping
+// method selected for the destination is added to the total untaxed.
+// Everything is rolled back if any of the steps fail.
+func (s *Store) PlaceOrder(checkout types.Checkout) (*types.Order, error) {
+	cartItems := mergeCartItems(checkout.Items)
+	userID, rate := checkout.UserID, checkout.Rate
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	// lock the product rows so concurrent checkouts wait for each other
+	products, err := lockProducts(tx, cartItems)
+	if err != nil {
+		return nil, err
+	}
+
+	variants, err := lockVariants(tx, cartItems)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := buildOrderItems(cartItems, products, variants)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := convertOrderItems(items, rate); err != nil {
+		return nil, err
+	}
+
+	discounts, err := applyPromotions(tx, checkout, items)
+	if err != nil {
+		return nil, err
+	}
+
+	// tax the discounted items at the rates of the destination
+	rates, err := tax.GetCountryTaxRates(tx, checkout.Country)
+	if err != nil {
+		return nil, err
+	}
+
+	var exempt bool
+	if err := tx.QueryRow("SELECT taxExempt FROM users WHERE id = ?", userID).Scan(&exempt); err != nil {
+		return nil, err
+	}
+
+	taxAmount, total, err := tax.Calculate(items, discounts.Discount, rates, checkout.Country, checkout.Region, checkout.TaxInclusive, exempt)
+	if err != nil {
+		return nil, err
+	}
+
+	quote, err := quoteShipping(tx, checkout, products, items, discounts)
+	if err != nil {
+		return nil, err
+	}
+
+	shippingAmount := types.NewMoney(0, rate.Currency)
+	var shippingMethodID *int
+	var shippingMethod string
+	if quote != nil {
+		shippingAmount, shippingMethodID, shippingMethod = quote.Amount, &quote.MethodID, quote.Name
+		if total, err = total.Add(shippingAmount); err != nil {
+			return nil, err
+		}
+	}
+
+	// decrement the stock, the quantity check guards against overselling
+	for _, item := range items {
+		var result sql.Result
+		if item.VariantID != nil {
+			result, err = tx.Exec("UPDATE product_variants SET quantity = quantity - ? WHERE id = ? AND quantity >= ?", item.Quantity, *item.VariantID, item.Quantity)
+		} else {
+			result, err = tx.Exec("UPDATE products SET quantity = quantity - ? WHERE id = ? AND quantity >= ?", item.Quantity, item.ProductID, item.Quantity)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+
+		if affected != 1 {
+			return nil, fmt.Errorf("%w: product %d", ErrInsufficientStock, item.ProductID)
+		}
+	}
+
+	// keep a copy of the addresses, later changes to the address book do not affect the order
+	shippingAddress, err := marshalAddress(checkout.ShippingAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	billingAddress, err := marshalAddress(checkout.BillingAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	// create the order
+	result, err := tx.Exec(
+		`INSERT INTO orders (userId, total, discount, tax, shipping, shippingMethodId, shippingMethod, taxInclusive, taxExempt, currency, exchangeRate, status,
+			address, country, region, shippingAddress, billingAddress)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		userID, total, discounts.Discount, taxAmount, shippingAmount, shippingMethodID, shippingMethod, checkout.TaxInclusive, exempt, rate.Currency, rate.Rate,
+		types.OrderStatusPending, checkout.Address, checkout.Country, checkout.Region, shippingAddress, billingAddress,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	orderID, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	// create the order items
+	for i := range items {
+		items[i].OrderID = int(orderID)
+
+		var sku *string
+		if items[i].SKU != "" {
+			sku = &items[i].SKU
+		}
+
+		_, err := tx.Exec(
+			"INSERT INTO order_items (orderId, productId, variantId, sku, quantity, price, taxClass, discount, taxRate, tax) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			items[i].OrderID, items[i].ProductID, items[i].VariantID, sku, items[i].Quantity, items[i].Price,
+			items[i].TaxClass, items[i].Discount, items[i].TaxRate, items[i].Tax,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// record the coupons used and count their usage
+	for _, applied := range discounts.Applied {
+		_, err := tx.Exec(
+			"INSERT INTO order_promotions (orderId, promotionId, code, type, amount) VALUES (?, ?, ?, ?, ?)",
+			orderID, applied.PromotionID, applied.Code, applied.Type, applied.Amount,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := tx.Exec("UPDATE promotions SET usageCount = usageCount + 1 WHERE id = ?", applied.PromotionID); err != nil {
+			return nil, err
+		}
+	}
+
+	// record the initial status in the history
+	if err := insertStatusChange(tx, int(orderID), nil, types.OrderStatusPending, &userID, "order placed"); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &types.Order{
+		ID:               int(orderID),
+		UserID:           userID,
+		Total:            total,
+		Discount:         discounts.Discount,
+		Tax:              taxAmount,
+		Shipping:         shippingAmount,
+		Refunded:         types.NewMoney(0, rate.Currency),
+		ShippingMethodID: shippingMethodID,
+		ShippingMethod:   shippingMethod,
+		TaxInclusive:     checkout.TaxInclusive,
+		TaxExempt:        exempt,
+		Currency:         rate.Currency,
+		ExchangeRate:     rate.Rate,
+		Status:           types.OrderStatusPending,
+		Address:          checkout.Address,
+		Country:          checkout.Country,
+		Region:           checkout.Region,
+		ShippingAddress:  checkout.ShippingAddress,
+		BillingAddress:   checkout.BillingAddress,
+		Items:            items,
+		Promotions:       discounts.Applied,
+	}, nil
+}
+
+// GetOrderByID function to find the order by id
+func (s *Store) GetOrderByID(id int) (*types.Order, error) {
+	rows, err := s.db.Query("SELECT "+orderColumns+" FROM orders WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	o := new(types.Order)
+	for rows.Next() {
+		o, err = scanRowIntoOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if o.ID == 0 {
+		return nil, fmt.Errorf("%w: %v", ErrOrderNotFound, id)
+	}
+
+	return o, nil
+}
+
+// GetOrdersByUserID function to get all the orders placed by the user
+func (s *Store) GetOrdersByUserID(userID int) ([]types.Order, error) {
+	rows, err := s.db.Query("SELECT "+orderColumns+" FROM orders WHERE userId = ? ORDER BY id DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := []types.Order{}
+	for rows.Next() {
+		o, err := scanRowIntoOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		orders = append(orders, *o)
+	}
+
+	return orders, rows.Err()
+}
+
+// GetAllOrders function to get the orders of all the users
+func (s *Store) GetAllOrders() ([]types.Order, error) {
+	rows, err := s.db.Query("SELECT " + orderColumns + " FROM orders ORDER BY id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := []types.Order{}
+	for rows.Next() {
+		o, err := scanRowIntoOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		orders = append(orders, *o)
+	}
+
+	return orders, rows.Err()
+}
+
+// GetOrderItems function to get all the lines of an order
+// the product is resolved even when it was archived since
+func (s *Store) GetOrderItems(orderID int) ([]types.OrderItem, error) {
+	rows, err := s.db.Query(`
+		SELECT oi.id, oi.orderId, oi.productId, p.name, oi.variantId, oi.sku, oi.quantity, oi.refundedQuantity,
+			oi.price, oi.taxClass, oi.discount, oi.taxRate, oi.tax, o.currency
+		FROM order_items oi
+		JOIN orders o ON o.id = oi.orderId
+		JOIN products p ON p.id = oi.productId
+		WHERE oi.orderId = ?
+		ORDER BY oi.id`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []types.OrderItem{}
+	for rows.Next() {
+		item := types.OrderItem{}
+		var variantID sql.NullInt64
+		var sku sql.NullString
+		var price, discount, taxAmount, currency string
+
+		err := rows.Scan(
+			&item.ID,
+			&item.OrderID,
+			&item.ProductID,
+			&item.ProductName,
+			&variantID,
+			&sku,
+			&item.Quantity,
+			&item.RefundedQuantity,
+			&price,
+			&item.TaxClass,
+			&discount,
+			&item.TaxRate,
+			&taxAmount,
+			&currency,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		// the amounts are in the currency of the order
+		if item.Price, err = types.ParseMoney(price, currency); err != nil {
+			return nil, err
+		}
+
+		if item.Discount, err = types.ParseMoney(discount, currency); err != nil {
+			return nil, err
+		}
+
+		if item.Tax, err = types.ParseMoney(taxAmount, currency); err != nil {
+			return nil, err
+		}
+		item.TaxRate = types.TrimDecimal(item.TaxRate)
+
+		if variantID.Valid {
+			id := int(variantID.Int64)
+			item.VariantID = &id
+			item.SKU = sku.String
+		}
+
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// UpdateOrderStatus function to move the order to the given status.
+// The transition is validated against the order state machine and recorded
+// in the status history in the same transaction, with no user when
+// changedBy is 0. Cancelled orders return the units of their items that
+// were not refunded to the product or variant stock, the orders with a
+// payment that is not voided, declined or refunded are not cancelled.
+func (s *Store) UpdateOrderStatus(orderID int, status types.OrderStatus, changedBy int, note string) (*types.Order, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	// lock the order so concurrent status changes are serialized
+	rows, err := tx.Query("SELECT "+orderColumns+" FROM orders WHERE id = ? FOR UPDATE", orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	o := new(types.Order)
+	for rows.Next() {
+		o, err = scanRowIntoOrder(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+	}
+	rows.Close()
+
+	if o.ID == 0 {
+		return nil, fmt.Errorf("%w: %v", ErrOrderNotFound, orderID)
+	}
+
+	if err := ValidateTransition(o.Status, status); err != nil {
+		return nil, err
+	}
+
+	// the payments lock the order too, a payment can not start while the order is cancelled
+	if status == types.OrderStatusCancelled {
+		var active int
+		err := tx.QueryRow(
+			"SELECT COUNT(*) FROM payments WHERE orderId = ? AND status IN (?, ?, ?, ?)",
+			orderID, types.PaymentRequiresAction, types.PaymentAuthorized, types.PaymentCaptured, types.PaymentPartiallyRefunded,
+		).Scan(&active)
+		if err != nil {
+			return nil, err
+		}
+
+		if active > 0 {
+			return nil, fmt.Errorf("%w: order %v", ErrActivePayment, orderID)
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE orders SET status = ? WHERE id = ?", status, orderID); err != nil {
+		return nil, err
+	}
+
+	if status == types.OrderStatusCancelled {
+		// the refunded units are left out, their credit note already restocked them when asked to
+		_, err := tx.Exec(`
+			UPDATE products p
+			JOIN order_items oi ON oi.productId = p.id
+			SET p.quantity = p.quantity + oi.quantity - oi.refundedQuantity
+			WHERE oi.orderId = ? AND oi.variantId IS NULL`, orderID)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tx.Exec(`
+			UPDATE product_variants v
+			JOIN order_items oi ON oi.variantId = v.id
+			SET v.quantity = v.quantity + oi.quantity - oi.refundedQuantity
+			WHERE oi.orderId = ?`, orderID)
+		if err != nil {
+			return nil, err
+		}
+
+		// the coupons of a cancelled order can be used again
+		_, err = tx.Exec(`
+			UPDATE promotions p
+			JOIN order_promotions op ON op.promotionId = p.id
+			SET p.usageCount = p.usageCount - 1
+			WHERE op.orderId = ? AND p.usageCount > 0`, orderID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the changes made by the system, like the ones of the
+	// payment webhooks, are recorded without a user
+	var by *int
+	if changedBy != 0 {
+		by = &changedBy
+	}
+
+	from := o.Status
+	if err := insertStatusChange(tx, orderID, &from, status, by, note); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	o.Status = status
+	return o, nil
+}
+
+// GetOrderStatusHistory function to get the status changes of the order
+// ordered from the oldest to the newest
+func (s *Store) GetOrderStatusHistory(orderID int) ([]types.OrderStatusChange, error) {
+	rows, err := s.db.Query("SELECT id, orderId, fromStatus, toStatus, changedBy, note, createdAt FROM order_status_history WHERE orderId = ? ORDER BY id", orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []types.OrderStatusChange{}
+	for rows.Next() {
+		var change types.OrderStatusChange
+		var from sql.NullString
+		var changedBy sql.NullInt64
+
+		err := rows.Scan(
+			&change.ID,
+			&change.OrderID,
+			&from,
+			&change.ToStatus,
+			&changedBy,
+			&change.Note,
+			&change.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if from.Valid {
+			status := types.OrderStatus(from.String)
+			change.FromStatus = &status
+		}
+
+		if changedBy.Valid {
+			id := int(changedBy.Int64)
+			change.ChangedBy = &id
+		}
+
+		history = append(history, change)
+	}
+
+	return history, rows.Err()
+}
+
+// GetOrderPromotions function to get the coupons applied to the order
+func (s *Store) GetOrderPromotions(orderID int) ([]types.AppliedPromotion, error) {
+	rows, err := s.db.Query(`
+		SELECT op.promotionId, op.code, op.type, op.amount, o.currency
+		FROM order_promotions op
+		JOIN orders o ON o.id = op.orderId
+		WHERE op.orderId = ? ORDER BY op.id`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	promotions := []types.AppliedPromotion{}
+	for rows.Next() {
+		var applied types.AppliedPromotion
+		var amount, currencyCode string
+
+		if err := rows.Scan(&applied.PromotionID, &applied.Code, &applied.Type, &amount, &currencyCode); err != nil {
+			return nil, err
+		}
+
+		// the amounts are in the currency of the order
+		if applied.Amount, err = types.ParseMoney(amount, currencyCode); err != nil {
+			return nil, err
+		}
+
+		promotions = append(promotions, applied)
+	}
+
+	return promotions, rows.Err()
+}
+
+func insertStatusChange(tx *sql.Tx, orderID int, from *types.OrderStatus, to types.OrderStatus, changedBy *int, note string) error {
+	_, err := tx.Exec("INSERT INTO order_status_history (orderId, fromStatus, toStatus, changedBy, note) VALUES (?, ?, ?, ?, ?)", orderID, from, to, changedBy, note)
+
+	return err
+}
+
+// applyPromotions locks the promotions of the coupons with FOR UPDATE, checks
+// they can still be used by the user and computes their discounts on the items
+func applyPromotions(tx *sql.Tx, checkout types.Checkout, items []types.OrderItem) (*types.DiscountResult, error) {
+	lines := make([]types.DiscountLine, len(items))
+	for i, item := range items {
+		lines[i] = types.DiscountLine{ProductID: item.ProductID, Price: item.Price, Quantity: item.Quantity}
+	}
+
+	if len(checkout.Coupons) == 0 {
+		return promotion.Apply(nil, lines, checkout.Rate.Currency)
+	}
+
+	placeholders := strings.Repeat("?,", len(checkout.Coupons)-1) + "?"
+
+	args := make([]interface{}, len(checkout.Coupons))
+	for i, code := range checkout.Coupons {
+		args[i] = code
+	}
+
+	rows, err := tx.Query(fmt.Sprintf("SELECT %s FROM promotions WHERE code IN (%s) FOR UPDATE", promotion.Columns, placeholders), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	byCode := make(map[string]types.Promotion)
+	for rows.Next() {
+		p, err := promotion.ScanRowIntoPromotion(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		byCode[p.Code] = *p
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	promotions := make([]types.Promotion, 0, len(checkout.Coupons))
+	for _, code := range checkout.Coupons {
+		p, ok := byCode[code]
+		if !ok {
+			return nil, &promotion.NotApplicableError{Code: code, Reason: "does not exist"}
+		}
+
+		redemptions := 0
+		if p.PerUserLimit != nil {
+			if redemptions, err = promotion.CountUserRedemptions(tx, p.ID, checkout.UserID); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := promotion.CheckAvailable(p, now, redemptions); err != nil {
+			return nil, err
+		}
+
+		if p, err = promotion.Convert(checkout.Rate, p); err != nil {
+			return nil, err
+		}
+		promotions = append(promotions, p)
+	}
+
+	return promotion.Apply(promotions, lines, checkout.Rate.Currency)
+}
+
+// quoteShipping prices the shipping method selected for the order to its destination
+// in the currency of the order, the thresholds of the methods apply to the subtotal
+// after the discounts. Nil is returned for destinations without shipping methods
+func quoteShipping(tx *sql.Tx, checkout types.Checkout, products []types.Product, items []types.OrderItem, discounts *types.DiscountResult) (*types.ShippingQuote, error) {
+	methods, err := shipping.GetShippingMethodsFor(tx, checkout.Country, checkout.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range methods {
+		if methods[i], err = shipping.Convert(checkout.Rate, methods[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	weights := make(map[int]int, len(products))
+	for _, p := range products {
+		weights[p.ID] = shipping.ChargeableWeight(p)
+	}
+
+	weight := 0
+	for _, item := range items {
+		weight += weights[item.ProductID] * item.Quantity
+	}
+
+	subtotal, err := discounts.Subtotal.Sub(discounts.Discount)
+	if err != nil {
+		return nil, err
+	}
+
+	quotes, err := shipping.Quote(methods, weight, subtotal, discounts.FreeShipping)
+	if err != nil {
+		return nil, err
+	}
+
+	return shipping.Select(quotes, checkout.ShippingMethodID)
+}
+
+// lockProducts selects the products of the order lines with FOR UPDATE
+// so their rows stay locked until the transaction ends
+func lockProducts(tx *sql.Tx, cartItems []types.CartItem) ([]types.Product, error) {
+	placeholders := strings.Repeat("?,", len(cartItems)-1) + "?"
+
+	args := make([]interface{}, len(cartItems))
+	for i, item := range cartItems {
+		args[i] = item.ProductID
+	}
+
+	rows, err := tx.Query(fmt.Sprintf("SELECT id, name, price, quantity, taxClass, weight, length, width, height FROM products WHERE id IN (%s) AND deletedAt IS NULL FOR UPDATE", placeholders), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []types.Product
+	for rows.Next() {
+		var p types.Product
+
+		if err := rows.Scan(&p.ID, &p.Name, &p.Price, &p.Quantity, &p.TaxClass, &p.Weight, &p.Length, &p.Width, &p.Height); err != nil {
+			return nil, err
+		}
+
+		products = append(products, p)
+	}
+
+	return products, rows.Err()
+}
+
+// lockVariants selects the variants of the products of the order lines
+// with FOR UPDATE. Every active variant of the products is returned so
+// lines without a sku can be checked against products with variants
+func lockVariants(tx *sql.Tx, cartItems []types.CartItem) ([]types.ProductVariant, error) {
+	placeholders := strings.Repeat("?,", len(cartItems)-1) + "?"
+
+	args := make([]interface{}, len(cartItems))
+	for i, item := range cartItems {
+		args[i] = item.ProductID
+	}
+
+	rows, err := tx.Query(fmt.Sprintf("SELECT id, productId, sku, price, quantity FROM product_variants WHERE productId IN (%s) AND deletedAt IS NULL ORDER BY id FOR UPDATE", placeholders), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var variants []types.ProductVariant
+	for rows.Next() {
+		var v types.ProductVariant
+		var price types.NullMoney
+
+		if err := rows.Scan(&v.ID, &v.ProductID, &v.SKU, &price, &v.Quantity); err != nil {
+			return nil, err
+		}
+
+		if price.Valid {
+			v.Price = &price.Money
+		}
+
+		variants = append(variants, v)
+	}
+
+	return variants, rows.Err()
+}
+
+// mergeCartItems combines the lines requesting the same product variant
+// and sorts them by product id and sku so rows are always locked in the same order
+func mergeCartItems(cartItems []types.CartItem) []types.CartItem {
+	type line struct {
+		productID int
+		sku       string
+	}
+
+	quantities := make(map[line]int, len(cartItems))
+	for _, item := range cartItems {
+		quantities[line{item.ProductID, item.SKU}] += item.Quantity
+	}
+
+	merged := make([]types.CartItem, 0, len(quantities))
+	for l, quantity := range quantities {
+		merged = append(merged, types.CartItem{ProductID: l.productID, SKU: l.sku, Quantity: quantity})
+	}
+
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].ProductID != merged[j].ProductID {
+			return merged[i].ProductID < merged[j].ProductID
+		}
+		return merged[i].SKU < merged[j].SKU
+	})
+
+	return merged
+}
+
+// buildOrderItems checks every requested item against the products and their
+// variants and returns the order lines with the price snapshotted from the
+// variant when it overrides it, from the product otherwise
+func buildOrderItems(cartItems []types.CartItem, products []types.Product, variants []types.ProductVariant) ([]types.OrderItem, error) {
+	productMap := make(map[int]types.Product, len(products))
+	for _, p := range products {
+		productMap[p.ID] = p
+	}
+
+	variantMap := make(map[string]types.ProductVariant, len(variants))
+	hasVariants := make(map[int]bool)
+	for _, v := range variants {
+		variantMap[v.SKU] = v
+		hasVariants[v.ProductID] = true
+	}
+
+	items := make([]types.OrderItem, 0, len(cartItems))
+
+	for _, item := range cartItems {
+		p, ok := productMap[item.ProductID]
+		if !ok {
+			return nil, fmt.Errorf("%w: product %d", ErrProductNotFound, item.ProductID)
+		}
+
+		line := types.OrderItem{
+			ProductID: p.ID,
+			Quantity:  item.Quantity,
+			Price:     p.Price,
+			TaxClass:  p.TaxClass,
+		}
+		name, available := p.Name, p.Quantity
+
+		if item.SKU != "" {
+			v, ok := variantMap[item.SKU]
+			if !ok || v.ProductID != p.ID {
+				return nil, fmt.Errorf("%w: variant %s of product %d", ErrProductNotFound, item.SKU, item.ProductID)
+			}
+
+			if v.Price != nil {
+				line.Price = *v.Price
+			}
+			line.VariantID = &v.ID
+			line.SKU = v.SKU
+			name, available = fmt.Sprintf("%s (%s)", p.Name, v.SKU), v.Quantity
+		} else if hasVariants[p.ID] {
+			return nil, fmt.Errorf("%w: product %s", ErrVariantRequired, p.Name)
+		}
+
+		if available < item.Quantity {
+			return nil, fmt.Errorf("%w: product %s has only %d left", ErrInsufficientStock, name, available)
+		}
+
+		items = append(items, line)
+	}
+
+	return items, nil
+}
+
+// convertOrderItems converts the prices of the items from the base currency
+// to the currency of the rate and returns the order total in that currency.
+// The unit prices are converted first so the lines add up to the total
+func convertOrderItems(items []types.OrderItem, rate types.ExchangeRate) (types.Money, error) {
+	total := types.NewMoney(0, rate.Currency)
+
+	for i := range items {
+		price, err := currency.Convert(rate, items[i].Price)
+		if err != nil {
+			return total, err
+		}
+		items[i].Price = price
+
+		lineTotal, err := price.Mul(int64(items[i].Quantity))
+		if err != nil {
+			return total, err
+		}
+
+		if total, err = total.Add(lineTotal); err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+func scanRowIntoOrder(rows *sql.Rows) (*types.Order, error) {
+	order := new(types.Order)
+	var total, discount, taxAmount, shippingAmount, refunded string
+	var shippingMethodID sql.NullInt64
+	var shippingAddress, billingAddress []byte
+
+	err := rows.Scan(
+		&order.ID,
+		&order.UserID,
+		&total,
+		&discount,
+		&taxAmount,
+		&shippingAmount,
+		&refunded,
+		&shippingMethodID,
+		&order.ShippingMethod,
+		&order.TaxInclusive,
+		&order.TaxExempt,
+		&order.Currency,
+		&order.ExchangeRate,
+		&order.Status,
+		&order.Address,
+		&order.Country,
+		&order.Region,
+		&shippingAddress,
+		&billingAddress,
+		&order.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// the amounts are in the currency of the order
+	if order.Total, err = types.ParseMoney(total, order.Currency); err != nil {
+		return nil, err
+	}
+
+	if order.Discount, err = types.ParseMoney(discount, order.Currency); err != nil {
+		return nil, err
+	}
+
+	if order.Tax, err = types.ParseMoney(taxAmount, order.Currency); err != nil {
+		return nil, err
+	}
+
+	if order.Shipping, err = types.ParseMoney(shippingAmount, order.Currency); err != nil {
+		return nil, err
+	}
+
+	if order.Refunded, err = types.ParseMoney(refunded, order.Currency); err != nil {
+		return nil, err
+	}
+
+	if shippingMethodID.Valid {
+		id := int(shippingMethodID.Int64)
+		order.ShippingMethodID = &id
+	}
+	order.ExchangeRate = types.TrimDecimal(order.ExchangeRate)
+
+	if shippingAddress != nil {
+		if err := json.Unmarshal(shippingAddress, &order.ShippingAddress); err != nil {
+			return nil, err
+		}
+	}
+
+	if billingAddress != nil {
+		if err := json.Unmarshal(billingAddress, &order.BillingAddress); err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
+
+// marshalAddress encodes the copy of an address of the JSON columns, NULL without address
+func marshalAddress(address *types.PostalAddress) (*string, error) {
+	if address == nil {
+		return nil, nil
+	}
+
+	encoded, err := json.Marshal(address)
+	if err != nil {
+		return nil, err
+	}
+
+	s := string(encoded)
+	return &s, nil
+}
@@ -0,0 +1,473 @@
+package order
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/akshtrikha/golang-ecomm/services/promotion"
+	"github.com/akshtrikha/golang-ecomm/services/shipping"
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// checkoutFixture holds the rows the database returns during a checkout
+type checkoutFixture struct {
+	products []types.Product
+	variants []types.ProductVariant
+	coupons  []types.Promotion
+	rates    []types.TaxRate
+	methods  []types.ShippingMethod
+}
+
+// newCheckoutFixture returns the catalog of the tests, a product with 5 units
+// left and a shirt sold as variants, Germany and California tax the standard
+// class and only Great Britain has a shipping method, 4.99 free over 50.00
+func newCheckoutFixture() checkoutFixture {
+	price := types.NewMoney(2500, types.DefaultCurrency)
+	amount, freeOver := types.NewMoney(499, types.DefaultCurrency), types.NewMoney(5000, types.DefaultCurrency)
+
+	return checkoutFixture{
+		products: []types.Product{
+			{ID: 1, Name: "test", Price: types.NewMoney(1000, types.DefaultCurrency), Quantity: 5, TaxClass: types.DefaultTaxClass},
+			{ID: 3, Name: "shirt", Price: types.NewMoney(2000, types.DefaultCurrency), TaxClass: types.DefaultTaxClass},
+		},
+		variants: []types.ProductVariant{
+			{ID: 1, ProductID: 3, SKU: "SHIRT-M", Price: &price, Quantity: 1},
+			{ID: 2, ProductID: 3, SKU: "SHIRT-L", Quantity: 5},
+		},
+		coupons: []types.Promotion{
+			{ID: 1, Code: "SAVE10", Type: types.PromotionPercentage, Percent: "10.00", Active: true},
+		},
+		rates: []types.TaxRate{
+			{ID: 1, Country: "DE", TaxClass: types.DefaultTaxClass, Rate: "19.0000"},
+			{ID: 2, Country: "US", Region: "CA", TaxClass: types.DefaultTaxClass, Rate: "7.2500"},
+		},
+		methods: []types.ShippingMethod{
+			{ID: 1, ZoneID: 1, Name: "Standard", Type: types.ShippingFlat, Amount: &amount, FreeOver: &freeOver, Active: true},
+		},
+	}
+}
+
+// expectLocks expects the rows of the products of the checkout and of
+// their variants to be locked, only the rows of the items are returned
+func (f checkoutFixture) expectLocks(mock sqlmock.Sqlmock, checkout types.Checkout) {
+	requested := make(map[int]bool)
+	for _, item := range checkout.Items {
+		requested[item.ProductID] = true
+	}
+
+	products := sqlmock.NewRows([]string{"id", "name", "price", "quantity", "taxClass", "weight", "length", "width", "height"})
+	for _, p := range f.products {
+		if requested[p.ID] {
+			products.AddRow(p.ID, p.Name, p.Price.Decimal(), p.Quantity, p.TaxClass, p.Weight, p.Length, p.Width, p.Height)
+		}
+	}
+	mock.ExpectQuery(`SELECT .+ FROM products WHERE id IN \(.+\) AND deletedAt IS NULL FOR UPDATE`).WillReturnRows(products)
+
+	variants := sqlmock.NewRows([]string{"id", "productId", "sku", "price", "quantity"})
+	for _, v := range f.variants {
+		if requested[v.ProductID] {
+			var price any
+			if v.Price != nil {
+				price = v.Price.Decimal()
+			}
+			variants.AddRow(v.ID, v.ProductID, v.SKU, price, v.Quantity)
+		}
+	}
+	mock.ExpectQuery(`SELECT .+ FROM product_variants WHERE productId IN \(.+\) AND deletedAt IS NULL ORDER BY id FOR UPDATE`).WillReturnRows(variants)
+}
+
+// expectPricing expects the coupons, the tax rates, the tax exemption of the
+// user and the shipping methods of the destination of the checkout to be read
+func (f checkoutFixture) expectPricing(mock sqlmock.Sqlmock, checkout types.Checkout) {
+	if len(checkout.Coupons) > 0 {
+		coupons := sqlmock.NewRows([]string{"id", "code", "description", "type", "percent", "amount", "productId", "buyQuantity", "getQuantity",
+			"minOrderValue", "usageLimit", "perUserLimit", "usageCount", "stackable", "active", "startsAt", "endsAt", "createdAt"})
+		for _, p := range f.coupons {
+			coupons.AddRow(p.ID, p.Code, p.Description, p.Type, p.Percent, nil, nil, 0, 0, nil, nil, nil, p.UsageCount, p.Stackable, p.Active, nil, nil, time.Now())
+		}
+		mock.ExpectQuery(`SELECT .+ FROM promotions WHERE code IN \(.+\) FOR UPDATE`).WillReturnRows(coupons)
+	}
+
+	rates := sqlmock.NewRows([]string{"id", "country", "region", "taxClass", "name", "rate", "createdAt"})
+	for _, rate := range f.rates {
+		if rate.Country == checkout.Country {
+			rates.AddRow(rate.ID, rate.Country, rate.Region, rate.TaxClass, rate.Name, rate.Rate, time.Now())
+		}
+	}
+	mock.ExpectQuery(`SELECT .+ FROM tax_rates WHERE country = \?`).WithArgs(checkout.Country).WillReturnRows(rates)
+
+	mock.ExpectQuery(`SELECT taxExempt FROM users WHERE id = \?`).WithArgs(checkout.UserID).
+		WillReturnRows(sqlmock.NewRows([]string{"taxExempt"}).AddRow(false))
+
+	methods := sqlmock.NewRows([]string{"id", "zoneId", "name", "type", "amount", "tiers", "freeOver", "active", "createdAt"})
+	if checkout.Country == "GB" {
+		for _, m := range f.methods {
+			methods.AddRow(m.ID, m.ZoneID, m.Name, m.Type, m.Amount.Decimal(), nil, m.FreeOver.Decimal(), m.Active, time.Now())
+		}
+	}
+	mock.ExpectQuery(`SELECT .+ FROM shipping_methods`).WithArgs(checkout.Country, checkout.Region).WillReturnRows(methods)
+}
+
+// expectInserts expects the order, its lines, its coupons and its first status to be recorded and committed
+func expectInserts(mock sqlmock.Sqlmock, lines int, coupons int) {
+	mock.ExpectExec(`INSERT INTO orders`).WillReturnResult(sqlmock.NewResult(1, 1))
+	for i := 0; i < lines; i++ {
+		mock.ExpectExec(`INSERT INTO order_items`).WillReturnResult(sqlmock.NewResult(int64(i+1), 1))
+	}
+	for i := 0; i < coupons; i++ {
+		mock.ExpectExec(`INSERT INTO order_promotions`).WillReturnResult(sqlmock.NewResult(int64(i+1), 1))
+		mock.ExpectExec(`UPDATE promotions SET usageCount = usageCount \+ 1 WHERE id = \?`).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectExec(`INSERT INTO order_status_history`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+}
+
+// newMockStore returns a store on a mocked database whose
+// expectations are checked once the test is done
+func newMockStore(t *testing.T) (*Store, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	return NewStore(db), mock
+}
+
+func newCheckout(country, region string, items ...types.CartItem) types.Checkout {
+	return types.Checkout{
+		UserID:  1,
+		Address: "test address",
+		Country: country,
+		Region:  region,
+		Items:   items,
+		Rate:    types.BaseExchangeRate,
+	}
+}
+
+// TestPlaceOrder function to test the checkout transaction
+func TestPlaceOrder(t *testing.T) {
+	fixture := newCheckoutFixture()
+
+	t.Run("Should place the order and decrement the stock", func(t *testing.T) {
+		store, mock := newMockStore(t)
+		checkout := newCheckout("US", "NY", types.CartItem{ProductID: 1, Quantity: 2})
+
+		mock.ExpectBegin()
+		fixture.expectLocks(mock, checkout)
+		fixture.expectPricing(mock, checkout)
+		mock.ExpectExec(`UPDATE products SET quantity = quantity - \? WHERE id = \? AND quantity >= \?`).
+			WithArgs(2, 1, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+		expectInserts(mock, 1, 0)
+
+		o, err := store.PlaceOrder(checkout)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if o.ID != 1 || o.Total != types.NewMoney(2000, types.DefaultCurrency) || o.Status != types.OrderStatusPending {
+			t.Errorf("Expected the pending order 1 of 20.00 USD, got %+v", o)
+		}
+	})
+
+	t.Run("Should sell the last unit once when the checkouts wait for the lock", func(t *testing.T) {
+		store, mock := newMockStore(t)
+		checkout := newCheckout("US", "NY", types.CartItem{ProductID: 1, Quantity: 1})
+
+		last := newCheckoutFixture()
+		last.products[0].Quantity = 1
+
+		// the first checkout locks the last unit and takes it
+		mock.ExpectBegin()
+		last.expectLocks(mock, checkout)
+		last.expectPricing(mock, checkout)
+		mock.ExpectExec(`UPDATE products SET quantity = quantity - \?`).WithArgs(1, 1, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+		expectInserts(mock, 1, 0)
+
+		// the second one waited for the lock and reads the stock left by the first one
+		sold := newCheckoutFixture()
+		sold.products[0].Quantity = 0
+
+		mock.ExpectBegin()
+		sold.expectLocks(mock, checkout)
+		mock.ExpectRollback()
+
+		if _, err := store.PlaceOrder(checkout); err != nil {
+			t.Fatalf("Expected the first checkout to succeed, got %v", err)
+		}
+
+		if _, err := store.PlaceOrder(checkout); !errors.Is(err, ErrInsufficientStock) {
+			t.Errorf("Expected the second checkout to fail with %v, got %v", ErrInsufficientStock, err)
+		}
+	})
+
+	t.Run("Should roll back when the guarded decrement finds no stock", func(t *testing.T) {
+		store, mock := newMockStore(t)
+		checkout := newCheckout("US", "NY", types.CartItem{ProductID: 1, Quantity: 1})
+
+		mock.ExpectBegin()
+		fixture.expectLocks(mock, checkout)
+		fixture.expectPricing(mock, checkout)
+		mock.ExpectExec(`UPDATE products SET quantity = quantity - \?`).WithArgs(1, 1, 1).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		if _, err := store.PlaceOrder(checkout); !errors.Is(err, ErrInsufficientStock) {
+			t.Errorf("Expected %v, got %v", ErrInsufficientStock, err)
+		}
+	})
+
+	t.Run("Should roll back the decremented stock when the order cannot be recorded", func(t *testing.T) {
+		store, mock := newMockStore(t)
+		checkout := newCheckout("US", "NY", types.CartItem{ProductID: 1, Quantity: 2})
+
+		mock.ExpectBegin()
+		fixture.expectLocks(mock, checkout)
+		fixture.expectPricing(mock, checkout)
+		mock.ExpectExec(`UPDATE products SET quantity = quantity - \?`).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`INSERT INTO orders`).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(`INSERT INTO order_items`).WillReturnError(errors.New("connection reset"))
+		mock.ExpectRollback()
+
+		if _, err := store.PlaceOrder(checkout); err == nil {
+			t.Error("Expected the checkout to fail")
+		}
+	})
+
+	t.Run("Should check the stock before the order is priced", func(t *testing.T) {
+		for _, c := range []struct {
+			name  string
+			items []types.CartItem
+			err   error
+		}{
+			{"more than the stock", []types.CartItem{{ProductID: 1, Quantity: 10}}, ErrInsufficientStock},
+			{"repeated lines exceeding the stock", []types.CartItem{{ProductID: 1, Quantity: 3}, {ProductID: 1, Quantity: 3}}, ErrInsufficientStock},
+			{"a product that does not exist", []types.CartItem{{ProductID: 2, Quantity: 1}}, ErrProductNotFound},
+			{"a product with variants without a sku", []types.CartItem{{ProductID: 3, Quantity: 1}}, ErrVariantRequired},
+			{"more than the stock of the variant", []types.CartItem{{ProductID: 3, SKU: "SHIRT-M", Quantity: 2}}, ErrInsufficientStock},
+		} {
+			store, mock := newMockStore(t)
+			checkout := newCheckout("US", "NY", c.items...)
+
+			mock.ExpectBegin()
+			fixture.expectLocks(mock, checkout)
+			mock.ExpectRollback()
+
+			if _, err := store.PlaceOrder(checkout); !errors.Is(err, c.err) {
+				t.Errorf("%s: expected %v, got %v", c.name, c.err, err)
+			}
+		}
+	})
+
+	t.Run("Should price and decrement the variants of the order", func(t *testing.T) {
+		store, mock := newMockStore(t)
+		checkout := newCheckout("US", "NY", types.CartItem{ProductID: 3, SKU: "SHIRT-M", Quantity: 1}, types.CartItem{ProductID: 3, SKU: "SHIRT-L", Quantity: 2})
+
+		mock.ExpectBegin()
+		fixture.expectLocks(mock, checkout)
+		fixture.expectPricing(mock, checkout)
+		mock.ExpectExec(`UPDATE product_variants SET quantity = quantity - \? WHERE id = \? AND quantity >= \?`).
+			WithArgs(2, 2, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`UPDATE product_variants SET quantity = quantity - \? WHERE id = \? AND quantity >= \?`).
+			WithArgs(1, 1, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+		expectInserts(mock, 2, 0)
+
+		o, err := store.PlaceOrder(checkout)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if o.Total != types.NewMoney(6500, types.DefaultCurrency) {
+			t.Errorf("Expected total %v, got %v", "65.00 USD", o.Total)
+		}
+	})
+
+	t.Run("Should apply and count the coupons of the order", func(t *testing.T) {
+		store, mock := newMockStore(t)
+		checkout := newCheckout("US", "NY", types.CartItem{ProductID: 3, SKU: "SHIRT-M", Quantity: 1}, types.CartItem{ProductID: 3, SKU: "SHIRT-L", Quantity: 2})
+		checkout.Coupons = []string{"SAVE10"}
+
+		mock.ExpectBegin()
+		fixture.expectLocks(mock, checkout)
+		fixture.expectPricing(mock, checkout)
+		mock.ExpectExec(`UPDATE product_variants`).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`UPDATE product_variants`).WillReturnResult(sqlmock.NewResult(0, 1))
+		expectInserts(mock, 2, 1)
+
+		o, err := store.PlaceOrder(checkout)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if o.Discount != types.NewMoney(650, types.DefaultCurrency) || o.Total != types.NewMoney(5850, types.DefaultCurrency) {
+			t.Errorf("Expected a discount of 6.50 USD and a total of 58.50 USD, got %v and %v", o.Discount, o.Total)
+		}
+	})
+
+	t.Run("Should fail if a coupon does not exist", func(t *testing.T) {
+		store, mock := newMockStore(t)
+		checkout := newCheckout("US", "NY", types.CartItem{ProductID: 1, Quantity: 1})
+		checkout.Coupons = []string{"UNKNOWN"}
+
+		mock.ExpectBegin()
+		fixture.expectLocks(mock, checkout)
+		mock.ExpectQuery(`SELECT .+ FROM promotions WHERE code IN \(.+\) FOR UPDATE`).WithArgs("UNKNOWN").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectRollback()
+
+		if _, err := store.PlaceOrder(checkout); !errors.Is(err, promotion.ErrNotApplicable) {
+			t.Errorf("Expected %v, got %v", promotion.ErrNotApplicable, err)
+		}
+	})
+
+	t.Run("Should tax the order at the rate of the region", func(t *testing.T) {
+		for _, c := range []struct {
+			country string
+			region  string
+			tax     int64
+		}{
+			{"DE", "", 380},
+			{"US", "CA", 145},
+			{"US", "NY", 0},
+		} {
+			store, mock := newMockStore(t)
+			checkout := newCheckout(c.country, c.region, types.CartItem{ProductID: 1, Quantity: 2})
+
+			mock.ExpectBegin()
+			fixture.expectLocks(mock, checkout)
+			fixture.expectPricing(mock, checkout)
+			mock.ExpectExec(`UPDATE products`).WillReturnResult(sqlmock.NewResult(0, 1))
+			expectInserts(mock, 1, 0)
+
+			o, err := store.PlaceOrder(checkout)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if o.Tax != types.NewMoney(c.tax, types.DefaultCurrency) || o.Total != types.NewMoney(2000+c.tax, types.DefaultCurrency) {
+				t.Errorf("Expected a tax of %d cents in %s %s, got %v", c.tax, c.country, c.region, o.Tax)
+			}
+		}
+	})
+
+	t.Run("Should add the shipping method to the total", func(t *testing.T) {
+		methodID := 1
+		for _, c := range []struct {
+			item     types.CartItem
+			shipping int64
+			total    int64
+		}{
+			{types.CartItem{ProductID: 1, Quantity: 2}, 499, 2499},
+			{types.CartItem{ProductID: 3, SKU: "SHIRT-L", Quantity: 3}, 0, 6000},
+		} {
+			store, mock := newMockStore(t)
+			checkout := newCheckout("GB", "", c.item)
+			checkout.ShippingMethodID = &methodID
+
+			mock.ExpectBegin()
+			fixture.expectLocks(mock, checkout)
+			fixture.expectPricing(mock, checkout)
+			mock.ExpectExec(`UPDATE product`).WillReturnResult(sqlmock.NewResult(0, 1))
+			expectInserts(mock, 1, 0)
+
+			o, err := store.PlaceOrder(checkout)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if o.Shipping != types.NewMoney(c.shipping, types.DefaultCurrency) || o.Total != types.NewMoney(c.total, types.DefaultCurrency) {
+				t.Errorf("Expected a shipping of %d cents and a total of %d cents, got %v and %v", c.shipping, c.total, o.Shipping, o.Total)
+			}
+		}
+	})
+
+	t.Run("Should require a shipping method the destination has", func(t *testing.T) {
+		unknown := 7
+		for _, methodID := range []*int{nil, &unknown} {
+			store, mock := newMockStore(t)
+			checkout := newCheckout("GB", "", types.CartItem{ProductID: 1, Quantity: 1})
+			checkout.ShippingMethodID = methodID
+
+			mock.ExpectBegin()
+			fixture.expectLocks(mock, checkout)
+			fixture.expectPricing(mock, checkout)
+			mock.ExpectRollback()
+
+			_, err := store.PlaceOrder(checkout)
+			if !errors.Is(err, shipping.ErrMethodRequired) && !errors.Is(err, shipping.ErrMethodUnavailable) {
+				t.Errorf("Expected the shipping method to be required, got %v", err)
+			}
+		}
+	})
+}
+
+// orderRows returns the row of an order of 20.00 USD in the status
+func orderRows(id int, status types.OrderStatus) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "userId", "total", "discount", "tax", "shipping", "refunded", "shippingMethodId", "shippingMethod",
+		"taxInclusive", "taxExempt", "currency", "exchangeRate", "status", "address", "country", "region", "shippingAddress", "billingAddress", "createAt"}).
+		AddRow(id, 1, "20.00", "0.00", "0.00", "0.00", "0.00", nil, "", false, false, types.DefaultCurrency, "1", status, "test address", "US", "NY", nil, nil, time.Now())
+}
+
+// expectActivePayments expects the payments holding the money of the order to be counted
+func expectActivePayments(mock sqlmock.Sqlmock, active int) {
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM payments WHERE orderId = \? AND status IN`).
+		WithArgs(1, types.PaymentRequiresAction, types.PaymentAuthorized, types.PaymentCaptured, types.PaymentPartiallyRefunded).
+		WillReturnRows(sqlmock.NewRows([]string{"active"}).AddRow(active))
+}
+
+// TestUpdateOrderStatus function to test the status changes of the orders
+func TestUpdateOrderStatus(t *testing.T) {
+	t.Run("Should restock the units that were not refunded when cancelling", func(t *testing.T) {
+		store, mock := newMockStore(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT .+ FROM orders WHERE id = \? FOR UPDATE`).WithArgs(1).WillReturnRows(orderRows(1, types.OrderStatusPending))
+		expectActivePayments(mock, 0)
+		mock.ExpectExec(`UPDATE orders SET status = \? WHERE id = \?`).WithArgs(types.OrderStatusCancelled, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`UPDATE products p .+ SET p.quantity = p.quantity \+ oi.quantity - oi.refundedQuantity`).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`UPDATE product_variants v .+ SET v.quantity = v.quantity \+ oi.quantity - oi.refundedQuantity`).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`UPDATE promotions p`).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`INSERT INTO order_status_history`).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		o, err := store.UpdateOrderStatus(1, types.OrderStatusCancelled, 1, "changed my mind")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if o.Status != types.OrderStatusCancelled {
+			t.Errorf("Expected the order to be cancelled, got %s", o.Status)
+		}
+	})
+
+	t.Run("Should not cancel a paid order", func(t *testing.T) {
+		store, mock := newMockStore(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT .+ FROM orders WHERE id = \? FOR UPDATE`).WithArgs(1).WillReturnRows(orderRows(1, types.OrderStatusPaid))
+		mock.ExpectRollback()
+
+		if _, err := store.UpdateOrderStatus(1, types.OrderStatusCancelled, 1, ""); !errors.Is(err, ErrInvalidTransition) {
+			t.Errorf("Expected %v, got %v", ErrInvalidTransition, err)
+		}
+	})
+
+	t.Run("Should not cancel an order while its payment holds the money", func(t *testing.T) {
+		store, mock := newMockStore(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT .+ FROM orders WHERE id = \? FOR UPDATE`).WithArgs(1).WillReturnRows(orderRows(1, types.OrderStatusPending))
+		expectActivePayments(mock, 1)
+		mock.ExpectRollback()
+
+		if _, err := store.UpdateOrderStatus(1, types.OrderStatusCancelled, 1, ""); !errors.Is(err, ErrActivePayment) {
+			t.Errorf("Expected %v, got %v", ErrActivePayment, err)
+		}
+	})
+}
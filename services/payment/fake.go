@@ -0,0 +1,163 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// FakeGatewayName is the name the fake gateway records its payments with
+const FakeGatewayName = "fake"
+
+// FakeMode is what the fake gateway does with the payments it authorizes
+type FakeMode string
+
+// Modes of the fake gateway
+const (
+	FakeSucceed FakeMode = "succeed"
+	FakeDecline FakeMode = "decline"
+	FakeAction  FakeMode = "action"
+)
+
+// Test cards of the fake gateway, they behave the same whatever the mode
+const (
+	FakeCardSucceed = "tok_succeed"
+	FakeCardDecline = "tok_decline"
+	FakeCardAction  = "tok_action"
+)
+
+// FakeDeclineReason is the reason given for the payments the fake gateway declines
+const FakeDeclineReason = "card_declined"
+
+// ErrUnknownReference is returned for a payment the gateway does not know of
+var ErrUnknownReference = errors.New("unknown payment reference")
+
+// FakeGateway is a deterministic in-memory gateway to exercise the checkout
+// locally and in tests. The test cards decide the outcome of an authorization,
+// the mode decides it for any other payment method. References are numbered
+// in order and the payments are lost when the process exits
+type FakeGateway struct {
+	mode FakeMode
+
+	mu       sync.Mutex
+	seq      int
+	payments map[string]*types.Payment
+}
+
+// NewFakeGateway function to return a fake gateway in the mode
+func NewFakeGateway(mode FakeMode) (*FakeGateway, error) {
+	switch mode {
+	case FakeSucceed, FakeDecline, FakeAction:
+	default:
+		return nil, fmt.Errorf("unknown fake gateway mode %q", mode)
+	}
+
+	return &FakeGateway{mode: mode, payments: map[string]*types.Payment{}}, nil
+}
+
+// Name returns the name of the gateway
+func (g *FakeGateway) Name() string {
+	return FakeGatewayName
+}
+
+// Authorize holds the amount, declines the payment or asks for an action
+func (g *FakeGateway) Authorize(ctx context.Context, req types.AuthorizeRequest) (*types.GatewayResult, error) {
+	if req.Amount.IsZero() || req.Amount.IsNegative() {
+		return nil, fmt.Errorf("the amount must be positive, got %v", req.Amount)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.seq++
+	reference := fmt.Sprintf("fake_%d_%d", req.OrderID, g.seq)
+
+	switch g.modeFor(req.PaymentMethod) {
+	case FakeDecline:
+		return &types.GatewayResult{Reference: reference, Status: types.PaymentDeclined, DeclineReason: FakeDeclineReason}, nil
+	case FakeAction:
+		g.payments[reference] = NewPayment(reference, types.PaymentRequiresAction, req.Amount)
+		return &types.GatewayResult{
+			Reference: reference,
+			Status:    types.PaymentRequiresAction,
+			ActionURL: "https://fake-gateway.test/authenticate/" + reference,
+		}, nil
+	}
+
+	g.payments[reference] = NewPayment(reference, types.PaymentAuthorized, req.Amount)
+	return &types.GatewayResult{Reference: reference, Status: types.PaymentAuthorized}, nil
+}
+
+// Confirm authorizes a payment once the customer completed the action
+func (g *FakeGateway) Confirm(ctx context.Context, reference string) (*types.GatewayResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	p, ok := g.payments[reference]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownReference, reference)
+	}
+
+	if p.Status != types.PaymentRequiresAction {
+		return nil, fmt.Errorf("%w: cannot confirm a %s payment", ErrInvalidOperation, p.Status)
+	}
+
+	p.Status = types.PaymentAuthorized
+	return &types.GatewayResult{Reference: reference, Status: p.Status}, nil
+}
+
+// Capture takes the amount of an authorized payment
+func (g *FakeGateway) Capture(ctx context.Context, reference string, amount types.Money) (*types.GatewayResult, error) {
+	return g.apply(reference, func(p *types.Payment) error {
+		return Capture(p, amount)
+	})
+}
+
+// Void releases a payment that was not captured
+func (g *FakeGateway) Void(ctx context.Context, reference string) (*types.GatewayResult, error) {
+	return g.apply(reference, Void)
+}
+
+// Refund gives back the amount of a captured payment
+func (g *FakeGateway) Refund(ctx context.Context, reference string, amount types.Money) (*types.GatewayResult, error) {
+	return g.apply(reference, func(p *types.Payment) error {
+		return Refund(p, amount)
+	})
+}
+
+// apply runs the operation on a copy of the payment and keeps the copy
+// only when the operation succeeds
+func (g *FakeGateway) apply(reference string, op func(*types.Payment) error) (*types.GatewayResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	p, ok := g.payments[reference]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownReference, reference)
+	}
+
+	next := *p
+	if err := op(&next); err != nil {
+		return nil, err
+	}
+
+	*p = next
+	return &types.GatewayResult{Reference: reference, Status: p.Status}, nil
+}
+
+// modeFor returns the mode of the test card or the mode of the gateway
+func (g *FakeGateway) modeFor(paymentMethod string) FakeMode {
+	switch paymentMethod {
+	case FakeCardSucceed:
+		return FakeSucceed
+	case FakeCardDecline:
+		return FakeDecline
+	case FakeCardAction:
+		return FakeAction
+	}
+
+	return g.mode
+}
@@ -0,0 +1,117 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+func usd(amount int64) types.Money {
+	return types.NewMoney(amount, types.DefaultCurrency)
+}
+
+// TestFakeGateway function to test the outcomes of the fake gateway
+func TestFakeGateway(t *testing.T) {
+	ctx := context.Background()
+
+	if _, err := NewFakeGateway("flaky"); err == nil {
+		t.Error("Expected the unknown mode to be rejected")
+	}
+
+	for _, c := range []struct {
+		mode          FakeMode
+		paymentMethod string
+		status        types.PaymentStatus
+	}{
+		{FakeSucceed, "tok_visa", types.PaymentAuthorized},
+		{FakeDecline, "tok_visa", types.PaymentDeclined},
+		{FakeAction, "tok_visa", types.PaymentRequiresAction},
+		{FakeDecline, FakeCardSucceed, types.PaymentAuthorized},
+		{FakeSucceed, FakeCardDecline, types.PaymentDeclined},
+		{FakeSucceed, FakeCardAction, types.PaymentRequiresAction},
+	} {
+		gateway, err := NewFakeGateway(c.mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := gateway.Authorize(ctx, types.AuthorizeRequest{OrderID: 7, Amount: usd(1000), PaymentMethod: c.paymentMethod})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if result.Status != c.status || result.Reference != "fake_7_1" {
+			t.Errorf("Expected a %s payment fake_7_1 in the %s mode with %s, got %+v", c.status, c.mode, c.paymentMethod, result)
+		}
+
+		if (result.Status == types.PaymentRequiresAction) != (result.ActionURL != "") {
+			t.Errorf("Expected an action url only for the payments requiring an action, got %+v", result)
+		}
+	}
+
+	t.Run("Should capture and refund the authorized payment", func(t *testing.T) {
+		gateway, _ := NewFakeGateway(FakeSucceed)
+
+		result, _ := gateway.Authorize(ctx, types.AuthorizeRequest{OrderID: 1, Amount: usd(1000), PaymentMethod: "tok_visa"})
+
+		if _, err := gateway.Refund(ctx, result.Reference, usd(100)); !errors.Is(err, ErrInvalidOperation) {
+			t.Errorf("Expected the refund of an uncaptured payment to fail, got %v", err)
+		}
+
+		if _, err := gateway.Capture(ctx, result.Reference, usd(1001)); !errors.Is(err, ErrAmountTooLarge) {
+			t.Errorf("Expected the capture over the authorized amount to fail, got %v", err)
+		}
+
+		if res, err := gateway.Capture(ctx, result.Reference, usd(800)); err != nil || res.Status != types.PaymentCaptured {
+			t.Fatalf("Expected the payment to be captured, got %+v, error: %v", res, err)
+		}
+
+		if res, err := gateway.Refund(ctx, result.Reference, usd(300)); err != nil || res.Status != types.PaymentPartiallyRefunded {
+			t.Errorf("Expected the payment to be partially refunded, got %+v, error: %v", res, err)
+		}
+
+		if _, err := gateway.Refund(ctx, result.Reference, usd(501)); !errors.Is(err, ErrAmountTooLarge) {
+			t.Errorf("Expected the refund over the captured amount to fail, got %v", err)
+		}
+
+		if res, err := gateway.Refund(ctx, result.Reference, usd(500)); err != nil || res.Status != types.PaymentRefunded {
+			t.Errorf("Expected the payment to be refunded, got %+v, error: %v", res, err)
+		}
+
+		if _, err := gateway.Void(ctx, result.Reference); !errors.Is(err, ErrInvalidOperation) {
+			t.Errorf("Expected the void of a refunded payment to fail, got %v", err)
+		}
+	})
+
+	t.Run("Should confirm the payment requiring an action", func(t *testing.T) {
+		gateway, _ := NewFakeGateway(FakeAction)
+
+		result, _ := gateway.Authorize(ctx, types.AuthorizeRequest{OrderID: 1, Amount: usd(1000), PaymentMethod: "tok_visa"})
+
+		if _, err := gateway.Capture(ctx, result.Reference, usd(1000)); !errors.Is(err, ErrInvalidOperation) {
+			t.Errorf("Expected the capture before the confirmation to fail, got %v", err)
+		}
+
+		if res, err := gateway.Confirm(ctx, result.Reference); err != nil || res.Status != types.PaymentAuthorized {
+			t.Errorf("Expected the payment to be authorized, got %+v, error: %v", res, err)
+		}
+
+		if _, err := gateway.Confirm(ctx, result.Reference); !errors.Is(err, ErrInvalidOperation) {
+			t.Errorf("Expected the payment to be confirmed once, got %v", err)
+		}
+
+		if res, err := gateway.Void(ctx, result.Reference); err != nil || res.Status != types.PaymentVoided {
+			t.Errorf("Expected the payment to be voided, got %+v, error: %v", res, err)
+		}
+	})
+
+	t.Run("Should fail for an unknown reference", func(t *testing.T) {
+		gateway, _ := NewFakeGateway(FakeSucceed)
+
+		if _, err := gateway.Capture(ctx, "fake_1_1", usd(100)); !errors.Is(err, ErrUnknownReference) {
+			t.Errorf("Expected the reference to be unknown, got %v", err)
+		}
+	})
+}
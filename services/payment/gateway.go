@@ -0,0 +1,27 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/akshtrikha/golang-ecomm/config"
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// ErrUnknownGateway is returned when the configured gateway does not exist
+var ErrUnknownGateway = errors.New("unknown payment gateway")
+
+// NewGateway function to return the payment gateway selected by the configuration
+func NewGateway(cfg config.Config) (types.PaymentGateway, error) {
+	switch cfg.PaymentGateway {
+	case FakeGatewayName:
+		gateway, err := NewFakeGateway(FakeMode(cfg.FakeGatewayMode))
+		if err != nil {
+			return nil, err
+		}
+
+		return gateway, nil
+	}
+
+	return nil, fmt.Errorf("%w: %q", ErrUnknownGateway, cfg.PaymentGateway)
+}
@@ -0,0 +1,393 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/akshtrikha/golang-ecomm/config"
+	"github.com/akshtrikha/golang-ecomm/services/auth"
+	"github.com/akshtrikha/golang-ecomm/services/order"
+	"github.com/akshtrikha/golang-ecomm/types"
+	"github.com/akshtrikha/golang-ecomm/utils"
+	"github.com/gorilla/mux"
+)
+
+// errGateway wraps the errors of the payment gateway
+var errGateway = errors.New("the payment gateway failed")
+
+// API Endpoints for the customers to pay their orders and for the admins
+// to capture and void the payments, the orders are refunded with credit notes
+
+// Handler to the payment store which will deal with the database
+// regarding payments, the payments are made with the gateway
+type Handler struct {
+	store      types.PaymentStore
+	orderStore types.OrderStore
+	gateway    types.PaymentGateway
+	// capture the payments as soon as they are authorized
+	autoCapture bool
+}
+
+// NewHandler constructor takes PaymentStore, OrderStore and PaymentGateway as dependencies
+func NewHandler(store types.PaymentStore, orderStore types.OrderStore, gateway types.PaymentGateway) *Handler {
+	return &Handler{store: store, orderStore: orderStore, gateway: gateway, autoCapture: config.Envs.PaymentAutoCapture}
+}
+
+// RegisterRoutes func for payments
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/orders/{id:[0-9]+}/payments", auth.RequireAuth(h.handlePayOrder)).Methods("POST")
+	router.HandleFunc("/orders/{id:[0-9]+}/payments", auth.RequireAuth(h.handleGetOrderPayments)).Methods("GET")
+	router.HandleFunc("/payments/{id:[0-9]+}/confirm", auth.RequireAuth(h.handleConfirmPayment)).Methods("POST")
+	router.HandleFunc("/admin/payments/{id:[0-9]+}/capture", auth.RequireRole(auth.RoleAdmin, h.handleCapturePayment)).Methods("POST")
+	router.HandleFunc("/admin/payments/{id:[0-9]+}/void", auth.RequireRole(auth.RoleAdmin, h.handleVoidPayment)).Methods("POST")
+}
+
+func (h *Handler) handlePayOrder(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle POST /orders/{id}/payments endpoint hit")
+
+	userID, _ := auth.UserIDFromContext(r.Context())
+
+	orderID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid order id"))
+		return
+	}
+
+	// get the json payload
+	var payload types.AuthorizePaymentPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		log.Println("Payload parsing met with an error")
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// validate the json payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.ValidationError("Invalid payload", err))
+		return
+	}
+
+	// only the customer who placed the order pays it
+	o, err := h.orderStore.GetOrderByID(orderID)
+	if errors.Is(err, order.ErrOrderNotFound) || (err == nil && o.UserID != userID) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("order with id: %v not found", orderID))
+		return
+	}
+	if err != nil {
+		log.Printf("Error finding the order %v, error: %+v", orderID, err)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if o.Total.IsZero() {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("order %v has nothing to pay", orderID))
+		return
+	}
+
+	// the order and its payments stay locked until the payment is recorded,
+	// an order is paid by one payment at a time
+	p, err := h.store.CreatePayment(orderID, func(o *types.Order, payments []types.Payment) (*types.Payment, error) {
+		if !order.IsAwaitingPayment(o.Status) {
+			return nil, fmt.Errorf("%w: order %v is %s and cannot be paid", ErrNotPayable, orderID, o.Status)
+		}
+
+		for _, p := range payments {
+			if IsActive(p) {
+				return nil, fmt.Errorf("%w: order %v already has the %s payment %v", ErrNotPayable, orderID, p.Status, p.ID)
+			}
+		}
+
+		result, err := h.gateway.Authorize(r.Context(), types.AuthorizeRequest{
+			OrderID:       orderID,
+			Amount:        o.Total,
+			PaymentMethod: payload.PaymentMethod,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errGateway, err)
+		}
+
+		p := NewPayment(result.Reference, result.Status, o.Total)
+		p.Gateway = h.gateway.Name()
+		p.ActionURL = result.ActionURL
+		p.DeclineReason = result.DeclineReason
+
+		return p, nil
+	})
+	if err != nil {
+		log.Printf("Error paying the order %v, error: %+v", orderID, err)
+		utils.WriteError(w, statusFor(err), err)
+		return
+	}
+
+	log.Printf("Payment %v of order %v is %s", p.ID, orderID, p.Status)
+
+	h.writePayment(r.Context(), w, p, userID)
+}
+
+func (h *Handler) handleGetOrderPayments(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle GET /orders/{id}/payments endpoint hit")
+
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	orderID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid order id"))
+		return
+	}
+
+	o, err := h.orderStore.GetOrderByID(orderID)
+	if errors.Is(err, order.ErrOrderNotFound) || (err == nil && !principal.IsAdmin() && o.UserID != principal.UserID) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("order with id: %v not found", orderID))
+		return
+	}
+	if err != nil {
+		log.Printf("Error finding the order %v, error: %+v", orderID, err)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	payments, err := h.store.GetPaymentsByOrderID(orderID)
+	if err != nil {
+		log.Println("Error fetching the payments of the order from the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, payments)
+}
+
+func (h *Handler) handleConfirmPayment(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle POST /payments/{id}/confirm endpoint hit")
+
+	userID, _ := auth.UserIDFromContext(r.Context())
+
+	p, status, err := h.getPayment(r)
+	if err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	o, err := h.orderStore.GetOrderByID(p.OrderID)
+	if errors.Is(err, order.ErrOrderNotFound) || (err == nil && o.UserID != userID) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("payment with id: %v not found", p.ID))
+		return
+	}
+	if err != nil {
+		log.Printf("Error finding the order %v, error: %+v", p.OrderID, err)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// the order may have been cancelled while the customer completed the action
+	confirmed, err := h.store.UpdatePayment(p.ID, func(o *types.Order, next *types.Payment) error {
+		if next.Status != types.PaymentRequiresAction {
+			return fmt.Errorf("%w: cannot confirm a %s payment", ErrInvalidOperation, next.Status)
+		}
+
+		if !order.IsAwaitingPayment(o.Status) {
+			return fmt.Errorf("%w: order %v is %s and cannot be paid", ErrNotPayable, o.ID, o.Status)
+		}
+
+		result, err := h.gateway.Confirm(r.Context(), next.Reference)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errGateway, err)
+		}
+
+		next.Status = result.Status
+		next.ActionURL = ""
+		next.DeclineReason = result.DeclineReason
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error confirming the payment %v, error: %+v", p.ID, err)
+		utils.WriteError(w, statusFor(err), err)
+		return
+	}
+	p = confirmed
+
+	log.Printf("Payment %v of order %v is %s", p.ID, p.OrderID, p.Status)
+
+	h.writePayment(r.Context(), w, p, userID)
+}
+
+func (h *Handler) handleCapturePayment(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle POST /admin/payments/{id}/capture endpoint hit")
+
+	userID, _ := auth.UserIDFromContext(r.Context())
+
+	p, status, err := h.getPayment(r)
+	if err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	amount, err := parseAmount(r, p.Amount)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if status, err := h.capture(r.Context(), p, amount, userID); err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, p)
+}
+
+func (h *Handler) handleVoidPayment(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle POST /admin/payments/{id}/void endpoint hit")
+
+	p, status, err := h.getPayment(r)
+	if err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	voided, err := h.store.UpdatePayment(p.ID, func(o *types.Order, next *types.Payment) error {
+		if err := Void(next); err != nil {
+			return err
+		}
+
+		if _, err := h.gateway.Void(r.Context(), next.Reference); err != nil {
+			return fmt.Errorf("%w: %v", errGateway, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error voiding the payment %v, error: %+v", p.ID, err)
+		utils.WriteError(w, statusFor(err), err)
+		return
+	}
+
+	log.Printf("Payment %v of order %v voided", p.ID, p.OrderID)
+
+	utils.WriteJSON(w, http.StatusOK, voided)
+}
+
+// writePayment writes the payment with the status code of its status,
+// authorized payments are captured first when auto capture is set
+func (h *Handler) writePayment(ctx context.Context, w http.ResponseWriter, p *types.Payment, userID int) {
+	switch p.Status {
+	case types.PaymentDeclined:
+		utils.WriteJSON(w, http.StatusPaymentRequired, p)
+		return
+	case types.PaymentRequiresAction:
+		utils.WriteJSON(w, http.StatusAccepted, p)
+		return
+	}
+
+	if h.autoCapture {
+		if status, err := h.capture(ctx, p, p.Amount, userID); err != nil {
+			utils.WriteError(w, status, err)
+			return
+		}
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, p)
+}
+
+// capture takes the amount of the payment at the gateway and moves its order to paid,
+// the payments of the orders that are no longer awaiting payment are not captured.
+// The returned status code is meant to be used when an error is returned.
+func (h *Handler) capture(ctx context.Context, p *types.Payment, amount types.Money, changedBy int) (int, error) {
+	captured, err := h.store.UpdatePayment(p.ID, func(o *types.Order, next *types.Payment) error {
+		if err := Capture(next, amount); err != nil {
+			return err
+		}
+
+		if !order.IsAwaitingPayment(o.Status) {
+			return fmt.Errorf("%w: order %v is %s and cannot be paid", ErrNotPayable, o.ID, o.Status)
+		}
+
+		if _, err := h.gateway.Capture(ctx, next.Reference, amount); err != nil {
+			return fmt.Errorf("%w: %v", errGateway, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error capturing the payment %v, error: %+v", p.ID, err)
+		return statusFor(err), err
+	}
+	*p = *captured
+
+	log.Printf("Captured %v of the payment %v of order %v", amount, p.ID, p.OrderID)
+
+	// the order can not be cancelled while its payment is captured,
+	// the request fails so the order is not left pending unnoticed
+	note := fmt.Sprintf("payment %v captured", p.ID)
+	if _, err := h.orderStore.UpdateOrderStatus(p.OrderID, types.OrderStatusPaid, changedBy, note); err != nil {
+		log.Printf("Error moving the order %v to paid, error: %+v", p.OrderID, err)
+		return http.StatusInternalServerError, err
+	}
+
+	return http.StatusOK, nil
+}
+
+// getPayment returns the payment of the id of the path.
+// The returned status code is meant to be used when an error is returned.
+func (h *Handler) getPayment(r *http.Request) (*types.Payment, int, error) {
+	paymentID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid payment id")
+	}
+
+	p, err := h.store.GetPaymentByID(paymentID)
+	if errors.Is(err, ErrPaymentNotFound) {
+		return nil, http.StatusNotFound, fmt.Errorf("payment with id: %v not found", paymentID)
+	}
+	if err != nil {
+		log.Printf("Error finding the payment %v, error: %+v", paymentID, err)
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return p, http.StatusOK, nil
+}
+
+// parseAmount returns the amount of the payload, the fallback when
+// the payload or its amount is missing
+func parseAmount(r *http.Request, fallback types.Money) (types.Money, error) {
+	// get the json payload
+	var payload types.PaymentAmountPayload
+	if err := utils.ParseJSON(r, &payload); err != nil && !errors.Is(err, io.EOF) {
+		return fallback, err
+	}
+
+	// validate the json payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		return fallback, utils.ValidationError("Invalid payload", err)
+	}
+
+	if payload.Amount == nil {
+		return fallback, nil
+	}
+
+	if !payload.Amount.SameCurrency(fallback) {
+		return fallback, fmt.Errorf("the amount must be in %s", fallback.Currency)
+	}
+
+	return *payload.Amount, nil
+}
+
+// statusFor returns the status code of an error of an operation on a payment
+func statusFor(err error) int {
+	switch {
+	case errors.Is(err, ErrInvalidOperation), errors.Is(err, ErrNotPayable):
+		return http.StatusConflict
+	case errors.Is(err, ErrAmountTooLarge), errors.Is(err, ErrInvalidAmount):
+		return http.StatusBadRequest
+	case errors.Is(err, errGateway):
+		return http.StatusBadGateway
+	case errors.Is(err, ErrPaymentNotFound), errors.Is(err, order.ErrOrderNotFound):
+		return http.StatusNotFound
+	}
+
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,395 @@
+package payment
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/akshtrikha/golang-ecomm/services/auth"
+	"github.com/akshtrikha/golang-ecomm/services/order"
+	"github.com/akshtrikha/golang-ecomm/types"
+	"github.com/gorilla/mux"
+)
+
+// mockPaymentStore keeps the payments in memory
+// along with the orders they pay
+type mockPaymentStore struct {
+	payments   map[int]types.Payment
+	orderStore *mockOrderStore
+}
+
+func (m *mockPaymentStore) GetPaymentByID(id int) (*types.Payment, error) {
+	p, ok := m.payments[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrPaymentNotFound, id)
+	}
+
+	return &p, nil
+}
+
+func (m *mockPaymentStore) GetPaymentsByOrderID(orderID int) ([]types.Payment, error) {
+	payments := []types.Payment{}
+	for _, p := range m.payments {
+		if p.OrderID == orderID {
+			payments = append(payments, p)
+		}
+	}
+
+	return payments, nil
+}
+
+func (m *mockPaymentStore) GetPaymentByReference(gateway string, reference string) (*types.Payment, error) {
+	for _, p := range m.payments {
+		if p.Gateway == gateway && p.Reference == reference {
+			return &p, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %v", ErrPaymentNotFound, reference)
+}
+
+func (m *mockPaymentStore) CreatePayment(orderID int, authorize func(*types.Order, []types.Payment) (*types.Payment, error)) (*types.Payment, error) {
+	o, err := m.orderStore.GetOrderByID(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	payments, _ := m.GetPaymentsByOrderID(orderID)
+
+	p, err := authorize(o, payments)
+	if err != nil {
+		return nil, err
+	}
+
+	p.ID = len(m.payments) + 1
+	p.OrderID = orderID
+	m.payments[p.ID] = *p
+
+	return p, nil
+}
+
+func (m *mockPaymentStore) UpdatePayment(id int, update func(*types.Order, *types.Payment) error) (*types.Payment, error) {
+	p, err := m.GetPaymentByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	o, err := m.orderStore.GetOrderByID(p.OrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := update(o, p); err != nil {
+		return nil, err
+	}
+
+	m.payments[id] = *p
+	return p, nil
+}
+
+// mockOrderStore keeps the orders in memory and moves them
+// through the order state machine
+type mockOrderStore struct {
+	orders map[int]types.Order
+}
+
+func (m *mockOrderStore) PlaceOrder(checkout types.Checkout) (*types.Order, error) {
+	return nil, nil
+}
+
+func (m *mockOrderStore) GetOrderByID(id int) (*types.Order, error) {
+	o, ok := m.orders[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", order.ErrOrderNotFound, id)
+	}
+
+	return &o, nil
+}
+
+func (m *mockOrderStore) GetOrdersByUserID(userID int) ([]types.Order, error) {
+	return []types.Order{}, nil
+}
+
+func (m *mockOrderStore) GetAllOrders() ([]types.Order, error) {
+	return []types.Order{}, nil
+}
+
+func (m *mockOrderStore) GetOrderItems(orderID int) ([]types.OrderItem, error) {
+	return []types.OrderItem{}, nil
+}
+
+func (m *mockOrderStore) GetOrderPromotions(orderID int) ([]types.AppliedPromotion, error) {
+	return []types.AppliedPromotion{}, nil
+}
+
+func (m *mockOrderStore) UpdateOrderStatus(orderID int, status types.OrderStatus, changedBy int, note string) (*types.Order, error) {
+	o, err := m.GetOrderByID(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := order.ValidateTransition(o.Status, status); err != nil {
+		return nil, err
+	}
+
+	o.Status = status
+	m.orders[orderID] = *o
+
+	return o, nil
+}
+
+func (m *mockOrderStore) GetOrderStatusHistory(orderID int) ([]types.OrderStatusChange, error) {
+	return []types.OrderStatusChange{}, nil
+}
+
+// TestPaymentServiceHandlers function to implement testing
+func TestPaymentServiceHandlers(t *testing.T) {
+	// the orders of 20.00 of the customer 2
+	orderStore := &mockOrderStore{orders: map[int]types.Order{}}
+	for id := 1; id <= 5; id++ {
+		orderStore.orders[id] = types.Order{ID: id, UserID: 2, Total: usd(2000), Currency: types.DefaultCurrency, Status: types.OrderStatusPending}
+	}
+
+	store := &mockPaymentStore{payments: map[int]types.Payment{}, orderStore: orderStore}
+
+	gateway, err := NewFakeGateway(FakeSucceed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := NewHandler(store, orderStore, gateway)
+
+	adminToken, err := auth.GenerateJWT(1, []string{auth.RoleAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	customerToken, err := auth.GenerateJWT(2, []string{auth.RoleCustomer})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherToken, err := auth.GenerateJWT(3, []string{auth.RoleCustomer})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(method, path string, body string, token string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+		router.Use(auth.JWTMiddleware)
+
+		handler.RegisterRoutes(router)
+		router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	decode := func(rr *httptest.ResponseRecorder) types.Payment {
+		var p types.Payment
+		if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+			t.Fatal(err)
+		}
+
+		return p
+	}
+
+	t.Run("Should fail if the payment method is missing", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/orders/1/payments", `{}`, customerToken)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("Should not let a customer pay the order of another", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/orders/1/payments", `{"paymentMethod":"tok_visa"}`, otherToken)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("Should record the declined payment", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/orders/1/payments", `{"paymentMethod":"tok_decline"}`, customerToken)
+
+		if rr.Code != http.StatusPaymentRequired {
+			t.Fatalf("Expected status code %d, got %d", http.StatusPaymentRequired, rr.Code)
+		}
+
+		if p := decode(rr); p.Status != types.PaymentDeclined || p.DeclineReason != FakeDeclineReason {
+			t.Errorf("Expected a declined payment, got %+v", p)
+		}
+
+		if orderStore.orders[1].Status != types.OrderStatusPending {
+			t.Errorf("Expected the order to stay pending, got %s", orderStore.orders[1].Status)
+		}
+	})
+
+	t.Run("Should capture the payment and mark the order as paid", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/orders/1/payments", `{"paymentMethod":"tok_visa"}`, customerToken)
+
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		if p := decode(rr); p.Status != types.PaymentCaptured || p.Captured != usd(2000) || p.Gateway != FakeGatewayName {
+			t.Errorf("Expected a captured payment of 20.00, got %+v", p)
+		}
+
+		if orderStore.orders[1].Status != types.OrderStatusPaid {
+			t.Errorf("Expected the order to be paid, got %s", orderStore.orders[1].Status)
+		}
+
+		if rr := serve(http.MethodPost, "/orders/1/payments", `{"paymentMethod":"tok_visa"}`, customerToken); rr.Code != http.StatusConflict {
+			t.Errorf("Expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+	})
+
+	t.Run("Should list the payments of the order", func(t *testing.T) {
+		rr := serve(http.MethodGet, "/orders/1/payments", "", adminToken)
+
+		var payments []types.Payment
+		if err := json.NewDecoder(rr.Body).Decode(&payments); err != nil || len(payments) != 2 {
+			t.Errorf("Expected the 2 payments of the order, got %+v, error: %v", payments, err)
+		}
+
+		if rr := serve(http.MethodGet, "/orders/1/payments", "", otherToken); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("Should confirm the payment requiring an action", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/orders/2/payments", `{"paymentMethod":"tok_action"}`, customerToken)
+
+		if rr.Code != http.StatusAccepted {
+			t.Fatalf("Expected status code %d, got %d", http.StatusAccepted, rr.Code)
+		}
+
+		p := decode(rr)
+		if p.ActionURL == "" {
+			t.Errorf("Expected an action url, got %+v", p)
+		}
+
+		path := fmt.Sprintf("/payments/%d/confirm", p.ID)
+		if rr := serve(http.MethodPost, path, "", otherToken); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+
+		if rr := serve(http.MethodPost, path, "", customerToken); rr.Code != http.StatusCreated {
+			t.Errorf("Expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		if orderStore.orders[2].Status != types.OrderStatusPaid {
+			t.Errorf("Expected the order to be paid, got %s", orderStore.orders[2].Status)
+		}
+
+		if rr := serve(http.MethodPost, path, "", customerToken); rr.Code != http.StatusConflict {
+			t.Errorf("Expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+	})
+
+	t.Run("Should let the admins capture and void the authorized payments", func(t *testing.T) {
+		handler.autoCapture = false
+		defer func() { handler.autoCapture = true }()
+
+		rr := serve(http.MethodPost, "/orders/3/payments", `{"paymentMethod":"tok_visa"}`, customerToken)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		p := decode(rr)
+		if p.Status != types.PaymentAuthorized || orderStore.orders[3].Status != types.OrderStatusPending {
+			t.Errorf("Expected an authorized payment of a pending order, got %+v", p)
+		}
+
+		path := fmt.Sprintf("/admin/payments/%d", p.ID)
+		if rr := serve(http.MethodPost, path+"/capture", "", customerToken); rr.Code != http.StatusForbidden {
+			t.Errorf("Expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+
+		if rr := serve(http.MethodPost, path+"/capture", `{"amount":"20.01"}`, adminToken); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+
+		if rr := serve(http.MethodPost, path+"/capture", `{"amount":"15.00"}`, adminToken); rr.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		if p := store.payments[p.ID]; p.Captured != usd(1500) || orderStore.orders[3].Status != types.OrderStatusPaid {
+			t.Errorf("Expected 15.00 captured and the order paid, got %+v", p)
+		}
+
+		if rr := serve(http.MethodPost, path+"/void", "", adminToken); rr.Code != http.StatusConflict {
+			t.Errorf("Expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+
+		rr = serve(http.MethodPost, "/orders/4/payments", `{"paymentMethod":"tok_visa"}`, customerToken)
+		path = fmt.Sprintf("/admin/payments/%d", decode(rr).ID)
+
+		if rr := serve(http.MethodPost, path+"/void", "", adminToken); rr.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		// the order can be paid again once its payment is voided
+		if rr := serve(http.MethodPost, "/orders/4/payments", `{"paymentMethod":"tok_visa"}`, customerToken); rr.Code != http.StatusCreated {
+			t.Errorf("Expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+	})
+
+	t.Run("Should not capture or confirm the payments of a cancelled order", func(t *testing.T) {
+		handler.autoCapture = false
+		defer func() { handler.autoCapture = true }()
+
+		rr := serve(http.MethodPost, "/orders/5/payments", `{"paymentMethod":"tok_visa"}`, customerToken)
+		authorized := decode(rr)
+
+		rr = serve(http.MethodPost, "/orders/5/payments", `{"paymentMethod":"tok_action"}`, customerToken)
+		if rr.Code != http.StatusConflict {
+			t.Errorf("Expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+
+		// the order was cancelled before the payments were locked with it
+		o := orderStore.orders[5]
+		o.Status = types.OrderStatusCancelled
+		orderStore.orders[5] = o
+
+		if rr := serve(http.MethodPost, fmt.Sprintf("/admin/payments/%d/capture", authorized.ID), "", adminToken); rr.Code != http.StatusConflict {
+			t.Errorf("Expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+
+		if p := store.payments[authorized.ID]; p.Status != types.PaymentAuthorized || !p.Captured.IsZero() {
+			t.Errorf("Expected the payment to stay authorized, got %+v", p)
+		}
+
+		action := NewPayment("fake_action", types.PaymentRequiresAction, usd(2000))
+		action.ID, action.OrderID = len(store.payments)+1, 5
+		store.payments[action.ID] = *action
+
+		if rr := serve(http.MethodPost, fmt.Sprintf("/payments/%d/confirm", action.ID), "", customerToken); rr.Code != http.StatusConflict {
+			t.Errorf("Expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+
+		if p := store.payments[action.ID]; p.Status != types.PaymentRequiresAction {
+			t.Errorf("Expected the payment to still require an action, got %+v", p)
+		}
+	})
+
+	t.Run("Should not refund the payments outside of the credit notes", func(t *testing.T) {
+		rr := serve(http.MethodPost, fmt.Sprintf("/admin/payments/%d/refund", 2), "", adminToken)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// ErrInvalidOperation is returned when the status of a
+// payment does not allow the operation
+var ErrInvalidOperation = errors.New("invalid payment operation")
+
+// ErrNotPayable is returned when the order of a payment is no longer
+// awaiting payment, like an order that was cancelled or already paid
+var ErrNotPayable = errors.New("order is not awaiting payment")
+
+// ErrAmountTooLarge is returned when more than what is
+// left of a payment is captured or refunded
+var ErrAmountTooLarge = errors.New("amount exceeds the payment")
+
+// ErrInvalidAmount is returned when the amount captured or refunded is not positive
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// A payment moves through the statuses below, the functions of
+// this file check the operations and record them on the payment.
+//
+//	authorize -> authorized, requires_action or declined
+//	requires_action -> authorized or declined once confirmed
+//	authorized -> captured -> partially_refunded -> refunded
+//	authorized, requires_action -> voided
+
+// NewPayment function to return a payment of the amount with nothing captured
+// or refunded yet, in the currency of the amount
+func NewPayment(reference string, status types.PaymentStatus, amount types.Money) *types.Payment {
+	return &types.Payment{
+		Reference: reference,
+		Status:    status,
+		Amount:    amount,
+		Captured:  types.NewMoney(0, amount.Currency),
+		Refunded:  types.NewMoney(0, amount.Currency),
+	}
+}
+
+// IsActive reports whether the payment holds or took money from the customer,
+// an order cannot be paid again while one of its payments is active
+func IsActive(p types.Payment) bool {
+	switch p.Status {
+	case types.PaymentRequiresAction, types.PaymentAuthorized, types.PaymentCaptured, types.PaymentPartiallyRefunded:
+		return true
+	}
+
+	return false
+}
+
+// Capture records the capture of the amount of an authorized payment,
+// at most the authorized amount can be captured
+func Capture(p *types.Payment, amount types.Money) error {
+	if p.Status != types.PaymentAuthorized {
+		return fmt.Errorf("%w: cannot capture a %s payment", ErrInvalidOperation, p.Status)
+	}
+
+	if err := checkAmount(amount, p.Amount); err != nil {
+		return err
+	}
+
+	p.Captured = amount
+	p.Status = types.PaymentCaptured
+	return nil
+}
+
+// Void records the release of a payment that was not captured
+func Void(p *types.Payment) error {
+	if p.Status != types.PaymentAuthorized && p.Status != types.PaymentRequiresAction {
+		return fmt.Errorf("%w: cannot void a %s payment", ErrInvalidOperation, p.Status)
+	}
+
+	p.Status = types.PaymentVoided
+	return nil
+}
+
+// Refund records the refund of the amount of a captured payment,
+// the payment is refunded once the whole captured amount is given back
+func Refund(p *types.Payment, amount types.Money) error {
+	if p.Status != types.PaymentCaptured && p.Status != types.PaymentPartiallyRefunded {
+		return fmt.Errorf("%w: cannot refund a %s payment", ErrInvalidOperation, p.Status)
+	}
+
+	remaining, err := Refundable(*p)
+	if err != nil {
+		return err
+	}
+
+	if err := checkAmount(amount, remaining); err != nil {
+		return err
+	}
+
+	if p.Refunded, err = p.Refunded.Add(amount); err != nil {
+		return err
+	}
+
+	p.Status = types.PaymentPartiallyRefunded
+	if p.Refunded == p.Captured {
+		p.Status = types.PaymentRefunded
+	}
+
+	return nil
+}
+
+// Refundable returns what is left to refund of the captured amount
+func Refundable(p types.Payment) (types.Money, error) {
+	return p.Captured.Sub(p.Refunded)
+}
+
+// checkAmount checks the amount is positive and at most max
+func checkAmount(amount, max types.Money) error {
+	if amount.IsZero() || amount.IsNegative() {
+		return fmt.Errorf("%w: the amount must be positive, got %v", ErrInvalidAmount, amount)
+	}
+
+	cmp, err := amount.Cmp(max)
+	if err != nil {
+		return err
+	}
+
+	if cmp > 0 {
+		return fmt.Errorf("%w: %v is more than %v", ErrAmountTooLarge, amount, max)
+	}
+
+	return nil
+}
@@ -0,0 +1,293 @@
+package payment
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/akshtrikha/golang-ecomm/services/order"
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// paymentColumns is the list of columns scanned by scanRowIntoPayment
+const paymentColumns = "id, orderId, gateway, reference, status, amount, capturedAmount, refundedAmount, currency, actionUrl, declineReason, createdAt, updatedAt"
+
+// Store struct to hold the database object
+// This will be used to handle the database queries
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore function to return a reference to the Store struct
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// ErrPaymentNotFound is returned when a payment does not exist
+var ErrPaymentNotFound = errors.New("payment not found")
+
+// GetPaymentByID function to find the payment by id
+func (s *Store) GetPaymentByID(id int) (*types.Payment, error) {
+	rows, err := s.db.Query("SELECT "+paymentColumns+" FROM payments WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	p := new(types.Payment)
+	for rows.Next() {
+		p, err = scanRowIntoPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if p.ID == 0 {
+		return nil, fmt.Errorf("%w: %v", ErrPaymentNotFound, id)
+	}
+
+	return p, nil
+}
+
+// GetPaymentByReference function to find the payment by its reference at the gateway
+func (s *Store) GetPaymentByReference(gateway string, reference string) (*types.Payment, error) {
+	rows, err := s.db.Query("SELECT "+paymentColumns+" FROM payments WHERE gateway = ? AND reference = ?", gateway, reference)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	p := new(types.Payment)
+	for rows.Next() {
+		p, err = scanRowIntoPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if p.ID == 0 {
+		return nil, fmt.Errorf("%w: %v", ErrPaymentNotFound, reference)
+	}
+
+	return p, nil
+}
+
+// GetPaymentsByOrderID function to get the payments of an order, the latest first
+func (s *Store) GetPaymentsByOrderID(orderID int) ([]types.Payment, error) {
+	rows, err := s.db.Query("SELECT "+paymentColumns+" FROM payments WHERE orderId = ? ORDER BY id DESC", orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := []types.Payment{}
+	for rows.Next() {
+		p, err := scanRowIntoPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		payments = append(payments, *p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return payments, nil
+}
+
+// CreatePayment function to record the payment of the order returned by authorize.
+// The order and its payments are locked while authorize runs with them, so
+// the order is not paid twice and is not cancelled while it is being paid.
+// Nothing is recorded when authorize fails
+func (s *Store) CreatePayment(orderID int, authorize func(*types.Order, []types.Payment) (*types.Payment, error)) (*types.Payment, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	o, err := lockOrder(tx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	payments, err := lockPayments(tx, "orderId = ?", orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := authorize(o, payments)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := tx.Exec(
+		"INSERT INTO payments (orderId, gateway, reference, status, amount, capturedAmount, refundedAmount, currency, actionUrl, declineReason) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		orderID, p.Gateway, p.Reference, p.Status, p.Amount, p.Captured, p.Refunded, p.Amount.Currency, p.ActionURL, p.DeclineReason,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	p.ID = int(id)
+	p.OrderID = orderID
+	return p, nil
+}
+
+// UpdatePayment function to record the outcome of an operation on the payment.
+// The payment and its order are locked while update changes the payment, so the
+// operations on a payment are serialized and see the current status of its order.
+// Nothing is recorded when update fails
+func (s *Store) UpdatePayment(id int, update func(*types.Order, *types.Payment) error) (*types.Payment, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	// the order is locked before the payment, in the order the payments are created
+	var orderID int
+	err = tx.QueryRow("SELECT orderId FROM payments WHERE id = ?", id).Scan(&orderID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %v", ErrPaymentNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	o, err := lockOrder(tx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	payments, err := lockPayments(tx, "id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(payments) == 0 {
+		return nil, fmt.Errorf("%w: %v", ErrPaymentNotFound, id)
+	}
+
+	p := &payments[0]
+	if err := update(o, p); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(
+		"UPDATE payments SET status = ?, capturedAmount = ?, refundedAmount = ?, actionUrl = ?, declineReason = ? WHERE id = ?",
+		p.Status, p.Captured, p.Refunded, p.ActionURL, p.DeclineReason, p.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// lockOrder reads the status and the total of the order with FOR UPDATE
+func lockOrder(tx *sql.Tx, orderID int) (*types.Order, error) {
+	o := new(types.Order)
+	var total string
+
+	err := tx.QueryRow("SELECT id, userId, total, currency, status FROM orders WHERE id = ? FOR UPDATE", orderID).
+		Scan(&o.ID, &o.UserID, &total, &o.Currency, &o.Status)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %v", order.ErrOrderNotFound, orderID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if o.Total, err = types.ParseMoney(total, o.Currency); err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// lockPayments reads the payments matching the condition with FOR UPDATE, the latest first
+func lockPayments(tx *sql.Tx, where string, args ...interface{}) ([]types.Payment, error) {
+	rows, err := tx.Query("SELECT "+paymentColumns+" FROM payments WHERE "+where+" ORDER BY id DESC FOR UPDATE", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := []types.Payment{}
+	for rows.Next() {
+		p, err := scanRowIntoPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		payments = append(payments, *p)
+	}
+
+	return payments, rows.Err()
+}
+
+func scanRowIntoPayment(rows *sql.Rows) (*types.Payment, error) {
+	p := new(types.Payment)
+	var amount, captured, refunded, currency string
+
+	err := rows.Scan(
+		&p.ID,
+		&p.OrderID,
+		&p.Gateway,
+		&p.Reference,
+		&p.Status,
+		&amount,
+		&captured,
+		&refunded,
+		&currency,
+		&p.ActionURL,
+		&p.DeclineReason,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// the amounts are in the currency of the order
+	if p.Amount, err = types.ParseMoney(amount, currency); err != nil {
+		return nil, err
+	}
+
+	if p.Captured, err = types.ParseMoney(captured, currency); err != nil {
+		return nil, err
+	}
+
+	if p.Refunded, err = types.ParseMoney(refunded, currency); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
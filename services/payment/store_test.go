@@ -0,0 +1,113 @@
+package payment
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// newMockStore returns a store on a mocked database whose
+// expectations are checked once the test is done
+func newMockStore(t *testing.T) (*Store, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	return NewStore(db), mock
+}
+
+// expectOrderLock expects the pending order 1 of 20.00 to be locked
+func expectOrderLock(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT id, userId, total, currency, status FROM orders WHERE id = \? FOR UPDATE`).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "userId", "total", "currency", "status"}).AddRow(1, 2, "20.00", types.DefaultCurrency, types.OrderStatusPending))
+}
+
+// paymentRows returns the rows of the payments of the order 1 of 20.00 in the statuses
+func paymentRows(statuses ...types.PaymentStatus) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "orderId", "gateway", "reference", "status", "amount", "capturedAmount", "refundedAmount", "currency", "actionUrl", "declineReason", "createdAt", "updatedAt"})
+	for i, status := range statuses {
+		rows.AddRow(i+1, 1, "fake", "fake_1", status, "20.00", "0.00", "0.00", types.DefaultCurrency, "", "", time.Now(), time.Now())
+	}
+
+	return rows
+}
+
+// TestCreatePayment function to test the payments recorded while their order is locked
+func TestCreatePayment(t *testing.T) {
+	t.Run("Should authorize the payment with the order and its payments locked", func(t *testing.T) {
+		store, mock := newMockStore(t)
+
+		mock.ExpectBegin()
+		expectOrderLock(mock)
+		mock.ExpectQuery(`SELECT .+ FROM payments WHERE orderId = \? ORDER BY id DESC FOR UPDATE`).WithArgs(1).WillReturnRows(paymentRows(types.PaymentDeclined))
+		mock.ExpectExec(`INSERT INTO payments`).WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectCommit()
+
+		p, err := store.CreatePayment(1, func(o *types.Order, payments []types.Payment) (*types.Payment, error) {
+			if o.Status != types.OrderStatusPending || len(payments) != 1 {
+				t.Errorf("Expected the pending order and its declined payment, got %+v, %+v", o, payments)
+			}
+
+			return NewPayment("fake_2", types.PaymentAuthorized, o.Total), nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if p.ID != 2 || p.OrderID != 1 {
+			t.Errorf("Expected the payment 2 of the order 1, got %+v", p)
+		}
+	})
+
+	t.Run("Should record nothing when the payment is not authorized", func(t *testing.T) {
+		store, mock := newMockStore(t)
+
+		mock.ExpectBegin()
+		expectOrderLock(mock)
+		mock.ExpectQuery(`SELECT .+ FROM payments WHERE orderId = \? ORDER BY id DESC FOR UPDATE`).WithArgs(1).WillReturnRows(paymentRows(types.PaymentAuthorized))
+		mock.ExpectRollback()
+
+		_, err := store.CreatePayment(1, func(o *types.Order, payments []types.Payment) (*types.Payment, error) {
+			return nil, ErrNotPayable
+		})
+		if !errors.Is(err, ErrNotPayable) {
+			t.Errorf("Expected %v, got %v", ErrNotPayable, err)
+		}
+	})
+}
+
+// TestUpdatePayment function to test the operations on the payments while their order is locked
+func TestUpdatePayment(t *testing.T) {
+	t.Run("Should lock the order before the payment and save the payment", func(t *testing.T) {
+		store, mock := newMockStore(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT orderId FROM payments WHERE id = \?`).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"orderId"}).AddRow(1))
+		expectOrderLock(mock)
+		mock.ExpectQuery(`SELECT .+ FROM payments WHERE id = \? ORDER BY id DESC FOR UPDATE`).WithArgs(1).WillReturnRows(paymentRows(types.PaymentAuthorized))
+		mock.ExpectExec(`UPDATE payments SET status = \?, capturedAmount = \?`).
+			WithArgs(types.PaymentCaptured, usd(2000), usd(0), "", "", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		p, err := store.UpdatePayment(1, func(o *types.Order, p *types.Payment) error {
+			return Capture(p, p.Amount)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if p.Status != types.PaymentCaptured {
+			t.Errorf("Expected the payment to be captured, got %+v", p)
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package product
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// defaultPageSize is the number of products of a page without a limit,
+// the largest limit accepted is set by the validation of the query
+const defaultPageSize = 20
+
+// ErrInvalidCursor is returned for a cursor that was not created by
+// EncodeCursor or that was created for another sort
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// sortColumns maps the sort fields of the listing to the product columns
+var sortColumns = map[string]string{
+	"id":        "id",
+	"price":     "price",
+	"name":      "name",
+	"createdAt": "createAt",
+}
+
+// EncodeCursor returns the opaque cursor pointing after the product
+// for a listing sorted by the given field
+func EncodeCursor(p types.Product, sort string) string {
+	c := types.ProductCursor{ID: p.ID, Sort: sort}
+
+	switch sort {
+	case "price":
+		c.Value = p.Price.Decimal()
+	case "name":
+		c.Value = p.Name
+	case "createdAt":
+		c.Value = p.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor parses a cursor created by EncodeCursor
+func DecodeCursor(s string) (*types.ProductCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	c := new(types.ProductCursor)
+	if err := json.Unmarshal(b, c); err != nil || c.ID <= 0 {
+		return nil, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+// cursorArg converts the value of the cursor to the type of the sort column,
+// ErrInvalidCursor is returned for the cursors of another sort
+func cursorArg(c *types.ProductCursor, sort string) (interface{}, error) {
+	if c.Sort != sort {
+		return nil, ErrInvalidCursor
+	}
+
+	switch sort {
+	case "price":
+		price, err := types.ParseMoney(c.Value, types.DefaultCurrency)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		return price, nil
+	case "name":
+		return c.Value, nil
+	case "createdAt":
+		createdAt, err := time.Parse(time.RFC3339Nano, c.Value)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		return createdAt, nil
+	}
+
+	return c.ID, nil
+}
+
+// buildListFilters returns the where clause of the listing filters, the
+// cursor is left out so the same filters can be used to count the products
+func buildListFilters(q types.ProductListQuery) (string, []interface{}) {
+	conditions := []string{"deletedAt IS NULL"}
+	var args []interface{}
+
+	if q.MinPrice != nil {
+		conditions = append(conditions, "price >= ?")
+		args = append(args, *q.MinPrice)
+	}
+
+	if q.MaxPrice != nil {
+		conditions = append(conditions, "price <= ?")
+		args = append(args, *q.MaxPrice)
+	}
+
+	// products with variants are in stock when any of their variants is
+	if q.InStock {
+		conditions = append(conditions, "(quantity > 0 OR EXISTS (SELECT 1 FROM product_variants v WHERE v.productId = products.id AND v.deletedAt IS NULL AND v.quantity > 0))")
+	}
+
+	if q.Name != "" {
+		conditions = append(conditions, "name LIKE ?")
+		args = append(args, "%"+escapeLike(q.Name)+"%")
+	}
+
+	if len(q.CategoryIDs) > 0 {
+		placeholders := strings.Repeat("?,", len(q.CategoryIDs)-1) + "?"
+		conditions = append(conditions, "id IN (SELECT productId FROM product_categories WHERE categoryId IN ("+placeholders+"))")
+		for _, id := range q.CategoryIDs {
+			args = append(args, id)
+		}
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -1,56 +1,60 @@
 package product
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
-	"github.com/akshtrikha/golang-ecomm/config"
 	"github.com/akshtrikha/golang-ecomm/services/auth"
+	"github.com/akshtrikha/golang-ecomm/services/currency"
 	"github.com/akshtrikha/golang-ecomm/types"
 	"github.com/akshtrikha/golang-ecomm/utils"
-	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 )
 
-// API Endpoints to create products and get all the products
+// API Endpoints to create, get, update and archive products
+// and to manage the options and variants of the products
 
 // Handler to the product store which will deal
 // with the database regarding products
 type Handler struct {
-	store types.ProductStore
+	store        types.ProductStore
+	variantStore types.VariantStore
 }
 
-// NewHandler constructor
-func NewHandler(s *Store) *Handler {
-	return &Handler{store: s}
+// NewHandler constructor takes ProductStore and VariantStore as dependencies
+func NewHandler(s types.ProductStore, variantStore types.VariantStore) *Handler {
+	return &Handler{store: s, variantStore: variantStore}
 }
 
 // RegisterRoutes func for products
+// /get-products and /add-product are kept for the existing clients
 func (h *Handler) RegisterRoutes(router *mux.Router) {
-	router.HandleFunc("/get-products", h.handleGetProducts).Methods("GET")
-	router.HandleFunc("/add-product", h.handleAddProduct).Methods("POST")
+	router.HandleFunc("/get-products", auth.RequireAuth(h.handleGetProducts)).Methods("GET")
+	router.HandleFunc("/add-product", auth.RequireRole(auth.RoleAdmin, h.handleAddProduct)).Methods("POST")
+
+	router.HandleFunc("/products", auth.RequireAuth(h.handleListProducts)).Methods("GET")
+	router.HandleFunc("/products", auth.RequireRole(auth.RoleAdmin, h.handleAddProduct)).Methods("POST")
+	router.HandleFunc("/products/{id:[0-9]+}", auth.RequireAuth(h.handleGetProduct)).Methods("GET")
+	router.HandleFunc("/products/{id:[0-9]+}", auth.RequireRole(auth.RoleAdmin, h.handleReplaceProduct)).Methods("PUT")
+	router.HandleFunc("/products/{id:[0-9]+}", auth.RequireRole(auth.RoleAdmin, h.handleUpdateProduct)).Methods("PATCH")
+	router.HandleFunc("/products/{id:[0-9]+}", auth.RequireRole(auth.RoleAdmin, h.handleDeleteProduct)).Methods("DELETE")
+
+	router.HandleFunc("/products/{id:[0-9]+}/options", auth.RequireRole(auth.RoleAdmin, h.handleSetProductOptions)).Methods("PUT")
+	router.HandleFunc("/products/{id:[0-9]+}/variants", auth.RequireAuth(h.handleGetVariants)).Methods("GET")
+	router.HandleFunc("/products/{id:[0-9]+}/variants", auth.RequireRole(auth.RoleAdmin, h.handleAddVariant)).Methods("POST")
+	router.HandleFunc("/products/{id:[0-9]+}/variants/{variantID:[0-9]+}", auth.RequireRole(auth.RoleAdmin, h.handleUpdateVariant)).Methods("PATCH")
+	router.HandleFunc("/products/{id:[0-9]+}/variants/{variantID:[0-9]+}", auth.RequireRole(auth.RoleAdmin, h.handleDeleteVariant)).Methods("DELETE")
 }
 
 func (h *Handler) handleGetProducts(w http.ResponseWriter, r *http.Request) {
 	log.Println("handle /get-products hit")
-	// get token from the headers
-	token := r.Header.Get("Authorization")
-	if token == "" {
-		log.Println("Token missing")
-		utils.WriteError(w, http.StatusUnauthorized, fmt.Errorf("Missing token"))
-		return
-	}
-
-	// verify the token
-	claims, err := auth.VerifyTokenAndClaims(token, config.Envs.JWTSecret)
-	if err != nil {
-		log.Println("Invalid token")
-		utils.WriteError(w, http.StatusUnauthorized, err)
-		return
-	}
 
-	userID := claims["userID"]
+	userID, _ := auth.UserIDFromContext(r.Context())
 	log.Printf("Request from the user: %v", userID)
 
 	// get the products from the database
@@ -61,29 +65,61 @@ func (h *Handler) handleGetProducts(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// show the prices in the selected currency
+	if err := currency.ConvertProducts(currency.RateFromContext(r.Context()), products); err != nil {
+		log.Println("Error converting the prices")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
 	// return the products
 	utils.WriteJSON(w, http.StatusOK, products)
 }
 
-func (h *Handler) handleAddProduct(w http.ResponseWriter, r *http.Request) {
-	log.Println("handler /add-products hit")
+func (h *Handler) handleListProducts(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle GET /products hit")
 
-	// get the token
-	token := r.Header.Get("Authorization")
-	if token == "" {
-		log.Println("Token Missing")
-		utils.WriteError(w, http.StatusUnauthorized, fmt.Errorf("Missing token"))
+	// get the pagination, sorting and filters from the query
+	q, err := ParseListQuery(r.URL.Query(), currency.RateFromContext(r.Context()))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
 		return
 	}
 
-	// verify the token
-	claims, err := auth.VerifyTokenAndClaims(token, config.Envs.JWTSecret)
+	// verify the query
+	if err := utils.Validate.Struct(q); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.ValidationError("Invalid query", err))
+		return
+	}
+
+	// get the page from the database
+	page, err := h.store.ListProducts(q)
+	if errors.Is(err, ErrInvalidCursor) {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
 	if err != nil {
-		log.Println("Invalid token")
-		utils.WriteError(w, http.StatusUnauthorized, err)
+		log.Println("Error fetching the products from the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	LinkNextPage(r, page, q.Sort)
+
+	// show the prices in the selected currency
+	if err := currency.ConvertProducts(currency.RateFromContext(r.Context()), page.Items); err != nil {
+		log.Println("Error converting the prices")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
 	}
 
-	userID := claims["userID"]
+	utils.WriteJSON(w, http.StatusOK, page)
+}
+
+func (h *Handler) handleAddProduct(w http.ResponseWriter, r *http.Request) {
+	log.Println("handler /add-products hit")
+
+	userID, _ := auth.UserIDFromContext(r.Context())
 	log.Printf("Request from the user: %v", userID)
 
 	// get the payload
@@ -98,15 +134,630 @@ func (h *Handler) handleAddProduct(w http.ResponseWriter, r *http.Request) {
 
 	// verify the payload
 	if err := utils.Validate.Struct(payload); err != nil {
-		validationErrors := err.(validator.ValidationErrors)
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("Invalid Payload: %v", validationErrors))
+		utils.WriteError(w, http.StatusBadRequest, utils.ValidationError("Invalid Payload", err))
 		return
 	}
 
+	if err := checkPriceCurrency(&payload.Price); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	payload.TaxClass = taxClassOrDefault(payload.TaxClass)
+
 	// add the product
 	productID, err := h.store.AddProduct(payload)
+	if err != nil {
+		log.Println("Error adding the product to the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
 	log.Printf("Product Added %v", productID)
 
 	// return the product id
 	utils.WriteJSON(w, http.StatusCreated, map[string]int{"id": productID})
 }
+
+func (h *Handler) handleGetProduct(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle GET /products/{id} hit")
+
+	productID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid product id"))
+		return
+	}
+
+	// archived products are returned with their deletedAt set
+	product, err := h.store.GetProductByID(productID)
+	if errors.Is(err, ErrProductNotFound) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("product with id: %v not found", productID))
+		return
+	}
+	if err != nil {
+		log.Printf("Error finding the product %v, error: %+v", productID, err)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	product.Options, err = h.variantStore.GetProductOptions(productID)
+	if err != nil {
+		log.Println("Error fetching the product options from the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	product.Variants, err = h.variantStore.GetVariantsByProductID(productID)
+	if err != nil {
+		log.Println("Error fetching the product variants from the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// show the prices in the selected currency
+	if err := currency.ConvertProduct(currency.RateFromContext(r.Context()), product); err != nil {
+		log.Println("Error converting the prices")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, product)
+}
+
+func (h *Handler) handleReplaceProduct(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle PUT /products/{id} hit")
+
+	product, ok := h.getActiveProduct(w, r)
+	if !ok {
+		return
+	}
+
+	// get the payload
+	var payload types.AddProductPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		log.Printf("Error parsing the payload\n")
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// verify the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.ValidationError("Invalid Payload", err))
+		return
+	}
+
+	if err := checkPriceCurrency(&payload.Price); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	product.Name = payload.Name
+	product.Description = payload.Description
+	product.Image = payload.Image
+	product.Price = payload.Price
+	product.Quantity = payload.Quantity
+	product.TaxClass = taxClassOrDefault(payload.TaxClass)
+	product.Weight = payload.Weight
+	product.Length = payload.Length
+	product.Width = payload.Width
+	product.Height = payload.Height
+
+	h.saveProduct(w, product)
+}
+
+func (h *Handler) handleUpdateProduct(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle PATCH /products/{id} hit")
+
+	product, ok := h.getActiveProduct(w, r)
+	if !ok {
+		return
+	}
+
+	// get the payload
+	var payload types.UpdateProductPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		log.Printf("Error parsing the payload\n")
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// verify the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.ValidationError("Invalid Payload", err))
+		return
+	}
+
+	if err := checkPriceCurrency(payload.Price); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// only update the fields present in the payload
+	if payload.Name != nil {
+		product.Name = *payload.Name
+	}
+	if payload.Description != nil {
+		product.Description = *payload.Description
+	}
+	if payload.Image != nil {
+		product.Image = *payload.Image
+	}
+	if payload.Price != nil {
+		product.Price = *payload.Price
+	}
+	if payload.Quantity != nil {
+		product.Quantity = *payload.Quantity
+	}
+	if payload.TaxClass != nil {
+		product.TaxClass = taxClassOrDefault(*payload.TaxClass)
+	}
+	if payload.Weight != nil {
+		product.Weight = *payload.Weight
+	}
+	if payload.Length != nil {
+		product.Length = *payload.Length
+	}
+	if payload.Width != nil {
+		product.Width = *payload.Width
+	}
+	if payload.Height != nil {
+		product.Height = *payload.Height
+	}
+
+	h.saveProduct(w, product)
+}
+
+func (h *Handler) handleDeleteProduct(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle DELETE /products/{id} hit")
+
+	productID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid product id"))
+		return
+	}
+
+	// the product is archived, not removed
+	err = h.store.DeleteProduct(productID)
+	if errors.Is(err, ErrProductNotFound) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("product with id: %v not found", productID))
+		return
+	}
+	if err != nil {
+		log.Printf("Error deleting the product %v, error: %+v", productID, err)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	log.Printf("Product Archived %v", productID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) handleSetProductOptions(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle PUT /products/{id}/options hit")
+
+	product, ok := h.getActiveProduct(w, r)
+	if !ok {
+		return
+	}
+
+	// get the payload
+	var payload types.ProductOptionsPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		log.Printf("Error parsing the payload\n")
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// verify the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.ValidationError("Invalid Payload", err))
+		return
+	}
+
+	if err := ValidateOptions(payload.Options); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// the existing variants have to fit the new options
+	variants, err := h.variantStore.GetVariantsByProductID(product.ID)
+	if err != nil {
+		log.Println("Error fetching the product variants from the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	for _, v := range variants {
+		if err := ValidateVariantOptions(payload.Options, v.Options); err != nil {
+			utils.WriteError(w, http.StatusConflict, fmt.Errorf("variant %s does not fit the options: %v", v.SKU, err))
+			return
+		}
+	}
+
+	if err := h.variantStore.SetProductOptions(product.ID, payload.Options); err != nil {
+		log.Println("Error updating the product options in the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, payload.Options)
+}
+
+func (h *Handler) handleGetVariants(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle GET /products/{id}/variants hit")
+
+	productID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid product id"))
+		return
+	}
+
+	_, err = h.store.GetProductByID(productID)
+	if errors.Is(err, ErrProductNotFound) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("product with id: %v not found", productID))
+		return
+	}
+	if err != nil {
+		log.Printf("Error finding the product %v, error: %+v", productID, err)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	variants, err := h.variantStore.GetVariantsByProductID(productID)
+	if err != nil {
+		log.Println("Error fetching the product variants from the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// show the prices in the selected currency
+	if err := currency.ConvertVariants(currency.RateFromContext(r.Context()), variants); err != nil {
+		log.Println("Error converting the prices")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, variants)
+}
+
+func (h *Handler) handleAddVariant(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle POST /products/{id}/variants hit")
+
+	product, ok := h.getActiveProduct(w, r)
+	if !ok {
+		return
+	}
+
+	// get the payload
+	var payload types.AddVariantPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		log.Printf("Error parsing the payload\n")
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// verify the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.ValidationError("Invalid Payload", err))
+		return
+	}
+
+	if err := checkPriceCurrency(payload.Price); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	options, err := h.variantStore.GetProductOptions(product.ID)
+	if err != nil {
+		log.Println("Error fetching the product options from the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := ValidateVariantOptions(options, payload.Options); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// skus are unique across products, deleted variants included
+	if _, err := h.variantStore.GetVariantBySKU(payload.SKU); err == nil {
+		utils.WriteError(w, http.StatusConflict, fmt.Errorf("variant with sku: %v already exists", payload.SKU))
+		return
+	}
+
+	variants, err := h.variantStore.GetVariantsByProductID(product.ID)
+	if err != nil {
+		log.Println("Error fetching the product variants from the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	key := optionsKey(payload.Options)
+	for _, v := range variants {
+		if optionsKey(v.Options) == key {
+			utils.WriteError(w, http.StatusConflict, fmt.Errorf("variant %s already has these options", v.SKU))
+			return
+		}
+	}
+
+	variant := types.ProductVariant{
+		ProductID: product.ID,
+		SKU:       payload.SKU,
+		Options:   payload.Options,
+		Price:     payload.Price,
+		Quantity:  payload.Quantity,
+		Image:     payload.Image,
+	}
+
+	variant.ID, err = h.variantStore.AddVariant(variant)
+	if err != nil {
+		log.Println("Error adding the variant to the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	log.Printf("Variant Added %v", variant.SKU)
+
+	utils.WriteJSON(w, http.StatusCreated, variant)
+}
+
+func (h *Handler) handleUpdateVariant(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle PATCH /products/{id}/variants/{variantID} hit")
+
+	variant, ok := h.getVariant(w, r)
+	if !ok {
+		return
+	}
+
+	// get the payload
+	var payload types.UpdateVariantPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		log.Printf("Error parsing the payload\n")
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// verify the payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.ValidationError("Invalid Payload", err))
+		return
+	}
+
+	if err := checkPriceCurrency(payload.Price); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if payload.ClearPrice && payload.Price != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("price can not be set when clearing the price"))
+		return
+	}
+
+	// only update the fields present in the payload
+	if payload.Price != nil {
+		variant.Price = payload.Price
+	}
+	if payload.ClearPrice {
+		variant.Price = nil
+	}
+	if payload.Quantity != nil {
+		variant.Quantity = *payload.Quantity
+	}
+	if payload.Image != nil {
+		variant.Image = *payload.Image
+	}
+
+	if err := h.variantStore.UpdateVariant(*variant); err != nil {
+		log.Println("Error updating the variant in the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	log.Printf("Variant Updated %v", variant.SKU)
+
+	utils.WriteJSON(w, http.StatusOK, variant)
+}
+
+func (h *Handler) handleDeleteVariant(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle DELETE /products/{id}/variants/{variantID} hit")
+
+	variant, ok := h.getVariant(w, r)
+	if !ok {
+		return
+	}
+
+	// the variant is archived, not removed
+	err := h.variantStore.DeleteVariant(variant.ID)
+	if errors.Is(err, ErrVariantNotFound) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("variant with id: %v not found", variant.ID))
+		return
+	}
+	if err != nil {
+		log.Printf("Error deleting the variant %v, error: %+v", variant.ID, err)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	log.Printf("Variant Archived %v", variant.SKU)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getVariant gets the active variant of the request writing a
+// not found error when it does not belong to the product of the request
+func (h *Handler) getVariant(w http.ResponseWriter, r *http.Request) (*types.ProductVariant, bool) {
+	product, ok := h.getActiveProduct(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	variantID, err := strconv.Atoi(mux.Vars(r)["variantID"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid variant id"))
+		return nil, false
+	}
+
+	variants, err := h.variantStore.GetVariantsByProductID(product.ID)
+	if err != nil {
+		log.Println("Error fetching the product variants from the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+
+	for _, v := range variants {
+		if v.ID == variantID {
+			return &v, true
+		}
+	}
+
+	utils.WriteError(w, http.StatusNotFound, fmt.Errorf("variant with id: %v not found", variantID))
+	return nil, false
+}
+
+// LinkNextPage sets the cursor and the link to the next page
+// of the listing keeping the filters of the request
+func LinkNextPage(r *http.Request, page *types.ProductPage, sort string) {
+	if !page.HasMore || len(page.Items) == 0 {
+		return
+	}
+
+	page.NextCursor = EncodeCursor(page.Items[len(page.Items)-1], sort)
+
+	next := r.URL.Query()
+	next.Del("offset")
+	next.Set("cursor", page.NextCursor)
+	page.Next = r.URL.Path + "?" + next.Encode()
+}
+
+// ParseListQuery reads the listing query parameters
+// falling back to the first page of products sorted by id.
+// The prices of the filters are in the currency of the rate,
+// they are converted to the base currency the products are stored in
+func ParseListQuery(values url.Values, rate types.ExchangeRate) (types.ProductListQuery, error) {
+	q := types.ProductListQuery{
+		Limit: defaultPageSize,
+		Sort:  "id",
+		Order: "asc",
+		Name:  strings.TrimSpace(values.Get("name")),
+	}
+
+	if v := values.Get("sort"); v != "" {
+		q.Sort = v
+	}
+
+	if v := values.Get("order"); v != "" {
+		q.Order = strings.ToLower(v)
+	}
+
+	var err error
+	if v := values.Get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil {
+			return q, fmt.Errorf("invalid limit: %v", v)
+		}
+	}
+
+	if v := values.Get("offset"); v != "" {
+		if q.Offset, err = strconv.Atoi(v); err != nil {
+			return q, fmt.Errorf("invalid offset: %v", v)
+		}
+	}
+
+	if v := values.Get("cursor"); v != "" {
+		if q.After, err = DecodeCursor(v); err != nil {
+			return q, err
+		}
+	}
+
+	// the bounds are rounded inwards so the products shown
+	// at a price out of the range are not listed
+	if v := values.Get("minPrice"); v != "" {
+		price, err := types.ParseMoney(v, rate.Currency)
+		if err != nil {
+			return q, fmt.Errorf("invalid minPrice: %v", v)
+		}
+
+		if price, err = currency.ConvertToBase(rate, price, types.RoundUp); err != nil {
+			return q, err
+		}
+		q.MinPrice = &price
+	}
+
+	if v := values.Get("maxPrice"); v != "" {
+		price, err := types.ParseMoney(v, rate.Currency)
+		if err != nil {
+			return q, fmt.Errorf("invalid maxPrice: %v", v)
+		}
+
+		if price, err = currency.ConvertToBase(rate, price, types.RoundDown); err != nil {
+			return q, err
+		}
+		q.MaxPrice = &price
+	}
+
+	if v := values.Get("inStock"); v != "" {
+		if q.InStock, err = strconv.ParseBool(v); err != nil {
+			return q, fmt.Errorf("invalid inStock: %v", v)
+		}
+	}
+
+	if q.MinPrice != nil && q.MaxPrice != nil && q.MinPrice.Amount > q.MaxPrice.Amount {
+		return q, fmt.Errorf("minPrice can not be greater than maxPrice")
+	}
+
+	return q, nil
+}
+
+// getActiveProduct gets the product of the request writing
+// a not found error when it does not exist or was archived
+func (h *Handler) getActiveProduct(w http.ResponseWriter, r *http.Request) (*types.Product, bool) {
+	productID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid product id"))
+		return nil, false
+	}
+
+	product, err := h.store.GetProductByID(productID)
+	if errors.Is(err, ErrProductNotFound) || (err == nil && product.DeletedAt != nil) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("product with id: %v not found", productID))
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("Error finding the product %v, error: %+v", productID, err)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+
+	return product, true
+}
+
+func (h *Handler) saveProduct(w http.ResponseWriter, product *types.Product) {
+	if err := h.store.UpdateProduct(*product); err != nil {
+		log.Println("Error updating the product in the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	log.Printf("Product Updated %v", product.ID)
+
+	utils.WriteJSON(w, http.StatusOK, product)
+}
+
+// checkPriceCurrency returns an error when the price is not in the base currency,
+// the prices are stored without their currency
+func checkPriceCurrency(price *types.Money) error {
+	if price != nil && price.Currency != types.DefaultCurrency {
+		return fmt.Errorf("prices must be in the base currency %v", types.DefaultCurrency)
+	}
+
+	return nil
+}
+
+// taxClassOrDefault returns the standard tax class for an empty tax class
+func taxClassOrDefault(taxClass string) string {
+	if taxClass = strings.TrimSpace(taxClass); taxClass == "" {
+		return types.DefaultTaxClass
+	}
+
+	return taxClass
+}
@@ -0,0 +1,577 @@
+package product
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/akshtrikha/golang-ecomm/services/auth"
+	"github.com/akshtrikha/golang-ecomm/types"
+	"github.com/gorilla/mux"
+)
+
+// mockProductStore keeps the products in memory
+type mockProductStore struct {
+	products map[int]*types.Product
+	query    types.ProductListQuery
+}
+
+func (m *mockProductStore) AddProduct(p types.AddProductPayload) (int, error) {
+	id := len(m.products) + 1
+	m.products[id] = &types.Product{
+		ID:          id,
+		Name:        p.Name,
+		Description: p.Description,
+		Image:       p.Image,
+		Price:       p.Price,
+		Quantity:    p.Quantity,
+		TaxClass:    p.TaxClass,
+		Weight:      p.Weight,
+		Length:      p.Length,
+		Width:       p.Width,
+		Height:      p.Height,
+	}
+
+	return id, nil
+}
+
+func (m *mockProductStore) GetProducts() ([]types.Product, error) {
+	var products []types.Product
+	for _, p := range m.products {
+		if p.DeletedAt == nil {
+			products = append(products, *p)
+		}
+	}
+
+	return products, nil
+}
+
+// ListProducts records the query and returns the products sorted by id
+func (m *mockProductStore) ListProducts(q types.ProductListQuery) (*types.ProductPage, error) {
+	m.query = q
+
+	if q.After != nil {
+		if _, err := cursorArg(q.After, q.Sort); err != nil {
+			return nil, err
+		}
+	}
+
+	page := &types.ProductPage{Items: []types.Product{}, Limit: q.Limit}
+	for id := 1; id <= len(m.products); id++ {
+		p := m.products[id]
+		if p.DeletedAt != nil || (q.After != nil && p.ID <= q.After.ID) {
+			continue
+		}
+
+		page.Total++
+		if len(page.Items) == q.Limit {
+			page.HasMore = true
+			continue
+		}
+		page.Items = append(page.Items, *p)
+	}
+
+	return page, nil
+}
+
+func (m *mockProductStore) GetProductsByIDs(ids []int) ([]types.Product, error) {
+	return nil, nil
+}
+
+func (m *mockProductStore) GetProductByID(id int) (*types.Product, error) {
+	p, ok := m.products[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrProductNotFound, id)
+	}
+
+	copied := *p
+	return &copied, nil
+}
+
+func (m *mockProductStore) UpdateProduct(p types.Product) error {
+	m.products[p.ID] = &p
+	return nil
+}
+
+func (m *mockProductStore) DeleteProduct(id int) error {
+	p, ok := m.products[id]
+	if !ok || p.DeletedAt != nil {
+		return fmt.Errorf("%w: %v", ErrProductNotFound, id)
+	}
+
+	now := time.Now()
+	p.DeletedAt = &now
+	return nil
+}
+
+// mockVariantStore keeps the options and variants in memory
+type mockVariantStore struct {
+	options  map[int][]types.ProductOption
+	variants []types.ProductVariant
+}
+
+func (m *mockVariantStore) GetProductOptions(productID int) ([]types.ProductOption, error) {
+	return m.options[productID], nil
+}
+
+func (m *mockVariantStore) SetProductOptions(productID int, options []types.ProductOption) error {
+	m.options[productID] = options
+	return nil
+}
+
+func (m *mockVariantStore) GetVariantsByProductID(productID int) ([]types.ProductVariant, error) {
+	variants := []types.ProductVariant{}
+	for _, v := range m.variants {
+		if v.ProductID == productID && v.DeletedAt == nil {
+			variants = append(variants, v)
+		}
+	}
+
+	return variants, nil
+}
+
+func (m *mockVariantStore) GetVariantBySKU(sku string) (*types.ProductVariant, error) {
+	for _, v := range m.variants {
+		if v.SKU == sku {
+			return &v, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %v", ErrVariantNotFound, sku)
+}
+
+func (m *mockVariantStore) AddVariant(v types.ProductVariant) (int, error) {
+	v.ID = len(m.variants) + 1
+	m.variants = append(m.variants, v)
+
+	return v.ID, nil
+}
+
+func (m *mockVariantStore) UpdateVariant(v types.ProductVariant) error {
+	m.variants[v.ID-1] = v
+	return nil
+}
+
+func (m *mockVariantStore) DeleteVariant(id int) error {
+	now := time.Now()
+	m.variants[id-1].DeletedAt = &now
+	return nil
+}
+
+// TestProductServiceHandlers function to implement testing
+func TestProductServiceHandlers(t *testing.T) {
+	store := &mockProductStore{products: map[int]*types.Product{}}
+	variantStore := &mockVariantStore{options: map[int][]types.ProductOption{}}
+	handler := NewHandler(store, variantStore)
+
+	adminToken, err := auth.GenerateJWT(1, []string{auth.RoleAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	customerToken, err := auth.GenerateJWT(2, []string{auth.RoleCustomer})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(method, path string, payload any, token string) *httptest.ResponseRecorder {
+		var body []byte
+		if payload != nil {
+			body, _ = json.Marshal(payload)
+		}
+
+		req, err := http.NewRequest(method, path, bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+		router.Use(auth.JWTMiddleware)
+
+		handler.RegisterRoutes(router)
+		router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	product := types.AddProductPayload{
+		Name:        "test",
+		Description: "test description",
+		Image:       "test.png",
+		Price:       types.NewMoney(1000, types.DefaultCurrency),
+		Quantity:    5,
+	}
+
+	t.Run("Should not allow customers to add products", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/products", product, customerToken)
+
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("Expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	t.Run("Should add the product", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/products", product, adminToken)
+
+		if rr.Code != http.StatusCreated {
+			t.Errorf("Expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		if p, _ := store.GetProductByID(1); p == nil || p.TaxClass != types.DefaultTaxClass {
+			t.Errorf("Expected the standard tax class, got %+v", p)
+		}
+	})
+
+	t.Run("Should fail if the price has more decimals than the currency", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/products", map[string]any{
+			"name":        "test",
+			"description": "test description",
+			"image":       "test.png",
+			"price":       10.999,
+			"quantity":    5,
+		}, adminToken)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("Should fail if the price is not in the base currency", func(t *testing.T) {
+		priced := product
+		priced.Price = types.NewMoney(500, "EUR")
+
+		if rr := serve(http.MethodPost, "/products", priced, adminToken); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+
+		if rr := serve(http.MethodPatch, "/products/1", types.UpdateProductPayload{Price: &priced.Price}, adminToken); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+
+		if p, _ := store.GetProductByID(1); p.Price != product.Price {
+			t.Errorf("Expected the price to be left as it is, got %v", p.Price)
+		}
+	})
+
+	t.Run("Should partially update the product", func(t *testing.T) {
+		price := types.NewMoney(1250, types.DefaultCurrency)
+		rr := serve(http.MethodPatch, "/products/1", types.UpdateProductPayload{Price: &price}, adminToken)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		p, _ := store.GetProductByID(1)
+		if p.Price != price || p.Name != "test" {
+			t.Errorf("Expected only the price to change, got %+v", p)
+		}
+	})
+
+	t.Run("Should update the weight and dimensions of the product", func(t *testing.T) {
+		weight, length := 1200, 300
+		rr := serve(http.MethodPatch, "/products/1", types.UpdateProductPayload{Weight: &weight, Length: &length}, adminToken)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		p, _ := store.GetProductByID(1)
+		if p.Weight != weight || p.Length != length || p.Width != 0 {
+			t.Errorf("Expected the weight 1200 and the length 300, got %+v", p)
+		}
+
+		negative := -1
+		if rr := serve(http.MethodPatch, "/products/1", types.UpdateProductPayload{Weight: &negative}, adminToken); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("Should fail to replace the product with an invalid payload", func(t *testing.T) {
+		rr := serve(http.MethodPut, "/products/1", types.AddProductPayload{Name: "test"}, adminToken)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("Should archive the product", func(t *testing.T) {
+		rr := serve(http.MethodDelete, "/products/1", nil, adminToken)
+
+		if rr.Code != http.StatusNoContent {
+			t.Fatalf("Expected status code %d, got %d", http.StatusNoContent, rr.Code)
+		}
+
+		products, _ := store.GetProducts()
+		if len(products) != 0 {
+			t.Errorf("Expected the archived product to be left out of the listing, got %+v", products)
+		}
+	})
+
+	t.Run("Should still resolve the archived product", func(t *testing.T) {
+		rr := serve(http.MethodGet, "/products/1", nil, customerToken)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var p types.Product
+		json.NewDecoder(rr.Body).Decode(&p)
+
+		if p.DeletedAt == nil {
+			t.Error("Expected the archived product to have deletedAt set")
+		}
+	})
+
+	t.Run("Should not update an archived product", func(t *testing.T) {
+		name := "new name"
+		rr := serve(http.MethodPatch, "/products/1", types.UpdateProductPayload{Name: &name}, adminToken)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("Should fail to list products with an unknown sort", func(t *testing.T) {
+		rr := serve(http.MethodGet, "/products?sort=quantity", nil, customerToken)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("Should fail to list products with a page too large", func(t *testing.T) {
+		rr := serve(http.MethodGet, "/products?limit=1000", nil, customerToken)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("Should pass the filters to the store", func(t *testing.T) {
+		rr := serve(http.MethodGet, "/products?sort=price&order=DESC&minPrice=5&maxPrice=20&inStock=true&name=shirt", nil, customerToken)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		q := store.query
+		if q.Sort != "price" || q.Order != "desc" || !q.InStock || q.Name != "shirt" ||
+			q.MinPrice == nil || q.MinPrice.Amount != 500 || q.MaxPrice == nil || q.MaxPrice.Amount != 2000 {
+			t.Errorf("Expected the filters of the request, got %+v", q)
+		}
+	})
+
+	t.Run("Should link to the next page with a cursor", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			store.AddProduct(product)
+		}
+
+		rr := serve(http.MethodGet, "/products?limit=1&offset=0", nil, customerToken)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var page types.ProductPage
+		json.NewDecoder(rr.Body).Decode(&page)
+
+		if page.Total != 2 || len(page.Items) != 1 || !page.HasMore || page.NextCursor == "" {
+			t.Fatalf("Expected the first of two products with a next cursor, got %+v", page)
+		}
+
+		rr = serve(http.MethodGet, page.Next, nil, customerToken)
+
+		page = types.ProductPage{}
+		json.NewDecoder(rr.Body).Decode(&page)
+
+		if len(page.Items) != 1 || page.Items[0].ID != 3 || page.HasMore || page.Next != "" {
+			t.Errorf("Expected the last product without a next link, got %+v", page)
+		}
+	})
+
+	t.Run("Should fail to list products with an invalid cursor", func(t *testing.T) {
+		rr := serve(http.MethodGet, "/products?cursor=invalid", nil, customerToken)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("Should fail to list products with the cursor of another sort", func(t *testing.T) {
+		cursor := EncodeCursor(types.Product{ID: 1, Name: "test"}, "name")
+
+		rr := serve(http.MethodGet, "/products?sort=price&cursor="+cursor, nil, customerToken)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("Should fail to add a variant before the options are defined", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/products/2/variants", types.AddVariantPayload{
+			SKU:     "SHIRT-M-RED",
+			Options: map[string]string{"size": "M"},
+		}, adminToken)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("Should fail to define an option twice", func(t *testing.T) {
+		rr := serve(http.MethodPut, "/products/2/options", types.ProductOptionsPayload{Options: []types.ProductOption{
+			{Name: "size", Values: []string{"M"}},
+			{Name: "size", Values: []string{"L"}},
+		}}, adminToken)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("Should define the options of the product", func(t *testing.T) {
+		rr := serve(http.MethodPut, "/products/2/options", types.ProductOptionsPayload{Options: []types.ProductOption{
+			{Name: "size", Values: []string{"M", "L"}},
+			{Name: "color", Values: []string{"red", "blue"}},
+		}}, adminToken)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+	})
+
+	t.Run("Should fail to add a variant with an unknown option value", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/products/2/variants", types.AddVariantPayload{
+			SKU:     "SHIRT-XL-RED",
+			Options: map[string]string{"size": "XL", "color": "red"},
+		}, adminToken)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("Should fail to add a variant priced in another currency", func(t *testing.T) {
+		price := types.NewMoney(1500, "EUR")
+		rr := serve(http.MethodPost, "/products/2/variants", types.AddVariantPayload{
+			SKU:     "SHIRT-M-RED",
+			Options: map[string]string{"size": "M", "color": "red"},
+			Price:   &price,
+		}, adminToken)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("Should add the variant", func(t *testing.T) {
+		price := types.NewMoney(1500, types.DefaultCurrency)
+		rr := serve(http.MethodPost, "/products/2/variants", types.AddVariantPayload{
+			SKU:      "SHIRT-M-RED",
+			Options:  map[string]string{"size": "M", "color": "red"},
+			Price:    &price,
+			Quantity: 3,
+		}, adminToken)
+
+		if rr.Code != http.StatusCreated {
+			t.Errorf("Expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+	})
+
+	t.Run("Should fail to add a variant with the same options", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/products/2/variants", types.AddVariantPayload{
+			SKU:     "SHIRT-M-RED-2",
+			Options: map[string]string{"color": "red", "size": "M"},
+		}, adminToken)
+
+		if rr.Code != http.StatusConflict {
+			t.Errorf("Expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+	})
+
+	t.Run("Should fail to add a variant with an existing sku", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/products/2/variants", types.AddVariantPayload{
+			SKU:     "SHIRT-M-RED",
+			Options: map[string]string{"size": "L", "color": "red"},
+		}, adminToken)
+
+		if rr.Code != http.StatusConflict {
+			t.Errorf("Expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+	})
+
+	t.Run("Should fail to remove an option used by the variants", func(t *testing.T) {
+		rr := serve(http.MethodPut, "/products/2/options", types.ProductOptionsPayload{Options: []types.ProductOption{
+			{Name: "size", Values: []string{"M", "L"}},
+		}}, adminToken)
+
+		if rr.Code != http.StatusConflict {
+			t.Errorf("Expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+	})
+
+	t.Run("Should clear the price override of the variant", func(t *testing.T) {
+		rr := serve(http.MethodPatch, "/products/2/variants/1", types.UpdateVariantPayload{ClearPrice: true}, adminToken)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		if variantStore.variants[0].Price != nil {
+			t.Errorf("Expected the price override to be cleared, got %v", *variantStore.variants[0].Price)
+		}
+	})
+
+	t.Run("Should return the product with its options and variants", func(t *testing.T) {
+		rr := serve(http.MethodGet, "/products/2", nil, customerToken)
+
+		var p types.Product
+		json.NewDecoder(rr.Body).Decode(&p)
+
+		if len(p.Options) != 2 || len(p.Variants) != 1 || p.Variants[0].SKU != "SHIRT-M-RED" {
+			t.Errorf("Expected the options and the variant of the product, got %+v", p)
+		}
+	})
+
+	t.Run("Should not find the variant of another product", func(t *testing.T) {
+		rr := serve(http.MethodDelete, "/products/3/variants/1", nil, adminToken)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
+
+// TestParseListQuery function to test the parsing of the listing query
+func TestParseListQuery(t *testing.T) {
+	t.Run("Should read the price filters in the currency of the rate", func(t *testing.T) {
+		eur := types.ExchangeRate{Currency: "EUR", Rate: "0.9215"}
+
+		q, err := ParseListQuery(url.Values{"minPrice": {"9.22"}, "maxPrice": {"18.43"}}, eur)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// 9.22 / 0.9215 = 10.0054 is rounded up and 18.43 / 0.9215 = 20
+		if q.MinPrice == nil || *q.MinPrice != types.NewMoney(1001, types.DefaultCurrency) ||
+			q.MaxPrice == nil || *q.MaxPrice != types.NewMoney(2000, types.DefaultCurrency) {
+			t.Errorf("Expected the prices 10.01 USD and 20.00 USD, got %v and %v", q.MinPrice, q.MaxPrice)
+		}
+	})
+
+	t.Run("Should fail if the price has more decimals than the currency", func(t *testing.T) {
+		jpy := types.ExchangeRate{Currency: "JPY", Rate: "151.5"}
+
+		if _, err := ParseListQuery(url.Values{"minPrice": {"1.50"}}, jpy); err == nil {
+			t.Error("Expected the price to be invalid in JPY")
+		}
+	})
+}
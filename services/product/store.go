@@ -2,10 +2,20 @@ package product
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/akshtrikha/golang-ecomm/types"
 )
 
+// productColumns is the list of columns scanned by scanRowIntoProduct
+const productColumns = "id, name, description, image, price, quantity, taxClass, weight, length, width, height, createAt, deletedAt"
+
+// variantColumns is the list of columns scanned by scanRowIntoVariant
+const variantColumns = "id, productId, sku, options, price, quantity, image, createdAt, deletedAt"
+
 // Store struct to hold the database object
 // This will be used to handle the database queries
 type Store struct {
@@ -17,11 +27,23 @@ func NewStore(db *sql.DB) *Store {
 	return &Store{db: db}
 }
 
+// ErrProductNotFound is returned when a product does not exist,
+// or is already archived when it is archived
+var ErrProductNotFound = errors.New("product not found")
+
+// ErrVariantNotFound is returned when a variant does not exist,
+// or is already archived when it is archived
+var ErrVariantNotFound = errors.New("variant not found")
+
 // AddProduct function to add the product to db
 // /add-product api endpoint
 func (s *Store) AddProduct(product types.AddProductPayload) (int, error) {
 	// run command to insert product in the products table
-	result, err := s.db.Exec("INSERT INTO products (name, description, image, price, quantity) VALUES (?, ?, ?, ?, ?)", product.Name, product.Description, product.Image, product.Price, product.Quantity)
+	result, err := s.db.Exec(
+		"INSERT INTO products (name, description, image, price, quantity, taxClass, weight, length, width, height) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		product.Name, product.Description, product.Image, product.Price, product.Quantity, product.TaxClass,
+		product.Weight, product.Length, product.Width, product.Height,
+	)
 	if err != nil {
 		return 0, err
 	}
@@ -38,12 +60,14 @@ func (s *Store) AddProduct(product types.AddProductPayload) (int, error) {
 
 // GetProducts func to get all the products
 // response to /get-products api endpoint
+// archived products are not listed
 func (s *Store) GetProducts() ([]types.Product, error) {
 	// run the query to get all the rows from products table
-	rows, err := s.db.Query(`SELECT * FROM products`)
+	rows, err := s.db.Query("SELECT " + productColumns + " FROM products WHERE deletedAt IS NULL")
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	var products []types.Product
 
@@ -59,12 +83,372 @@ func (s *Store) GetProducts() ([]types.Product, error) {
 	}
 
 	// return the result
-	return products, err
+	return products, rows.Err()
+}
+
+// ListProducts func to get a page of the products matching the filters
+// the page is taken after q.After when set, at q.Offset otherwise
+func (s *Store) ListProducts(q types.ProductListQuery) (*types.ProductPage, error) {
+	where, args := buildListFilters(q)
+
+	// count the products matching the filters
+	page := &types.ProductPage{Limit: q.Limit, Offset: q.Offset}
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM products WHERE "+where, args...).Scan(&page.Total); err != nil {
+		return nil, err
+	}
+
+	column := sortColumns[q.Sort]
+	direction, comparison := "ASC", ">"
+	if q.Order == "desc" {
+		direction, comparison = "DESC", "<"
+	}
+
+	// ties on the sort column are broken by the id
+	// so the cursor always points at a single product
+	if q.After != nil {
+		value, err := cursorArg(q.After, q.Sort)
+		if err != nil {
+			return nil, err
+		}
+
+		if column == "id" {
+			where += fmt.Sprintf(" AND id %s ?", comparison)
+			args = append(args, q.After.ID)
+		} else {
+			where += fmt.Sprintf(" AND (%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", column, comparison)
+			args = append(args, value, value, q.After.ID)
+		}
+
+		page.Offset = 0
+	}
+
+	orderBy := "id " + direction
+	if column != "id" {
+		orderBy = column + " " + direction + ", " + orderBy
+	}
+
+	// one more row is fetched to know if there is a next page
+	query := fmt.Sprintf("SELECT %s FROM products WHERE %s ORDER BY %s LIMIT ? OFFSET ?", productColumns, where, orderBy)
+	args = append(args, q.Limit+1, page.Offset)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page.Items = []types.Product{}
+
+	// scan the rows
+	for rows.Next() {
+		product, err := scanRowIntoProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		page.Items = append(page.Items, *product)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Items) > q.Limit {
+		page.Items = page.Items[:q.Limit]
+		page.HasMore = true
+	}
+
+	return page, nil
+}
+
+// GetProductsByIDs func to get the products with the given ids
+// archived products are left out as they can no longer be sold
+func (s *Store) GetProductsByIDs(productIDs []int) ([]types.Product, error) {
+	if len(productIDs) == 0 {
+		return nil, fmt.Errorf("no product ids provided")
+	}
+
+	// build the placeholders for the IN clause
+	placeholders := strings.Repeat("?,", len(productIDs)-1) + "?"
+
+	args := make([]interface{}, len(productIDs))
+	for i, id := range productIDs {
+		args[i] = id
+	}
+
+	rows, err := s.db.Query(fmt.Sprintf("SELECT %s FROM products WHERE id IN (%s) AND deletedAt IS NULL", productColumns, placeholders), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []types.Product
+
+	// scan the rows
+	for rows.Next() {
+		product, err := scanRowIntoProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		products = append(products, *product)
+	}
+
+	return products, rows.Err()
+}
+
+// GetProductByID func to get the product by id
+// archived products are returned too so historical orders can resolve them
+func (s *Store) GetProductByID(id int) (*types.Product, error) {
+	rows, err := s.db.Query("SELECT "+productColumns+" FROM products WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	p := new(types.Product)
+	for rows.Next() {
+		p, err = scanRowIntoProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if p.ID == 0 {
+		return nil, fmt.Errorf("%w: %v", ErrProductNotFound, id)
+	}
+
+	return p, nil
+}
+
+// UpdateProduct func to update every field of the product
+// archived products can not be updated
+func (s *Store) UpdateProduct(product types.Product) error {
+	_, err := s.db.Exec(
+		"UPDATE products SET name = ?, description = ?, image = ?, price = ?, quantity = ?, taxClass = ?, weight = ?, length = ?, width = ?, height = ? WHERE id = ? AND deletedAt IS NULL",
+		product.Name, product.Description, product.Image, product.Price, product.Quantity, product.TaxClass,
+		product.Weight, product.Length, product.Width, product.Height, product.ID,
+	)
+
+	return err
+}
+
+// DeleteProduct func to archive the product
+// the row is kept so historical orders can still resolve it
+func (s *Store) DeleteProduct(id int) error {
+	result, err := s.db.Exec("UPDATE products SET deletedAt = CURRENT_TIMESTAMP WHERE id = ? AND deletedAt IS NULL", id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w: %v", ErrProductNotFound, id)
+	}
+
+	return nil
+}
+
+// GetProductOptions func to get the options of the product in their order
+func (s *Store) GetProductOptions(productID int) ([]types.ProductOption, error) {
+	rows, err := s.db.Query("SELECT name, `values` FROM product_options WHERE productId = ? ORDER BY position", productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	options := []types.ProductOption{}
+	for rows.Next() {
+		var option types.ProductOption
+		var values []byte
+
+		if err := rows.Scan(&option.Name, &values); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(values, &option.Values); err != nil {
+			return nil, err
+		}
+
+		options = append(options, option)
+	}
+
+	return options, rows.Err()
+}
+
+// SetProductOptions func to replace the options of the product
+func (s *Store) SetProductOptions(productID int, options []types.ProductOption) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM product_options WHERE productId = ?", productID); err != nil {
+		return err
+	}
+
+	for i, option := range options {
+		values, err := json.Marshal(option.Values)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("INSERT INTO product_options (productId, name, `values`, position) VALUES (?, ?, ?, ?)", productID, option.Name, values, i)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetVariantsByProductID func to get the variants of the product
+// deleted variants are left out
+func (s *Store) GetVariantsByProductID(productID int) ([]types.ProductVariant, error) {
+	rows, err := s.db.Query("SELECT "+variantColumns+" FROM product_variants WHERE productId = ? AND deletedAt IS NULL ORDER BY id", productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	variants := []types.ProductVariant{}
+	for rows.Next() {
+		v, err := scanRowIntoVariant(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		variants = append(variants, *v)
+	}
+
+	return variants, rows.Err()
+}
+
+// GetVariantBySKU func to get the variant by its sku
+// deleted variants are returned too so historical orders can resolve them
+func (s *Store) GetVariantBySKU(sku string) (*types.ProductVariant, error) {
+	rows, err := s.db.Query("SELECT "+variantColumns+" FROM product_variants WHERE sku = ?", sku)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	v := new(types.ProductVariant)
+	for rows.Next() {
+		v, err = scanRowIntoVariant(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if v.ID == 0 {
+		return nil, fmt.Errorf("%w: %v", ErrVariantNotFound, sku)
+	}
+
+	return v, nil
+}
+
+// AddVariant func to add a variant to the product
+func (s *Store) AddVariant(v types.ProductVariant) (int, error) {
+	options, err := json.Marshal(v.Options)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := s.db.Exec(
+		"INSERT INTO product_variants (productId, sku, options, price, quantity, image) VALUES (?, ?, ?, ?, ?, ?)",
+		v.ProductID, v.SKU, options, v.Price, v.Quantity, v.Image,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// UpdateVariant func to update the price, stock and image of the variant
+// the sku and options of a variant never change
+func (s *Store) UpdateVariant(v types.ProductVariant) error {
+	_, err := s.db.Exec(
+		"UPDATE product_variants SET price = ?, quantity = ?, image = ? WHERE id = ? AND deletedAt IS NULL",
+		v.Price, v.Quantity, v.Image, v.ID,
+	)
+
+	return err
+}
+
+// DeleteVariant func to archive the variant
+// the row is kept so historical orders can still resolve it
+func (s *Store) DeleteVariant(id int) error {
+	result, err := s.db.Exec("UPDATE product_variants SET deletedAt = CURRENT_TIMESTAMP WHERE id = ? AND deletedAt IS NULL", id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w: %v", ErrVariantNotFound, id)
+	}
+
+	return nil
+}
+
+func scanRowIntoVariant(rows *sql.Rows) (*types.ProductVariant, error) {
+	v := new(types.ProductVariant)
+
+	var options []byte
+	var price types.NullMoney
+	var deletedAt sql.NullTime
+	err := rows.Scan(
+		&v.ID,
+		&v.ProductID,
+		&v.SKU,
+		&options,
+		&price,
+		&v.Quantity,
+		&v.Image,
+		&v.CreatedAt,
+		&deletedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(options, &v.Options); err != nil {
+		return nil, err
+	}
+
+	if price.Valid {
+		v.Price = &price.Money
+	}
+
+	if deletedAt.Valid {
+		v.DeletedAt = &deletedAt.Time
+	}
+
+	return v, nil
 }
 
 func scanRowIntoProduct(rows *sql.Rows) (*types.Product, error) {
 	product := new(types.Product)
 
+	var deletedAt sql.NullTime
 	err := rows.Scan(
 		&product.ID,
 		&product.Name,
@@ -72,12 +456,22 @@ func scanRowIntoProduct(rows *sql.Rows) (*types.Product, error) {
 		&product.Image,
 		&product.Price,
 		&product.Quantity,
+		&product.TaxClass,
+		&product.Weight,
+		&product.Length,
+		&product.Width,
+		&product.Height,
 		&product.CreatedAt,
+		&deletedAt,
 	)
 
 	if err != nil {
 		return nil, err
 	}
 
+	if deletedAt.Valid {
+		product.DeletedAt = &deletedAt.Time
+	}
+
 	return product, nil
 }
@@ -0,0 +1,81 @@
+package product
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// ValidateOptions checks the option names and the values
+// of every option are unique
+func ValidateOptions(options []types.ProductOption) error {
+	names := make(map[string]bool, len(options))
+	for _, option := range options {
+		if names[option.Name] {
+			return fmt.Errorf("option %q is defined twice", option.Name)
+		}
+		names[option.Name] = true
+
+		values := make(map[string]bool, len(option.Values))
+		for _, value := range option.Values {
+			if values[value] {
+				return fmt.Errorf("value %q of option %q is defined twice", value, option.Name)
+			}
+			values[value] = true
+		}
+	}
+
+	return nil
+}
+
+// ValidateVariantOptions checks the variant has a value for every
+// option of the product and the values are among the option values
+func ValidateVariantOptions(options []types.ProductOption, values map[string]string) error {
+	if len(options) == 0 {
+		return fmt.Errorf("the options of the product have to be defined before adding variants")
+	}
+
+	if len(values) != len(options) {
+		return fmt.Errorf("the variant needs a value for each of the %d options of the product", len(options))
+	}
+
+	for _, option := range options {
+		value, ok := values[option.Name]
+		if !ok {
+			return fmt.Errorf("the variant is missing a value for option %q", option.Name)
+		}
+
+		found := false
+		for _, v := range option.Values {
+			if v == value {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("%q is not a value of option %q", value, option.Name)
+		}
+	}
+
+	return nil
+}
+
+// optionsKey returns the values of the variant options in a
+// canonical form so two variants with the same values compare equal
+func optionsKey(values map[string]string) string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + "=" + values[name]
+	}
+
+	return strings.Join(pairs, "\x00")
+}
@@ -0,0 +1,349 @@
+package promotion
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/akshtrikha/golang-ecomm/services/currency"
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// ErrNotApplicable is wrapped by the errors of the coupons
+// that can not be used for a cart or an order
+var ErrNotApplicable = errors.New("coupon not applicable")
+
+// NotApplicableError explains why the coupon with the code does not apply
+type NotApplicableError struct {
+	Code   string
+	Reason string
+}
+
+func (e *NotApplicableError) Error() string {
+	return fmt.Sprintf("coupon %s %s", e.Code, e.Reason)
+}
+
+// Unwrap makes the coupon errors match ErrNotApplicable
+func (e *NotApplicableError) Unwrap() error {
+	return ErrNotApplicable
+}
+
+func notApplicable(code string, format string, args ...any) error {
+	return &NotApplicableError{Code: code, Reason: fmt.Sprintf(format, args...)}
+}
+
+// NormalizeCode returns the code as it is stored, codes are case insensitive
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// NormalizeCodes normalizes the codes and drops the repeated ones
+func NormalizeCodes(codes []string) []string {
+	normalized := make([]string, 0, len(codes))
+	seen := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		code = NormalizeCode(code)
+		if !seen[code] {
+			seen[code] = true
+			normalized = append(normalized, code)
+		}
+	}
+
+	return normalized
+}
+
+// Validate checks the promotion has the settings its type needs
+func Validate(p types.Promotion) error {
+	switch p.Type {
+	case types.PromotionPercentage:
+		percent, err := types.ParseRate(p.Percent)
+		if err != nil || percent.Cmp(big.NewRat(100, 1)) > 0 {
+			return fmt.Errorf("percent must be between 0 and 100")
+		}
+	case types.PromotionFixed:
+		if p.Amount == nil {
+			return fmt.Errorf("amount is required for fixed promotions")
+		}
+	case types.PromotionBuyXGetY:
+		if p.ProductID == nil || p.BuyQuantity < 1 || p.GetQuantity < 1 {
+			return fmt.Errorf("productId, buyQuantity and getQuantity are required for buy x get y promotions")
+		}
+	}
+
+	for _, m := range []*types.Money{p.Amount, p.MinOrderValue} {
+		if m != nil && m.Currency != types.DefaultCurrency {
+			return fmt.Errorf("promotion amounts must be in the base currency %v", types.DefaultCurrency)
+		}
+	}
+
+	if p.StartsAt != nil && p.EndsAt != nil && !p.EndsAt.After(*p.StartsAt) {
+		return fmt.Errorf("endsAt must be after startsAt")
+	}
+
+	return nil
+}
+
+// CheckAvailable verifies the promotion is active, within its validity
+// window and under its usage limits, redemptions is the number of times
+// the user already used it
+func CheckAvailable(p types.Promotion, now time.Time, redemptions int) error {
+	switch {
+	case !p.Active:
+		return notApplicable(p.Code, "is not active")
+	case p.StartsAt != nil && now.Before(*p.StartsAt):
+		return notApplicable(p.Code, "is not valid yet")
+	case p.EndsAt != nil && !now.Before(*p.EndsAt):
+		return notApplicable(p.Code, "has expired")
+	case p.UsageLimit != nil && p.UsageCount >= *p.UsageLimit:
+		return notApplicable(p.Code, "has reached its usage limit")
+	case p.PerUserLimit != nil && redemptions >= *p.PerUserLimit:
+		return notApplicable(p.Code, "has already been used")
+	}
+
+	return nil
+}
+
+// Convert returns the promotion with its amounts in the currency of the rate
+func Convert(rate types.ExchangeRate, p types.Promotion) (types.Promotion, error) {
+	for _, m := range []**types.Money{&p.Amount, &p.MinOrderValue} {
+		if *m == nil {
+			continue
+		}
+
+		converted, err := currency.Convert(rate, **m)
+		if err != nil {
+			return p, err
+		}
+		*m = &converted
+	}
+
+	return p, nil
+}
+
+// Apply computes the discounts of the promotions on the lines priced in the currency.
+// Promotions are applied in a fixed order so the result does not depend on the
+// order of the codes: buy X get Y first, then percentages, then fixed amounts,
+// each on what is left of the subtotal so the discount never exceeds it.
+// Percentages are rounded half to even. A promotion that is not stackable can
+// only be used alone, a minimum order value applies to the subtotal before discounts
+func Apply(promotions []types.Promotion, lines []types.DiscountLine, currencyCode string) (*types.DiscountResult, error) {
+	subtotals := make([]types.Money, len(lines))
+	for i, line := range lines {
+		var err error
+		if subtotals[i], err = line.Price.Mul(int64(line.Quantity)); err != nil {
+			return nil, err
+		}
+	}
+
+	subtotal, err := types.Sum(currencyCode, subtotals...)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &types.DiscountResult{
+		Subtotal: subtotal,
+		Discount: types.NewMoney(0, currencyCode),
+		Applied:  []types.AppliedPromotion{},
+	}
+
+	ordered := make([]types.Promotion, len(promotions))
+	copy(ordered, promotions)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return applyOrder[ordered[i].Type] < applyOrder[ordered[j].Type]
+	})
+
+	for _, p := range ordered {
+		if len(ordered) > 1 && !p.Stackable {
+			return nil, notApplicable(p.Code, "can not be combined with other coupons")
+		}
+
+		if p.MinOrderValue != nil {
+			if cmp, err := subtotal.Cmp(*p.MinOrderValue); err != nil {
+				return nil, err
+			} else if cmp < 0 {
+				return nil, notApplicable(p.Code, "requires a minimum order of %v", *p.MinOrderValue)
+			}
+		}
+
+		remaining, err := subtotal.Sub(result.Discount)
+		if err != nil {
+			return nil, err
+		}
+
+		amount, err := discountOf(p, lines, remaining)
+		if err != nil {
+			return nil, err
+		}
+
+		// the discount never exceeds what is left to pay
+		if amount.Amount > remaining.Amount {
+			amount = remaining
+		}
+
+		if result.Discount, err = result.Discount.Add(amount); err != nil {
+			return nil, err
+		}
+
+		if p.Type == types.PromotionFreeShipping {
+			result.FreeShipping = true
+		}
+
+		result.Applied = append(result.Applied, types.AppliedPromotion{
+			PromotionID: p.ID,
+			Code:        p.Code,
+			Type:        p.Type,
+			Amount:      amount,
+		})
+	}
+
+	return result, nil
+}
+
+var applyOrder = map[types.PromotionType]int{
+	types.PromotionBuyXGetY:     0,
+	types.PromotionPercentage:   1,
+	types.PromotionFixed:        2,
+	types.PromotionFreeShipping: 3,
+}
+
+func discountOf(p types.Promotion, lines []types.DiscountLine, remaining types.Money) (types.Money, error) {
+	zero := types.NewMoney(0, remaining.Currency)
+
+	switch p.Type {
+	case types.PromotionPercentage:
+		percent, err := types.ParseRate(p.Percent)
+		if err != nil {
+			return zero, err
+		}
+
+		return remaining.MulRat(percent.Quo(percent, big.NewRat(100, 1)), types.RoundHalfEven)
+	case types.PromotionFixed:
+		return *p.Amount, nil
+	case types.PromotionBuyXGetY:
+		return buyXGetY(p, lines, zero)
+	}
+
+	// free shipping is applied to the shipping cost, not to the items
+	return zero, nil
+}
+
+// buyXGetY gives GetQuantity units of the product for free for every
+// BuyQuantity units bought, the cheapest units (variants) are the free ones
+func buyXGetY(p types.Promotion, lines []types.DiscountLine, zero types.Money) (types.Money, error) {
+	var units []types.DiscountLine
+	quantity := 0
+	for _, line := range lines {
+		if line.ProductID == *p.ProductID {
+			units = append(units, line)
+			quantity += line.Quantity
+		}
+	}
+
+	free := quantity / (p.BuyQuantity + p.GetQuantity) * p.GetQuantity
+	if free == 0 {
+		return zero, notApplicable(p.Code, "requires %d units of the product %d", p.BuyQuantity+p.GetQuantity, *p.ProductID)
+	}
+
+	sort.SliceStable(units, func(i, j int) bool {
+		return units[i].Price.Amount < units[j].Price.Amount
+	})
+
+	discount := zero
+	for _, line := range units {
+		n := min(free, line.Quantity)
+
+		amount, err := line.Price.Mul(int64(n))
+		if err != nil {
+			return zero, err
+		}
+
+		if discount, err = discount.Add(amount); err != nil {
+			return zero, err
+		}
+
+		if free -= n; free == 0 {
+			break
+		}
+	}
+
+	return discount, nil
+}
+
+// ApplyValid applies the promotions leaving out the ones that are not
+// applicable, they are returned with the reason they were left out
+func ApplyValid(promotions []types.Promotion, lines []types.DiscountLine, currencyCode string) (*types.DiscountResult, []types.CouponError, error) {
+	invalid := []types.CouponError{}
+
+	for {
+		result, err := Apply(promotions, lines, currencyCode)
+
+		var couponErr *NotApplicableError
+		if !errors.As(err, &couponErr) {
+			return result, invalid, err
+		}
+
+		invalid = append(invalid, types.CouponError{Code: couponErr.Code, Reason: couponErr.Reason})
+
+		remaining := promotions[:0:0]
+		for _, p := range promotions {
+			if p.Code != couponErr.Code {
+				remaining = append(remaining, p)
+			}
+		}
+		promotions = remaining
+	}
+}
+
+// Resolve loads the promotions of the codes, checks they can be used now by
+// the user and converts their amounts to the currency of the rate. Codes that
+// can not be used are returned with the reason. The per user limit is not
+// checked for guests (userID 0), orders are always placed by a user
+func Resolve(store types.PromotionStore, codes []string, userID int, rate types.ExchangeRate, now time.Time) ([]types.Promotion, []types.CouponError, error) {
+	invalid := []types.CouponError{}
+	if len(codes) == 0 {
+		return nil, invalid, nil
+	}
+
+	found, err := store.GetPromotionsByCodes(codes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	byCode := make(map[string]types.Promotion, len(found))
+	for _, p := range found {
+		byCode[p.Code] = p
+	}
+
+	var promotions []types.Promotion
+	for _, code := range codes {
+		p, ok := byCode[code]
+		if !ok {
+			invalid = append(invalid, types.CouponError{Code: code, Reason: "does not exist"})
+			continue
+		}
+
+		redemptions := 0
+		if userID != 0 && p.PerUserLimit != nil {
+			if redemptions, err = store.CountUserRedemptions(p.ID, userID); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		if err := CheckAvailable(p, now, redemptions); err != nil {
+			var couponErr *NotApplicableError
+			errors.As(err, &couponErr)
+			invalid = append(invalid, types.CouponError{Code: code, Reason: couponErr.Reason})
+			continue
+		}
+
+		if p, err = Convert(rate, p); err != nil {
+			return nil, nil, err
+		}
+		promotions = append(promotions, p)
+	}
+
+	return promotions, invalid, nil
+}
@@ -0,0 +1,141 @@
+package promotion
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+func usd(amount int64) types.Money {
+	return types.NewMoney(amount, types.DefaultCurrency)
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func moneyPtr(m types.Money) *types.Money {
+	return &m
+}
+
+// TestApply function to test the discounts of the promotions
+func TestApply(t *testing.T) {
+	// 49.98 of shirts (product 1) and 10.00 of socks (product 2)
+	lines := []types.DiscountLine{
+		{ProductID: 1, Price: usd(1999), Quantity: 2},
+		{ProductID: 2, Price: usd(1000), Quantity: 1},
+	}
+
+	percentage := types.Promotion{ID: 1, Code: "SAVE10", Type: types.PromotionPercentage, Percent: "10", Stackable: true}
+	fixed := types.Promotion{ID: 2, Code: "FIVE", Type: types.PromotionFixed, Amount: moneyPtr(usd(500)), Stackable: true}
+
+	t.Run("Should apply the percentage before the fixed amount", func(t *testing.T) {
+		// the order of the codes does not matter
+		result, err := Apply([]types.Promotion{fixed, percentage}, lines, types.DefaultCurrency)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// 10% of 49.98 rounds to 5.00, then 5.00 off
+		if result.Subtotal != usd(4998) || result.Discount != usd(1000) {
+			t.Errorf("Expected a subtotal of 49.98 and a discount of 10.00, got %v and %v", result.Subtotal, result.Discount)
+		}
+
+		if len(result.Applied) != 2 || result.Applied[0].Code != "SAVE10" || result.Applied[0].Amount != usd(500) {
+			t.Errorf("Expected SAVE10 of 5.00 to be applied first, got %+v", result.Applied)
+		}
+	})
+
+	t.Run("Should not discount more than the subtotal", func(t *testing.T) {
+		big := types.Promotion{Code: "BIG", Type: types.PromotionFixed, Amount: moneyPtr(usd(10000))}
+
+		result, err := Apply([]types.Promotion{big}, lines, types.DefaultCurrency)
+		if err != nil || result.Discount != usd(4998) {
+			t.Errorf("Expected a discount of 49.98, got %+v, error: %v", result, err)
+		}
+	})
+
+	t.Run("Should not combine a promotion that is not stackable", func(t *testing.T) {
+		alone := types.Promotion{Code: "ALONE", Type: types.PromotionFreeShipping}
+
+		if _, err := Apply([]types.Promotion{percentage, alone}, lines, types.DefaultCurrency); !errors.Is(err, ErrNotApplicable) {
+			t.Errorf("Expected the promotion not to apply, got %v", err)
+		}
+
+		result, err := Apply([]types.Promotion{alone}, lines, types.DefaultCurrency)
+		if err != nil || !result.FreeShipping || !result.Discount.IsZero() {
+			t.Errorf("Expected free shipping without a discount, got %+v, error: %v", result, err)
+		}
+	})
+
+	t.Run("Should check the minimum order value", func(t *testing.T) {
+		minimum := types.Promotion{Code: "MIN", Type: types.PromotionFixed, Amount: moneyPtr(usd(500)), MinOrderValue: moneyPtr(usd(6000))}
+
+		if _, err := Apply([]types.Promotion{minimum}, lines, types.DefaultCurrency); !errors.Is(err, ErrNotApplicable) {
+			t.Errorf("Expected the promotion not to apply, got %v", err)
+		}
+	})
+
+	t.Run("Should give the cheapest units for free", func(t *testing.T) {
+		buy2get1 := types.Promotion{Code: "B2G1", Type: types.PromotionBuyXGetY, ProductID: intPtr(1), BuyQuantity: 2, GetQuantity: 1}
+
+		// 4 shirts of two variants, one is free
+		shirts := []types.DiscountLine{
+			{ProductID: 1, Price: usd(1999), Quantity: 3},
+			{ProductID: 1, Price: usd(1500), Quantity: 1},
+		}
+
+		result, err := Apply([]types.Promotion{buy2get1}, shirts, types.DefaultCurrency)
+		if err != nil || result.Discount != usd(1500) {
+			t.Errorf("Expected a discount of 15.00, got %+v, error: %v", result, err)
+		}
+
+		if _, err := Apply([]types.Promotion{buy2get1}, lines, types.DefaultCurrency); !errors.Is(err, ErrNotApplicable) {
+			t.Errorf("Expected the promotion not to apply to 2 shirts, got %v", err)
+		}
+	})
+
+	t.Run("Should leave out the promotions that do not apply", func(t *testing.T) {
+		minimum := types.Promotion{Code: "MIN", Type: types.PromotionFixed, Amount: moneyPtr(usd(500)), MinOrderValue: moneyPtr(usd(6000)), Stackable: true}
+
+		result, invalid, err := ApplyValid([]types.Promotion{minimum, percentage}, lines, types.DefaultCurrency)
+		if err != nil || result.Discount != usd(500) {
+			t.Errorf("Expected a discount of 5.00, got %+v, error: %v", result, err)
+		}
+
+		if len(invalid) != 1 || invalid[0].Code != "MIN" {
+			t.Errorf("Expected MIN to be left out, got %+v", invalid)
+		}
+	})
+}
+
+// TestCheckAvailable function to test the validity windows and usage limits
+func TestCheckAvailable(t *testing.T) {
+	now := time.Date(2024, 7, 3, 12, 0, 0, 0, time.UTC)
+	yesterday, tomorrow := now.AddDate(0, 0, -1), now.AddDate(0, 0, 1)
+
+	tests := []struct {
+		name        string
+		promotion   types.Promotion
+		redemptions int
+		available   bool
+	}{
+		{"active", types.Promotion{Active: true, StartsAt: &yesterday, EndsAt: &tomorrow}, 0, true},
+		{"inactive", types.Promotion{Active: false}, 0, false},
+		{"not valid yet", types.Promotion{Active: true, StartsAt: &tomorrow}, 0, false},
+		{"expired", types.Promotion{Active: true, EndsAt: &yesterday}, 0, false},
+		{"usage limit", types.Promotion{Active: true, UsageLimit: intPtr(100), UsageCount: 100}, 0, false},
+		{"per user limit", types.Promotion{Active: true, PerUserLimit: intPtr(1)}, 1, false},
+		{"under the limits", types.Promotion{Active: true, UsageLimit: intPtr(100), UsageCount: 99, PerUserLimit: intPtr(2)}, 1, true},
+	}
+
+	for _, test := range tests {
+		err := CheckAvailable(test.promotion, now, test.redemptions)
+
+		if test.available != (err == nil) {
+			t.Errorf("%s: expected available %v, got %v", test.name, test.available, err)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package promotion
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/akshtrikha/golang-ecomm/services/auth"
+	"github.com/akshtrikha/golang-ecomm/types"
+	"github.com/akshtrikha/golang-ecomm/utils"
+	"github.com/gorilla/mux"
+)
+
+// API Endpoints for the admins to manage the promotions and their coupon codes
+
+// Handler to the promotion store which will deal
+// with the database regarding promotions
+type Handler struct {
+	store types.PromotionStore
+}
+
+// NewHandler constructor takes PromotionStore as dependency
+func NewHandler(store types.PromotionStore) *Handler {
+	return &Handler{store: store}
+}
+
+// RegisterRoutes func for promotions
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/promotions", auth.RequireRole(auth.RoleAdmin, h.handleGetPromotions)).Methods("GET")
+	router.HandleFunc("/admin/promotions", auth.RequireRole(auth.RoleAdmin, h.handleCreatePromotion)).Methods("POST")
+	router.HandleFunc("/admin/promotions/{id:[0-9]+}", auth.RequireRole(auth.RoleAdmin, h.handleGetPromotion)).Methods("GET")
+	router.HandleFunc("/admin/promotions/{id:[0-9]+}", auth.RequireRole(auth.RoleAdmin, h.handleUpdatePromotion)).Methods("PUT")
+}
+
+func (h *Handler) handleGetPromotions(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle GET /admin/promotions endpoint hit")
+
+	promotions, err := h.store.GetPromotions()
+	if err != nil {
+		log.Println("Error fetching the promotions from the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, promotions)
+}
+
+func (h *Handler) handleGetPromotion(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle GET /admin/promotions/{id} endpoint hit")
+
+	promotionID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid promotion id"))
+		return
+	}
+
+	p, err := h.store.GetPromotionByID(promotionID)
+	if errors.Is(err, ErrPromotionNotFound) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("promotion with id: %v not found", promotionID))
+		return
+	}
+	if err != nil {
+		log.Printf("Error finding the promotion %v, error: %+v", promotionID, err)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, p)
+}
+
+func (h *Handler) handleCreatePromotion(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle POST /admin/promotions endpoint hit")
+
+	p, status, err := h.parsePromotion(r, 0)
+	if err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	promotionID, err := h.store.CreatePromotion(*p)
+	if err != nil {
+		log.Println("Error adding the promotion to the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	log.Printf("Promotion Added %v", promotionID)
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]int{"id": promotionID})
+}
+
+func (h *Handler) handleUpdatePromotion(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle PUT /admin/promotions/{id} endpoint hit")
+
+	promotionID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid promotion id"))
+		return
+	}
+
+	existing, err := h.store.GetPromotionByID(promotionID)
+	if errors.Is(err, ErrPromotionNotFound) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("promotion with id: %v not found", promotionID))
+		return
+	}
+	if err != nil {
+		log.Printf("Error finding the promotion %v, error: %+v", promotionID, err)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	p, status, err := h.parsePromotion(r, promotionID)
+	if err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	if err := h.store.UpdatePromotion(*p); err != nil {
+		log.Println("Error updating the promotion in the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	p.UsageCount, p.CreatedAt = existing.UsageCount, existing.CreatedAt
+
+	utils.WriteJSON(w, http.StatusOK, p)
+}
+
+// parsePromotion reads and validates the promotion of the payload, the code
+// must not be used by another promotion than the one with the id.
+// The returned status code is meant to be used when an error is returned.
+func (h *Handler) parsePromotion(r *http.Request, id int) (*types.Promotion, int, error) {
+	// get the json payload
+	var payload types.PromotionPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		log.Println("Payload parsing met with an error")
+		return nil, http.StatusBadRequest, err
+	}
+
+	// validate the json payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		return nil, http.StatusBadRequest, utils.ValidationError("Invalid payload", err)
+	}
+
+	p := &types.Promotion{
+		ID:            id,
+		Code:          NormalizeCode(payload.Code),
+		Description:   strings.TrimSpace(payload.Description),
+		Type:          payload.Type,
+		Percent:       strings.TrimSpace(payload.Percent),
+		Amount:        payload.Amount,
+		ProductID:     payload.ProductID,
+		BuyQuantity:   payload.BuyQuantity,
+		GetQuantity:   payload.GetQuantity,
+		MinOrderValue: payload.MinOrderValue,
+		UsageLimit:    payload.UsageLimit,
+		PerUserLimit:  payload.PerUserLimit,
+		Stackable:     payload.Stackable,
+		Active:        payload.Active == nil || *payload.Active,
+		StartsAt:      payload.StartsAt,
+		EndsAt:        payload.EndsAt,
+	}
+
+	if err := Validate(*p); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	// "15.50" and "15.5" are the same percentage
+	p.Percent = types.TrimDecimal(p.Percent)
+
+	found, err := h.store.GetPromotionsByCodes([]string{p.Code})
+	if err != nil {
+		log.Println("Error fetching the promotions from the database")
+		return nil, http.StatusInternalServerError, err
+	}
+
+	for _, other := range found {
+		if other.ID != id {
+			return nil, http.StatusConflict, fmt.Errorf("coupon code %s is already used", p.Code)
+		}
+	}
+
+	return p, http.StatusOK, nil
+}
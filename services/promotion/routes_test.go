@@ -0,0 +1,163 @@
+package promotion
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/akshtrikha/golang-ecomm/services/auth"
+	"github.com/akshtrikha/golang-ecomm/types"
+	"github.com/gorilla/mux"
+)
+
+// mockPromotionStore keeps the promotions in memory
+type mockPromotionStore struct {
+	promotions map[int]types.Promotion
+}
+
+func (m *mockPromotionStore) GetPromotions() ([]types.Promotion, error) {
+	promotions := []types.Promotion{}
+	for _, p := range m.promotions {
+		promotions = append(promotions, p)
+	}
+
+	return promotions, nil
+}
+
+func (m *mockPromotionStore) GetPromotionByID(id int) (*types.Promotion, error) {
+	p, ok := m.promotions[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrPromotionNotFound, id)
+	}
+
+	return &p, nil
+}
+
+func (m *mockPromotionStore) GetPromotionsByCodes(codes []string) ([]types.Promotion, error) {
+	promotions := []types.Promotion{}
+	for _, p := range m.promotions {
+		for _, code := range codes {
+			if p.Code == code {
+				promotions = append(promotions, p)
+			}
+		}
+	}
+
+	return promotions, nil
+}
+
+func (m *mockPromotionStore) CreatePromotion(p types.Promotion) (int, error) {
+	p.ID = len(m.promotions) + 1
+	m.promotions[p.ID] = p
+
+	return p.ID, nil
+}
+
+func (m *mockPromotionStore) UpdatePromotion(p types.Promotion) error {
+	m.promotions[p.ID] = p
+	return nil
+}
+
+func (m *mockPromotionStore) CountUserRedemptions(promotionID int, userID int) (int, error) {
+	return 0, nil
+}
+
+// TestPromotionServiceHandlers function to implement testing
+func TestPromotionServiceHandlers(t *testing.T) {
+	store := &mockPromotionStore{promotions: map[int]types.Promotion{}}
+	handler := NewHandler(store)
+
+	adminToken, err := auth.GenerateJWT(1, []string{auth.RoleAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	customerToken, err := auth.GenerateJWT(2, []string{auth.RoleCustomer})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(method, path string, body string, token string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+		router.Use(auth.JWTMiddleware)
+
+		handler.RegisterRoutes(router)
+		router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	t.Run("Should not allow customers to create promotions", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/admin/promotions", `{"code":"SAVE10","type":"percentage","percent":"10"}`, customerToken)
+
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("Expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	t.Run("Should fail if the promotion is missing the settings of its type", func(t *testing.T) {
+		for _, body := range []string{
+			`{"code":"SAVE","type":"percentage","percent":"120"}`,
+			`{"code":"SAVE","type":"fixed"}`,
+			`{"code":"SAVE","type":"fixed","amount":{"amount":"5","currency":"EUR"}}`,
+			`{"code":"SAVE","type":"buy_x_get_y","productId":1,"buyQuantity":2}`,
+			`{"code":"SAVE","type":"free_shipping","startsAt":"2024-07-10T00:00:00Z","endsAt":"2024-07-01T00:00:00Z"}`,
+		} {
+			rr := serve(http.MethodPost, "/admin/promotions", body, adminToken)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("Expected status code %d for %s, got %d", http.StatusBadRequest, body, rr.Code)
+			}
+		}
+	})
+
+	t.Run("Should create the promotion with a normalized code", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/admin/promotions", `{"code":" save10 ","type":"percentage","percent":"10.00"}`, adminToken)
+
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		if p := store.promotions[1]; p.Code != "SAVE10" || p.Percent != "10" || !p.Active {
+			t.Errorf("Expected the active promotion SAVE10 of 10%%, got %+v", p)
+		}
+	})
+
+	t.Run("Should fail if the code is already used", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/admin/promotions", `{"code":"SAVE10","type":"fixed","amount":"5"}`, adminToken)
+
+		if rr.Code != http.StatusConflict {
+			t.Errorf("Expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+	})
+
+	t.Run("Should update the promotion", func(t *testing.T) {
+		rr := serve(http.MethodPut, "/admin/promotions/1", `{"code":"SAVE10","type":"percentage","percent":"15","active":false}`, adminToken)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		if p := store.promotions[1]; p.Percent != "15" || p.Active {
+			t.Errorf("Expected the inactive promotion of 15%%, got %+v", p)
+		}
+	})
+
+	t.Run("Should fail to update an unknown promotion", func(t *testing.T) {
+		rr := serve(http.MethodPut, "/admin/promotions/9", `{"code":"OTHER","type":"free_shipping"}`, adminToken)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
@@ -0,0 +1,248 @@
+package promotion
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// Columns lists the columns of the promotions table scanned by
+// ScanRowIntoPromotion in order, the order store locks them in its transaction
+const Columns = "id, code, description, type, percent, amount, productId, buyQuantity, getQuantity, " +
+	"minOrderValue, usageLimit, perUserLimit, usageCount, stackable, active, startsAt, endsAt, createdAt"
+
+// Store struct to hold the database object
+// This will be used to handle the database queries
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore function to return a reference to the Store struct
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// ErrPromotionNotFound is returned when a promotion does not exist
+var ErrPromotionNotFound = errors.New("promotion not found")
+
+// GetPromotions function to get every promotion, the newest first
+func (s *Store) GetPromotions() ([]types.Promotion, error) {
+	rows, err := s.db.Query("SELECT " + Columns + " FROM promotions ORDER BY id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	promotions := []types.Promotion{}
+	for rows.Next() {
+		p, err := ScanRowIntoPromotion(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		promotions = append(promotions, *p)
+	}
+
+	return promotions, rows.Err()
+}
+
+// GetPromotionByID function to find the promotion by id
+func (s *Store) GetPromotionByID(id int) (*types.Promotion, error) {
+	rows, err := s.db.Query("SELECT "+Columns+" FROM promotions WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	p := new(types.Promotion)
+	for rows.Next() {
+		p, err = ScanRowIntoPromotion(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if p.ID == 0 {
+		return nil, fmt.Errorf("%w: %v", ErrPromotionNotFound, id)
+	}
+
+	return p, nil
+}
+
+// GetPromotionsByCodes function to get the promotions of the coupon codes
+// codes without a promotion are left out
+func (s *Store) GetPromotionsByCodes(codes []string) ([]types.Promotion, error) {
+	if len(codes) == 0 {
+		return []types.Promotion{}, nil
+	}
+
+	placeholders := strings.Repeat("?,", len(codes)-1) + "?"
+	args := make([]interface{}, len(codes))
+	for i, code := range codes {
+		args[i] = code
+	}
+
+	rows, err := s.db.Query(fmt.Sprintf("SELECT %s FROM promotions WHERE code IN (%s)", Columns, placeholders), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	promotions := []types.Promotion{}
+	for rows.Next() {
+		p, err := ScanRowIntoPromotion(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		promotions = append(promotions, *p)
+	}
+
+	return promotions, rows.Err()
+}
+
+// CreatePromotion function to add the promotion
+func (s *Store) CreatePromotion(p types.Promotion) (int, error) {
+	result, err := s.db.Exec(`
+		INSERT INTO promotions (code, description, type, percent, amount, productId, buyQuantity, getQuantity,
+			minOrderValue, usageLimit, perUserLimit, stackable, active, startsAt, endsAt)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.Code, p.Description, p.Type, nullIfEmpty(p.Percent), p.Amount, p.ProductID, p.BuyQuantity, p.GetQuantity,
+		p.MinOrderValue, p.UsageLimit, p.PerUserLimit, p.Stackable, p.Active, p.StartsAt, p.EndsAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// UpdatePromotion function to replace the settings of the promotion
+// the usage count is kept
+func (s *Store) UpdatePromotion(p types.Promotion) error {
+	_, err := s.db.Exec(`
+		UPDATE promotions SET code = ?, description = ?, type = ?, percent = ?, amount = ?, productId = ?,
+			buyQuantity = ?, getQuantity = ?, minOrderValue = ?, usageLimit = ?, perUserLimit = ?,
+			stackable = ?, active = ?, startsAt = ?, endsAt = ?
+		WHERE id = ?`,
+		p.Code, p.Description, p.Type, nullIfEmpty(p.Percent), p.Amount, p.ProductID,
+		p.BuyQuantity, p.GetQuantity, p.MinOrderValue, p.UsageLimit, p.PerUserLimit,
+		p.Stackable, p.Active, p.StartsAt, p.EndsAt, p.ID,
+	)
+
+	return err
+}
+
+// CountUserRedemptions function to count the orders of the user the
+// promotion was used for, cancelled orders do not count
+func (s *Store) CountUserRedemptions(promotionID int, userID int) (int, error) {
+	return CountUserRedemptions(s.db, promotionID, userID)
+}
+
+// Querier is implemented by *sql.DB and *sql.Tx
+type Querier interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// CountUserRedemptions counts the orders of the user the promotion was used
+// for with the querier, the order store counts inside its transaction
+func CountUserRedemptions(q Querier, promotionID int, userID int) (int, error) {
+	var count int
+	err := q.QueryRow(`
+		SELECT COUNT(*) FROM order_promotions op
+		JOIN orders o ON o.id = op.orderId
+		WHERE op.promotionId = ? AND o.userId = ? AND o.status <> ?`,
+		promotionID, userID, types.OrderStatusCancelled,
+	).Scan(&count)
+
+	return count, err
+}
+
+func nullIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+
+	return &s
+}
+
+// ScanRowIntoPromotion scans a row selected with Columns
+func ScanRowIntoPromotion(rows *sql.Rows) (*types.Promotion, error) {
+	p := new(types.Promotion)
+
+	var percent sql.NullString
+	var amount, minOrderValue types.NullMoney
+	var productID, usageLimit, perUserLimit sql.NullInt64
+	var startsAt, endsAt sql.NullTime
+
+	err := rows.Scan(
+		&p.ID,
+		&p.Code,
+		&p.Description,
+		&p.Type,
+		&percent,
+		&amount,
+		&productID,
+		&p.BuyQuantity,
+		&p.GetQuantity,
+		&minOrderValue,
+		&usageLimit,
+		&perUserLimit,
+		&p.UsageCount,
+		&p.Stackable,
+		&p.Active,
+		&startsAt,
+		&endsAt,
+		&p.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// the column keeps 2 decimal places, "15.00" reads as "15"
+	if percent.Valid {
+		p.Percent = types.TrimDecimal(percent.String)
+	}
+
+	if amount.Valid {
+		p.Amount = &amount.Money
+	}
+
+	if minOrderValue.Valid {
+		p.MinOrderValue = &minOrderValue.Money
+	}
+
+	if productID.Valid {
+		id := int(productID.Int64)
+		p.ProductID = &id
+	}
+
+	if usageLimit.Valid {
+		limit := int(usageLimit.Int64)
+		p.UsageLimit = &limit
+	}
+
+	if perUserLimit.Valid {
+		limit := int(perUserLimit.Int64)
+		p.PerUserLimit = &limit
+	}
+
+	if startsAt.Valid {
+		p.StartsAt = &startsAt.Time
+	}
+
+	if endsAt.Valid {
+		p.EndsAt = &endsAt.Time
+	}
+
+	return p, nil
+}
@@ -0,0 +1,180 @@
+package refund
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/akshtrikha/golang-ecomm/services/order"
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// ErrNothingToRefund is returned when the refund gives nothing back
+var ErrNothingToRefund = errors.New("nothing left to refund")
+
+// ErrNotRefundable is returned for the orders that were not paid or were refunded
+var ErrNotRefundable = errors.New("order cannot be refunded")
+
+// ErrInvalidLine is returned for a line that is not part of the order
+// or that refunds more units than are left of it
+var ErrInvalidLine = errors.New("invalid refund line")
+
+// LineTotals returns what was paid for every item of the order: the line total
+// less its discount, with its tax when the tax is added to the prices. The
+// amounts are shared in proportion to them from the total of the order less its
+// shipping, so they always add up to what was paid for the items, the tax
+// included in the prices that exempt customers do not pay included
+func LineTotals(o types.Order, items []types.OrderItem) ([]types.Money, error) {
+	zero := types.NewMoney(0, o.Currency)
+
+	paid, err := o.Total.Sub(o.Shipping)
+	if err != nil {
+		return nil, err
+	}
+
+	totals := make([]types.Money, len(items))
+	ratios := make([]int64, len(items))
+
+	var sum int64
+	for i, item := range items {
+		net, err := item.Price.Mul(int64(item.Quantity))
+		if err != nil {
+			return nil, err
+		}
+
+		if net, err = net.Sub(item.Discount); err != nil {
+			return nil, err
+		}
+
+		if !o.TaxInclusive {
+			if net, err = net.Add(item.Tax); err != nil {
+				return nil, err
+			}
+		}
+
+		totals[i], ratios[i] = zero, net.Amount
+		sum += net.Amount
+	}
+
+	// nothing was paid for the items of a free order
+	if sum == 0 || paid.IsZero() {
+		return totals, nil
+	}
+
+	return paid.Allocate(ratios...)
+}
+
+// Build computes the credit note of the refund of the order. The units of a line
+// are refunded at the share of the line they make up, the last units refunded
+// get what is left of the line so the refunds of a line add up to what was
+// paid for it. The shipping is refunded with the lines when the payload asks
+// for it and with the refund of whatever is left of the order
+func Build(o types.Order, items []types.OrderItem, refundedShipping types.Money, payload types.RefundPayload) (*types.CreditNote, error) {
+	if !order.CanTransition(o.Status, types.OrderStatusRefunded) {
+		return nil, fmt.Errorf("%w: order %v is %s", ErrNotRefundable, o.ID, o.Status)
+	}
+
+	zero := types.NewMoney(0, o.Currency)
+
+	totals, err := LineTotals(o, items)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := payload.Lines
+	shipping := payload.Shipping
+	if lines == nil {
+		for _, item := range items {
+			if left := item.Quantity - item.RefundedQuantity; left > 0 {
+				lines = append(lines, types.RefundLinePayload{OrderItemID: item.ID, Quantity: left})
+			}
+		}
+		shipping = true
+	}
+
+	note := &types.CreditNote{
+		OrderID:  o.ID,
+		Lines:    []types.CreditNoteLine{},
+		Shipping: zero,
+		Tax:      zero,
+		Total:    zero,
+		Restock:  payload.Restock,
+		Reason:   payload.Reason,
+	}
+
+	seen := map[int]bool{}
+	for _, line := range lines {
+		i := indexOf(items, line.OrderItemID)
+		if i < 0 || seen[line.OrderItemID] {
+			return nil, fmt.Errorf("%w: order item %v is not part of the order or is refunded twice", ErrInvalidLine, line.OrderItemID)
+		}
+		seen[line.OrderItemID] = true
+
+		item := items[i]
+		if line.Quantity > item.Quantity-item.RefundedQuantity {
+			return nil, fmt.Errorf("%w: %d of the %d units of order item %v are left to refund", ErrInvalidLine, item.Quantity-item.RefundedQuantity, item.Quantity, item.ID)
+		}
+
+		amount, err := unitsShare(totals[i], item.RefundedQuantity, line.Quantity, item.Quantity)
+		if err != nil {
+			return nil, err
+		}
+
+		tax, err := unitsShare(item.Tax, item.RefundedQuantity, line.Quantity, item.Quantity)
+		if err != nil {
+			return nil, err
+		}
+
+		note.Lines = append(note.Lines, types.CreditNoteLine{OrderItemID: item.ID, Quantity: line.Quantity, Amount: amount, Tax: tax})
+
+		if note.Total, err = note.Total.Add(amount); err != nil {
+			return nil, err
+		}
+
+		if note.Tax, err = note.Tax.Add(tax); err != nil {
+			return nil, err
+		}
+	}
+
+	if shipping {
+		if note.Shipping, err = o.Shipping.Sub(refundedShipping); err != nil {
+			return nil, err
+		}
+
+		if note.Total, err = note.Total.Add(note.Shipping); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(note.Lines) == 0 && note.Shipping.IsZero() {
+		return nil, ErrNothingToRefund
+	}
+
+	return note, nil
+}
+
+// unitsShare returns the share of the amount of a line of quantity units
+// that the units refunded after the ones already refunded make up
+func unitsShare(amount types.Money, refunded int, units int, quantity int) (types.Money, error) {
+	before, err := amount.MulRat(big.NewRat(int64(refunded), int64(quantity)), types.RoundHalfEven)
+	if err != nil {
+		return amount, err
+	}
+
+	after, err := amount.MulRat(big.NewRat(int64(refunded+units), int64(quantity)), types.RoundHalfEven)
+	if err != nil {
+		return amount, err
+	}
+
+	return after.Sub(before)
+}
+
+func indexOf(items []types.OrderItem, id int) int {
+	for i, item := range items {
+		if item.ID == id {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,144 @@
+package refund
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+func usd(amount int64) types.Money {
+	return types.NewMoney(amount, types.DefaultCurrency)
+}
+
+// paidOrder is an order of 3 units of 10.00 and a unit of 5.00 with 4.00 of
+// discount, taxed at 20% on top of the prices and shipped for 4.99
+func paidOrder() (types.Order, []types.OrderItem) {
+	o := types.Order{ID: 1, Total: usd(4219), Shipping: usd(499), Refunded: usd(0), Currency: types.DefaultCurrency, Status: types.OrderStatusPaid}
+	items := []types.OrderItem{
+		{ID: 1, OrderID: 1, ProductID: 1, Quantity: 3, Price: usd(1000), Discount: usd(300), TaxRate: "20", Tax: usd(540)},
+		{ID: 2, OrderID: 1, ProductID: 2, Quantity: 1, Price: usd(500), Discount: usd(100), TaxRate: "20", Tax: usd(80)},
+	}
+
+	return o, items
+}
+
+// TestLineTotals function to test what was paid for the lines of the orders
+func TestLineTotals(t *testing.T) {
+	o, items := paidOrder()
+
+	totals, err := LineTotals(o, items)
+	if err != nil || totals[0] != usd(3240) || totals[1] != usd(480) {
+		t.Errorf("Expected the lines to total 32.40 and 4.80, got %v, error: %v", totals, err)
+	}
+
+	t.Run("Should leave out the tax exempt customers did not pay", func(t *testing.T) {
+		// 12.00 including 2.00 of tax at 20% paid 10.00 without it
+		o := types.Order{Total: usd(1000), Shipping: usd(0), Currency: types.DefaultCurrency, TaxInclusive: true, TaxExempt: true}
+		items := []types.OrderItem{{Quantity: 1, Price: usd(1200), Discount: usd(0), Tax: usd(0)}}
+
+		if totals, err := LineTotals(o, items); err != nil || totals[0] != usd(1000) {
+			t.Errorf("Expected the line to total 10.00, got %v, error: %v", totals, err)
+		}
+	})
+}
+
+// TestBuild function to test the credit notes of the refunds
+func TestBuild(t *testing.T) {
+	t.Run("Should refund a unit at its share of the line", func(t *testing.T) {
+		o, items := paidOrder()
+
+		note, err := Build(o, items, usd(0), types.RefundPayload{Lines: []types.RefundLinePayload{{OrderItemID: 1, Quantity: 1}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(note.Lines) != 1 || note.Lines[0].Amount != usd(1080) || note.Tax != usd(180) || note.Total != usd(1080) || !note.Shipping.IsZero() {
+			t.Errorf("Expected 10.80 refunded with 1.80 of tax, got %+v", note)
+		}
+	})
+
+	t.Run("Should add up the refunds of a line to what was paid for it", func(t *testing.T) {
+		o := types.Order{ID: 1, Total: usd(1000), Shipping: usd(0), Currency: types.DefaultCurrency, TaxInclusive: true, Status: types.OrderStatusDelivered}
+		items := []types.OrderItem{{ID: 1, Quantity: 3, Price: usd(1000), Discount: usd(2000), Tax: usd(0)}}
+
+		var sum int64
+		for _, expected := range []int64{333, 334, 333} {
+			note, err := Build(o, items, usd(0), types.RefundPayload{Lines: []types.RefundLinePayload{{OrderItemID: 1, Quantity: 1}}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if note.Total != usd(expected) {
+				t.Errorf("Expected %d cents refunded, got %v", expected, note.Total)
+			}
+
+			sum += note.Total.Amount
+			items[0].RefundedQuantity++
+		}
+
+		if sum != 1000 {
+			t.Errorf("Expected the refunds to add up to 10.00, got %d cents", sum)
+		}
+	})
+
+	t.Run("Should refund whatever is left of the order", func(t *testing.T) {
+		o, items := paidOrder()
+		items[0].RefundedQuantity = 1
+
+		note, err := Build(o, items, usd(0), types.RefundPayload{Restock: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// 32.40 - 10.80 + 4.80 + 4.99
+		if len(note.Lines) != 2 || note.Lines[0].Quantity != 2 || note.Shipping != usd(499) || note.Total != usd(3139) || !note.Restock {
+			t.Errorf("Expected the rest of the order refunded, got %+v", note)
+		}
+
+		// the shipping was already refunded
+		if note, err := Build(o, items, usd(499), types.RefundPayload{}); err != nil || note.Total != usd(2640) {
+			t.Errorf("Expected 26.40 refunded without the shipping, got %+v, error: %v", note, err)
+		}
+	})
+
+	t.Run("Should refund the shipping alone", func(t *testing.T) {
+		o, items := paidOrder()
+
+		note, err := Build(o, items, usd(0), types.RefundPayload{Lines: []types.RefundLinePayload{}, Shipping: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if note.Total != usd(499) {
+			t.Errorf("Expected the shipping to be refunded, got %+v", note)
+		}
+	})
+
+	t.Run("Should fail for invalid refunds", func(t *testing.T) {
+		o, items := paidOrder()
+
+		for name, c := range map[string]struct {
+			lines []types.RefundLinePayload
+			err   error
+		}{
+			"unknown item":   {[]types.RefundLinePayload{{OrderItemID: 9, Quantity: 1}}, ErrInvalidLine},
+			"too many units": {[]types.RefundLinePayload{{OrderItemID: 1, Quantity: 4}}, ErrInvalidLine},
+			"same item":      {[]types.RefundLinePayload{{OrderItemID: 1, Quantity: 1}, {OrderItemID: 1, Quantity: 1}}, ErrInvalidLine},
+		} {
+			if _, err := Build(o, items, usd(0), types.RefundPayload{Lines: c.lines}); !errors.Is(err, c.err) {
+				t.Errorf("%s: expected error %v, got %v", name, c.err, err)
+			}
+		}
+
+		items[0].RefundedQuantity, items[1].RefundedQuantity = 3, 1
+		if _, err := Build(o, items, usd(499), types.RefundPayload{}); !errors.Is(err, ErrNothingToRefund) {
+			t.Errorf("Expected nothing left to refund, got %v", err)
+		}
+
+		o.Status = types.OrderStatusPending
+		if _, err := Build(o, items, usd(0), types.RefundPayload{}); !errors.Is(err, ErrNotRefundable) {
+			t.Errorf("Expected the pending order not to be refundable, got %v", err)
+		}
+	})
+}
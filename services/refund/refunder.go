@@ -0,0 +1,144 @@
+package refund
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/akshtrikha/golang-ecomm/services/order"
+	"github.com/akshtrikha/golang-ecomm/services/payment"
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// errGateway wraps the errors of the payment gateway
+var errGateway = errors.New("the payment gateway could not refund the payment")
+
+// errNoPayment is returned when the order has no captured payment to refund
+var errNoPayment = errors.New("no captured payment to refund")
+
+// ErrPartialRefund is returned when the payment provider gave back part of what is
+// left of an order, the order items the money was given back for are not known
+var ErrPartialRefund = errors.New("partial refund made at the payment provider")
+
+// Refunder gives the money of the refunds back from the captured payment of
+// the order with the gateway and records them with credit notes. It is shared
+// by the services that refund orders and records the refunds made at the provider
+type Refunder struct {
+	store      types.CreditNoteStore
+	orderStore types.OrderStore
+	gateway    types.PaymentGateway
+}
+
+// NewRefunder constructor takes CreditNoteStore, OrderStore and PaymentGateway as dependencies
+func NewRefunder(store types.CreditNoteStore, orderStore types.OrderStore, gateway types.PaymentGateway) *Refunder {
+	return &Refunder{store: store, orderStore: orderStore, gateway: gateway}
+}
+
+// Refund refunds the order and moves it to refunded once everything that was
+// paid is given back, createdBy is the user refunding it.
+// The returned status code is meant to be used when an error is returned.
+func (r *Refunder) Refund(ctx context.Context, orderID int, createdBy int, payload types.RefundPayload) (*types.CreditNote, int, error) {
+	_, err := r.orderStore.GetOrderByID(orderID)
+	if errors.Is(err, order.ErrOrderNotFound) {
+		return nil, http.StatusNotFound, fmt.Errorf("order with id: %v not found", orderID)
+	}
+	if err != nil {
+		log.Printf("Error finding the order %v, error: %+v", orderID, err)
+		return nil, http.StatusInternalServerError, err
+	}
+
+	// give the money back from the locked payment once the credit note is
+	// computed, the credit note and the payment are only saved when it succeeds
+	refund := func(note *types.CreditNote, pay *types.Payment) error {
+		if pay == nil {
+			return fmt.Errorf("%w: order %v", errNoPayment, orderID)
+		}
+
+		if note.Total.IsZero() {
+			return nil
+		}
+
+		next := *pay
+		if err := payment.Refund(&next, note.Total); err != nil {
+			return err
+		}
+
+		if _, err := r.gateway.Refund(ctx, pay.Reference, note.Total); err != nil {
+			return fmt.Errorf("%w: %v", errGateway, err)
+		}
+
+		*pay = next
+		note.PaymentID = &pay.ID
+		return nil
+	}
+
+	note, err := r.store.CreateCreditNote(orderID, createdBy, payload, refund)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidLine):
+			return nil, http.StatusBadRequest, err
+		case errors.Is(err, ErrNotRefundable), errors.Is(err, ErrNothingToRefund), errors.Is(err, errNoPayment),
+			errors.Is(err, payment.ErrInvalidOperation), errors.Is(err, payment.ErrAmountTooLarge):
+			return nil, http.StatusConflict, err
+		case errors.Is(err, errGateway):
+			log.Printf("Error refunding the payment of order %v, error: %+v", orderID, err)
+			return nil, http.StatusBadGateway, err
+		default:
+			log.Println("Error adding the credit note to the database")
+			return nil, http.StatusInternalServerError, err
+		}
+	}
+
+	log.Printf("Credit Note %v of %v refunds order %v", note.Number, note.Total, orderID)
+	r.moveOrder(orderID, createdBy, note)
+
+	return note, http.StatusCreated, nil
+}
+
+// Record records with a credit note the refund of whatever is left of the order
+// the payment provider already made, like a refund from its dashboard, amount is
+// the money given back. The payment is not refunded with the gateway again and
+// the refunds of part of what is left fail with ErrPartialRefund
+func (r *Refunder) Record(orderID int, amount types.Money, reason string) (*types.CreditNote, error) {
+	refund := func(note *types.CreditNote, pay *types.Payment) error {
+		if pay == nil {
+			return fmt.Errorf("%w: order %v", errNoPayment, orderID)
+		}
+
+		if note.Total != amount {
+			return fmt.Errorf("%w: %v given back while %v is left of order %v", ErrPartialRefund, amount, note.Total, orderID)
+		}
+
+		next := *pay
+		if err := payment.Refund(&next, note.Total); err != nil {
+			return err
+		}
+
+		*pay = next
+		note.PaymentID = &pay.ID
+		return nil
+	}
+
+	note, err := r.store.CreateCreditNote(orderID, 0, types.RefundPayload{Reason: reason}, refund)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Credit Note %v of %v records the refund of order %v", note.Number, note.Total, orderID)
+	r.moveOrder(orderID, 0, note)
+
+	return note, nil
+}
+
+// moveOrder moves the order to refunded once everything that was paid is
+// given back, the credit note stays recorded if the order cannot be moved
+func (r *Refunder) moveOrder(orderID int, createdBy int, note *types.CreditNote) {
+	o, err := r.orderStore.GetOrderByID(orderID)
+	if err == nil && o.Refunded == o.Total && order.CanTransition(o.Status, types.OrderStatusRefunded) {
+		if _, err := r.orderStore.UpdateOrderStatus(orderID, types.OrderStatusRefunded, createdBy, "credit note "+note.Number); err != nil {
+			log.Printf("Error moving the order %v to refunded, error: %+v", orderID, err)
+		}
+	}
+}
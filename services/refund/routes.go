@@ -0,0 +1,104 @@
+package refund
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/akshtrikha/golang-ecomm/services/auth"
+	"github.com/akshtrikha/golang-ecomm/services/order"
+	"github.com/akshtrikha/golang-ecomm/types"
+	"github.com/akshtrikha/golang-ecomm/utils"
+	"github.com/gorilla/mux"
+)
+
+// API Endpoints for the admins to refund the orders
+// and for the customers to get their credit notes
+
+// Handler to the credit note store which will deal with the database
+// regarding refunds, the money is given back by the refunder
+type Handler struct {
+	store      types.CreditNoteStore
+	orderStore types.OrderStore
+	refunder   *Refunder
+}
+
+// NewHandler constructor takes CreditNoteStore, OrderStore and Refunder as dependencies
+func NewHandler(store types.CreditNoteStore, orderStore types.OrderStore, refunder *Refunder) *Handler {
+	return &Handler{store: store, orderStore: orderStore, refunder: refunder}
+}
+
+// RegisterRoutes func for refunds
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/orders/{id:[0-9]+}/refunds", auth.RequireRole(auth.RoleAdmin, h.handleRefundOrder)).Methods("POST")
+	router.HandleFunc("/orders/{id:[0-9]+}/credit-notes", auth.RequireAuth(h.handleGetCreditNotes)).Methods("GET")
+}
+
+func (h *Handler) handleRefundOrder(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle POST /admin/orders/{id}/refunds endpoint hit")
+
+	userID, _ := auth.UserIDFromContext(r.Context())
+
+	orderID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid order id"))
+		return
+	}
+
+	// get the json payload, without a payload the whole order is refunded
+	var payload types.RefundPayload
+	if err := utils.ParseJSON(r, &payload); err != nil && !errors.Is(err, io.EOF) {
+		log.Println("Payload parsing met with an error")
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// validate the json payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.ValidationError("Invalid payload", err))
+		return
+	}
+
+	note, status, err := h.refunder.Refund(r.Context(), orderID, userID, payload)
+	if err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, note)
+}
+
+func (h *Handler) handleGetCreditNotes(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle GET /orders/{id}/credit-notes endpoint hit")
+
+	principal, _ := auth.PrincipalFromContext(r.Context())
+
+	orderID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid order id"))
+		return
+	}
+
+	o, err := h.orderStore.GetOrderByID(orderID)
+	if errors.Is(err, order.ErrOrderNotFound) || (err == nil && !principal.IsAdmin() && o.UserID != principal.UserID) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("order with id: %v not found", orderID))
+		return
+	}
+	if err != nil {
+		log.Printf("Error finding the order %v, error: %+v", orderID, err)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	notes, err := h.store.GetCreditNotesByOrderID(orderID)
+	if err != nil {
+		log.Println("Error fetching the credit notes of the order from the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, notes)
+}
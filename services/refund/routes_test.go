@@ -0,0 +1,343 @@
+package refund
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/akshtrikha/golang-ecomm/services/auth"
+	"github.com/akshtrikha/golang-ecomm/services/order"
+	"github.com/akshtrikha/golang-ecomm/services/payment"
+	"github.com/akshtrikha/golang-ecomm/types"
+	"github.com/gorilla/mux"
+)
+
+// mockCreditNoteStore computes the credit notes of the orders kept in memory
+// and only records them and their payment when the refund succeeds
+type mockCreditNoteStore struct {
+	orderStore   *mockOrderStore
+	paymentStore *mockPaymentStore
+	notes        []types.CreditNote
+}
+
+func (m *mockCreditNoteStore) CreateCreditNote(orderID int, createdBy int, payload types.RefundPayload, refund func(*types.CreditNote, *types.Payment) error) (*types.CreditNote, error) {
+	o, err := m.orderStore.GetOrderByID(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	refundedShipping := types.NewMoney(0, o.Currency)
+	for _, note := range m.notes {
+		if note.OrderID == orderID {
+			refundedShipping.Amount += note.Shipping.Amount
+		}
+	}
+
+	items := m.orderStore.items[orderID]
+	note, err := Build(*o, items, refundedShipping, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	pay := m.paymentStore.capturedPayment(orderID)
+	if err := refund(note, pay); err != nil {
+		return nil, err
+	}
+
+	if note.PaymentID != nil {
+		m.paymentStore.payments[pay.ID] = *pay
+	}
+
+	note.ID = len(m.notes) + 1
+	note.Number = fmt.Sprintf("CN-%06d", note.ID)
+	m.notes = append(m.notes, *note)
+
+	for _, line := range note.Lines {
+		for i := range items {
+			if items[i].ID == line.OrderItemID {
+				items[i].RefundedQuantity += line.Quantity
+			}
+		}
+	}
+
+	o.Refunded.Amount += note.Total.Amount
+	m.orderStore.orders[orderID] = *o
+
+	return note, nil
+}
+
+func (m *mockCreditNoteStore) GetCreditNotesByOrderID(orderID int) ([]types.CreditNote, error) {
+	notes := []types.CreditNote{}
+	for _, note := range m.notes {
+		if note.OrderID == orderID {
+			notes = append(notes, note)
+		}
+	}
+
+	return notes, nil
+}
+
+// mockOrderStore keeps the orders and their items in memory
+// and moves the orders through the order state machine
+type mockOrderStore struct {
+	orders map[int]types.Order
+	items  map[int][]types.OrderItem
+}
+
+func (m *mockOrderStore) PlaceOrder(checkout types.Checkout) (*types.Order, error) {
+	return nil, nil
+}
+
+func (m *mockOrderStore) GetOrderByID(id int) (*types.Order, error) {
+	o, ok := m.orders[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", order.ErrOrderNotFound, id)
+	}
+
+	return &o, nil
+}
+
+func (m *mockOrderStore) GetOrdersByUserID(userID int) ([]types.Order, error) {
+	return []types.Order{}, nil
+}
+
+func (m *mockOrderStore) GetAllOrders() ([]types.Order, error) {
+	return []types.Order{}, nil
+}
+
+func (m *mockOrderStore) GetOrderItems(orderID int) ([]types.OrderItem, error) {
+	return m.items[orderID], nil
+}
+
+func (m *mockOrderStore) GetOrderPromotions(orderID int) ([]types.AppliedPromotion, error) {
+	return []types.AppliedPromotion{}, nil
+}
+
+func (m *mockOrderStore) UpdateOrderStatus(orderID int, status types.OrderStatus, changedBy int, note string) (*types.Order, error) {
+	o, err := m.GetOrderByID(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := order.ValidateTransition(o.Status, status); err != nil {
+		return nil, err
+	}
+
+	o.Status = status
+	m.orders[orderID] = *o
+
+	return o, nil
+}
+
+func (m *mockOrderStore) GetOrderStatusHistory(orderID int) ([]types.OrderStatusChange, error) {
+	return []types.OrderStatusChange{}, nil
+}
+
+// mockPaymentStore keeps the payments in memory
+type mockPaymentStore struct {
+	payments map[int]types.Payment
+}
+
+func (m *mockPaymentStore) GetPaymentByID(id int) (*types.Payment, error) {
+	p, ok := m.payments[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", payment.ErrPaymentNotFound, id)
+	}
+
+	return &p, nil
+}
+
+func (m *mockPaymentStore) GetPaymentsByOrderID(orderID int) ([]types.Payment, error) {
+	payments := []types.Payment{}
+	for _, p := range m.payments {
+		if p.OrderID == orderID {
+			payments = append(payments, p)
+		}
+	}
+
+	return payments, nil
+}
+
+func (m *mockPaymentStore) GetPaymentByReference(gateway string, reference string) (*types.Payment, error) {
+	return nil, fmt.Errorf("%w: %v", payment.ErrPaymentNotFound, reference)
+}
+
+func (m *mockPaymentStore) CreatePayment(p types.Payment) (int, error) {
+	p.ID = len(m.payments) + 1
+	m.payments[p.ID] = p
+
+	return p.ID, nil
+}
+
+func (m *mockPaymentStore) UpdatePayment(p types.Payment) error {
+	m.payments[p.ID] = p
+	return nil
+}
+
+// capturedPayment returns the payment of the order refunds are
+// given back from, nil when the order has none
+func (m *mockPaymentStore) capturedPayment(orderID int) *types.Payment {
+	for _, p := range m.payments {
+		if p.OrderID == orderID && (p.Status == types.PaymentCaptured || p.Status == types.PaymentPartiallyRefunded) {
+			return &p
+		}
+	}
+
+	return nil
+}
+
+// TestRefundServiceHandlers function to implement testing
+func TestRefundServiceHandlers(t *testing.T) {
+	// the order 1 of the customer 2 is paid, the order 2 has no captured payment
+	o, items := paidOrder()
+	o.UserID = 2
+	orderStore := &mockOrderStore{
+		orders: map[int]types.Order{1: o, 2: {ID: 2, UserID: 2, Total: usd(1000), Shipping: usd(0), Refunded: usd(0), Currency: types.DefaultCurrency, Status: types.OrderStatusPaid}},
+		items:  map[int][]types.OrderItem{1: items},
+	}
+
+	gateway, err := payment.NewFakeGateway(payment.FakeSucceed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := gateway.Authorize(context.Background(), types.AuthorizeRequest{OrderID: 1, Amount: o.Total, PaymentMethod: payment.FakeCardSucceed})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := gateway.Capture(context.Background(), result.Reference, o.Total); err != nil {
+		t.Fatal(err)
+	}
+
+	captured := payment.NewPayment(result.Reference, types.PaymentCaptured, o.Total)
+	captured.OrderID, captured.Gateway, captured.Captured = 1, payment.FakeGatewayName, o.Total
+	paymentStore := &mockPaymentStore{payments: map[int]types.Payment{}}
+	paymentID, _ := paymentStore.CreatePayment(*captured)
+
+	store := &mockCreditNoteStore{orderStore: orderStore, paymentStore: paymentStore}
+	handler := NewHandler(store, orderStore, NewRefunder(store, orderStore, gateway))
+
+	adminToken, err := auth.GenerateJWT(1, []string{auth.RoleAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	customerToken, err := auth.GenerateJWT(2, []string{auth.RoleCustomer})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherToken, err := auth.GenerateJWT(3, []string{auth.RoleCustomer})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(method, path string, body string, token string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+		router.Use(auth.JWTMiddleware)
+
+		handler.RegisterRoutes(router)
+		router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	t.Run("Should not let the customers refund orders", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/admin/orders/1/refunds", "", customerToken)
+
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("Expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	t.Run("Should fail if the payload is invalid", func(t *testing.T) {
+		for _, body := range []string{
+			`{"lines":[{"orderItemId":1,"quantity":0}]}`,
+			`{"lines":[{"orderItemId":9,"quantity":1}]}`,
+			`{"lines":[{"orderItemId":1,"quantity":4}]}`,
+		} {
+			if rr := serve(http.MethodPost, "/admin/orders/1/refunds", body, adminToken); rr.Code != http.StatusBadRequest {
+				t.Errorf("Expected status code %d, got %d for %s", http.StatusBadRequest, rr.Code, body)
+			}
+		}
+	})
+
+	t.Run("Should fail if the order has no captured payment", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/admin/orders/2/refunds", "", adminToken)
+
+		if rr.Code != http.StatusConflict {
+			t.Errorf("Expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+	})
+
+	t.Run("Should refund part of the order", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/admin/orders/1/refunds", `{"lines":[{"orderItemId":1,"quantity":1}],"reason":"damaged"}`, adminToken)
+
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		var note types.CreditNote
+		if err := json.NewDecoder(rr.Body).Decode(&note); err != nil {
+			t.Fatal(err)
+		}
+
+		if note.Total != usd(1080) || note.PaymentID == nil || *note.PaymentID != paymentID {
+			t.Errorf("Expected 10.80 refunded from the payment, got %+v", note)
+		}
+
+		if p := paymentStore.payments[paymentID]; p.Status != types.PaymentPartiallyRefunded || p.Refunded != usd(1080) {
+			t.Errorf("Expected the payment to be partially refunded, got %+v", p)
+		}
+
+		if orderStore.orders[1].Status != types.OrderStatusPaid {
+			t.Errorf("Expected the order to stay paid, got %s", orderStore.orders[1].Status)
+		}
+	})
+
+	t.Run("Should refund the rest of the order", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/admin/orders/1/refunds", "", adminToken)
+
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		if p := paymentStore.payments[paymentID]; p.Status != types.PaymentRefunded || p.Refunded != o.Total {
+			t.Errorf("Expected the payment to be refunded, got %+v", p)
+		}
+
+		if got := orderStore.orders[1]; got.Status != types.OrderStatusRefunded || got.Refunded != o.Total {
+			t.Errorf("Expected the order to be refunded, got %+v", got)
+		}
+
+		if rr := serve(http.MethodPost, "/admin/orders/1/refunds", "", adminToken); rr.Code != http.StatusConflict {
+			t.Errorf("Expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+	})
+
+	t.Run("Should list the credit notes of the order", func(t *testing.T) {
+		rr := serve(http.MethodGet, "/orders/1/credit-notes", "", customerToken)
+
+		var notes []types.CreditNote
+		if err := json.NewDecoder(rr.Body).Decode(&notes); err != nil || len(notes) != 2 {
+			t.Errorf("Expected the 2 credit notes of the order, got %+v, error: %v", notes, err)
+		}
+
+		if rr := serve(http.MethodGet, "/orders/1/credit-notes", "", otherToken); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
@@ -0,0 +1,445 @@
+package refund
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/akshtrikha/golang-ecomm/services/order"
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// creditNoteColumns is the list of columns scanned by scanRowIntoCreditNote
+const creditNoteColumns = "id, number, orderId, paymentId, returnId, shipping, tax, total, currency, restock, reason, createdBy, createdAt"
+
+// Store struct to hold the database object
+// This will be used to handle the database queries
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore function to return a reference to the Store struct
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// CreateCreditNote function to refund the order with a numbered credit note.
+// The order, its items and its captured payment are locked while the credit
+// note is computed and recorded, the refunded units go back to the stock when
+// the payload asks for it. refund is called last with the credit note and the
+// captured payment, nil when the order has none, to give the money back. The
+// credit note is not recorded when it fails, the payment is saved with it when
+// the credit note is linked to it. A return is refunded by one credit note, the
+// one already recorded for the return of the payload is returned as it is and
+// refund is not called. A createdBy of 0 records no user
+func (s *Store) CreateCreditNote(orderID int, createdBy int, payload types.RefundPayload, refund func(*types.CreditNote, *types.Payment) error) (*types.CreditNote, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	// lock the order so the refunds of an order are serialized
+	o := types.Order{}
+	var total, shipping string
+	err = tx.QueryRow("SELECT id, total, shipping, taxInclusive, currency, status FROM orders WHERE id = ? FOR UPDATE", orderID).
+		Scan(&o.ID, &total, &shipping, &o.TaxInclusive, &o.Currency, &o.Status)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %v", order.ErrOrderNotFound, orderID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// the refund of a return is retried when the return could not be
+	// moved to refunded, the money of its credit note was given back
+	if payload.ReturnID != 0 {
+		note, err := getReturnCreditNote(tx, payload.ReturnID)
+		if err != nil || note != nil {
+			return note, err
+		}
+	}
+
+	if o.Total, err = types.ParseMoney(total, o.Currency); err != nil {
+		return nil, err
+	}
+
+	if o.Shipping, err = types.ParseMoney(shipping, o.Currency); err != nil {
+		return nil, err
+	}
+
+	items, err := lockItems(tx, o)
+	if err != nil {
+		return nil, err
+	}
+
+	// lock the payment so the refunds read and update its refunded amount one at a time
+	pay, err := lockCapturedPayment(tx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	var refundedShipping string
+	if err := tx.QueryRow("SELECT COALESCE(SUM(shipping), 0) FROM credit_notes WHERE orderId = ?", orderID).Scan(&refundedShipping); err != nil {
+		return nil, err
+	}
+
+	shippingRefunded, err := types.ParseMoney(refundedShipping, o.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	note, err := Build(o, items, shippingRefunded, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if createdBy != 0 {
+		note.CreatedBy = &createdBy
+	}
+
+	if payload.ReturnID != 0 {
+		note.ReturnID = &payload.ReturnID
+	}
+
+	// the row of the last number stays locked until the commit,
+	// a credit note that is not recorded gives its number back
+	result, err := tx.Exec("UPDATE credit_note_numbers SET last = LAST_INSERT_ID(last + 1) WHERE id = 1")
+	if err != nil {
+		return nil, err
+	}
+
+	number, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	note.Number = fmt.Sprintf("CN-%06d", number)
+
+	result, err = tx.Exec(
+		"INSERT INTO credit_notes (number, orderId, returnId, shipping, tax, total, currency, restock, reason, createdBy) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		note.Number, orderID, note.ReturnID, note.Shipping, note.Tax, note.Total, o.Currency, note.Restock, note.Reason, note.CreatedBy,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	note.ID = int(id)
+
+	for i := range note.Lines {
+		line := &note.Lines[i]
+		line.CreditNoteID = note.ID
+
+		result, err := tx.Exec(
+			"INSERT INTO credit_note_lines (creditNoteId, orderItemId, quantity, amount, tax) VALUES (?, ?, ?, ?, ?)",
+			note.ID, line.OrderItemID, line.Quantity, line.Amount, line.Tax,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		lineID, err := result.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		line.ID = int(lineID)
+
+		if _, err := tx.Exec("UPDATE order_items SET refundedQuantity = refundedQuantity + ? WHERE id = ?", line.Quantity, line.OrderItemID); err != nil {
+			return nil, err
+		}
+
+		if note.Restock {
+			if err := restock(tx, *line); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE orders SET refunded = refunded + ? WHERE id = ?", note.Total, orderID); err != nil {
+		return nil, err
+	}
+
+	if err := refund(note, pay); err != nil {
+		return nil, err
+	}
+
+	if note.PaymentID != nil && pay != nil {
+		if _, err := tx.Exec("UPDATE payments SET status = ?, refundedAmount = ? WHERE id = ?", pay.Status, pay.Refunded, pay.ID); err != nil {
+			return nil, err
+		}
+
+		if _, err := tx.Exec("UPDATE credit_notes SET paymentId = ? WHERE id = ?", *note.PaymentID, note.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	note.CreatedAt = time.Now()
+	return note, nil
+}
+
+// GetCreditNotesByOrderID function to get the credit notes of the order with their lines
+func (s *Store) GetCreditNotesByOrderID(orderID int) ([]types.CreditNote, error) {
+	rows, err := s.db.Query("SELECT "+creditNoteColumns+" FROM credit_notes WHERE orderId = ? ORDER BY id", orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := []types.CreditNote{}
+	for rows.Next() {
+		note, err := scanRowIntoCreditNote(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		notes = append(notes, *note)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	lineRows, err := s.db.Query(`
+		SELECT l.id, l.creditNoteId, l.orderItemId, l.quantity, l.amount, l.tax, n.currency
+		FROM credit_note_lines l
+		JOIN credit_notes n ON n.id = l.creditNoteId
+		WHERE n.orderId = ?
+		ORDER BY l.id`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer lineRows.Close()
+
+	for lineRows.Next() {
+		var line types.CreditNoteLine
+		var amount, tax, currency string
+
+		if err := lineRows.Scan(&line.ID, &line.CreditNoteID, &line.OrderItemID, &line.Quantity, &amount, &tax, &currency); err != nil {
+			return nil, err
+		}
+
+		if line.Amount, err = types.ParseMoney(amount, currency); err != nil {
+			return nil, err
+		}
+
+		if line.Tax, err = types.ParseMoney(tax, currency); err != nil {
+			return nil, err
+		}
+
+		for i := range notes {
+			if notes[i].ID == line.CreditNoteID {
+				notes[i].Lines = append(notes[i].Lines, line)
+			}
+		}
+	}
+
+	return notes, lineRows.Err()
+}
+
+// getReturnCreditNote returns the credit note of the return with its lines,
+// nil when the return was not refunded
+func getReturnCreditNote(tx *sql.Tx, returnID int) (*types.CreditNote, error) {
+	rows, err := tx.Query("SELECT "+creditNoteColumns+" FROM credit_notes WHERE returnId = ?", returnID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var note *types.CreditNote
+	for rows.Next() {
+		if note, err = scanRowIntoCreditNote(rows); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := rows.Err(); err != nil || note == nil {
+		return nil, err
+	}
+
+	lineRows, err := tx.Query("SELECT id, creditNoteId, orderItemId, quantity, amount, tax FROM credit_note_lines WHERE creditNoteId = ? ORDER BY id", note.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer lineRows.Close()
+
+	for lineRows.Next() {
+		var line types.CreditNoteLine
+		var amount, tax string
+
+		if err := lineRows.Scan(&line.ID, &line.CreditNoteID, &line.OrderItemID, &line.Quantity, &amount, &tax); err != nil {
+			return nil, err
+		}
+
+		if line.Amount, err = types.ParseMoney(amount, note.Total.Currency); err != nil {
+			return nil, err
+		}
+
+		if line.Tax, err = types.ParseMoney(tax, note.Total.Currency); err != nil {
+			return nil, err
+		}
+
+		note.Lines = append(note.Lines, line)
+	}
+
+	return note, lineRows.Err()
+}
+
+// lockItems reads the items of the order with FOR UPDATE
+func lockItems(tx *sql.Tx, o types.Order) ([]types.OrderItem, error) {
+	rows, err := tx.Query("SELECT id, orderId, productId, variantId, quantity, refundedQuantity, price, discount, tax FROM order_items WHERE orderId = ? ORDER BY id FOR UPDATE", o.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []types.OrderItem{}
+	for rows.Next() {
+		item := types.OrderItem{}
+		var variantID sql.NullInt64
+		var price, discount, tax string
+
+		if err := rows.Scan(&item.ID, &item.OrderID, &item.ProductID, &variantID, &item.Quantity, &item.RefundedQuantity, &price, &discount, &tax); err != nil {
+			return nil, err
+		}
+
+		// the amounts are in the currency of the order
+		if item.Price, err = types.ParseMoney(price, o.Currency); err != nil {
+			return nil, err
+		}
+
+		if item.Discount, err = types.ParseMoney(discount, o.Currency); err != nil {
+			return nil, err
+		}
+
+		if item.Tax, err = types.ParseMoney(tax, o.Currency); err != nil {
+			return nil, err
+		}
+
+		if variantID.Valid {
+			id := int(variantID.Int64)
+			item.VariantID = &id
+		}
+
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// lockCapturedPayment reads the captured payment of the order with FOR UPDATE,
+// it returns nil when the order has no payment to refund
+func lockCapturedPayment(tx *sql.Tx, orderID int) (*types.Payment, error) {
+	p := &types.Payment{OrderID: orderID}
+	var amount, captured, refunded, currency string
+
+	err := tx.QueryRow(
+		"SELECT id, gateway, reference, status, amount, capturedAmount, refundedAmount, currency FROM payments WHERE orderId = ? AND status IN (?, ?) ORDER BY id LIMIT 1 FOR UPDATE",
+		orderID, types.PaymentCaptured, types.PaymentPartiallyRefunded,
+	).Scan(&p.ID, &p.Gateway, &p.Reference, &p.Status, &amount, &captured, &refunded, &currency)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// the amounts are in the currency of the order
+	if p.Amount, err = types.ParseMoney(amount, currency); err != nil {
+		return nil, err
+	}
+
+	if p.Captured, err = types.ParseMoney(captured, currency); err != nil {
+		return nil, err
+	}
+
+	if p.Refunded, err = types.ParseMoney(refunded, currency); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// restock returns the refunded units of the line to the stock
+// of the variant they were sold as or of the product
+func restock(tx *sql.Tx, line types.CreditNoteLine) error {
+	_, err := tx.Exec(`
+		UPDATE products p
+		JOIN order_items oi ON oi.productId = p.id
+		SET p.quantity = p.quantity + ?
+		WHERE oi.id = ? AND oi.variantId IS NULL`, line.Quantity, line.OrderItemID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE product_variants v
+		JOIN order_items oi ON oi.variantId = v.id
+		SET v.quantity = v.quantity + ?
+		WHERE oi.id = ?`, line.Quantity, line.OrderItemID)
+
+	return err
+}
+
+func scanRowIntoCreditNote(rows *sql.Rows) (*types.CreditNote, error) {
+	note := &types.CreditNote{Lines: []types.CreditNoteLine{}}
+	var paymentID, returnID, createdBy sql.NullInt64
+	var shipping, tax, total, currency string
+
+	err := rows.Scan(
+		&note.ID,
+		&note.Number,
+		&note.OrderID,
+		&paymentID,
+		&returnID,
+		&shipping,
+		&tax,
+		&total,
+		&currency,
+		&note.Restock,
+		&note.Reason,
+		&createdBy,
+		&note.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// the amounts are in the currency of the order
+	if note.Shipping, err = types.ParseMoney(shipping, currency); err != nil {
+		return nil, err
+	}
+
+	if note.Tax, err = types.ParseMoney(tax, currency); err != nil {
+		return nil, err
+	}
+
+	if note.Total, err = types.ParseMoney(total, currency); err != nil {
+		return nil, err
+	}
+
+	if paymentID.Valid {
+		id := int(paymentID.Int64)
+		note.PaymentID = &id
+	}
+
+	if returnID.Valid {
+		id := int(returnID.Int64)
+		note.ReturnID = &id
+	}
+
+	if createdBy.Valid {
+		id := int(createdBy.Int64)
+		note.CreatedBy = &id
+	}
+
+	return note, nil
+}
@@ -0,0 +1,164 @@
+package refund
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// newMockStore returns a store on a mocked database whose
+// expectations are checked once the test is done
+func newMockStore(t *testing.T) (*Store, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	return NewStore(db), mock
+}
+
+// expectLocks expects the paid order, its items and its payment to be locked,
+// the payment has the refunded amount or no row when refunded is nil
+func expectLocks(mock sqlmock.Sqlmock, refunded *types.Money) {
+	o, items := paidOrder()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT .+ FROM orders WHERE id = \? FOR UPDATE`).WithArgs(o.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "total", "shipping", "taxInclusive", "currency", "status"}).
+			AddRow(o.ID, o.Total.Decimal(), o.Shipping.Decimal(), o.TaxInclusive, o.Currency, o.Status))
+
+	rows := sqlmock.NewRows([]string{"id", "orderId", "productId", "variantId", "quantity", "refundedQuantity", "price", "discount", "tax"})
+	for _, item := range items {
+		rows.AddRow(item.ID, item.OrderID, item.ProductID, nil, item.Quantity, item.RefundedQuantity, item.Price.Decimal(), item.Discount.Decimal(), item.Tax.Decimal())
+	}
+	mock.ExpectQuery(`SELECT .+ FROM order_items WHERE orderId = \? ORDER BY id FOR UPDATE`).WithArgs(o.ID).WillReturnRows(rows)
+
+	payments := sqlmock.NewRows([]string{"id", "gateway", "reference", "status", "amount", "capturedAmount", "refundedAmount", "currency"})
+	if refunded != nil {
+		payments.AddRow(5, "fake", "fake_1_1", types.PaymentPartiallyRefunded, o.Total.Decimal(), o.Total.Decimal(), refunded.Decimal(), o.Currency)
+	}
+	mock.ExpectQuery(`SELECT .+ FROM payments WHERE orderId = \? AND status IN \(\?, \?\) ORDER BY id LIMIT 1 FOR UPDATE`).
+		WithArgs(o.ID, types.PaymentCaptured, types.PaymentPartiallyRefunded).WillReturnRows(payments)
+}
+
+// expectCreditNote expects a credit note of a line to be numbered and recorded
+func expectCreditNote(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT COALESCE\(SUM\(shipping\), 0\) FROM credit_notes WHERE orderId = \?`).
+		WillReturnRows(sqlmock.NewRows([]string{"shipping"}).AddRow("0.00"))
+	mock.ExpectExec(`UPDATE credit_note_numbers`).WillReturnResult(sqlmock.NewResult(7, 1))
+	mock.ExpectExec(`INSERT INTO credit_notes`).WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectExec(`INSERT INTO credit_note_lines`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE order_items SET refundedQuantity = refundedQuantity \+ \? WHERE id = \?`).WithArgs(1, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE orders SET refunded = refunded \+ \? WHERE id = \?`).WithArgs("10.80", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+// TestCreateCreditNote function to test the refunds of the orders with the database
+func TestCreateCreditNote(t *testing.T) {
+	payload := types.RefundPayload{Lines: []types.RefundLinePayload{{OrderItemID: 1, Quantity: 1}}}
+
+	t.Run("Should refund the payment read and saved in the transaction of the credit note", func(t *testing.T) {
+		store, mock := newMockStore(t)
+
+		refunded := usd(500)
+		expectLocks(mock, &refunded)
+		expectCreditNote(mock)
+		mock.ExpectExec(`UPDATE payments SET status = \?, refundedAmount = \? WHERE id = \?`).
+			WithArgs(types.PaymentPartiallyRefunded, "15.80", 5).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`UPDATE credit_notes SET paymentId = \? WHERE id = \?`).WithArgs(5, 3).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		note, err := store.CreateCreditNote(1, 1, payload, func(note *types.CreditNote, pay *types.Payment) error {
+			if pay == nil || pay.Refunded != usd(500) {
+				t.Errorf("Expected the locked payment with 5.00 refunded, got %+v", pay)
+				return errors.New("unexpected payment")
+			}
+
+			pay.Refunded, pay.Status = usd(1580), types.PaymentPartiallyRefunded
+			note.PaymentID = &pay.ID
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if note.Number != "CN-000007" || note.Total != usd(1080) {
+			t.Errorf("Expected the credit note CN-000007 of 10.80, got %+v", note)
+		}
+	})
+
+	t.Run("Should roll back the credit note when the refund fails", func(t *testing.T) {
+		store, mock := newMockStore(t)
+
+		refunded := usd(0)
+		expectLocks(mock, &refunded)
+		expectCreditNote(mock)
+		mock.ExpectRollback()
+
+		failure := errors.New("gateway down")
+		_, err := store.CreateCreditNote(1, 1, payload, func(note *types.CreditNote, pay *types.Payment) error {
+			return failure
+		})
+		if !errors.Is(err, failure) {
+			t.Errorf("Expected the error of the refund, got %v", err)
+		}
+	})
+
+	t.Run("Should refund without a payment when the order has none", func(t *testing.T) {
+		store, mock := newMockStore(t)
+
+		expectLocks(mock, nil)
+		expectCreditNote(mock)
+		mock.ExpectRollback()
+
+		_, err := store.CreateCreditNote(1, 1, payload, func(note *types.CreditNote, pay *types.Payment) error {
+			if pay != nil {
+				t.Errorf("Expected no payment, got %+v", pay)
+			}
+
+			return errNoPayment
+		})
+		if !errors.Is(err, errNoPayment) {
+			t.Errorf("Expected the error of the refund, got %v", err)
+		}
+	})
+
+	t.Run("Should return the credit note already recorded for the return", func(t *testing.T) {
+		store, mock := newMockStore(t)
+
+		o, _ := paidOrder()
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT .+ FROM orders WHERE id = \? FOR UPDATE`).WithArgs(o.ID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "total", "shipping", "taxInclusive", "currency", "status"}).
+				AddRow(o.ID, o.Total.Decimal(), o.Shipping.Decimal(), o.TaxInclusive, o.Currency, o.Status))
+		mock.ExpectQuery(`SELECT .+ FROM credit_notes WHERE returnId = \?`).WithArgs(4).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "number", "orderId", "paymentId", "returnId", "shipping", "tax", "total", "currency", "restock", "reason", "createdBy", "createdAt"}).
+				AddRow(3, "CN-000007", o.ID, 5, 4, "0.00", "1.80", "10.80", o.Currency, true, "return 4", 1, time.Now()))
+		mock.ExpectQuery(`SELECT .+ FROM credit_note_lines WHERE creditNoteId = \?`).WithArgs(3).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "creditNoteId", "orderItemId", "quantity", "amount", "tax"}).AddRow(1, 3, 1, 1, "10.80", "1.80"))
+		mock.ExpectRollback()
+
+		returned := payload
+		returned.ReturnID = 4
+
+		note, err := store.CreateCreditNote(1, 1, returned, func(note *types.CreditNote, pay *types.Payment) error {
+			t.Errorf("Expected nothing to be refunded again, got %+v", note)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if note.Number != "CN-000007" || note.ReturnID == nil || *note.ReturnID != 4 || len(note.Lines) != 1 || note.Lines[0].Amount != usd(1080) {
+			t.Errorf("Expected the credit note CN-000007 of the return, got %+v", note)
+		}
+	})
+}
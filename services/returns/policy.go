@@ -0,0 +1,122 @@
+package returns
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// ErrNotEligible is returned for the orders whose items cannot be returned
+var ErrNotEligible = errors.New("order is not eligible for returns")
+
+// ErrInvalidItem is returned for an item that is not part of the order or the
+// return, that returns more units than are left of it or that is not inspected
+var ErrInvalidItem = errors.New("invalid return item")
+
+// DeliveredAt returns when the order was last delivered according to its status history
+func DeliveredAt(history []types.OrderStatusChange) (time.Time, bool) {
+	var at time.Time
+	ok := false
+
+	for _, change := range history {
+		if change.ToStatus == types.OrderStatusDelivered {
+			at, ok = change.CreatedAt, true
+		}
+	}
+
+	return at, ok
+}
+
+// CheckEligibility checks the items of the order can be returned at now:
+// the order was delivered no longer than the window ago
+func CheckEligibility(o types.Order, history []types.OrderStatusChange, now time.Time, window time.Duration) error {
+	if o.Status != types.OrderStatusDelivered {
+		return fmt.Errorf("%w: order %v is %s, only delivered orders can be returned", ErrNotEligible, o.ID, o.Status)
+	}
+
+	delivered, ok := DeliveredAt(history)
+	if !ok {
+		return fmt.Errorf("%w: order %v has no delivery date", ErrNotEligible, o.ID)
+	}
+
+	if now.After(delivered.Add(window)) {
+		return fmt.Errorf("%w: the return window of order %v closed on %s", ErrNotEligible, o.ID, delivered.Add(window).Format(time.DateOnly))
+	}
+
+	return nil
+}
+
+// CheckItems checks the units of the payload can be returned: the units that
+// were refunded and the ones of the open returns of the order cannot be
+func CheckItems(items []types.OrderItem, open []types.ReturnItem, payload []types.ReturnItemPayload) error {
+	seen := map[int]bool{}
+
+	for _, line := range payload {
+		if seen[line.OrderItemID] {
+			return fmt.Errorf("%w: order item %v is returned more than once", ErrInvalidItem, line.OrderItemID)
+		}
+		seen[line.OrderItemID] = true
+
+		i := indexOfItem(items, line.OrderItemID)
+		if i < 0 {
+			return fmt.Errorf("%w: order item %v is not part of the order", ErrInvalidItem, line.OrderItemID)
+		}
+
+		left := items[i].Quantity - items[i].RefundedQuantity
+		for _, returned := range open {
+			if returned.OrderItemID == line.OrderItemID {
+				left -= returned.Quantity
+			}
+		}
+
+		if line.Quantity > left {
+			return fmt.Errorf("%w: only %d units of order item %v can be returned", ErrInvalidItem, max(left, 0), line.OrderItemID)
+		}
+	}
+
+	return nil
+}
+
+// CheckInspection checks every item of the return is given exactly one resolution
+func CheckInspection(r types.Return, payload []types.ReturnInspectionPayload) error {
+	resolved := map[int]bool{}
+
+	for _, line := range payload {
+		found := false
+		for _, item := range r.Items {
+			if item.ID == line.ReturnItemID {
+				found = true
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("%w: item %v is not part of return %v", ErrInvalidItem, line.ReturnItemID, r.ID)
+		}
+
+		if resolved[line.ReturnItemID] {
+			return fmt.Errorf("%w: item %v is inspected more than once", ErrInvalidItem, line.ReturnItemID)
+		}
+		resolved[line.ReturnItemID] = true
+	}
+
+	for _, item := range r.Items {
+		if !resolved[item.ID] {
+			return fmt.Errorf("%w: item %v of return %v is not inspected", ErrInvalidItem, item.ID, r.ID)
+		}
+	}
+
+	return nil
+}
+
+// indexOfItem returns the index of the order item in the items or -1
+func indexOfItem(items []types.OrderItem, id int) int {
+	for i, item := range items {
+		if item.ID == id {
+			return i
+		}
+	}
+
+	return -1
+}
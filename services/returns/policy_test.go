@@ -0,0 +1,90 @@
+package returns
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// TestCheckEligibility function to test the return window of the orders
+func TestCheckEligibility(t *testing.T) {
+	delivered := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	history := []types.OrderStatusChange{
+		{ToStatus: types.OrderStatusPending, CreatedAt: delivered.AddDate(0, 0, -5)},
+		{ToStatus: types.OrderStatusDelivered, CreatedAt: delivered},
+	}
+	window := time.Hour * 24 * 30
+	o := types.Order{ID: 1, Status: types.OrderStatusDelivered}
+
+	tests := []struct {
+		name    string
+		order   types.Order
+		history []types.OrderStatusChange
+		now     time.Time
+		err     error
+	}{
+		{"within the window", o, history, delivered.AddDate(0, 0, 29), nil},
+		{"on the last moment of the window", o, history, delivered.Add(window), nil},
+		{"after the window", o, history, delivered.Add(window + time.Second), ErrNotEligible},
+		{"without a delivery", o, history[:1], delivered, ErrNotEligible},
+		{"before the delivery", types.Order{ID: 1, Status: types.OrderStatusShipped}, history[:1], delivered, ErrNotEligible},
+		{"after a refund", types.Order{ID: 1, Status: types.OrderStatusRefunded}, history, delivered, ErrNotEligible},
+	}
+
+	for _, test := range tests {
+		if err := CheckEligibility(test.order, test.history, test.now, window); !errors.Is(err, test.err) {
+			t.Errorf("%s: expected error %v, got %v", test.name, test.err, err)
+		}
+	}
+}
+
+// TestCheckItems function to test the units that can be returned
+func TestCheckItems(t *testing.T) {
+	items := []types.OrderItem{
+		{ID: 1, Quantity: 3, RefundedQuantity: 1},
+		{ID: 2, Quantity: 2},
+	}
+	open := []types.ReturnItem{{OrderItemID: 2, Quantity: 1}}
+
+	tests := []struct {
+		name    string
+		payload []types.ReturnItemPayload
+		err     error
+	}{
+		{"the units left", []types.ReturnItemPayload{{OrderItemID: 1, Quantity: 2}, {OrderItemID: 2, Quantity: 1}}, nil},
+		{"refunded units", []types.ReturnItemPayload{{OrderItemID: 1, Quantity: 3}}, ErrInvalidItem},
+		{"units of an open return", []types.ReturnItemPayload{{OrderItemID: 2, Quantity: 2}}, ErrInvalidItem},
+		{"an unknown item", []types.ReturnItemPayload{{OrderItemID: 9, Quantity: 1}}, ErrInvalidItem},
+		{"the same item twice", []types.ReturnItemPayload{{OrderItemID: 1, Quantity: 1}, {OrderItemID: 1, Quantity: 1}}, ErrInvalidItem},
+	}
+
+	for _, test := range tests {
+		if err := CheckItems(items, open, test.payload); !errors.Is(err, test.err) {
+			t.Errorf("%s: expected error %v, got %v", test.name, test.err, err)
+		}
+	}
+}
+
+// TestCheckInspection function to test every returned item is inspected once
+func TestCheckInspection(t *testing.T) {
+	r := types.Return{ID: 1, Items: []types.ReturnItem{{ID: 1}, {ID: 2}}}
+
+	tests := []struct {
+		name    string
+		payload []types.ReturnInspectionPayload
+		err     error
+	}{
+		{"every item", []types.ReturnInspectionPayload{{ReturnItemID: 1, Resolution: types.ReturnResolutionRestock}, {ReturnItemID: 2, Resolution: types.ReturnResolutionWriteOff}}, nil},
+		{"a missing item", []types.ReturnInspectionPayload{{ReturnItemID: 1, Resolution: types.ReturnResolutionRestock}}, ErrInvalidItem},
+		{"an unknown item", []types.ReturnInspectionPayload{{ReturnItemID: 1}, {ReturnItemID: 2}, {ReturnItemID: 3}}, ErrInvalidItem},
+		{"an item twice", []types.ReturnInspectionPayload{{ReturnItemID: 1}, {ReturnItemID: 1}, {ReturnItemID: 2}}, ErrInvalidItem},
+	}
+
+	for _, test := range tests {
+		if err := CheckInspection(r, test.payload); !errors.Is(err, test.err) {
+			t.Errorf("%s: expected error %v, got %v", test.name, test.err, err)
+		}
+	}
+}
@@ -0,0 +1,407 @@
+package returns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/akshtrikha/golang-ecomm/config"
+	"github.com/akshtrikha/golang-ecomm/services/auth"
+	"github.com/akshtrikha/golang-ecomm/services/order"
+	"github.com/akshtrikha/golang-ecomm/services/refund"
+	"github.com/akshtrikha/golang-ecomm/types"
+	"github.com/akshtrikha/golang-ecomm/utils"
+	"github.com/gorilla/mux"
+)
+
+// API Endpoints for the customers to return the items of their orders
+// and for the admins to approve, receive and refund the returns
+
+// Handler to the return store which will deal with the database
+// regarding returns, the received returns are refunded by the refunder
+type Handler struct {
+	store      types.ReturnStore
+	orderStore types.OrderStore
+	refunder   *refund.Refunder
+	window     time.Duration
+}
+
+// NewHandler constructor takes ReturnStore, OrderStore and Refunder as dependencies
+func NewHandler(store types.ReturnStore, orderStore types.OrderStore, refunder *refund.Refunder) *Handler {
+	return &Handler{
+		store:      store,
+		orderStore: orderStore,
+		refunder:   refunder,
+		window:     time.Hour * 24 * time.Duration(config.Envs.ReturnWindowInDays),
+	}
+}
+
+// RegisterRoutes func for returns
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/orders/{id:[0-9]+}/returns", auth.RequireAuth(h.handleCreateReturn)).Methods("POST")
+	router.HandleFunc("/orders/{id:[0-9]+}/returns", auth.RequireAuth(h.handleGetOrderReturns)).Methods("GET")
+	router.HandleFunc("/returns", auth.RequireAuth(h.handleGetReturns)).Methods("GET")
+	router.HandleFunc("/returns/{id:[0-9]+}", auth.RequireAuth(h.handleGetReturn)).Methods("GET")
+	router.HandleFunc("/returns/{id:[0-9]+}/status", auth.RequireAuth(h.handleUpdateReturnStatus)).Methods("PATCH")
+	router.HandleFunc("/returns/{id:[0-9]+}/history", auth.RequireAuth(h.handleGetReturnStatusHistory)).Methods("GET")
+	router.HandleFunc("/admin/returns", auth.RequireRole(auth.RoleAdmin, h.handleGetReturnsByStatus)).Methods("GET")
+	router.HandleFunc("/admin/returns/{id:[0-9]+}/receive", auth.RequireRole(auth.RoleAdmin, h.handleReceiveReturn)).Methods("POST")
+	router.HandleFunc("/admin/returns/{id:[0-9]+}/refund", auth.RequireRole(auth.RoleAdmin, h.handleRefundReturn)).Methods("POST")
+}
+
+func (h *Handler) handleCreateReturn(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle POST /orders/{id}/returns endpoint hit")
+
+	userID, _ := auth.UserIDFromContext(r.Context())
+
+	orderID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid order id"))
+		return
+	}
+
+	// get the json payload
+	var payload types.CreateReturnPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		log.Println("Payload parsing met with an error")
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// validate the json payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.ValidationError("Invalid payload", err))
+		return
+	}
+
+	// only the customer of the order can return its items
+	o, err := h.orderStore.GetOrderByID(orderID)
+	if errors.Is(err, order.ErrOrderNotFound) || (err == nil && o.UserID != userID) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("order with id: %v not found", orderID))
+		return
+	}
+	if err != nil {
+		log.Printf("Error finding the order %v, error: %+v", orderID, err)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	history, err := h.orderStore.GetOrderStatusHistory(orderID)
+	if err != nil {
+		log.Println("Error fetching the order status history from the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := CheckEligibility(*o, history, time.Now(), h.window); err != nil {
+		utils.WriteError(w, http.StatusConflict, err)
+		return
+	}
+
+	ret, err := h.store.CreateReturn(orderID, userID, payload)
+	if err != nil {
+		if errors.Is(err, ErrInvalidItem) {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		log.Println("Error adding the return to the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	log.Printf("Return %v requested for order %v", ret.ID, orderID)
+
+	utils.WriteJSON(w, http.StatusCreated, ret)
+}
+
+func (h *Handler) handleGetOrderReturns(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle GET /orders/{id}/returns endpoint hit")
+
+	p, _ := auth.PrincipalFromContext(r.Context())
+
+	orderID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid order id"))
+		return
+	}
+
+	o, err := h.orderStore.GetOrderByID(orderID)
+	if errors.Is(err, order.ErrOrderNotFound) || (err == nil && !p.IsAdmin() && o.UserID != p.UserID) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("order with id: %v not found", orderID))
+		return
+	}
+	if err != nil {
+		log.Printf("Error finding the order %v, error: %+v", orderID, err)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	returns, err := h.store.GetReturnsByOrderID(orderID)
+	if err != nil {
+		log.Println("Error fetching the returns of the order from the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, returns)
+}
+
+func (h *Handler) handleGetReturns(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle GET /returns endpoint hit")
+
+	userID, _ := auth.UserIDFromContext(r.Context())
+
+	returns, err := h.store.GetReturnsByUserID(userID)
+	if err != nil {
+		log.Println("Error fetching the returns of the user from the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, returns)
+}
+
+func (h *Handler) handleGetReturnsByStatus(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle GET /admin/returns endpoint hit")
+
+	// the returns waiting for a decision are listed by default
+	status := types.ReturnStatus(r.URL.Query().Get("status"))
+	if status == "" {
+		status = types.ReturnStatusRequested
+	}
+
+	if !IsValidStatus(status) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("%w: %q", ErrUnknownStatus, status))
+		return
+	}
+
+	returns, err := h.store.GetReturnsByStatus(status)
+	if err != nil {
+		log.Println("Error fetching the returns from the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, returns)
+}
+
+func (h *Handler) handleGetReturn(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle GET /returns/{id} endpoint hit")
+
+	ret, status, err := h.getReturn(r)
+	if err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, ret)
+}
+
+func (h *Handler) handleUpdateReturnStatus(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle PATCH /returns/{id}/status endpoint hit")
+
+	p, _ := auth.PrincipalFromContext(r.Context())
+
+	ret, status, err := h.getReturn(r)
+	if err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	// get the json payload
+	var payload types.UpdateReturnStatusPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		log.Println("Payload parsing met with an error")
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// validate the json payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.ValidationError("Invalid payload", err))
+		return
+	}
+
+	// the returned units are inspected when they are received
+	// and the credit note is recorded when they are refunded
+	if payload.Status == types.ReturnStatusReceived || payload.Status == types.ReturnStatusRefunded {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("returns are moved to %s with the receive and refund endpoints", payload.Status))
+		return
+	}
+
+	// customers can only cancel their own returns before they are approved,
+	// every other transition is an admin operation
+	if !p.IsAdmin() && (payload.Status != types.ReturnStatusCancelled || ret.Status != types.ReturnStatusRequested) {
+		utils.WriteError(w, http.StatusForbidden, fmt.Errorf("You are not allowed to perform this action"))
+		return
+	}
+
+	ret, err = h.store.UpdateReturnStatus(ret.ID, payload.Status, p.UserID, payload.Note)
+	if err != nil {
+		writeStoreError(w, err, "Error updating the return status")
+		return
+	}
+
+	log.Printf("Return %v moved to %v", ret.ID, ret.Status)
+
+	utils.WriteJSON(w, http.StatusOK, ret)
+}
+
+func (h *Handler) handleGetReturnStatusHistory(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle GET /returns/{id}/history endpoint hit")
+
+	ret, status, err := h.getReturn(r)
+	if err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	history, err := h.store.GetReturnStatusHistory(ret.ID)
+	if err != nil {
+		log.Println("Error fetching the return status history from the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, history)
+}
+
+func (h *Handler) handleReceiveReturn(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle POST /admin/returns/{id}/receive endpoint hit")
+
+	userID, _ := auth.UserIDFromContext(r.Context())
+
+	ret, status, err := h.getReturn(r)
+	if err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	// get the json payload
+	var payload types.ReceiveReturnPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		log.Println("Payload parsing met with an error")
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// validate the json payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.ValidationError("Invalid payload", err))
+		return
+	}
+
+	ret, err = h.store.ReceiveReturn(ret.ID, userID, payload)
+	if err != nil {
+		writeStoreError(w, err, "Error recording the inspection of the return")
+		return
+	}
+
+	log.Printf("Return %v received", ret.ID)
+
+	// the received units are refunded right away, the return stays
+	// received and can be refunded again if the refund fails
+	ret, status, err = h.refund(r.Context(), ret, userID)
+	if err != nil {
+		utils.WriteError(w, status, fmt.Errorf("return %v was received but not refunded: %w", ret.ID, err))
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, ret)
+}
+
+func (h *Handler) handleRefundReturn(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle POST /admin/returns/{id}/refund endpoint hit")
+
+	userID, _ := auth.UserIDFromContext(r.Context())
+
+	ret, status, err := h.getReturn(r)
+	if err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	if err := ValidateTransition(ret.Status, types.ReturnStatusRefunded); err != nil {
+		utils.WriteError(w, http.StatusConflict, err)
+		return
+	}
+
+	ret, status, err = h.refund(r.Context(), ret, userID)
+	if err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, ret)
+}
+
+// refund refunds the units of the received return with a credit note and moves
+// the return to refunded, the units are restocked when they are received. The
+// credit note is recorded once per return, a retry after the return could not
+// be moved finds the credit note already recorded and gives nothing back again.
+// The returned status code is meant to be used when an error is returned.
+func (h *Handler) refund(ctx context.Context, ret *types.Return, userID int) (*types.Return, int, error) {
+	payload := types.RefundPayload{
+		Lines:    make([]types.RefundLinePayload, len(ret.Items)),
+		Reason:   fmt.Sprintf("return %d", ret.ID),
+		ReturnID: ret.ID,
+	}
+	for i, item := range ret.Items {
+		payload.Lines[i] = types.RefundLinePayload{OrderItemID: item.OrderItemID, Quantity: item.Quantity}
+	}
+
+	note, status, err := h.refunder.Refund(ctx, ret.OrderID, userID, payload)
+	if err != nil {
+		return ret, status, err
+	}
+
+	refunded, err := h.store.RefundReturn(ret.ID, note.ID, userID, "credit note "+note.Number)
+	if err != nil {
+		log.Printf("Error recording the credit note %v of the return %v, error: %+v", note.Number, ret.ID, err)
+		return ret, http.StatusInternalServerError, err
+	}
+
+	log.Printf("Return %v refunded with credit note %v", ret.ID, note.Number)
+
+	return refunded, http.StatusOK, nil
+}
+
+// getReturn returns the return of the id in the path, the customers can only get their own returns.
+// The returned status code is meant to be used when an error is returned.
+func (h *Handler) getReturn(r *http.Request) (*types.Return, int, error) {
+	p, _ := auth.PrincipalFromContext(r.Context())
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid return id")
+	}
+
+	ret, err := h.store.GetReturnByID(id)
+	if errors.Is(err, ErrReturnNotFound) || (err == nil && !p.IsAdmin() && ret.UserID != p.UserID) {
+		return nil, http.StatusNotFound, fmt.Errorf("return with id: %v not found", id)
+	}
+	if err != nil {
+		log.Printf("Error finding the return %v, error: %+v", id, err)
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return ret, http.StatusOK, nil
+}
+
+// writeStoreError writes the error of a change of the return made by the store
+func writeStoreError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, ErrUnknownStatus), errors.Is(err, ErrInvalidItem):
+		utils.WriteError(w, http.StatusBadRequest, err)
+	case errors.Is(err, ErrInvalidTransition):
+		utils.WriteError(w, http.StatusConflict, err)
+	default:
+		log.Println(message)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+	}
+}
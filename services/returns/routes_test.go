@@ -0,0 +1,571 @@
+package returns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akshtrikha/golang-ecomm/services/auth"
+	"github.com/akshtrikha/golang-ecomm/services/order"
+	"github.com/akshtrikha/golang-ecomm/services/payment"
+	"github.com/akshtrikha/golang-ecomm/services/refund"
+	"github.com/akshtrikha/golang-ecomm/types"
+	"github.com/gorilla/mux"
+)
+
+// mockReturnStore keeps the returns in memory and moves them
+// through the return state machine
+type mockReturnStore struct {
+	orderStore *mockOrderStore
+	returns    map[int]types.Return
+	history    map[int][]types.ReturnStatusChange
+	restocked  map[int]int
+	refundErr  error
+}
+
+func (m *mockReturnStore) CreateReturn(orderID int, userID int, payload types.CreateReturnPayload) (*types.Return, error) {
+	open := []types.ReturnItem{}
+	for _, r := range m.returns {
+		if r.OrderID == orderID && IsOpen(r.Status) {
+			open = append(open, r.Items...)
+		}
+	}
+
+	if err := CheckItems(m.orderStore.items[orderID], open, payload.Items); err != nil {
+		return nil, err
+	}
+
+	r := types.Return{ID: len(m.returns) + 1, OrderID: orderID, UserID: userID, Status: types.ReturnStatusRequested, Note: payload.Note, Items: []types.ReturnItem{}}
+	for _, item := range payload.Items {
+		r.Items = append(r.Items, types.ReturnItem{ID: r.ID*10 + len(r.Items) + 1, ReturnID: r.ID, OrderItemID: item.OrderItemID, Quantity: item.Quantity, Reason: item.Reason})
+	}
+
+	m.returns[r.ID] = r
+	m.history[r.ID] = []types.ReturnStatusChange{{ReturnID: r.ID, ToStatus: r.Status, ChangedBy: &userID, Note: "return requested"}}
+
+	return &r, nil
+}
+
+func (m *mockReturnStore) GetReturnByID(id int) (*types.Return, error) {
+	r, ok := m.returns[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrReturnNotFound, id)
+	}
+
+	return &r, nil
+}
+
+func (m *mockReturnStore) GetReturnsByOrderID(orderID int) ([]types.Return, error) {
+	returns := []types.Return{}
+	for _, r := range m.returns {
+		if r.OrderID == orderID {
+			returns = append(returns, r)
+		}
+	}
+
+	return returns, nil
+}
+
+func (m *mockReturnStore) GetReturnsByUserID(userID int) ([]types.Return, error) {
+	returns := []types.Return{}
+	for _, r := range m.returns {
+		if r.UserID == userID {
+			returns = append(returns, r)
+		}
+	}
+
+	return returns, nil
+}
+
+func (m *mockReturnStore) GetReturnsByStatus(status types.ReturnStatus) ([]types.Return, error) {
+	returns := []types.Return{}
+	for _, r := range m.returns {
+		if r.Status == status {
+			returns = append(returns, r)
+		}
+	}
+
+	return returns, nil
+}
+
+func (m *mockReturnStore) UpdateReturnStatus(id int, status types.ReturnStatus, changedBy int, note string) (*types.Return, error) {
+	r, err := m.GetReturnByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ValidateTransition(r.Status, status); err != nil {
+		return nil, err
+	}
+
+	m.history[id] = append(m.history[id], types.ReturnStatusChange{ReturnID: id, FromStatus: &r.Status, ToStatus: status, ChangedBy: &changedBy, Note: note})
+	r.Status = status
+	m.returns[id] = *r
+
+	return r, nil
+}
+
+func (m *mockReturnStore) ReceiveReturn(id int, changedBy int, payload types.ReceiveReturnPayload) (*types.Return, error) {
+	r, err := m.GetReturnByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ValidateTransition(r.Status, types.ReturnStatusReceived); err != nil {
+		return nil, err
+	}
+
+	if err := CheckInspection(*r, payload.Items); err != nil {
+		return nil, err
+	}
+
+	for _, line := range payload.Items {
+		for i := range r.Items {
+			if r.Items[i].ID == line.ReturnItemID {
+				r.Items[i].Resolution = line.Resolution
+				if line.Resolution == types.ReturnResolutionRestock {
+					m.restocked[r.Items[i].OrderItemID] += r.Items[i].Quantity
+				}
+			}
+		}
+	}
+
+	return m.UpdateReturnStatus(id, types.ReturnStatusReceived, changedBy, payload.Note)
+}
+
+func (m *mockReturnStore) RefundReturn(id int, creditNoteID int, changedBy int, note string) (*types.Return, error) {
+	if m.refundErr != nil {
+		return nil, m.refundErr
+	}
+
+	r, err := m.UpdateReturnStatus(id, types.ReturnStatusRefunded, changedBy, note)
+	if err != nil {
+		return nil, err
+	}
+
+	r.CreditNoteID = &creditNoteID
+	m.returns[id] = *r
+
+	return r, nil
+}
+
+func (m *mockReturnStore) GetReturnStatusHistory(id int) ([]types.ReturnStatusChange, error) {
+	return m.history[id], nil
+}
+
+// mockOrderStore keeps the orders, their items and their
+// status history in memory
+type mockOrderStore struct {
+	orders  map[int]types.Order
+	items   map[int][]types.OrderItem
+	history map[int][]types.OrderStatusChange
+}
+
+func (m *mockOrderStore) PlaceOrder(checkout types.Checkout) (*types.Order, error) {
+	return nil, nil
+}
+
+func (m *mockOrderStore) GetOrderByID(id int) (*types.Order, error) {
+	o, ok := m.orders[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", order.ErrOrderNotFound, id)
+	}
+
+	return &o, nil
+}
+
+func (m *mockOrderStore) GetOrdersByUserID(userID int) ([]types.Order, error) {
+	return []types.Order{}, nil
+}
+
+func (m *mockOrderStore) GetAllOrders() ([]types.Order, error) {
+	return []types.Order{}, nil
+}
+
+func (m *mockOrderStore) GetOrderItems(orderID int) ([]types.OrderItem, error) {
+	return m.items[orderID], nil
+}
+
+func (m *mockOrderStore) GetOrderPromotions(orderID int) ([]types.AppliedPromotion, error) {
+	return []types.AppliedPromotion{}, nil
+}
+
+func (m *mockOrderStore) UpdateOrderStatus(orderID int, status types.OrderStatus, changedBy int, note string) (*types.Order, error) {
+	o, err := m.GetOrderByID(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := order.ValidateTransition(o.Status, status); err != nil {
+		return nil, err
+	}
+
+	o.Status = status
+	m.orders[orderID] = *o
+
+	return o, nil
+}
+
+func (m *mockOrderStore) GetOrderStatusHistory(orderID int) ([]types.OrderStatusChange, error) {
+	return m.history[orderID], nil
+}
+
+// mockCreditNoteStore computes the credit notes of the orders kept in memory
+// and only records them and their payment when the refund succeeds
+type mockCreditNoteStore struct {
+	orderStore   *mockOrderStore
+	paymentStore *mockPaymentStore
+	notes        []types.CreditNote
+}
+
+func (m *mockCreditNoteStore) CreateCreditNote(orderID int, createdBy int, payload types.RefundPayload, refundFn func(*types.CreditNote, *types.Payment) error) (*types.CreditNote, error) {
+	o, err := m.orderStore.GetOrderByID(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the return is refunded by one credit note
+	for _, recorded := range m.notes {
+		if payload.ReturnID != 0 && recorded.ReturnID != nil && *recorded.ReturnID == payload.ReturnID {
+			return &recorded, nil
+		}
+	}
+
+	items := m.orderStore.items[orderID]
+	note, err := refund.Build(*o, items, types.NewMoney(0, o.Currency), payload)
+	if err != nil {
+		return nil, err
+	}
+
+	pay := m.paymentStore.capturedPayment(orderID)
+	if err := refundFn(note, pay); err != nil {
+		return nil, err
+	}
+
+	if note.PaymentID != nil {
+		m.paymentStore.payments[pay.ID] = *pay
+	}
+
+	if payload.ReturnID != 0 {
+		note.ReturnID = &payload.ReturnID
+	}
+
+	note.ID = len(m.notes) + 1
+	note.Number = fmt.Sprintf("CN-%06d", note.ID)
+	m.notes = append(m.notes, *note)
+
+	for _, line := range note.Lines {
+		for i := range items {
+			if items[i].ID == line.OrderItemID {
+				items[i].RefundedQuantity += line.Quantity
+			}
+		}
+	}
+
+	o.Refunded.Amount += note.Total.Amount
+	m.orderStore.orders[orderID] = *o
+
+	return note, nil
+}
+
+func (m *mockCreditNoteStore) GetCreditNotesByOrderID(orderID int) ([]types.CreditNote, error) {
+	return m.notes, nil
+}
+
+// mockPaymentStore keeps the payments in memory
+type mockPaymentStore struct {
+	payments map[int]types.Payment
+}
+
+func (m *mockPaymentStore) CreatePayment(p types.Payment) (int, error) {
+	p.ID = len(m.payments) + 1
+	m.payments[p.ID] = p
+
+	return p.ID, nil
+}
+
+// capturedPayment returns the payment of the order refunds are
+// given back from, nil when the order has none
+func (m *mockPaymentStore) capturedPayment(orderID int) *types.Payment {
+	for _, p := range m.payments {
+		if p.OrderID == orderID && (p.Status == types.PaymentCaptured || p.Status == types.PaymentPartiallyRefunded) {
+			return &p
+		}
+	}
+
+	return nil
+}
+
+func usd(amount int64) types.Money {
+	return types.NewMoney(amount, types.DefaultCurrency)
+}
+
+// TestReturnServiceHandlers function to implement testing
+func TestReturnServiceHandlers(t *testing.T) {
+	// the order 1 of the customer 2 was delivered two days ago, the order 2
+	// two months ago and the order 3 is still on its way
+	now := time.Now()
+	orderStore := &mockOrderStore{
+		orders:  map[int]types.Order{},
+		items:   map[int][]types.OrderItem{},
+		history: map[int][]types.OrderStatusChange{},
+	}
+	for id, status := range map[int]types.OrderStatus{1: types.OrderStatusDelivered, 2: types.OrderStatusDelivered, 3: types.OrderStatusShipped} {
+		orderStore.orders[id] = types.Order{ID: id, UserID: 2, Total: usd(2500), Shipping: usd(500), Refunded: usd(0), Currency: types.DefaultCurrency, TaxInclusive: true, Status: status}
+		orderStore.items[id] = []types.OrderItem{
+			{ID: id*10 + 1, OrderID: id, ProductID: 1, Quantity: 2, Price: usd(500), Discount: usd(0), Tax: usd(0)},
+			{ID: id*10 + 2, OrderID: id, ProductID: 2, Quantity: 1, Price: usd(1000), Discount: usd(0), Tax: usd(0)},
+		}
+	}
+	orderStore.history[1] = []types.OrderStatusChange{{OrderID: 1, ToStatus: types.OrderStatusDelivered, CreatedAt: now.AddDate(0, 0, -2)}}
+	orderStore.history[2] = []types.OrderStatusChange{{OrderID: 2, ToStatus: types.OrderStatusDelivered, CreatedAt: now.AddDate(0, -2, 0)}}
+
+	gateway, err := payment.NewFakeGateway(payment.FakeSucceed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	total := orderStore.orders[1].Total
+	result, err := gateway.Authorize(context.Background(), types.AuthorizeRequest{OrderID: 1, Amount: total, PaymentMethod: payment.FakeCardSucceed})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := gateway.Capture(context.Background(), result.Reference, total); err != nil {
+		t.Fatal(err)
+	}
+
+	captured := payment.NewPayment(result.Reference, types.PaymentCaptured, total)
+	captured.OrderID, captured.Gateway, captured.Captured = 1, payment.FakeGatewayName, total
+	paymentStore := &mockPaymentStore{payments: map[int]types.Payment{}}
+	paymentID, _ := paymentStore.CreatePayment(*captured)
+
+	creditNoteStore := &mockCreditNoteStore{orderStore: orderStore, paymentStore: paymentStore}
+	store := &mockReturnStore{orderStore: orderStore, returns: map[int]types.Return{}, history: map[int][]types.ReturnStatusChange{}, restocked: map[int]int{}}
+	handler := NewHandler(store, orderStore, refund.NewRefunder(creditNoteStore, orderStore, gateway))
+
+	adminToken, err := auth.GenerateJWT(1, []string{auth.RoleAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	customerToken, err := auth.GenerateJWT(2, []string{auth.RoleCustomer})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherToken, err := auth.GenerateJWT(3, []string{auth.RoleCustomer})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(method, path string, body string, token string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+		router.Use(auth.JWTMiddleware)
+
+		handler.RegisterRoutes(router)
+		router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	decode := func(rr *httptest.ResponseRecorder) types.Return {
+		var r types.Return
+		if err := json.NewDecoder(rr.Body).Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+
+		return r
+	}
+
+	t.Run("Should fail if the payload is invalid", func(t *testing.T) {
+		for _, body := range []string{
+			`{"items":[]}`,
+			`{"items":[{"orderItemId":11,"quantity":1,"reason":"changed_my_mind"}]}`,
+			`{"items":[{"orderItemId":11,"quantity":3,"reason":"damaged"}]}`,
+		} {
+			if rr := serve(http.MethodPost, "/orders/1/returns", body, customerToken); rr.Code != http.StatusBadRequest {
+				t.Errorf("Expected status code %d, got %d for %s", http.StatusBadRequest, rr.Code, body)
+			}
+		}
+	})
+
+	t.Run("Should not let a customer return the items of another", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/orders/1/returns", `{"items":[{"orderItemId":11,"quantity":1,"reason":"damaged"}]}`, otherToken)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("Should fail if the order is not eligible for returns", func(t *testing.T) {
+		for _, path := range []string{"/orders/2/returns", "/orders/3/returns"} {
+			body := fmt.Sprintf(`{"items":[{"orderItemId":%d,"quantity":1,"reason":"damaged"}]}`, orderStore.items[2][0].ID)
+			if rr := serve(http.MethodPost, path, body, customerToken); rr.Code != http.StatusConflict {
+				t.Errorf("Expected status code %d, got %d for %s", http.StatusConflict, rr.Code, path)
+			}
+		}
+	})
+
+	t.Run("Should let the customer cancel the requested return", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/orders/1/returns", `{"items":[{"orderItemId":12,"quantity":1,"reason":"no_longer_needed"}]}`, customerToken)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		path := fmt.Sprintf("/returns/%d/status", decode(rr).ID)
+		if rr := serve(http.MethodPatch, path, `{"status":"approved"}`, customerToken); rr.Code != http.StatusForbidden {
+			t.Errorf("Expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+
+		if rr := serve(http.MethodPatch, path, `{"status":"cancelled"}`, otherToken); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+
+		if rr := serve(http.MethodPatch, path, `{"status":"cancelled"}`, customerToken); rr.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		if rr := serve(http.MethodPatch, path, `{"status":"approved"}`, adminToken); rr.Code != http.StatusConflict {
+			t.Errorf("Expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+	})
+
+	t.Run("Should approve, receive and refund the return", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/orders/1/returns", `{"items":[{"orderItemId":11,"quantity":2,"reason":"damaged"}],"note":"the box was crushed"}`, customerToken)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		ret := decode(rr)
+		if ret.Status != types.ReturnStatusRequested || len(ret.Items) != 1 {
+			t.Errorf("Expected a requested return of an item, got %+v", ret)
+		}
+
+		// the units of the open return cannot be returned again
+		if rr := serve(http.MethodPost, "/orders/1/returns", `{"items":[{"orderItemId":11,"quantity":1,"reason":"damaged"}]}`, customerToken); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+
+		path := fmt.Sprintf("/returns/%d", ret.ID)
+		adminPath := fmt.Sprintf("/admin/returns/%d", ret.ID)
+		inspection := fmt.Sprintf(`{"items":[{"returnItemId":%d,"resolution":"restock"}]}`, ret.Items[0].ID)
+
+		if rr := serve(http.MethodPost, adminPath+"/receive", inspection, adminToken); rr.Code != http.StatusConflict {
+			t.Errorf("Expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+
+		if rr := serve(http.MethodPatch, path+"/status", `{"status":"received"}`, adminToken); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+
+		if rr := serve(http.MethodPatch, path+"/status", `{"status":"approved","note":"send it back"}`, adminToken); rr.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		if rr := serve(http.MethodPost, adminPath+"/receive", `{"items":[{"returnItemId":99,"resolution":"restock"}]}`, adminToken); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+
+		rr = serve(http.MethodPost, adminPath+"/receive", inspection, adminToken)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		if ret := decode(rr); ret.Status != types.ReturnStatusRefunded || ret.CreditNoteID == nil {
+			t.Errorf("Expected the return to be refunded with a credit note, got %+v", ret)
+		}
+
+		if store.restocked[11] != 2 {
+			t.Errorf("Expected the 2 units to be restocked, got %d", store.restocked[11])
+		}
+
+		if p := paymentStore.payments[paymentID]; p.Status != types.PaymentPartiallyRefunded || p.Refunded != usd(1000) {
+			t.Errorf("Expected 10.00 of the payment to be refunded, got %+v", p)
+		}
+
+		if rr := serve(http.MethodPost, adminPath+"/refund", "", adminToken); rr.Code != http.StatusConflict {
+			t.Errorf("Expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+
+		rr = serve(http.MethodGet, path+"/history", "", customerToken)
+
+		var history []types.ReturnStatusChange
+		if err := json.NewDecoder(rr.Body).Decode(&history); err != nil || len(history) != 4 {
+			t.Errorf("Expected the 4 status changes of the return, got %+v, error: %v", history, err)
+		}
+	})
+
+	t.Run("Should list the returns by status for the admins", func(t *testing.T) {
+		if rr := serve(http.MethodGet, "/admin/returns?status=refunded", "", customerToken); rr.Code != http.StatusForbidden {
+			t.Errorf("Expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+
+		if rr := serve(http.MethodGet, "/admin/returns?status=lost", "", adminToken); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+
+		rr := serve(http.MethodGet, "/admin/returns?status=refunded", "", adminToken)
+
+		var returns []types.Return
+		if err := json.NewDecoder(rr.Body).Decode(&returns); err != nil || len(returns) != 1 {
+			t.Errorf("Expected the refunded return, got %+v, error: %v", returns, err)
+		}
+
+		rr = serve(http.MethodGet, "/returns", "", otherToken)
+		if err := json.NewDecoder(rr.Body).Decode(&returns); err != nil || len(returns) != 0 {
+			t.Errorf("Expected no returns for the other customer, got %+v, error: %v", returns, err)
+		}
+	})
+	t.Run("Should not refund a return twice when its refund is retried", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/orders/1/returns", `{"items":[{"orderItemId":12,"quantity":1,"reason":"defective"}]}`, customerToken)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		ret := decode(rr)
+		adminPath := fmt.Sprintf("/admin/returns/%d", ret.ID)
+		inspection := fmt.Sprintf(`{"items":[{"returnItemId":%d,"resolution":"write_off"}]}`, ret.Items[0].ID)
+
+		if rr := serve(http.MethodPatch, fmt.Sprintf("/returns/%d/status", ret.ID), `{"status":"approved"}`, adminToken); rr.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		// the credit note is recorded but the return is not moved to refunded
+		store.refundErr = errors.New("connection lost")
+		rr = serve(http.MethodPost, adminPath+"/receive", inspection, adminToken)
+		store.refundErr = nil
+
+		if rr.Code != http.StatusInternalServerError {
+			t.Fatalf("Expected status code %d, got %d", http.StatusInternalServerError, rr.Code)
+		}
+
+		rr = serve(http.MethodPost, adminPath+"/refund", "", adminToken)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		refunded := decode(rr)
+		if len(creditNoteStore.notes) != 2 || refunded.CreditNoteID == nil || *refunded.CreditNoteID != creditNoteStore.notes[1].ID {
+			t.Errorf("Expected the return to be refunded with the credit note already recorded, got %+v and %d credit notes", refunded, len(creditNoteStore.notes))
+		}
+
+		if p := paymentStore.payments[paymentID]; p.Refunded != usd(2000) {
+			t.Errorf("Expected 20.00 of the payment to be refunded, got %+v", p)
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package returns
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// ErrInvalidTransition is returned when a return status change
+// is not allowed by the return state machine
+var ErrInvalidTransition = errors.New("invalid return status transition")
+
+// ErrUnknownStatus is returned for a status that is not part of the state machine
+var ErrUnknownStatus = errors.New("unknown return status")
+
+// transitions holds the state machine of a return.
+// Each status maps to the statuses it can be moved to.
+//
+//	requested -> approved -> received -> refunded
+//	requested -> rejected
+//	requested, approved -> cancelled
+var transitions = map[types.ReturnStatus][]types.ReturnStatus{
+	types.ReturnStatusRequested: {types.ReturnStatusApproved, types.ReturnStatusRejected, types.ReturnStatusCancelled},
+	types.ReturnStatusApproved:  {types.ReturnStatusReceived, types.ReturnStatusCancelled},
+	types.ReturnStatusReceived:  {types.ReturnStatusRefunded},
+	types.ReturnStatusRejected:  {},
+	types.ReturnStatusRefunded:  {},
+	types.ReturnStatusCancelled: {},
+}
+
+// IsOpen reports whether the units of the return are still on their way back,
+// they cannot be returned again until the return is closed
+func IsOpen(status types.ReturnStatus) bool {
+	return status == types.ReturnStatusRequested || status == types.ReturnStatusApproved || status == types.ReturnStatusReceived
+}
+
+// IsValidStatus reports whether the status is part of the state machine
+func IsValidStatus(status types.ReturnStatus) bool {
+	_, ok := transitions[status]
+	return ok
+}
+
+// CanTransition reports whether a return can be moved from one status to another
+func CanTransition(from, to types.ReturnStatus) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValidateTransition returns a descriptive error when
+// the return cannot be moved from one status to another
+func ValidateTransition(from, to types.ReturnStatus) error {
+	if !IsValidStatus(to) {
+		return fmt.Errorf("%w: %q", ErrUnknownStatus, to)
+	}
+
+	if CanTransition(from, to) {
+		return nil
+	}
+
+	allowed := transitions[from]
+	if len(allowed) == 0 {
+		return fmt.Errorf("%w: return is %s and can no longer change status", ErrInvalidTransition, from)
+	}
+
+	names := make([]string, len(allowed))
+	for i, status := range allowed {
+		names[i] = string(status)
+	}
+
+	return fmt.Errorf("%w: cannot move return from %s to %s, allowed: %s", ErrInvalidTransition, from, to, strings.Join(names, ", "))
+}
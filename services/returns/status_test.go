@@ -0,0 +1,38 @@
+package returns
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// TestValidateTransition function to test the return state machine
+func TestValidateTransition(t *testing.T) {
+	tests := []struct {
+		from types.ReturnStatus
+		to   types.ReturnStatus
+		err  error
+	}{
+		{types.ReturnStatusRequested, types.ReturnStatusApproved, nil},
+		{types.ReturnStatusRequested, types.ReturnStatusRejected, nil},
+		{types.ReturnStatusRequested, types.ReturnStatusCancelled, nil},
+		{types.ReturnStatusApproved, types.ReturnStatusReceived, nil},
+		{types.ReturnStatusApproved, types.ReturnStatusCancelled, nil},
+		{types.ReturnStatusReceived, types.ReturnStatusRefunded, nil},
+		{types.ReturnStatusRequested, types.ReturnStatusReceived, ErrInvalidTransition},
+		{types.ReturnStatusApproved, types.ReturnStatusRejected, ErrInvalidTransition},
+		{types.ReturnStatusReceived, types.ReturnStatusCancelled, ErrInvalidTransition},
+		{types.ReturnStatusRejected, types.ReturnStatusApproved, ErrInvalidTransition},
+		{types.ReturnStatusRefunded, types.ReturnStatusReceived, ErrInvalidTransition},
+		{types.ReturnStatusRequested, "lost", ErrUnknownStatus},
+	}
+
+	for _, test := range tests {
+		err := ValidateTransition(test.from, test.to)
+
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s -> %s: expected error %v, got %v", test.from, test.to, test.err, err)
+		}
+	}
+}
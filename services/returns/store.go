@@ -0,0 +1,467 @@
+package returns
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// returnColumns is the list of columns scanned by scanRowIntoReturn
+const returnColumns = "id, orderId, userId, status, note, creditNoteId, createdAt, updatedAt"
+
+// Store struct to hold the database object
+// This will be used to handle the database queries
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore function to return a reference to the Store struct
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// ErrReturnNotFound is returned when a return does not exist
+var ErrReturnNotFound = errors.New("return not found")
+
+// querier is implemented by *sql.DB and *sql.Tx
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// CreateReturn function to request the return of order items for the user.
+// The items of the order are locked while the units left to return are checked
+// so concurrent returns and refunds of the order cannot return a unit twice
+func (s *Store) CreateReturn(orderID int, userID int, payload types.CreateReturnPayload) (*types.Return, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	items, err := lockOrderItems(tx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	open, err := openItems(tx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := CheckItems(items, open, payload.Items); err != nil {
+		return nil, err
+	}
+
+	result, err := tx.Exec("INSERT INTO returns (orderId, userId, status, note) VALUES (?, ?, ?, ?)", orderID, userID, types.ReturnStatusRequested, payload.Note)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range payload.Items {
+		_, err := tx.Exec("INSERT INTO return_items (returnId, orderItemId, quantity, reason) VALUES (?, ?, ?, ?)", id, item.OrderItemID, item.Quantity, item.Reason)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// record the initial status in the history
+	if err := insertStatusChange(tx, int(id), nil, types.ReturnStatusRequested, &userID, "return requested"); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.GetReturnByID(int(id))
+}
+
+// GetReturnByID function to get the return with its items
+func (s *Store) GetReturnByID(id int) (*types.Return, error) {
+	returns, err := s.getReturns(s.db, "SELECT "+returnColumns+" FROM returns WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(returns) == 0 {
+		return nil, fmt.Errorf("%w: %v", ErrReturnNotFound, id)
+	}
+
+	return &returns[0], nil
+}
+
+// GetReturnsByOrderID function to get the returns of the order, the newest first
+func (s *Store) GetReturnsByOrderID(orderID int) ([]types.Return, error) {
+	return s.getReturns(s.db, "SELECT "+returnColumns+" FROM returns WHERE orderId = ? ORDER BY id DESC", orderID)
+}
+
+// GetReturnsByUserID function to get the returns requested by the user, the newest first
+func (s *Store) GetReturnsByUserID(userID int) ([]types.Return, error) {
+	return s.getReturns(s.db, "SELECT "+returnColumns+" FROM returns WHERE userId = ? ORDER BY id DESC", userID)
+}
+
+// GetReturnsByStatus function to get the returns in the status, the oldest first
+// so the admins handle them in the order they were requested
+func (s *Store) GetReturnsByStatus(status types.ReturnStatus) ([]types.Return, error) {
+	return s.getReturns(s.db, "SELECT "+returnColumns+" FROM returns WHERE status = ? ORDER BY id", status)
+}
+
+// UpdateReturnStatus function to move the return to a new status.
+// The transition is checked against the return state machine and recorded
+// in the status history in the same transaction
+func (s *Store) UpdateReturnStatus(id int, status types.ReturnStatus, changedBy int, note string) (*types.Return, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	r, err := s.lockReturn(tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ValidateTransition(r.Status, status); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec("UPDATE returns SET status = ? WHERE id = ?", status, id); err != nil {
+		return nil, err
+	}
+
+	if err := insertStatusChange(tx, id, &r.Status, status, &changedBy, note); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	r.Status = status
+	return r, nil
+}
+
+// ReceiveReturn function to record the inspection of the returned units.
+// The restocked units go back to the stock of the variant they were sold as
+// or of the product, the written off ones are not sold again
+func (s *Store) ReceiveReturn(id int, changedBy int, payload types.ReceiveReturnPayload) (*types.Return, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	r, err := s.lockReturn(tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ValidateTransition(r.Status, types.ReturnStatusReceived); err != nil {
+		return nil, err
+	}
+
+	if err := CheckInspection(*r, payload.Items); err != nil {
+		return nil, err
+	}
+
+	for _, line := range payload.Items {
+		if _, err := tx.Exec("UPDATE return_items SET resolution = ? WHERE id = ?", line.Resolution, line.ReturnItemID); err != nil {
+			return nil, err
+		}
+
+		for i := range r.Items {
+			item := &r.Items[i]
+			if item.ID != line.ReturnItemID {
+				continue
+			}
+
+			item.Resolution = line.Resolution
+			if line.Resolution == types.ReturnResolutionRestock {
+				if err := restock(tx, *item); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE returns SET status = ? WHERE id = ?", types.ReturnStatusReceived, id); err != nil {
+		return nil, err
+	}
+
+	if err := insertStatusChange(tx, id, &r.Status, types.ReturnStatusReceived, &changedBy, payload.Note); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	r.Status = types.ReturnStatusReceived
+	return r, nil
+}
+
+// RefundReturn function to move the received return to refunded
+// with the credit note its units were refunded with
+func (s *Store) RefundReturn(id int, creditNoteID int, changedBy int, note string) (*types.Return, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	r, err := s.lockReturn(tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ValidateTransition(r.Status, types.ReturnStatusRefunded); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec("UPDATE returns SET status = ?, creditNoteId = ? WHERE id = ?", types.ReturnStatusRefunded, creditNoteID, id); err != nil {
+		return nil, err
+	}
+
+	if err := insertStatusChange(tx, id, &r.Status, types.ReturnStatusRefunded, &changedBy, note); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	r.Status, r.CreditNoteID = types.ReturnStatusRefunded, &creditNoteID
+	return r, nil
+}
+
+// GetReturnStatusHistory function to get the status changes of the return
+// ordered from the oldest to the newest
+func (s *Store) GetReturnStatusHistory(id int) ([]types.ReturnStatusChange, error) {
+	rows, err := s.db.Query("SELECT id, returnId, fromStatus, toStatus, changedBy, note, createdAt FROM return_status_history WHERE returnId = ? ORDER BY id", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []types.ReturnStatusChange{}
+	for rows.Next() {
+		var change types.ReturnStatusChange
+		var from sql.NullString
+		var changedBy sql.NullInt64
+
+		err := rows.Scan(
+			&change.ID,
+			&change.ReturnID,
+			&from,
+			&change.ToStatus,
+			&changedBy,
+			&change.Note,
+			&change.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if from.Valid {
+			status := types.ReturnStatus(from.String)
+			change.FromStatus = &status
+		}
+
+		if changedBy.Valid {
+			id := int(changedBy.Int64)
+			change.ChangedBy = &id
+		}
+
+		history = append(history, change)
+	}
+
+	return history, rows.Err()
+}
+
+// lockReturn reads the return with FOR UPDATE along with its items
+func (s *Store) lockReturn(tx *sql.Tx, id int) (*types.Return, error) {
+	returns, err := s.getReturns(tx, "SELECT "+returnColumns+" FROM returns WHERE id = ? FOR UPDATE", id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(returns) == 0 {
+		return nil, fmt.Errorf("%w: %v", ErrReturnNotFound, id)
+	}
+
+	return &returns[0], nil
+}
+
+// getReturns runs the query of returns with the querier and loads their items
+func (s *Store) getReturns(q querier, query string, args ...any) ([]types.Return, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	returns := []types.Return{}
+	for rows.Next() {
+		r, err := scanRowIntoReturn(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		returns = append(returns, *r)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(returns) == 0 {
+		return returns, nil
+	}
+
+	// build the placeholders for the IN clause
+	placeholders := strings.Repeat("?,", len(returns)-1) + "?"
+
+	ids := make([]interface{}, len(returns))
+	for i, r := range returns {
+		ids[i] = r.ID
+	}
+
+	itemRows, err := q.Query(fmt.Sprintf("SELECT id, returnId, orderItemId, quantity, reason, resolution FROM return_items WHERE returnId IN (%s) ORDER BY id", placeholders), ids...)
+	if err != nil {
+		return nil, err
+	}
+	defer itemRows.Close()
+
+	for itemRows.Next() {
+		var item types.ReturnItem
+		var resolution sql.NullString
+
+		if err := itemRows.Scan(&item.ID, &item.ReturnID, &item.OrderItemID, &item.Quantity, &item.Reason, &resolution); err != nil {
+			return nil, err
+		}
+
+		item.Resolution = types.ReturnResolution(resolution.String)
+
+		for i := range returns {
+			if returns[i].ID == item.ReturnID {
+				returns[i].Items = append(returns[i].Items, item)
+			}
+		}
+	}
+
+	return returns, itemRows.Err()
+}
+
+// lockOrderItems reads the units of the items of the order with FOR UPDATE
+func lockOrderItems(tx *sql.Tx, orderID int) ([]types.OrderItem, error) {
+	rows, err := tx.Query("SELECT id, orderId, quantity, refundedQuantity FROM order_items WHERE orderId = ? ORDER BY id FOR UPDATE", orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []types.OrderItem{}
+	for rows.Next() {
+		item := types.OrderItem{}
+		if err := rows.Scan(&item.ID, &item.OrderID, &item.Quantity, &item.RefundedQuantity); err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// openItems reads the items of the open returns of the order
+func openItems(tx *sql.Tx, orderID int) ([]types.ReturnItem, error) {
+	rows, err := tx.Query(`
+		SELECT ri.id, ri.returnId, ri.orderItemId, ri.quantity, ri.reason
+		FROM return_items ri
+		JOIN returns r ON r.id = ri.returnId
+		WHERE r.orderId = ? AND r.status IN (?, ?, ?)`,
+		orderID, types.ReturnStatusRequested, types.ReturnStatusApproved, types.ReturnStatusReceived)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []types.ReturnItem{}
+	for rows.Next() {
+		var item types.ReturnItem
+		if err := rows.Scan(&item.ID, &item.ReturnID, &item.OrderItemID, &item.Quantity, &item.Reason); err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// restock returns the units of the returned item to the stock
+// of the variant they were sold as or of the product
+func restock(tx *sql.Tx, item types.ReturnItem) error {
+	_, err := tx.Exec(`
+		UPDATE products p
+		JOIN order_items oi ON oi.productId = p.id
+		SET p.quantity = p.quantity + ?
+		WHERE oi.id = ? AND oi.variantId IS NULL`, item.Quantity, item.OrderItemID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE product_variants v
+		JOIN order_items oi ON oi.variantId = v.id
+		SET v.quantity = v.quantity + ?
+		WHERE oi.id = ?`, item.Quantity, item.OrderItemID)
+
+	return err
+}
+
+func insertStatusChange(tx *sql.Tx, returnID int, from *types.ReturnStatus, to types.ReturnStatus, changedBy *int, note string) error {
+	_, err := tx.Exec("INSERT INTO return_status_history (returnId, fromStatus, toStatus, changedBy, note) VALUES (?, ?, ?, ?, ?)", returnID, from, to, changedBy, note)
+
+	return err
+}
+
+func scanRowIntoReturn(rows *sql.Rows) (*types.Return, error) {
+	r := &types.Return{Items: []types.ReturnItem{}}
+	var creditNoteID sql.NullInt64
+
+	err := rows.Scan(
+		&r.ID,
+		&r.OrderID,
+		&r.UserID,
+		&r.Status,
+		&r.Note,
+		&creditNoteID,
+		&r.CreatedAt,
+		&r.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if creditNoteID.Valid {
+		id := int(creditNoteID.Int64)
+		r.CreditNoteID = &id
+	}
+
+	return r, nil
+}
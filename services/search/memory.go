@@ -0,0 +1,354 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// BM25 parameters, matches in the name weigh more than in the description
+const (
+	bm25K1     = 1.2
+	bm25B      = 0.75
+	nameBoost  = 3.0
+	prefixRank = 0.8
+)
+
+// priceBucket is a price range of the price facet in minor units of the
+// DefaultCurrency, Max is exclusive and a negative Max leaves the bucket open ended
+type priceBucket struct {
+	Value string
+	Min   int64
+	Max   int64
+}
+
+var priceBuckets = []priceBucket{
+	{"0-25", 0, 2500},
+	{"25-50", 2500, 5000},
+	{"50-100", 5000, 10000},
+	{"100-250", 10000, 25000},
+	{"250+", 25000, -1},
+}
+
+// MemoryIndex is an in-process SearchIndex keeping an inverted index of
+// the product names and descriptions, so search works without an external service
+type MemoryIndex struct {
+	mu    sync.RWMutex
+	state *indexState
+}
+
+type indexState struct {
+	docs              map[int]*indexedDocument
+	postings          map[string][]int
+	avgNameLen        float64
+	avgDescriptionLen float64
+}
+
+type indexedDocument struct {
+	doc            types.SearchDocument
+	name           map[string]int
+	description    map[string]int
+	nameLen        int
+	descriptionLen int
+}
+
+// NewMemoryIndex function to return an empty in-process index
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{state: newIndexState(nil)}
+}
+
+// Replace builds the index of the documents and swaps it for the
+// current one, searches keep using the previous index meanwhile
+func (m *MemoryIndex) Replace(docs []types.SearchDocument) error {
+	state := newIndexState(docs)
+
+	m.mu.Lock()
+	m.state = state
+	m.mu.Unlock()
+
+	return nil
+}
+
+// Search returns the documents matching every term of the query text,
+// exactly, with a few typos or as a prefix for the last term, ranked by BM25
+func (m *MemoryIndex) Search(q types.SearchQuery) (*types.SearchResult, error) {
+	m.mu.RLock()
+	state := m.state
+	m.mu.RUnlock()
+
+	scores := state.match(tokenize(q.Text))
+
+	result := &types.SearchResult{Matches: []types.SearchMatch{}}
+	categories := map[int]*types.FacetCount{}
+	prices := make([]int, len(priceBuckets))
+	stock := map[bool]int{}
+
+	for id, score := range scores {
+		doc := state.docs[id].doc
+
+		inCategory := q.CategoryID == 0 || hasCategory(doc, q.CategoryID)
+		inPrice := (q.MinPrice == nil || doc.Price.Amount >= q.MinPrice.Amount) && (q.MaxPrice == nil || doc.Price.Amount <= q.MaxPrice.Amount)
+		inStock := !q.InStock || doc.InStock
+
+		// a facet counts the matches of every filter but its own
+		if inPrice && inStock {
+			for _, c := range doc.Categories {
+				if categories[c.ID] == nil {
+					categories[c.ID] = &types.FacetCount{Value: strconv.Itoa(c.ID), Label: c.Name}
+				}
+				categories[c.ID].Count++
+			}
+		}
+
+		if inCategory && inStock {
+			if i := bucketOf(doc.Price); i >= 0 {
+				prices[i]++
+			}
+		}
+
+		if inCategory && inPrice {
+			stock[doc.InStock]++
+		}
+
+		if inCategory && inPrice && inStock {
+			result.Matches = append(result.Matches, types.SearchMatch{ProductID: id, Score: score})
+		}
+	}
+
+	// the most relevant first, the oldest product first among equals
+	sort.Slice(result.Matches, func(i, j int) bool {
+		if result.Matches[i].Score != result.Matches[j].Score {
+			return result.Matches[i].Score > result.Matches[j].Score
+		}
+		return result.Matches[i].ProductID < result.Matches[j].ProductID
+	})
+
+	result.Total = len(result.Matches)
+	result.Matches = paginate(result.Matches, q.Offset, q.Limit)
+	result.Facets = buildFacets(categories, prices, stock)
+
+	return result, nil
+}
+
+func newIndexState(docs []types.SearchDocument) *indexState {
+	state := &indexState{
+		docs:     make(map[int]*indexedDocument, len(docs)),
+		postings: map[string][]int{},
+	}
+
+	var nameLen, descriptionLen int
+	for _, doc := range docs {
+		indexed := &indexedDocument{
+			doc:         doc,
+			name:        termFrequencies(doc.Name),
+			description: termFrequencies(doc.Description),
+		}
+
+		for _, tf := range indexed.name {
+			indexed.nameLen += tf
+		}
+		for _, tf := range indexed.description {
+			indexed.descriptionLen += tf
+		}
+
+		for term := range indexed.name {
+			state.postings[term] = append(state.postings[term], doc.ProductID)
+		}
+		for term := range indexed.description {
+			if _, ok := indexed.name[term]; !ok {
+				state.postings[term] = append(state.postings[term], doc.ProductID)
+			}
+		}
+
+		nameLen += indexed.nameLen
+		descriptionLen += indexed.descriptionLen
+		state.docs[doc.ProductID] = indexed
+	}
+
+	if len(docs) > 0 {
+		state.avgNameLen = float64(nameLen) / float64(len(docs))
+		state.avgDescriptionLen = float64(descriptionLen) / float64(len(docs))
+	}
+
+	return state
+}
+
+// match returns the score of every document matching all the terms,
+// every document matches an empty query with a zero score
+func (s *indexState) match(terms []string) map[int]float64 {
+	scores := make(map[int]float64)
+
+	if len(terms) == 0 {
+		for id := range s.docs {
+			scores[id] = 0
+		}
+
+		return scores
+	}
+
+	for i, term := range terms {
+		termScores := make(map[int]float64)
+
+		// a document scores the best of the expansions of the term
+		for expansion, weight := range s.expand(term, i == len(terms)-1) {
+			for _, id := range s.postings[expansion] {
+				if score := weight * s.bm25(expansion, s.docs[id]); score > termScores[id] {
+					termScores[id] = score
+				}
+			}
+		}
+
+		if i == 0 {
+			scores = termScores
+			continue
+		}
+
+		for id := range scores {
+			if score, ok := termScores[id]; ok {
+				scores[id] += score
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	return scores
+}
+
+// expand returns the indexed terms the query term matches with their weight.
+// Terms with typos weigh less the more typos they have, the last term of
+// the query also matches as a prefix for search as you type
+func (s *indexState) expand(term string, last bool) map[string]float64 {
+	expansions := make(map[string]float64)
+	if _, ok := s.postings[term]; ok {
+		expansions[term] = 1
+	}
+
+	typos := maxTypos(term)
+	prefix := last && len([]rune(term)) >= 3
+	if typos == 0 && !prefix {
+		return expansions
+	}
+
+	for indexed := range s.postings {
+		if indexed == term {
+			continue
+		}
+
+		var weight float64
+		if prefix && strings.HasPrefix(indexed, term) {
+			weight = prefixRank
+		}
+
+		if typos > 0 {
+			if d := editDistance(term, indexed, typos); d <= typos {
+				weight = math.Max(weight, 1/float64(1+d))
+			}
+		}
+
+		if weight > 0 {
+			expansions[indexed] = weight
+		}
+	}
+
+	return expansions
+}
+
+func (s *indexState) bm25(term string, doc *indexedDocument) float64 {
+	n := float64(len(s.docs))
+	df := float64(len(s.postings[term]))
+	idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+
+	return idf * (nameBoost*tfNorm(doc.name[term], doc.nameLen, s.avgNameLen) +
+		tfNorm(doc.description[term], doc.descriptionLen, s.avgDescriptionLen))
+}
+
+func tfNorm(tf int, length int, avgLength float64) float64 {
+	if tf == 0 {
+		return 0
+	}
+
+	norm := 1 - bm25B
+	if avgLength > 0 {
+		norm += bm25B * float64(length) / avgLength
+	}
+
+	return float64(tf) * (bm25K1 + 1) / (float64(tf) + bm25K1*norm)
+}
+
+func termFrequencies(text string) map[string]int {
+	tf := make(map[string]int)
+	for _, term := range tokenize(text) {
+		tf[term]++
+	}
+
+	return tf
+}
+
+func hasCategory(doc types.SearchDocument, categoryID int) bool {
+	for _, c := range doc.Categories {
+		if c.ID == categoryID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func bucketOf(price types.Money) int {
+	for i, b := range priceBuckets {
+		if price.Amount >= b.Min && (b.Max < 0 || price.Amount < b.Max) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func paginate(matches []types.SearchMatch, offset, limit int) []types.SearchMatch {
+	if offset >= len(matches) {
+		return []types.SearchMatch{}
+	}
+
+	end := offset + limit
+	if limit <= 0 || end > len(matches) {
+		end = len(matches)
+	}
+
+	return matches[offset:end]
+}
+
+// buildFacets orders the categories by their count, the
+// price buckets from the cheapest and in stock first
+func buildFacets(categories map[int]*types.FacetCount, prices []int, stock map[bool]int) types.SearchFacets {
+	facets := types.SearchFacets{
+		Categories:   []types.FacetCount{},
+		PriceBuckets: []types.FacetCount{},
+		InStock:      []types.FacetCount{},
+	}
+
+	for _, c := range categories {
+		facets.Categories = append(facets.Categories, *c)
+	}
+
+	sort.Slice(facets.Categories, func(i, j int) bool {
+		if facets.Categories[i].Count != facets.Categories[j].Count {
+			return facets.Categories[i].Count > facets.Categories[j].Count
+		}
+		return facets.Categories[i].Label < facets.Categories[j].Label
+	})
+
+	for i, b := range priceBuckets {
+		facets.PriceBuckets = append(facets.PriceBuckets, types.FacetCount{Value: b.Value, Count: prices[i]})
+	}
+
+	for _, inStock := range []bool{true, false} {
+		facets.InStock = append(facets.InStock, types.FacetCount{Value: strconv.FormatBool(inStock), Count: stock[inStock]})
+	}
+
+	return facets
+}
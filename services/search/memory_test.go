@@ -0,0 +1,149 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+var testDocuments = []types.SearchDocument{
+	{
+		ProductID:   1,
+		Name:        "Leather Wallet",
+		Description: "A slim wallet made of brown leather",
+		Price:       types.NewMoney(3000, types.DefaultCurrency),
+		InStock:     true,
+		Categories:  []types.Category{{ID: 1, Name: "Accessories"}},
+	},
+	{
+		ProductID:   2,
+		Name:        "Leather Jacket",
+		Description: "Warm jacket for the winter",
+		Price:       types.NewMoney(18000, types.DefaultCurrency),
+		InStock:     false,
+		Categories:  []types.Category{{ID: 2, Name: "Clothing"}},
+	},
+	{
+		ProductID:   3,
+		Name:        "Canvas Backpack",
+		Description: "Backpack with a leather strap",
+		Price:       types.NewMoney(6000, types.DefaultCurrency),
+		InStock:     true,
+		Categories:  []types.Category{{ID: 1, Name: "Accessories"}},
+	},
+}
+
+func search(t *testing.T, index *MemoryIndex, q types.SearchQuery) *types.SearchResult {
+	if q.Limit == 0 {
+		q.Limit = 10
+	}
+
+	result, err := index.Search(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return result
+}
+
+func productIDs(result *types.SearchResult) []int {
+	ids := []int{}
+	for _, match := range result.Matches {
+		ids = append(ids, match.ProductID)
+	}
+
+	return ids
+}
+
+// TestMemoryIndex function to test the ranking, the typo tolerance and the facets
+func TestMemoryIndex(t *testing.T) {
+	index := NewMemoryIndex()
+	if err := index.Replace(testDocuments); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Should rank the matches in the name first", func(t *testing.T) {
+		ids := productIDs(search(t, index, types.SearchQuery{Text: "leather"}))
+
+		if len(ids) != 3 || ids[2] != 3 {
+			t.Errorf("Expected the backpack last, got %v", ids)
+		}
+	})
+
+	t.Run("Should require every term of the query", func(t *testing.T) {
+		ids := productIDs(search(t, index, types.SearchQuery{Text: "leather jacket"}))
+
+		if len(ids) != 1 || ids[0] != 2 {
+			t.Errorf("Expected only the jacket, got %v", ids)
+		}
+	})
+
+	t.Run("Should tolerate typos", func(t *testing.T) {
+		ids := productIDs(search(t, index, types.SearchQuery{Text: "lether walet"}))
+
+		if len(ids) != 1 || ids[0] != 1 {
+			t.Errorf("Expected the wallet, got %v", ids)
+		}
+	})
+
+	t.Run("Should match the last term as a prefix", func(t *testing.T) {
+		ids := productIDs(search(t, index, types.SearchQuery{Text: "back"}))
+
+		if len(ids) != 1 || ids[0] != 3 {
+			t.Errorf("Expected the backpack, got %v", ids)
+		}
+	})
+
+	t.Run("Should filter and count the facets without their own filter", func(t *testing.T) {
+		result := search(t, index, types.SearchQuery{Text: "leather", CategoryID: 1, InStock: true})
+
+		if result.Total != 2 {
+			t.Errorf("Expected 2 matches, got %d", result.Total)
+		}
+
+		categories := map[string]int{}
+		for _, c := range result.Facets.Categories {
+			categories[c.Label] = c.Count
+		}
+
+		// the out of stock jacket is left out of the category counts
+		if categories["Accessories"] != 2 || categories["Clothing"] != 0 {
+			t.Errorf("Expected 2 accessories and no clothing, got %+v", result.Facets.Categories)
+		}
+
+		// the stock facet ignores the stock filter
+		if result.Facets.InStock[0].Count != 2 || result.Facets.InStock[1].Count != 0 {
+			t.Errorf("Expected 2 in stock accessories, got %+v", result.Facets.InStock)
+		}
+
+		if result.Facets.PriceBuckets[1].Count != 1 || result.Facets.PriceBuckets[2].Count != 1 {
+			t.Errorf("Expected a product in the 25-50 and 50-100 buckets, got %+v", result.Facets.PriceBuckets)
+		}
+	})
+
+	t.Run("Should paginate the matches", func(t *testing.T) {
+		result := search(t, index, types.SearchQuery{Text: "leather", Limit: 2, Offset: 2})
+
+		if result.Total != 3 || len(result.Matches) != 1 {
+			t.Errorf("Expected the last of 3 matches, got %+v", result)
+		}
+	})
+}
+
+// TestEditDistance function to test the typo distance of the terms
+func TestEditDistance(t *testing.T) {
+	for _, c := range []struct {
+		a, b     string
+		expected int
+	}{
+		{"wallet", "wallet", 0},
+		{"walet", "wallet", 1},
+		{"wlalet", "wallet", 1},
+		{"jacket", "packet", 1},
+		{"jacket", "wallet", 3},
+	} {
+		if d := editDistance(c.a, c.b, 2); min(d, 3) != min(c.expected, 3) {
+			t.Errorf("Expected the distance between %s and %s to be %d, got %d", c.a, c.b, c.expected, d)
+		}
+	}
+}
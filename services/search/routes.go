@@ -0,0 +1,197 @@
+package search
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akshtrikha/golang-ecomm/services/auth"
+	"github.com/akshtrikha/golang-ecomm/services/currency"
+	"github.com/akshtrikha/golang-ecomm/types"
+	"github.com/akshtrikha/golang-ecomm/utils"
+	"github.com/gorilla/mux"
+)
+
+// API Endpoints to search the products and to rebuild the search index
+
+const defaultPageSize = 20
+
+// Handler to the search index which is loaded from the search store
+type Handler struct {
+	index        types.SearchIndex
+	store        types.SearchStore
+	productStore types.ProductStore
+}
+
+// NewHandler constructor takes SearchIndex, SearchStore and ProductStore as dependencies
+// the index is filled from SearchStore, ProductStore resolves the current product data
+func NewHandler(index types.SearchIndex, store types.SearchStore, productStore types.ProductStore) *Handler {
+	return &Handler{index: index, store: store, productStore: productStore}
+}
+
+// RegisterRoutes func for search
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/search", auth.RequireAuth(h.handleSearch)).Methods("GET")
+	router.HandleFunc("/admin/search/reindex", auth.RequireRole(auth.RoleAdmin, h.handleReindex)).Methods("POST")
+}
+
+// Reindex replaces the documents of the index with the current products
+func (h *Handler) Reindex() error {
+	docs, err := h.store.GetSearchDocuments()
+	if err != nil {
+		return err
+	}
+
+	if err := h.index.Replace(docs); err != nil {
+		return err
+	}
+
+	log.Printf("search index rebuilt with %d products", len(docs))
+	return nil
+}
+
+// ReindexEvery rebuilds the index on the interval until the server stops.
+// Product changes show up in the search results after the next rebuild,
+// prices and archived products are always resolved from the database
+func (h *Handler) ReindexEvery(interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := h.Reindex(); err != nil {
+			log.Printf("Error rebuilding the search index, error: %+v", err)
+		}
+	}
+}
+
+func (h *Handler) handleSearch(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle GET /search endpoint hit")
+
+	// get the text and the filters from the query
+	q, err := parseSearchQuery(r.URL.Query())
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// verify the query
+	if err := utils.Validate.Struct(q); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.ValidationError("Invalid query", err))
+		return
+	}
+
+	result, err := h.index.Search(q)
+	if err != nil {
+		log.Println("Error searching the index")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response := types.SearchResponse{
+		Items:  []types.SearchHit{},
+		Total:  result.Total,
+		Limit:  q.Limit,
+		Offset: q.Offset,
+		Facets: result.Facets,
+	}
+
+	if len(result.Matches) > 0 {
+		ids := make([]int, len(result.Matches))
+		for i, match := range result.Matches {
+			ids[i] = match.ProductID
+		}
+
+		products, err := h.productStore.GetProductsByIDs(ids)
+		if err != nil {
+			log.Println("Error fetching the products from the database")
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		// show the prices in the selected currency
+		if err := currency.ConvertProducts(currency.RateFromContext(r.Context()), products); err != nil {
+			log.Println("Error converting the prices")
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		productMap := make(map[int]types.Product, len(products))
+		for _, p := range products {
+			productMap[p.ID] = p
+		}
+
+		// keep the ranking, products archived since the last rebuild are left out
+		for _, match := range result.Matches {
+			if p, ok := productMap[match.ProductID]; ok {
+				response.Items = append(response.Items, types.SearchHit{Product: p, Score: match.Score})
+			}
+		}
+	}
+
+	utils.WriteJSON(w, http.StatusOK, response)
+}
+
+func (h *Handler) handleReindex(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle POST /admin/search/reindex endpoint hit")
+
+	if err := h.Reindex(); err != nil {
+		log.Println("Error rebuilding the search index")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseSearchQuery reads the search query parameters
+// falling back to the first page of the results
+func parseSearchQuery(values url.Values) (types.SearchQuery, error) {
+	q := types.SearchQuery{
+		Text:  strings.TrimSpace(values.Get("q")),
+		Limit: defaultPageSize,
+	}
+
+	var err error
+	if v := values.Get("category"); v != "" {
+		if q.CategoryID, err = strconv.Atoi(v); err != nil {
+			return q, fmt.Errorf("invalid category: %v", v)
+		}
+	}
+
+	if v := values.Get("minPrice"); v != "" {
+		price, err := types.ParseMoney(v, types.DefaultCurrency)
+		if err != nil {
+			return q, fmt.Errorf("invalid minPrice: %v", v)
+		}
+		q.MinPrice = &price
+	}
+
+	if v := values.Get("maxPrice"); v != "" {
+		price, err := types.ParseMoney(v, types.DefaultCurrency)
+		if err != nil {
+			return q, fmt.Errorf("invalid maxPrice: %v", v)
+		}
+		q.MaxPrice = &price
+	}
+
+	if v := values.Get("inStock"); v != "" {
+		if q.InStock, err = strconv.ParseBool(v); err != nil {
+			return q, fmt.Errorf("invalid inStock: %v", v)
+		}
+	}
+
+	if v := values.Get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil {
+			return q, fmt.Errorf("invalid limit: %v", v)
+		}
+	}
+
+	if v := values.Get("offset"); v != "" {
+		if q.Offset, err = strconv.Atoi(v); err != nil {
+			return q, fmt.Errorf("invalid offset: %v", v)
+		}
+	}
+
+	return q, nil
+}
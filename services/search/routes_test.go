@@ -0,0 +1,110 @@
+package search
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/akshtrikha/golang-ecomm/services/auth"
+	"github.com/akshtrikha/golang-ecomm/types"
+	"github.com/gorilla/mux"
+)
+
+type mockSearchStore struct{}
+
+func (m *mockSearchStore) GetSearchDocuments() ([]types.SearchDocument, error) {
+	return testDocuments, nil
+}
+
+// mockProductStore has the product 2 archived since the index was built
+type mockProductStore struct {
+	types.ProductStore
+}
+
+func (m *mockProductStore) GetProductsByIDs(ids []int) ([]types.Product, error) {
+	products := []types.Product{}
+	for _, id := range ids {
+		if id != 2 {
+			products = append(products, types.Product{ID: id, Price: types.NewMoney(1000, types.DefaultCurrency)})
+		}
+	}
+
+	return products, nil
+}
+
+// TestSearchServiceHandlers function to implement testing
+func TestSearchServiceHandlers(t *testing.T) {
+	handler := NewHandler(NewMemoryIndex(), &mockSearchStore{}, &mockProductStore{})
+
+	customerToken, err := auth.GenerateJWT(2, []string{auth.RoleCustomer})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adminToken, err := auth.GenerateJWT(1, []string{auth.RoleAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(method, path string, token string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+		router.Use(auth.JWTMiddleware)
+
+		handler.RegisterRoutes(router)
+		router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	t.Run("Should not allow customers to rebuild the index", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/admin/search/reindex", customerToken)
+
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("Expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	t.Run("Should rebuild the index", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/admin/search/reindex", adminToken)
+
+		if rr.Code != http.StatusNoContent {
+			t.Errorf("Expected status code %d, got %d", http.StatusNoContent, rr.Code)
+		}
+	})
+
+	t.Run("Should fail with an invalid filter", func(t *testing.T) {
+		rr := serve(http.MethodGet, "/search?q=leather&minPrice=cheap", customerToken)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("Should return the ranked products without the archived ones", func(t *testing.T) {
+		rr := serve(http.MethodGet, "/search?q=leather", customerToken)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var response types.SearchResponse
+		json.NewDecoder(rr.Body).Decode(&response)
+
+		if len(response.Items) != 2 || response.Items[0].Product.ID != 1 || response.Items[1].Product.ID != 3 {
+			t.Errorf("Expected the wallet and the backpack, got %+v", response.Items)
+		}
+
+		if len(response.Facets.PriceBuckets) != len(priceBuckets) {
+			t.Errorf("Expected the price facet, got %+v", response.Facets)
+		}
+	})
+}
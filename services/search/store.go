@@ -0,0 +1,136 @@
+package search
+
+import (
+	"database/sql"
+
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// Store struct to hold the database object
+// This will be used to handle the database queries
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore function to return a reference to the Store struct
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// GetSearchDocuments function to load the searchable data of every active product.
+// Products with variants are in stock when any of their variants is, and
+// the categories of a product are expanded with their ancestors so
+// filtering on a category matches the products of its descendants.
+func (s *Store) GetSearchDocuments() ([]types.SearchDocument, error) {
+	categories, err := s.getCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	productCategories, err := s.getProductCategoryIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT p.id, p.name, p.description, p.price,
+			p.quantity > 0 OR EXISTS (SELECT 1 FROM product_variants v WHERE v.productId = p.id AND v.deletedAt IS NULL AND v.quantity > 0)
+		FROM products p
+		WHERE p.deletedAt IS NULL
+		ORDER BY p.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	docs := []types.SearchDocument{}
+	for rows.Next() {
+		var doc types.SearchDocument
+
+		err := rows.Scan(
+			&doc.ProductID,
+			&doc.Name,
+			&doc.Description,
+			&doc.Price,
+			&doc.InStock,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		doc.Categories = withAncestors(categories, productCategories[doc.ProductID])
+		docs = append(docs, doc)
+	}
+
+	return docs, rows.Err()
+}
+
+func (s *Store) getCategories() (map[int]types.Category, error) {
+	rows, err := s.db.Query("SELECT id, name, parentId FROM categories")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := map[int]types.Category{}
+	for rows.Next() {
+		var c types.Category
+		var parentID sql.NullInt64
+
+		if err := rows.Scan(&c.ID, &c.Name, &parentID); err != nil {
+			return nil, err
+		}
+
+		if parentID.Valid {
+			id := int(parentID.Int64)
+			c.ParentID = &id
+		}
+
+		categories[c.ID] = c
+	}
+
+	return categories, rows.Err()
+}
+
+func (s *Store) getProductCategoryIDs() (map[int][]int, error) {
+	rows, err := s.db.Query("SELECT productId, categoryId FROM product_categories")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := map[int][]int{}
+	for rows.Next() {
+		var productID, categoryID int
+		if err := rows.Scan(&productID, &categoryID); err != nil {
+			return nil, err
+		}
+
+		ids[productID] = append(ids[productID], categoryID)
+	}
+
+	return ids, rows.Err()
+}
+
+// withAncestors returns the categories with the given ids and all
+// their ancestors, each category listed once
+func withAncestors(categories map[int]types.Category, ids []int) []types.Category {
+	seen := map[int]bool{}
+	var result []types.Category
+
+	for _, id := range ids {
+		c, ok := categories[id]
+		for ok && !seen[c.ID] {
+			seen[c.ID] = true
+			result = append(result, types.Category{ID: c.ID, Name: c.Name})
+
+			if c.ParentID == nil {
+				break
+			}
+
+			c, ok = categories[*c.ParentID]
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,87 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// stopWords are left out of the index and the queries
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "for": true, "in": true,
+	"of": true, "on": true, "or": true, "the": true, "to": true, "with": true,
+}
+
+// tokenize splits the text into lower cased terms
+// on every character that is not a letter or a digit
+func tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if !stopWords[field] {
+			terms = append(terms, field)
+		}
+	}
+
+	return terms
+}
+
+// maxTypos is the number of typos tolerated in a query term,
+// short terms have to match exactly
+func maxTypos(term string) int {
+	switch n := len([]rune(term)); {
+	case n >= 8:
+		return 2
+	case n >= 4:
+		return 1
+	}
+
+	return 0
+}
+
+// editDistance returns the Damerau-Levenshtein (optimal string alignment)
+// distance between the terms, stopping at max+1 once it is exceeded
+func editDistance(a, b string, max int) int {
+	ra, rb := []rune(a), []rune(b)
+
+	if diff := len(ra) - len(rb); diff > max || -diff > max {
+		return max + 1
+	}
+
+	// three rows are enough as transpositions look two rows back
+	prevPrev := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				curr[j] = min(curr[j], prevPrev[j-2]+1)
+			}
+
+			rowMin = min(rowMin, curr[j])
+		}
+
+		if rowMin > max {
+			return max + 1
+		}
+
+		prevPrev, prev, curr = prev, curr, prevPrev
+	}
+
+	return prev[len(rb)]
+}
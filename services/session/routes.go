@@ -0,0 +1,202 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/akshtrikha/golang-ecomm/config"
+	"github.com/akshtrikha/golang-ecomm/services/auth"
+	"github.com/akshtrikha/golang-ecomm/types"
+	"github.com/akshtrikha/golang-ecomm/utils"
+	"github.com/gorilla/mux"
+)
+
+// API Endpoints to refresh the access token and to logout
+
+// Handler to the session store which will deal
+// with the database regarding refresh tokens
+type Handler struct {
+	store     types.SessionStore
+	userStore types.UserStore
+}
+
+// NewHandler constructor takes SessionStore and UserStore as dependencies
+// UserStore is used to embed the current roles of the user in new access tokens
+func NewHandler(store types.SessionStore, userStore types.UserStore) *Handler {
+	return &Handler{store: store, userStore: userStore}
+}
+
+// RegisterRoutes func for sessions
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/refresh", h.handleRefresh).Methods("POST")
+	router.HandleFunc("/logout", h.handleLogout).Methods("POST")
+}
+
+// NewSession function issues an access token and a refresh token
+// starting a new refresh token family for the user
+func NewSession(store types.SessionStore, userID int, roles []string) (*types.TokenPair, error) {
+	familyID, err := auth.GenerateTokenFamilyID()
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, hash, err := auth.GenerateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = store.CreateRefreshToken(types.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hash,
+		ExpiresAt: refreshTokenExpiry(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := auth.GenerateJWT(userID, roles)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.TokenPair{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    config.Envs.JWTExpirationInSeconds,
+	}, nil
+}
+
+func (h *Handler) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle /refresh endpoint hit")
+
+	// get the json payload
+	var payload types.RefreshTokenPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		log.Println("Payload parsing met with an error")
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// validate the json payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.ValidationError("Invalid payload", err))
+		return
+	}
+
+	current, err := h.store.GetRefreshTokenByHash(auth.HashRefreshToken(payload.RefreshToken))
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, fmt.Errorf("Invalid refresh token"))
+		return
+	}
+
+	// a rotated token presented again means it was stolen,
+	// every session of the user is revoked
+	if current.RevokedAt != nil {
+		if current.ReplacedBy != nil {
+			h.revokeAllSessions(current.UserID)
+		}
+
+		utils.WriteError(w, http.StatusUnauthorized, fmt.Errorf("Invalid refresh token"))
+		return
+	}
+
+	if time.Now().After(current.ExpiresAt) {
+		utils.WriteError(w, http.StatusUnauthorized, fmt.Errorf("Refresh token expired"))
+		return
+	}
+
+	refreshToken, hash, err := auth.GenerateRefreshToken()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	_, err = h.store.RotateRefreshToken(current.ID, types.RefreshToken{
+		UserID:    current.UserID,
+		FamilyID:  current.FamilyID,
+		TokenHash: hash,
+		ExpiresAt: refreshTokenExpiry(),
+	})
+	if errors.Is(err, ErrTokenReused) {
+		h.revokeAllSessions(current.UserID)
+		utils.WriteError(w, http.StatusUnauthorized, fmt.Errorf("Invalid refresh token"))
+		return
+	}
+	if err != nil {
+		log.Println("Error rotating the refresh token")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// the roles may have changed since the last token was issued
+	roles, err := h.userStore.GetUserRoles(current.UserID)
+	if err != nil {
+		log.Println("Error fetching the user roles")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	token, err := auth.GenerateJWT(current.UserID, roles)
+	if err != nil {
+		log.Println("Error creating jwt")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// the tokens must not be kept by the caches and the idempotency keys
+	w.Header().Set("Cache-Control", "no-store")
+	utils.WriteJSON(w, http.StatusOK, types.TokenPair{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    config.Envs.JWTExpirationInSeconds,
+	})
+}
+
+func (h *Handler) handleLogout(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle /logout endpoint hit")
+
+	// get the json payload
+	var payload types.RefreshTokenPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		log.Println("Payload parsing met with an error")
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// validate the json payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.ValidationError("Invalid payload", err))
+		return
+	}
+
+	current, err := h.store.GetRefreshTokenByHash(auth.HashRefreshToken(payload.RefreshToken))
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, fmt.Errorf("Invalid refresh token"))
+		return
+	}
+
+	// revoke every token issued since the login
+	if err := h.store.RevokeRefreshTokenFamily(current.FamilyID); err != nil {
+		log.Println("Error revoking the refresh tokens")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) revokeAllSessions(userID int) {
+	log.Printf("refresh token reuse detected for user %v, revoking all sessions", userID)
+
+	if err := h.store.RevokeUserRefreshTokens(userID); err != nil {
+		log.Printf("Error revoking the sessions of user %v, error: %+v", userID, err)
+	}
+}
+
+func refreshTokenExpiry() time.Time {
+	return time.Now().Add(time.Second * time.Duration(config.Envs.RefreshTokenExpirationInSeconds))
+}
@@ -0,0 +1,184 @@
+package session
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/akshtrikha/golang-ecomm/types"
+	"github.com/gorilla/mux"
+)
+
+// mockSessionStore keeps the refresh tokens in memory
+type mockSessionStore struct {
+	tokens []*types.RefreshToken
+}
+
+func (m *mockSessionStore) CreateRefreshToken(t types.RefreshToken) (int, error) {
+	t.ID = len(m.tokens) + 1
+	m.tokens = append(m.tokens, &t)
+
+	return t.ID, nil
+}
+
+func (m *mockSessionStore) GetRefreshTokenByHash(hash string) (*types.RefreshToken, error) {
+	for _, t := range m.tokens {
+		if t.TokenHash == hash {
+			copied := *t
+			return &copied, nil
+		}
+	}
+
+	return nil, fmt.Errorf("refresh token not found")
+}
+
+func (m *mockSessionStore) RotateRefreshToken(oldID int, next types.RefreshToken) (int, error) {
+	old := m.tokens[oldID-1]
+	if old.RevokedAt != nil {
+		return 0, ErrTokenReused
+	}
+
+	id, _ := m.CreateRefreshToken(next)
+
+	now := time.Now()
+	old.RevokedAt = &now
+	old.ReplacedBy = &id
+
+	return id, nil
+}
+
+func (m *mockSessionStore) RevokeRefreshTokenFamily(familyID string) error {
+	now := time.Now()
+	for _, t := range m.tokens {
+		if t.FamilyID == familyID && t.RevokedAt == nil {
+			t.RevokedAt = &now
+		}
+	}
+
+	return nil
+}
+
+func (m *mockSessionStore) RevokeUserRefreshTokens(userID int) error {
+	now := time.Now()
+	for _, t := range m.tokens {
+		if t.UserID == userID && t.RevokedAt == nil {
+			t.RevokedAt = &now
+		}
+	}
+
+	return nil
+}
+
+type mockUserStore struct {
+	types.UserStore
+}
+
+func (m *mockUserStore) GetUserRoles(id int) ([]string, error) {
+	return []string{"customer"}, nil
+}
+
+// TestSessionServiceHandlers function to implement testing
+func TestSessionServiceHandlers(t *testing.T) {
+	store := &mockSessionStore{}
+	handler := NewHandler(store, &mockUserStore{})
+
+	serve := func(path string, refreshToken string) *httptest.ResponseRecorder {
+		marshalled, _ := json.Marshal(types.RefreshTokenPayload{RefreshToken: refreshToken})
+
+		req, err := http.NewRequest(http.MethodPost, path, bytes.NewBuffer(marshalled))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		handler.RegisterRoutes(router)
+		router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	refresh := func(refreshToken string) (*types.TokenPair, int) {
+		rr := serve("/refresh", refreshToken)
+
+		var tokens types.TokenPair
+		json.NewDecoder(rr.Body).Decode(&tokens)
+
+		return &tokens, rr.Code
+	}
+
+	t.Run("Should rotate the refresh token", func(t *testing.T) {
+		login, err := NewSession(store, 1, []string{"customer"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := serve("/refresh", login.RefreshToken)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var tokens types.TokenPair
+		json.NewDecoder(rr.Body).Decode(&tokens)
+
+		if tokens.Token == "" || tokens.RefreshToken == "" || tokens.RefreshToken == login.RefreshToken {
+			t.Errorf("Expected a new token pair, got %+v", tokens)
+		}
+
+		if rr.Header().Get("Cache-Control") != "no-store" {
+			t.Errorf("Expected the tokens not to be stored, got %q", rr.Header().Get("Cache-Control"))
+		}
+	})
+
+	t.Run("Should revoke all sessions when a rotated token is reused", func(t *testing.T) {
+		login, _ := NewSession(store, 2, []string{"customer"})
+		other, _ := NewSession(store, 2, []string{"customer"})
+
+		rotated, code := refresh(login.RefreshToken)
+		if code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, code)
+		}
+
+		if _, code := refresh(login.RefreshToken); code != http.StatusUnauthorized {
+			t.Errorf("Expected status code %d, got %d", http.StatusUnauthorized, code)
+		}
+
+		if _, code := refresh(rotated.RefreshToken); code != http.StatusUnauthorized {
+			t.Errorf("Expected the rotated token to be revoked, got %d", code)
+		}
+
+		if _, code := refresh(other.RefreshToken); code != http.StatusUnauthorized {
+			t.Errorf("Expected the other session to be revoked, got %d", code)
+		}
+	})
+
+	t.Run("Should revoke the refresh token family on logout", func(t *testing.T) {
+		login, _ := NewSession(store, 3, []string{"customer"})
+		other, _ := NewSession(store, 3, []string{"customer"})
+
+		rotated, _ := refresh(login.RefreshToken)
+
+		if rr := serve("/logout", rotated.RefreshToken); rr.Code != http.StatusNoContent {
+			t.Errorf("Expected status code %d, got %d", http.StatusNoContent, rr.Code)
+		}
+
+		if _, code := refresh(rotated.RefreshToken); code != http.StatusUnauthorized {
+			t.Errorf("Expected status code %d, got %d", http.StatusUnauthorized, code)
+		}
+
+		if _, code := refresh(other.RefreshToken); code != http.StatusOK {
+			t.Errorf("Expected the other session to be kept, got %d", code)
+		}
+	})
+
+	t.Run("Should fail for an unknown refresh token", func(t *testing.T) {
+		if _, code := refresh("unknown"); code != http.StatusUnauthorized {
+			t.Errorf("Expected status code %d, got %d", http.StatusUnauthorized, code)
+		}
+	})
+}
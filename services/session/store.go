@@ -0,0 +1,151 @@
+package session
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// ErrTokenReused is returned when a refresh token that was already
+// rotated is presented again
+var ErrTokenReused = errors.New("refresh token reused")
+
+// Store struct to hold the database object
+// This will be used to handle the database queries
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore function to return a reference to the Store struct
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// CreateRefreshToken function to store a new refresh token
+func (s *Store) CreateRefreshToken(t types.RefreshToken) (int, error) {
+	result, err := s.db.Exec("INSERT INTO refresh_tokens (userId, familyId, tokenHash, expiresAt) VALUES (?, ?, ?, ?)", t.UserID, t.FamilyID, t.TokenHash, t.ExpiresAt)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// GetRefreshTokenByHash function to find a refresh token by its hash
+func (s *Store) GetRefreshTokenByHash(hash string) (*types.RefreshToken, error) {
+	rows, err := s.db.Query("SELECT id, userId, familyId, tokenHash, expiresAt, revokedAt, replacedBy, createdAt FROM refresh_tokens WHERE tokenHash = ?", hash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	t := new(types.RefreshToken)
+	for rows.Next() {
+		t, err = scanRowIntoRefreshToken(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if t.ID == 0 {
+		return nil, fmt.Errorf("refresh token not found")
+	}
+
+	return t, nil
+}
+
+// RotateRefreshToken function to revoke the refresh token and store the
+// token replacing it in the same transaction. ErrTokenReused is returned
+// when the token was already revoked by a concurrent rotation.
+func (s *Store) RotateRefreshToken(oldID int, next types.RefreshToken) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT INTO refresh_tokens (userId, familyId, tokenHash, expiresAt) VALUES (?, ?, ?, ?)", next.UserID, next.FamilyID, next.TokenHash, next.ExpiresAt)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	result, err = tx.Exec("UPDATE refresh_tokens SET revokedAt = CURRENT_TIMESTAMP, replacedBy = ? WHERE id = ? AND revokedAt IS NULL", id, oldID)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if affected != 1 {
+		return 0, ErrTokenReused
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// RevokeRefreshTokenFamily function to revoke every token issued for the same login
+func (s *Store) RevokeRefreshTokenFamily(familyID string) error {
+	_, err := s.db.Exec("UPDATE refresh_tokens SET revokedAt = CURRENT_TIMESTAMP WHERE familyId = ? AND revokedAt IS NULL", familyID)
+
+	return err
+}
+
+// RevokeUserRefreshTokens function to revoke every session of the user
+func (s *Store) RevokeUserRefreshTokens(userID int) error {
+	_, err := s.db.Exec("UPDATE refresh_tokens SET revokedAt = CURRENT_TIMESTAMP WHERE userId = ? AND revokedAt IS NULL", userID)
+
+	return err
+}
+
+func scanRowIntoRefreshToken(rows *sql.Rows) (*types.RefreshToken, error) {
+	t := new(types.RefreshToken)
+
+	var revokedAt sql.NullTime
+	var replacedBy sql.NullInt64
+
+	err := rows.Scan(
+		&t.ID,
+		&t.UserID,
+		&t.FamilyID,
+		&t.TokenHash,
+		&t.ExpiresAt,
+		&revokedAt,
+		&replacedBy,
+		&t.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if revokedAt.Valid {
+		t.RevokedAt = &revokedAt.Time
+	}
+
+	if replacedBy.Valid {
+		id := int(replacedBy.Int64)
+		t.ReplacedBy = &id
+	}
+
+	return t, nil
+}
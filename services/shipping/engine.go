@@ -0,0 +1,234 @@
+package shipping
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/akshtrikha/golang-ecomm/services/currency"
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// ErrMethodRequired is returned when an order to a destination
+// with shipping methods does not select one of them
+var ErrMethodRequired = errors.New("shipping method required")
+
+// ErrMethodUnavailable is returned when the selected shipping method
+// does not ship to the destination or can not ship the order
+var ErrMethodUnavailable = errors.New("shipping method unavailable")
+
+// VolumetricDivisor converts the volume of a package in cubic millimetres to
+// its volumetric weight in grams, the usual 5000 cm³ per kg of the carriers
+const VolumetricDivisor = 5000
+
+// ChargeableWeight returns the weight in grams a unit of the product is
+// shipped as, the greater of its weight and of its volumetric weight
+func ChargeableWeight(p types.Product) int {
+	volumetric := p.Length * p.Width * p.Height / VolumetricDivisor
+	if volumetric > p.Weight {
+		return volumetric
+	}
+
+	return p.Weight
+}
+
+// Validate checks the method has the settings its type needs and sorts its tiers
+func Validate(m *types.ShippingMethod) error {
+	switch m.Type {
+	case types.ShippingFlat:
+		if m.Amount == nil || len(m.Tiers) > 0 {
+			return fmt.Errorf("flat methods need an amount and no tiers")
+		}
+	case types.ShippingWeight:
+		if m.Amount != nil || len(m.Tiers) == 0 {
+			return fmt.Errorf("weight based methods need tiers and no amount")
+		}
+
+		sort.Slice(m.Tiers, func(i, j int) bool { return m.Tiers[i].MaxWeight < m.Tiers[j].MaxWeight })
+		for i, tier := range m.Tiers {
+			if tier.MaxWeight <= 0 || tier.MinSubtotal != nil {
+				return fmt.Errorf("the tiers of weight based methods need a maxWeight and no minSubtotal")
+			}
+
+			if i > 0 && tier.MaxWeight == m.Tiers[i-1].MaxWeight {
+				return fmt.Errorf("the tiers must have different maxWeight")
+			}
+		}
+	case types.ShippingPrice:
+		if m.Amount != nil || len(m.Tiers) == 0 {
+			return fmt.Errorf("price tiered methods need tiers and no amount")
+		}
+
+		for _, tier := range m.Tiers {
+			if tier.MinSubtotal == nil || tier.MaxWeight != 0 {
+				return fmt.Errorf("the tiers of price tiered methods need a minSubtotal and no maxWeight")
+			}
+		}
+
+		sort.Slice(m.Tiers, func(i, j int) bool { return m.Tiers[i].MinSubtotal.Amount < m.Tiers[j].MinSubtotal.Amount })
+		for i := 1; i < len(m.Tiers); i++ {
+			if m.Tiers[i].MinSubtotal.Amount == m.Tiers[i-1].MinSubtotal.Amount {
+				return fmt.Errorf("the tiers must have different minSubtotal")
+			}
+		}
+	default:
+		return fmt.Errorf("unknown shipping method type %q", m.Type)
+	}
+
+	for _, amount := range amounts(m) {
+		if amount.Currency != types.DefaultCurrency {
+			return fmt.Errorf("shipping amounts must be in the base currency %v", types.DefaultCurrency)
+		}
+	}
+
+	return nil
+}
+
+// Convert returns the method with its amounts converted with the exchange rate
+func Convert(rate types.ExchangeRate, m types.ShippingMethod) (types.ShippingMethod, error) {
+	// copy the tiers so the method read from the store is left untouched
+	m.Tiers = append([]types.ShippingTier(nil), m.Tiers...)
+	for i := range m.Tiers {
+		if m.Tiers[i].MinSubtotal != nil {
+			minSubtotal := *m.Tiers[i].MinSubtotal
+			m.Tiers[i].MinSubtotal = &minSubtotal
+		}
+	}
+
+	for _, amount := range amounts(&m) {
+		converted, err := currency.Convert(rate, *amount)
+		if err != nil {
+			return m, err
+		}
+		*amount = converted
+	}
+
+	return m, nil
+}
+
+// amounts returns pointers to every amount of the method
+func amounts(m *types.ShippingMethod) []*types.Money {
+	var list []*types.Money
+	for _, amount := range []*types.Money{m.Amount, m.FreeOver} {
+		if amount != nil {
+			list = append(list, amount)
+		}
+	}
+
+	for i := range m.Tiers {
+		list = append(list, &m.Tiers[i].Amount)
+		if m.Tiers[i].MinSubtotal != nil {
+			list = append(list, m.Tiers[i].MinSubtotal)
+		}
+	}
+
+	return list
+}
+
+// Cost computes the cost of shipping the weight in grams with the method for
+// an order of the subtotal, in the currency of the method. The bool is false
+// when the method can not ship the order: heavier than its heaviest tier or
+// below the subtotal of its cheapest tier. Reaching FreeOver makes it free
+func Cost(m types.ShippingMethod, weight int, subtotal types.Money) (types.Money, bool, error) {
+	zero := types.NewMoney(0, subtotal.Currency)
+
+	var cost *types.Money
+	switch m.Type {
+	case types.ShippingFlat:
+		cost = m.Amount
+	case types.ShippingWeight:
+		// the tiers are sorted by weight, the first one that holds the weight applies
+		for i := range m.Tiers {
+			if weight <= m.Tiers[i].MaxWeight {
+				cost = &m.Tiers[i].Amount
+				break
+			}
+		}
+	case types.ShippingPrice:
+		// the tiers are sorted by subtotal, the last one reached applies
+		for i := range m.Tiers {
+			cmp, err := subtotal.Cmp(*m.Tiers[i].MinSubtotal)
+			if err != nil {
+				return zero, false, err
+			}
+
+			if cmp >= 0 {
+				cost = &m.Tiers[i].Amount
+			}
+		}
+	}
+
+	if cost == nil {
+		return zero, false, nil
+	}
+
+	if m.FreeOver != nil {
+		cmp, err := subtotal.Cmp(*m.FreeOver)
+		if err != nil {
+			return zero, false, err
+		}
+
+		if cmp >= 0 {
+			return zero, true, nil
+		}
+	}
+
+	if !cost.SameCurrency(subtotal) {
+		return zero, false, fmt.Errorf("shipping method %d is not in %v", m.ID, subtotal.Currency)
+	}
+
+	return *cost, true, nil
+}
+
+// Quote prices the active methods able to ship the weight in grams for an order
+// of the subtotal, the subtotal after the discounts. The methods must already be
+// converted to the currency of the subtotal. Every method is free with
+// freeShipping, the quotes are sorted from the cheapest
+func Quote(methods []types.ShippingMethod, weight int, subtotal types.Money, freeShipping bool) ([]types.ShippingQuote, error) {
+	quotes := []types.ShippingQuote{}
+	for _, m := range methods {
+		if !m.Active {
+			continue
+		}
+
+		cost, ok, err := Cost(m, weight, subtotal)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			continue
+		}
+
+		if freeShipping {
+			cost = types.NewMoney(0, subtotal.Currency)
+		}
+
+		quotes = append(quotes, types.ShippingQuote{MethodID: m.ID, Name: m.Name, Type: m.Type, Amount: cost})
+	}
+
+	sort.SliceStable(quotes, func(i, j int) bool { return quotes[i].Amount.Amount < quotes[j].Amount.Amount })
+
+	return quotes, nil
+}
+
+// Select returns the quote of the selected method. Destinations without any
+// method do not charge shipping and nil is returned when none is selected,
+// otherwise a method must be selected and it must be quoted
+func Select(quotes []types.ShippingQuote, methodID *int) (*types.ShippingQuote, error) {
+	if methodID == nil {
+		if len(quotes) > 0 {
+			return nil, ErrMethodRequired
+		}
+
+		return nil, nil
+	}
+
+	for i := range quotes {
+		if quotes[i].MethodID == *methodID {
+			return &quotes[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: method %d does not ship this order to the destination", ErrMethodUnavailable, *methodID)
+}
@@ -0,0 +1,182 @@
+package shipping
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+func usd(amount int64) types.Money {
+	return types.NewMoney(amount, types.DefaultCurrency)
+}
+
+func money(amount int64) *types.Money {
+	m := usd(amount)
+	return &m
+}
+
+// methods of a flat rate of 4.99 free over 50.00, an express rate of 9.99 up
+// to 1kg and 14.99 up to 5kg and a rate of 7.99 under 25.00 and 2.99 above
+func methods() []types.ShippingMethod {
+	return []types.ShippingMethod{
+		{ID: 1, Name: "Standard", Type: types.ShippingFlat, Amount: money(499), FreeOver: money(5000), Active: true},
+		{ID: 2, Name: "Express", Type: types.ShippingWeight, Active: true, Tiers: []types.ShippingTier{
+			{MaxWeight: 1000, Amount: usd(999)},
+			{MaxWeight: 5000, Amount: usd(1499)},
+		}},
+		{ID: 3, Name: "Economy", Type: types.ShippingPrice, Active: true, Tiers: []types.ShippingTier{
+			{MinSubtotal: money(0), Amount: usd(799)},
+			{MinSubtotal: money(2500), Amount: usd(299)},
+		}},
+	}
+}
+
+// TestChargeableWeight function to test the volumetric weight of the products
+func TestChargeableWeight(t *testing.T) {
+	// a box of 400x300x200mm weighs 4.8kg by volume
+	if weight := ChargeableWeight(types.Product{Weight: 1000, Length: 400, Width: 300, Height: 200}); weight != 4800 {
+		t.Errorf("Expected the volumetric weight of 4800g, got %d", weight)
+	}
+
+	if weight := ChargeableWeight(types.Product{Weight: 1000, Length: 100, Width: 100, Height: 100}); weight != 1000 {
+		t.Errorf("Expected the weight of 1000g, got %d", weight)
+	}
+}
+
+// TestQuote function to test the costs of the shipping methods
+func TestQuote(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		weight   int
+		subtotal int64
+		amounts  map[int]int64
+	}{
+		{"light and cheap", 800, 2000, map[int]int64{1: 499, 2: 999, 3: 799}},
+		{"heavy", 3000, 3000, map[int]int64{1: 499, 2: 1499, 3: 299}},
+		{"too heavy for express", 6000, 3000, map[int]int64{1: 499, 3: 299}},
+		{"free over the threshold", 800, 5000, map[int]int64{1: 0, 2: 999, 3: 299}},
+	} {
+		quotes, err := Quote(methods(), c.weight, usd(c.subtotal), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(quotes) != len(c.amounts) {
+			t.Errorf("%s: expected %d quotes, got %+v", c.name, len(c.amounts), quotes)
+			continue
+		}
+
+		for i, quote := range quotes {
+			if quote.Amount != usd(c.amounts[quote.MethodID]) {
+				t.Errorf("%s: expected %d cents for %s, got %v", c.name, c.amounts[quote.MethodID], quote.Name, quote.Amount)
+			}
+
+			if i > 0 && quote.Amount.Amount < quotes[i-1].Amount.Amount {
+				t.Errorf("%s: expected the quotes from the cheapest, got %+v", c.name, quotes)
+			}
+		}
+	}
+
+	t.Run("Should make every method free with free shipping", func(t *testing.T) {
+		quotes, err := Quote(methods(), 800, usd(2000), true)
+		if err != nil || len(quotes) != 3 {
+			t.Fatalf("Expected 3 quotes, got %+v, error: %v", quotes, err)
+		}
+
+		for _, quote := range quotes {
+			if !quote.Amount.IsZero() {
+				t.Errorf("Expected %s to be free, got %v", quote.Name, quote.Amount)
+			}
+		}
+	})
+
+	t.Run("Should leave out the inactive methods", func(t *testing.T) {
+		list := methods()
+		list[0].Active = false
+
+		quotes, _ := Quote(list, 800, usd(2000), false)
+		if len(quotes) != 2 || quotes[0].MethodID == 1 || quotes[1].MethodID == 1 {
+			t.Errorf("Expected the standard method to be left out, got %+v", quotes)
+		}
+	})
+
+	t.Run("Should quote in the currency of the order", func(t *testing.T) {
+		rate := types.ExchangeRate{Currency: "EUR", Rate: "0.9"}
+
+		list := methods()
+		for i := range list {
+			var err error
+			if list[i], err = Convert(rate, list[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// 45.00 EUR is over the converted threshold of 45.00 EUR
+		quotes, err := Quote(list, 800, types.NewMoney(4500, "EUR"), false)
+		if err != nil || quotes[0].MethodID != 1 || quotes[0].Amount != types.NewMoney(0, "EUR") {
+			t.Errorf("Expected free standard shipping, got %+v, error: %v", quotes, err)
+		}
+
+		if methods()[0].FreeOver.Currency != types.DefaultCurrency {
+			t.Error("Expected the methods to be left in the base currency")
+		}
+	})
+}
+
+// TestSelect function to test the selection of the shipping method of an order
+func TestSelect(t *testing.T) {
+	quotes, _ := Quote(methods(), 800, usd(2000), false)
+
+	express, unknown := 2, 9
+	if quote, err := Select(quotes, &express); err != nil || quote.Amount != usd(999) {
+		t.Errorf("Expected the express quote, got %+v, error: %v", quote, err)
+	}
+
+	if _, err := Select(quotes, nil); !errors.Is(err, ErrMethodRequired) {
+		t.Errorf("Expected a shipping method to be required, got %v", err)
+	}
+
+	if _, err := Select(quotes, &unknown); !errors.Is(err, ErrMethodUnavailable) {
+		t.Errorf("Expected the shipping method to be unavailable, got %v", err)
+	}
+
+	if quote, err := Select([]types.ShippingQuote{}, nil); quote != nil || err != nil {
+		t.Errorf("Expected no shipping without methods, got %+v, error: %v", quote, err)
+	}
+}
+
+// TestValidate function to test the settings of the shipping methods
+func TestValidate(t *testing.T) {
+	for _, m := range methods() {
+		if err := Validate(&m); err != nil {
+			t.Errorf("Expected %s to be valid, got %v", m.Name, err)
+		}
+	}
+
+	eur := types.NewMoney(499, "EUR")
+	for _, m := range []types.ShippingMethod{
+		{Type: types.ShippingFlat},
+		{Type: types.ShippingFlat, Amount: &eur},
+		{Type: types.ShippingWeight, Amount: money(499)},
+		{Type: types.ShippingWeight, Tiers: []types.ShippingTier{{Amount: usd(499)}}},
+		{Type: types.ShippingWeight, Tiers: []types.ShippingTier{{MaxWeight: 1000, Amount: usd(499)}, {MaxWeight: 1000, Amount: usd(599)}}},
+		{Type: types.ShippingPrice, Tiers: []types.ShippingTier{{MaxWeight: 1000, Amount: usd(499)}}},
+		{Type: "pigeon", Amount: money(499)},
+	} {
+		if err := Validate(&m); err == nil {
+			t.Errorf("Expected %+v to be invalid", m)
+		}
+	}
+
+	t.Run("Should sort the tiers", func(t *testing.T) {
+		m := types.ShippingMethod{Type: types.ShippingWeight, Tiers: []types.ShippingTier{
+			{MaxWeight: 5000, Amount: usd(1499)},
+			{MaxWeight: 1000, Amount: usd(999)},
+		}}
+
+		if err := Validate(&m); err != nil || m.Tiers[0].MaxWeight != 1000 {
+			t.Errorf("Expected the tiers sorted by weight, got %+v, error: %v", m.Tiers, err)
+		}
+	})
+}
@@ -0,0 +1,313 @@
+package shipping
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/akshtrikha/golang-ecomm/services/auth"
+	"github.com/akshtrikha/golang-ecomm/services/tax"
+	"github.com/akshtrikha/golang-ecomm/types"
+	"github.com/akshtrikha/golang-ecomm/utils"
+	"github.com/gorilla/mux"
+)
+
+// API Endpoints for the admins to manage the shipping zones and their methods
+
+// Handler to the shipping store which will deal
+// with the database regarding shipping zones and methods
+type Handler struct {
+	store types.ShippingStore
+}
+
+// NewHandler constructor takes ShippingStore as dependency
+func NewHandler(store types.ShippingStore) *Handler {
+	return &Handler{store: store}
+}
+
+// RegisterRoutes func for shipping zones and methods
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/shipping-zones", auth.RequireRole(auth.RoleAdmin, h.handleGetZones)).Methods("GET")
+	router.HandleFunc("/admin/shipping-zones", auth.RequireRole(auth.RoleAdmin, h.handleCreateZone)).Methods("POST")
+	router.HandleFunc("/admin/shipping-zones/{id:[0-9]+}", auth.RequireRole(auth.RoleAdmin, h.handleUpdateZone)).Methods("PUT")
+	router.HandleFunc("/admin/shipping-zones/{id:[0-9]+}", auth.RequireRole(auth.RoleAdmin, h.handleDeleteZone)).Methods("DELETE")
+	router.HandleFunc("/admin/shipping-zones/{id:[0-9]+}/methods", auth.RequireRole(auth.RoleAdmin, h.handleCreateMethod)).Methods("POST")
+	router.HandleFunc("/admin/shipping-methods/{id:[0-9]+}", auth.RequireRole(auth.RoleAdmin, h.handleUpdateMethod)).Methods("PUT")
+	router.HandleFunc("/admin/shipping-methods/{id:[0-9]+}", auth.RequireRole(auth.RoleAdmin, h.handleDeleteMethod)).Methods("DELETE")
+}
+
+func (h *Handler) handleGetZones(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle GET /admin/shipping-zones endpoint hit")
+
+	zones, err := h.store.GetShippingZones()
+	if err != nil {
+		log.Println("Error fetching the shipping zones from the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, zones)
+}
+
+func (h *Handler) handleCreateZone(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle POST /admin/shipping-zones endpoint hit")
+
+	zone, status, err := h.parseZone(r, 0)
+	if err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	zoneID, err := h.store.CreateShippingZone(*zone)
+	if err != nil {
+		log.Println("Error adding the shipping zone to the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	log.Printf("Shipping Zone Added %v", zoneID)
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]int{"id": zoneID})
+}
+
+func (h *Handler) handleUpdateZone(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle PUT /admin/shipping-zones/{id} endpoint hit")
+
+	zoneID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid shipping zone id"))
+		return
+	}
+
+	_, err = h.store.GetShippingZoneByID(zoneID)
+	if errors.Is(err, ErrZoneNotFound) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("shipping zone with id: %v not found", zoneID))
+		return
+	}
+	if err != nil {
+		log.Printf("Error finding the shipping zone %v, error: %+v", zoneID, err)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	zone, status, err := h.parseZone(r, zoneID)
+	if err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	if err := h.store.UpdateShippingZone(*zone); err != nil {
+		log.Println("Error updating the shipping zone in the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, zone)
+}
+
+func (h *Handler) handleDeleteZone(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle DELETE /admin/shipping-zones/{id} endpoint hit")
+
+	zoneID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid shipping zone id"))
+		return
+	}
+
+	err = h.store.DeleteShippingZone(zoneID)
+	if errors.Is(err, ErrZoneNotFound) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("shipping zone with id: %v not found", zoneID))
+		return
+	}
+	if err != nil {
+		log.Printf("Error deleting the shipping zone %v, error: %+v", zoneID, err)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) handleCreateMethod(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle POST /admin/shipping-zones/{id}/methods endpoint hit")
+
+	zoneID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid shipping zone id"))
+		return
+	}
+
+	_, err = h.store.GetShippingZoneByID(zoneID)
+	if errors.Is(err, ErrZoneNotFound) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("shipping zone with id: %v not found", zoneID))
+		return
+	}
+	if err != nil {
+		log.Printf("Error finding the shipping zone %v, error: %+v", zoneID, err)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	method, err := parseMethod(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	method.ZoneID = zoneID
+
+	methodID, err := h.store.CreateShippingMethod(*method)
+	if err != nil {
+		log.Println("Error adding the shipping method to the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	log.Printf("Shipping Method Added %v", methodID)
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]int{"id": methodID})
+}
+
+func (h *Handler) handleUpdateMethod(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle PUT /admin/shipping-methods/{id} endpoint hit")
+
+	methodID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid shipping method id"))
+		return
+	}
+
+	existing, err := h.store.GetShippingMethodByID(methodID)
+	if errors.Is(err, ErrMethodNotFound) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("shipping method with id: %v not found", methodID))
+		return
+	}
+	if err != nil {
+		log.Printf("Error finding the shipping method %v, error: %+v", methodID, err)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	method, err := parseMethod(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	method.ID, method.ZoneID, method.CreatedAt = existing.ID, existing.ZoneID, existing.CreatedAt
+
+	if err := h.store.UpdateShippingMethod(*method); err != nil {
+		log.Println("Error updating the shipping method in the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, method)
+}
+
+func (h *Handler) handleDeleteMethod(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle DELETE /admin/shipping-methods/{id} endpoint hit")
+
+	methodID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid shipping method id"))
+		return
+	}
+
+	err = h.store.DeleteShippingMethod(methodID)
+	if errors.Is(err, ErrMethodNotFound) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("shipping method with id: %v not found", methodID))
+		return
+	}
+	if err != nil {
+		log.Printf("Error deleting the shipping method %v, error: %+v", methodID, err)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseZone reads and validates the zone of the payload, a destination
+// can only belong to one zone, the one with the id.
+// The returned status code is meant to be used when an error is returned.
+func (h *Handler) parseZone(r *http.Request, id int) (*types.ShippingZone, int, error) {
+	// get the json payload
+	var payload types.ShippingZonePayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		log.Println("Payload parsing met with an error")
+		return nil, http.StatusBadRequest, err
+	}
+
+	// country codes are case insensitive
+	for i := range payload.Regions {
+		payload.Regions[i].Country = strings.ToUpper(strings.TrimSpace(payload.Regions[i].Country))
+		payload.Regions[i].Region = tax.NormalizeRegion(payload.Regions[i].Region)
+	}
+
+	// validate the json payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		return nil, http.StatusBadRequest, utils.ValidationError("Invalid payload", err)
+	}
+
+	zone := &types.ShippingZone{ID: id, Name: strings.TrimSpace(payload.Name)}
+
+	seen := make(map[types.ShippingRegion]bool, len(payload.Regions))
+	for _, region := range payload.Regions {
+		if !seen[region] {
+			seen[region] = true
+			zone.Regions = append(zone.Regions, region)
+		}
+	}
+
+	zones, err := h.store.GetShippingZones()
+	if err != nil {
+		log.Println("Error fetching the shipping zones from the database")
+		return nil, http.StatusInternalServerError, err
+	}
+
+	for _, other := range zones {
+		if other.ID == id {
+			continue
+		}
+
+		for _, region := range other.Regions {
+			if seen[region] {
+				return nil, http.StatusConflict, fmt.Errorf("%s %s already belongs to the shipping zone %s", region.Country, region.Region, other.Name)
+			}
+		}
+	}
+
+	return zone, http.StatusOK, nil
+}
+
+// parseMethod reads and validates the shipping method of the payload
+func parseMethod(r *http.Request) (*types.ShippingMethod, error) {
+	// get the json payload
+	var payload types.ShippingMethodPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		log.Println("Payload parsing met with an error")
+		return nil, err
+	}
+
+	// validate the json payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		return nil, utils.ValidationError("Invalid payload", err)
+	}
+
+	method := &types.ShippingMethod{
+		Name:     strings.TrimSpace(payload.Name),
+		Type:     payload.Type,
+		Amount:   payload.Amount,
+		Tiers:    payload.Tiers,
+		FreeOver: payload.FreeOver,
+		Active:   payload.Active == nil || *payload.Active,
+	}
+
+	if err := Validate(method); err != nil {
+		return nil, err
+	}
+
+	return method, nil
+}
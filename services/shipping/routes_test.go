@@ -0,0 +1,245 @@
+package shipping
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/akshtrikha/golang-ecomm/services/auth"
+	"github.com/akshtrikha/golang-ecomm/types"
+	"github.com/gorilla/mux"
+)
+
+// mockShippingStore keeps the zones and the methods in memory
+type mockShippingStore struct {
+	zones   map[int]types.ShippingZone
+	methods map[int]types.ShippingMethod
+}
+
+func (m *mockShippingStore) GetShippingZones() ([]types.ShippingZone, error) {
+	zones := []types.ShippingZone{}
+	for id := range m.zones {
+		zone, _ := m.GetShippingZoneByID(id)
+		zones = append(zones, *zone)
+	}
+
+	return zones, nil
+}
+
+func (m *mockShippingStore) GetShippingZoneByID(id int) (*types.ShippingZone, error) {
+	zone, ok := m.zones[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrZoneNotFound, id)
+	}
+
+	zone.Methods = []types.ShippingMethod{}
+	for _, method := range m.methods {
+		if method.ZoneID == id {
+			zone.Methods = append(zone.Methods, method)
+		}
+	}
+
+	return &zone, nil
+}
+
+func (m *mockShippingStore) CreateShippingZone(zone types.ShippingZone) (int, error) {
+	zone.ID = len(m.zones) + 1
+	m.zones[zone.ID] = zone
+
+	return zone.ID, nil
+}
+
+func (m *mockShippingStore) UpdateShippingZone(zone types.ShippingZone) error {
+	m.zones[zone.ID] = zone
+	return nil
+}
+
+func (m *mockShippingStore) DeleteShippingZone(id int) error {
+	if _, ok := m.zones[id]; !ok {
+		return fmt.Errorf("%w: %v", ErrZoneNotFound, id)
+	}
+
+	delete(m.zones, id)
+	return nil
+}
+
+func (m *mockShippingStore) GetShippingMethodByID(id int) (*types.ShippingMethod, error) {
+	method, ok := m.methods[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrMethodNotFound, id)
+	}
+
+	return &method, nil
+}
+
+func (m *mockShippingStore) CreateShippingMethod(method types.ShippingMethod) (int, error) {
+	method.ID = len(m.methods) + 1
+	m.methods[method.ID] = method
+
+	return method.ID, nil
+}
+
+func (m *mockShippingStore) UpdateShippingMethod(method types.ShippingMethod) error {
+	m.methods[method.ID] = method
+	return nil
+}
+
+func (m *mockShippingStore) DeleteShippingMethod(id int) error {
+	if _, ok := m.methods[id]; !ok {
+		return fmt.Errorf("%w: %v", ErrMethodNotFound, id)
+	}
+
+	delete(m.methods, id)
+	return nil
+}
+
+func (m *mockShippingStore) GetShippingMethodsFor(country string, region string) ([]types.ShippingMethod, error) {
+	return []types.ShippingMethod{}, nil
+}
+
+// TestShippingServiceHandlers function to implement testing
+func TestShippingServiceHandlers(t *testing.T) {
+	store := &mockShippingStore{zones: map[int]types.ShippingZone{}, methods: map[int]types.ShippingMethod{}}
+	handler := NewHandler(store)
+
+	adminToken, err := auth.GenerateJWT(1, []string{auth.RoleAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	customerToken, err := auth.GenerateJWT(2, []string{auth.RoleCustomer})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(method, path string, body string, token string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+		router.Use(auth.JWTMiddleware)
+
+		handler.RegisterRoutes(router)
+		router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	t.Run("Should not allow customers to create shipping zones", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/admin/shipping-zones", `{"name":"EU","regions":[{"country":"DE"}]}`, customerToken)
+
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("Expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	t.Run("Should fail if the shipping zone is invalid", func(t *testing.T) {
+		for _, body := range []string{
+			`{"name":"EU","regions":[]}`,
+			`{"name":"EU","regions":[{"country":"XX"}]}`,
+			`{"regions":[{"country":"DE"}]}`,
+		} {
+			rr := serve(http.MethodPost, "/admin/shipping-zones", body, adminToken)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("Expected status code %d for %s, got %d", http.StatusBadRequest, body, rr.Code)
+			}
+		}
+	})
+
+	t.Run("Should create the shipping zone", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/admin/shipping-zones", `{"name":"Europe","regions":[{"country":"de"},{"country":"FR"},{"country":"DE"}]}`, adminToken)
+
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		if regions := store.zones[1].Regions; len(regions) != 2 || regions[0].Country != "DE" {
+			t.Errorf("Expected the regions DE and FR, got %+v", regions)
+		}
+	})
+
+	t.Run("Should fail if the destination belongs to another zone", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/admin/shipping-zones", `{"name":"Germany","regions":[{"country":"DE"}]}`, adminToken)
+
+		if rr.Code != http.StatusConflict {
+			t.Errorf("Expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+
+		// a region of the country can have its own zone
+		if rr := serve(http.MethodPost, "/admin/shipping-zones", `{"name":"Bavaria","regions":[{"country":"DE","region":"by"}]}`, adminToken); rr.Code != http.StatusCreated {
+			t.Errorf("Expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+	})
+
+	t.Run("Should fail if the shipping method is invalid", func(t *testing.T) {
+		for _, body := range []string{
+			`{"name":"Standard","type":"flat"}`,
+			`{"name":"Standard","type":"pigeon","amount":"4.99"}`,
+			`{"name":"Standard","type":"weight","tiers":[{"amount":"4.99"}]}`,
+			`{"name":"Standard","type":"price","tiers":[{"minSubtotal":"-1","amount":"4.99"}]}`,
+			`{"name":"Standard","type":"flat","amount":"4.99","freeOver":"0"}`,
+		} {
+			rr := serve(http.MethodPost, "/admin/shipping-zones/1/methods", body, adminToken)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("Expected status code %d for %s, got %d", http.StatusBadRequest, body, rr.Code)
+			}
+		}
+	})
+
+	t.Run("Should fail to add a method to a zone that does not exist", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/admin/shipping-zones/9/methods", `{"name":"Standard","type":"flat","amount":"4.99"}`, adminToken)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("Should add the weight based method to the zone", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/admin/shipping-zones/1/methods", `{"name":"Express","type":"weight","tiers":[{"maxWeight":5000,"amount":"14.99"},{"maxWeight":1000,"amount":"9.99"}]}`, adminToken)
+
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		method := store.methods[1]
+		if method.ZoneID != 1 || !method.Active || len(method.Tiers) != 2 || method.Tiers[0].MaxWeight != 1000 {
+			t.Errorf("Expected an active method with sorted tiers, got %+v", method)
+		}
+	})
+
+	t.Run("Should replace the shipping method", func(t *testing.T) {
+		rr := serve(http.MethodPut, "/admin/shipping-methods/1", `{"name":"Standard","type":"flat","amount":"4.99","freeOver":"50","active":false}`, adminToken)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		method := store.methods[1]
+		if method.ZoneID != 1 || method.Active || method.Tiers != nil || *method.Amount != usd(499) {
+			t.Errorf("Expected an inactive flat method of 4.99, got %+v", method)
+		}
+	})
+
+	t.Run("Should delete the shipping method and the zone", func(t *testing.T) {
+		if rr := serve(http.MethodDelete, "/admin/shipping-methods/1", "", adminToken); rr.Code != http.StatusNoContent {
+			t.Errorf("Expected status code %d, got %d", http.StatusNoContent, rr.Code)
+		}
+
+		if rr := serve(http.MethodDelete, "/admin/shipping-zones/1", "", adminToken); rr.Code != http.StatusNoContent {
+			t.Errorf("Expected status code %d, got %d", http.StatusNoContent, rr.Code)
+		}
+
+		if rr := serve(http.MethodDelete, "/admin/shipping-zones/1", "", adminToken); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
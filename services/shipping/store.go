@@ -0,0 +1,376 @@
+package shipping
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// methodColumns is the list of columns scanned by scanRowIntoMethod
+const methodColumns = "id, zoneId, name, type, amount, tiers, freeOver, active, createdAt"
+
+// Store struct to hold the database object
+// This will be used to handle the database queries
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore function to return a reference to the Store struct
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// ErrZoneNotFound is returned when a shipping zone does not exist
+var ErrZoneNotFound = errors.New("shipping zone not found")
+
+// ErrMethodNotFound is returned when a shipping method does not exist
+var ErrMethodNotFound = errors.New("shipping method not found")
+
+// GetShippingZones function to get every zone with its regions and methods
+func (s *Store) GetShippingZones() ([]types.ShippingZone, error) {
+	rows, err := s.db.Query("SELECT id, name, createdAt FROM shipping_zones ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	zones := []types.ShippingZone{}
+	for rows.Next() {
+		zone := types.ShippingZone{Regions: []types.ShippingRegion{}, Methods: []types.ShippingMethod{}}
+		if err := rows.Scan(&zone.ID, &zone.Name, &zone.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		zones = append(zones, zone)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range zones {
+		if err := s.loadZone(&zones[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return zones, nil
+}
+
+// GetShippingZoneByID function to find the zone by id with its regions and methods
+func (s *Store) GetShippingZoneByID(id int) (*types.ShippingZone, error) {
+	zone := &types.ShippingZone{Regions: []types.ShippingRegion{}, Methods: []types.ShippingMethod{}}
+
+	err := s.db.QueryRow("SELECT id, name, createdAt FROM shipping_zones WHERE id = ?", id).Scan(&zone.ID, &zone.Name, &zone.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %v", ErrZoneNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.loadZone(zone); err != nil {
+		return nil, err
+	}
+
+	return zone, nil
+}
+
+// loadZone reads the regions and the methods of the zone
+func (s *Store) loadZone(zone *types.ShippingZone) error {
+	rows, err := s.db.Query("SELECT country, region FROM shipping_zone_regions WHERE zoneId = ? ORDER BY country, region", zone.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var region types.ShippingRegion
+		if err := rows.Scan(&region.Country, &region.Region); err != nil {
+			return err
+		}
+
+		zone.Regions = append(zone.Regions, region)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	methodRows, err := s.db.Query("SELECT "+methodColumns+" FROM shipping_methods WHERE zoneId = ? ORDER BY id", zone.ID)
+	if err != nil {
+		return err
+	}
+	defer methodRows.Close()
+
+	methods, err := scanMethods(methodRows)
+	if err != nil {
+		return err
+	}
+	zone.Methods = methods
+
+	return nil
+}
+
+// CreateShippingZone function to add the zone with its regions
+func (s *Store) CreateShippingZone(zone types.ShippingZone) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT INTO shipping_zones (name) VALUES (?)", zone.Name)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := insertRegions(tx, int(id), zone.Regions); err != nil {
+		return 0, err
+	}
+
+	return int(id), tx.Commit()
+}
+
+// UpdateShippingZone function to rename the zone and replace its regions
+func (s *Store) UpdateShippingZone(zone types.ShippingZone) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE shipping_zones SET name = ? WHERE id = ?", zone.Name, zone.ID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM shipping_zone_regions WHERE zoneId = ?", zone.ID); err != nil {
+		return err
+	}
+
+	if err := insertRegions(tx, zone.ID, zone.Regions); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func insertRegions(tx *sql.Tx, zoneID int, regions []types.ShippingRegion) error {
+	for _, region := range regions {
+		_, err := tx.Exec("INSERT INTO shipping_zone_regions (zoneId, country, region) VALUES (?, ?, ?)", zoneID, region.Country, region.Region)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteShippingZone function to remove the zone, its regions and methods
+// are removed with it
+func (s *Store) DeleteShippingZone(id int) error {
+	result, err := s.db.Exec("DELETE FROM shipping_zones WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w: %v", ErrZoneNotFound, id)
+	}
+
+	return nil
+}
+
+// GetShippingMethodByID function to find the method by id
+func (s *Store) GetShippingMethodByID(id int) (*types.ShippingMethod, error) {
+	rows, err := s.db.Query("SELECT "+methodColumns+" FROM shipping_methods WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	m := new(types.ShippingMethod)
+	for rows.Next() {
+		m, err = scanRowIntoMethod(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if m.ID == 0 {
+		return nil, fmt.Errorf("%w: %v", ErrMethodNotFound, id)
+	}
+
+	return m, nil
+}
+
+// CreateShippingMethod function to add the method to its zone
+func (s *Store) CreateShippingMethod(m types.ShippingMethod) (int, error) {
+	tiers, err := marshalTiers(m.Tiers)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := s.db.Exec(
+		"INSERT INTO shipping_methods (zoneId, name, type, amount, tiers, freeOver, active) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		m.ZoneID, m.Name, m.Type, m.Amount, tiers, m.FreeOver, m.Active,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// UpdateShippingMethod function to replace the method
+// the shipping of the orders already placed is not changed
+func (s *Store) UpdateShippingMethod(m types.ShippingMethod) error {
+	tiers, err := marshalTiers(m.Tiers)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(
+		"UPDATE shipping_methods SET name = ?, type = ?, amount = ?, tiers = ?, freeOver = ?, active = ? WHERE id = ?",
+		m.Name, m.Type, m.Amount, tiers, m.FreeOver, m.Active, m.ID,
+	)
+
+	return err
+}
+
+// DeleteShippingMethod function to remove the method
+func (s *Store) DeleteShippingMethod(id int) error {
+	result, err := s.db.Exec("DELETE FROM shipping_methods WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w: %v", ErrMethodNotFound, id)
+	}
+
+	return nil
+}
+
+// GetShippingMethodsFor function to get the active methods shipping to the destination
+func (s *Store) GetShippingMethodsFor(country string, region string) ([]types.ShippingMethod, error) {
+	return GetShippingMethodsFor(s.db, country, region)
+}
+
+// Querier is implemented by *sql.DB and *sql.Tx
+type Querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// GetShippingMethodsFor gets the active methods of the zone of the destination
+// with the querier, the order store reads them inside its transaction.
+// The zone of the region takes precedence over the zone of the whole country
+func GetShippingMethodsFor(q Querier, country string, region string) ([]types.ShippingMethod, error) {
+	rows, err := q.Query(`
+		SELECT `+methodColumns+` FROM shipping_methods
+		WHERE active AND zoneId = (
+			SELECT zoneId FROM shipping_zone_regions
+			WHERE country = ? AND region IN (?, '')
+			ORDER BY region DESC
+			LIMIT 1
+		)
+		ORDER BY id`, country, region)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanMethods(rows)
+}
+
+// marshalTiers encodes the tiers of the JSON column, NULL without tiers
+func marshalTiers(tiers []types.ShippingTier) (*string, error) {
+	if len(tiers) == 0 {
+		return nil, nil
+	}
+
+	encoded, err := json.Marshal(tiers)
+	if err != nil {
+		return nil, err
+	}
+
+	s := string(encoded)
+	return &s, nil
+}
+
+func scanMethods(rows *sql.Rows) ([]types.ShippingMethod, error) {
+	methods := []types.ShippingMethod{}
+	for rows.Next() {
+		m, err := scanRowIntoMethod(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		methods = append(methods, *m)
+	}
+
+	return methods, rows.Err()
+}
+
+func scanRowIntoMethod(rows *sql.Rows) (*types.ShippingMethod, error) {
+	m := new(types.ShippingMethod)
+
+	var amount, freeOver types.NullMoney
+	var tiers []byte
+	err := rows.Scan(
+		&m.ID,
+		&m.ZoneID,
+		&m.Name,
+		&m.Type,
+		&amount,
+		&tiers,
+		&freeOver,
+		&m.Active,
+		&m.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if amount.Valid {
+		m.Amount = &amount.Money
+	}
+
+	if freeOver.Valid {
+		m.FreeOver = &freeOver.Money
+	}
+
+	if tiers != nil {
+		if err := json.Unmarshal(tiers, &m.Tiers); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
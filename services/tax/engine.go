@@ -0,0 +1,153 @@
+package tax
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// NormalizeRegion returns the region as it is stored, regions are case insensitive
+func NormalizeRegion(region string) string {
+	return strings.ToUpper(strings.TrimSpace(region))
+}
+
+// ParseRate parses a tax rate in percent ("20", "8.875") exactly,
+// rates are between 0 and 100 with at most 4 decimal places
+func ParseRate(s string) (*big.Rat, error) {
+	if !ratePattern.MatchString(s) {
+		return nil, fmt.Errorf("invalid tax rate %q, it must be a percentage between 0 and 100", s)
+	}
+
+	rate, ok := new(big.Rat).SetString(s)
+	if !ok || rate.Cmp(big.NewRat(100, 1)) > 0 {
+		return nil, fmt.Errorf("invalid tax rate %q, it must be a percentage between 0 and 100", s)
+	}
+
+	return rate, nil
+}
+
+var ratePattern = regexp.MustCompile(`^[0-9]{1,3}(\.[0-9]{1,4})?$`)
+
+// Find returns the rate of the tax class at the destination, the rate of
+// the region takes precedence over the rate of the whole country.
+// Tax classes without a rate at the destination are not taxed
+func Find(rates []types.TaxRate, country string, region string, taxClass string) (types.TaxRate, bool) {
+	var found types.TaxRate
+	ok := false
+
+	for _, rate := range rates {
+		if rate.Country != country || rate.TaxClass != taxClass {
+			continue
+		}
+
+		if rate.Region == region && region != "" {
+			return rate, true
+		}
+
+		if rate.Region == "" {
+			found, ok = rate, true
+		}
+	}
+
+	return found, ok
+}
+
+// Calculate shares the discount of the order among the items in proportion to
+// their totals and computes the tax of every item on its total less its share,
+// rounded half to even per line so the lines always add up to the order tax.
+// With inclusive prices the tax is the part of the price that is tax, otherwise
+// it is added to the price. Exempt customers pay no tax, the tax included in
+// inclusive prices is deducted for them. The items are updated in place,
+// the tax of the order and its total are returned
+func Calculate(items []types.OrderItem, discount types.Money, rates []types.TaxRate, country string, region string, inclusive bool, exempt bool) (types.Money, types.Money, error) {
+	zero := types.NewMoney(0, discount.Currency)
+
+	lineTotals := make([]types.Money, len(items))
+	ratios := make([]int64, len(items))
+	for i, item := range items {
+		var err error
+		if lineTotals[i], err = item.Price.Mul(int64(item.Quantity)); err != nil {
+			return zero, zero, err
+		}
+		ratios[i] = lineTotals[i].Amount
+	}
+
+	discounts := make([]types.Money, len(items))
+	for i := range discounts {
+		discounts[i] = zero
+	}
+
+	if !discount.IsZero() {
+		var err error
+		if discounts, err = discount.Allocate(ratios...); err != nil {
+			return zero, zero, err
+		}
+	}
+
+	tax, total := zero, zero
+	for i := range items {
+		item := &items[i]
+		if item.TaxClass == "" {
+			item.TaxClass = types.DefaultTaxClass
+		}
+
+		net, err := lineTotals[i].Sub(discounts[i])
+		if err != nil {
+			return zero, zero, err
+		}
+
+		item.Discount, item.TaxRate, item.Tax = discounts[i], "0", zero
+
+		if rate, ok := Find(rates, country, region, item.TaxClass); ok {
+			amount, err := lineTax(net, rate.Rate, inclusive)
+			if err != nil {
+				return zero, zero, err
+			}
+
+			switch {
+			case exempt && inclusive:
+				// the exempt customer only pays the price without the tax
+				if net, err = net.Sub(amount); err != nil {
+					return zero, zero, err
+				}
+			case !exempt:
+				item.TaxRate, item.Tax = rate.Rate, amount
+			}
+		}
+
+		if !inclusive {
+			if net, err = net.Add(item.Tax); err != nil {
+				return zero, zero, err
+			}
+		}
+
+		if tax, err = tax.Add(item.Tax); err != nil {
+			return zero, zero, err
+		}
+
+		if total, err = total.Add(net); err != nil {
+			return zero, zero, err
+		}
+	}
+
+	return tax, total, nil
+}
+
+// lineTax computes the tax of the amount at the rate in percent, an inclusive
+// amount already contains the tax: 120.00 at 20% contains 20.00 of tax
+func lineTax(amount types.Money, rate string, inclusive bool) (types.Money, error) {
+	percent, err := ParseRate(rate)
+	if err != nil {
+		return amount, err
+	}
+
+	base := big.NewRat(100, 1)
+	if inclusive {
+		base.Add(base, percent)
+	}
+
+	return amount.MulRat(percent.Quo(percent, base), types.RoundHalfEven)
+}
@@ -0,0 +1,151 @@
+package tax
+
+import (
+	"testing"
+
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+func usd(amount int64) types.Money {
+	return types.NewMoney(amount, types.DefaultCurrency)
+}
+
+// rates of the standard and reduced classes in Germany, and of the
+// standard class in California only
+var rates = []types.TaxRate{
+	{Country: "DE", TaxClass: types.DefaultTaxClass, Rate: "19"},
+	{Country: "DE", TaxClass: "reduced", Rate: "7"},
+	{Country: "US", Region: "CA", TaxClass: types.DefaultTaxClass, Rate: "7.25"},
+}
+
+// items of 119.00 (standard) and 10.70 (reduced)
+func items() []types.OrderItem {
+	return []types.OrderItem{
+		{ProductID: 1, Price: usd(5950), Quantity: 2, TaxClass: types.DefaultTaxClass},
+		{ProductID: 2, Price: usd(1070), Quantity: 1, TaxClass: "reduced"},
+	}
+}
+
+// TestFind function to test the selection of the tax rate of a destination
+func TestFind(t *testing.T) {
+	withCountry := append([]types.TaxRate{{Country: "US", TaxClass: types.DefaultTaxClass, Rate: "5"}}, rates...)
+
+	for _, c := range []struct {
+		country  string
+		region   string
+		taxClass string
+		rate     string
+		found    bool
+	}{
+		{"DE", "", "reduced", "7", true},
+		{"DE", "BY", types.DefaultTaxClass, "19", true},
+		{"US", "CA", types.DefaultTaxClass, "7.25", true},
+		{"US", "NY", types.DefaultTaxClass, "5", true},
+		{"US", "", types.DefaultTaxClass, "5", true},
+		{"US", "CA", "reduced", "", false},
+		{"FR", "", types.DefaultTaxClass, "", false},
+	} {
+		rate, found := Find(withCountry, c.country, c.region, c.taxClass)
+
+		if found != c.found || rate.Rate != c.rate {
+			t.Errorf("%s %s %s: expected the rate %q, got %q", c.country, c.region, c.taxClass, c.rate, rate.Rate)
+		}
+	}
+}
+
+// TestCalculate function to test the taxes of the order items
+func TestCalculate(t *testing.T) {
+	t.Run("Should add the taxes to exclusive prices", func(t *testing.T) {
+		lines := items()
+
+		tax, total, err := Calculate(lines, usd(0), rates, "DE", "", false, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// 19% of 119.00 and 7% of 10.70 rounded to 0.75
+		if lines[0].Tax != usd(2261) || lines[1].Tax != usd(75) || lines[1].TaxRate != "7" {
+			t.Errorf("Expected the line taxes 22.61 and 0.75, got %+v", lines)
+		}
+
+		if tax != usd(2336) || total != usd(15306) {
+			t.Errorf("Expected a tax of 23.36 and a total of 153.06, got %v and %v", tax, total)
+		}
+	})
+
+	t.Run("Should extract the taxes of inclusive prices", func(t *testing.T) {
+		lines := items()
+
+		tax, total, err := Calculate(lines, usd(0), rates, "DE", "", true, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// 119.00 includes 19.00 and 10.70 includes 0.70
+		if lines[0].Tax != usd(1900) || lines[1].Tax != usd(70) {
+			t.Errorf("Expected the line taxes 19.00 and 0.70, got %+v", lines)
+		}
+
+		if tax != usd(1970) || total != usd(12970) {
+			t.Errorf("Expected a tax of 19.70 and a total of 129.70, got %v and %v", tax, total)
+		}
+	})
+
+	t.Run("Should tax the lines after their share of the discount", func(t *testing.T) {
+		lines := items()
+
+		// 12.97 is 10% of the order, 11.90 and 1.07 of the lines
+		tax, total, err := Calculate(lines, usd(1297), rates, "DE", "", false, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if lines[0].Discount != usd(1190) || lines[1].Discount != usd(107) {
+			t.Errorf("Expected the line discounts 11.90 and 1.07, got %+v", lines)
+		}
+
+		// 19% of 107.10 is 20.349 and 7% of 9.63 is 0.6741
+		if tax != usd(2102) || total != usd(13775) {
+			t.Errorf("Expected a tax of 21.02 and a total of 137.75, got %v and %v", tax, total)
+		}
+	})
+
+	t.Run("Should not tax exempt customers", func(t *testing.T) {
+		lines := items()
+
+		tax, total, err := Calculate(lines, usd(0), rates, "DE", "", false, true)
+		if err != nil || !tax.IsZero() || total != usd(12970) || lines[0].TaxRate != "0" {
+			t.Errorf("Expected no tax and a total of 129.70, got %v and %v, error: %v", tax, total, err)
+		}
+
+		// the included taxes are deducted
+		tax, total, err = Calculate(items(), usd(0), rates, "DE", "", true, true)
+		if err != nil || !tax.IsZero() || total != usd(11000) {
+			t.Errorf("Expected no tax and a total of 110.00, got %v and %v, error: %v", tax, total, err)
+		}
+	})
+
+	t.Run("Should not tax the classes without a rate", func(t *testing.T) {
+		tax, total, err := Calculate(items(), usd(0), rates, "US", "CA", false, false)
+
+		// only the standard line is taxed in California, 7.25% of 119.00 is 8.6275
+		if err != nil || tax != usd(863) || total != usd(13833) {
+			t.Errorf("Expected a tax of 8.63 and a total of 138.33, got %v and %v, error: %v", tax, total, err)
+		}
+	})
+}
+
+// TestParseRate function to test the parsing of the tax rates
+func TestParseRate(t *testing.T) {
+	for _, rate := range []string{"0", "19", "8.875", "100", "0.0001"} {
+		if _, err := ParseRate(rate); err != nil {
+			t.Errorf("Expected the rate %q to parse, got %v", rate, err)
+		}
+	}
+
+	for _, rate := range []string{"", "-1", "100.01", "1.23456", "abc", "1e2", "20."} {
+		if _, err := ParseRate(rate); err == nil {
+			t.Errorf("Expected the rate %q to fail", rate)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package tax
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/akshtrikha/golang-ecomm/services/auth"
+	"github.com/akshtrikha/golang-ecomm/types"
+	"github.com/akshtrikha/golang-ecomm/utils"
+	"github.com/gorilla/mux"
+)
+
+// API Endpoints for the admins to manage the tax rates by country,
+// region and product tax class
+
+// Handler to the tax store which will deal
+// with the database regarding tax rates
+type Handler struct {
+	store types.TaxStore
+}
+
+// NewHandler constructor takes TaxStore as dependency
+func NewHandler(store types.TaxStore) *Handler {
+	return &Handler{store: store}
+}
+
+// RegisterRoutes func for tax rates
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/tax-rates", auth.RequireRole(auth.RoleAdmin, h.handleGetTaxRates)).Methods("GET")
+	router.HandleFunc("/admin/tax-rates", auth.RequireRole(auth.RoleAdmin, h.handleCreateTaxRate)).Methods("POST")
+	router.HandleFunc("/admin/tax-rates/{id:[0-9]+}", auth.RequireRole(auth.RoleAdmin, h.handleUpdateTaxRate)).Methods("PUT")
+	router.HandleFunc("/admin/tax-rates/{id:[0-9]+}", auth.RequireRole(auth.RoleAdmin, h.handleDeleteTaxRate)).Methods("DELETE")
+}
+
+func (h *Handler) handleGetTaxRates(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle GET /admin/tax-rates endpoint hit")
+
+	rates, err := h.store.GetTaxRates()
+	if err != nil {
+		log.Println("Error fetching the tax rates from the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, rates)
+}
+
+func (h *Handler) handleCreateTaxRate(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle POST /admin/tax-rates endpoint hit")
+
+	rate, status, err := h.parseTaxRate(r, 0)
+	if err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	rateID, err := h.store.CreateTaxRate(*rate)
+	if err != nil {
+		log.Println("Error adding the tax rate to the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	log.Printf("Tax Rate Added %v", rateID)
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]int{"id": rateID})
+}
+
+func (h *Handler) handleUpdateTaxRate(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle PUT /admin/tax-rates/{id} endpoint hit")
+
+	rateID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid tax rate id"))
+		return
+	}
+
+	_, err = h.store.GetTaxRateByID(rateID)
+	if errors.Is(err, ErrTaxRateNotFound) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("tax rate with id: %v not found", rateID))
+		return
+	}
+	if err != nil {
+		log.Printf("Error finding the tax rate %v, error: %+v", rateID, err)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	rate, status, err := h.parseTaxRate(r, rateID)
+	if err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	if err := h.store.UpdateTaxRate(*rate); err != nil {
+		log.Println("Error updating the tax rate in the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, rate)
+}
+
+func (h *Handler) handleDeleteTaxRate(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle DELETE /admin/tax-rates/{id} endpoint hit")
+
+	rateID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid tax rate id"))
+		return
+	}
+
+	err = h.store.DeleteTaxRate(rateID)
+	if errors.Is(err, ErrTaxRateNotFound) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("tax rate with id: %v not found", rateID))
+		return
+	}
+	if err != nil {
+		log.Printf("Error deleting the tax rate %v, error: %+v", rateID, err)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseTaxRate reads and validates the tax rate of the payload, only one
+// rate, the one with the id, can exist for a destination and a tax class.
+// The returned status code is meant to be used when an error is returned.
+func (h *Handler) parseTaxRate(r *http.Request, id int) (*types.TaxRate, int, error) {
+	// get the json payload
+	var payload types.TaxRatePayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		log.Println("Payload parsing met with an error")
+		return nil, http.StatusBadRequest, err
+	}
+
+	// country codes are case insensitive
+	payload.Country = strings.ToUpper(strings.TrimSpace(payload.Country))
+
+	// validate the json payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		return nil, http.StatusBadRequest, utils.ValidationError("Invalid payload", err)
+	}
+
+	rate := &types.TaxRate{
+		ID:       id,
+		Country:  payload.Country,
+		Region:   NormalizeRegion(payload.Region),
+		TaxClass: strings.TrimSpace(payload.TaxClass),
+		Name:     strings.TrimSpace(payload.Name),
+		Rate:     strings.TrimSpace(payload.Rate),
+	}
+
+	if rate.TaxClass == "" {
+		rate.TaxClass = types.DefaultTaxClass
+	}
+
+	if _, err := ParseRate(rate.Rate); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	// "20.0" and "20" are the same rate
+	rate.Rate = types.TrimDecimal(rate.Rate)
+
+	rates, err := h.store.GetTaxRates()
+	if err != nil {
+		log.Println("Error fetching the tax rates from the database")
+		return nil, http.StatusInternalServerError, err
+	}
+
+	for _, other := range rates {
+		if other.ID != id && other.Country == rate.Country && other.Region == rate.Region && other.TaxClass == rate.TaxClass {
+			return nil, http.StatusConflict, fmt.Errorf("a %s tax rate already exists for %s %s", rate.TaxClass, rate.Country, rate.Region)
+		}
+	}
+
+	return rate, http.StatusOK, nil
+}
@@ -0,0 +1,157 @@
+package tax
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/akshtrikha/golang-ecomm/services/auth"
+	"github.com/akshtrikha/golang-ecomm/types"
+	"github.com/gorilla/mux"
+)
+
+// mockTaxStore keeps the tax rates in memory
+type mockTaxStore struct {
+	rates map[int]types.TaxRate
+}
+
+func (m *mockTaxStore) GetTaxRates() ([]types.TaxRate, error) {
+	rates := []types.TaxRate{}
+	for _, rate := range m.rates {
+		rates = append(rates, rate)
+	}
+
+	return rates, nil
+}
+
+func (m *mockTaxStore) GetTaxRateByID(id int) (*types.TaxRate, error) {
+	rate, ok := m.rates[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrTaxRateNotFound, id)
+	}
+
+	return &rate, nil
+}
+
+func (m *mockTaxStore) CreateTaxRate(rate types.TaxRate) (int, error) {
+	rate.ID = len(m.rates) + 1
+	m.rates[rate.ID] = rate
+
+	return rate.ID, nil
+}
+
+func (m *mockTaxStore) UpdateTaxRate(rate types.TaxRate) error {
+	m.rates[rate.ID] = rate
+	return nil
+}
+
+func (m *mockTaxStore) DeleteTaxRate(id int) error {
+	if _, ok := m.rates[id]; !ok {
+		return fmt.Errorf("%w: %v", ErrTaxRateNotFound, id)
+	}
+
+	delete(m.rates, id)
+	return nil
+}
+
+// TestTaxServiceHandlers function to implement testing
+func TestTaxServiceHandlers(t *testing.T) {
+	store := &mockTaxStore{rates: map[int]types.TaxRate{}}
+	handler := NewHandler(store)
+
+	adminToken, err := auth.GenerateJWT(1, []string{auth.RoleAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	customerToken, err := auth.GenerateJWT(2, []string{auth.RoleCustomer})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(method, path string, body string, token string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+		router.Use(auth.JWTMiddleware)
+
+		handler.RegisterRoutes(router)
+		router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	t.Run("Should not allow customers to create tax rates", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/admin/tax-rates", `{"country":"DE","name":"VAT","rate":"19"}`, customerToken)
+
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("Expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	t.Run("Should fail if the tax rate is invalid", func(t *testing.T) {
+		for _, body := range []string{
+			`{"country":"XX","name":"VAT","rate":"19"}`,
+			`{"country":"DE","name":"VAT","rate":"119"}`,
+			`{"country":"DE","name":"VAT","rate":"-1"}`,
+			`{"country":"DE","rate":"19"}`,
+		} {
+			rr := serve(http.MethodPost, "/admin/tax-rates", body, adminToken)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("Expected status code %d for %s, got %d", http.StatusBadRequest, body, rr.Code)
+			}
+		}
+	})
+
+	t.Run("Should create the tax rate of the standard class", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/admin/tax-rates", `{"country":"us","region":" ca","name":"Sales tax","rate":"7.2500"}`, adminToken)
+
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		rate := store.rates[1]
+		if rate.Country != "US" || rate.Region != "CA" || rate.TaxClass != types.DefaultTaxClass || rate.Rate != "7.25" {
+			t.Errorf("Expected the standard rate of 7.25 in US CA, got %+v", rate)
+		}
+	})
+
+	t.Run("Should fail if the destination already has a rate for the class", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/admin/tax-rates", `{"country":"US","region":"CA","name":"Sales tax","rate":"8"}`, adminToken)
+
+		if rr.Code != http.StatusConflict {
+			t.Errorf("Expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+	})
+
+	t.Run("Should update the tax rate", func(t *testing.T) {
+		rr := serve(http.MethodPut, "/admin/tax-rates/1", `{"country":"US","region":"CA","name":"Sales tax","rate":"7.5"}`, adminToken)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		if store.rates[1].Rate != "7.5" {
+			t.Errorf("Expected the rate 7.5, got %+v", store.rates[1])
+		}
+	})
+
+	t.Run("Should delete the tax rate", func(t *testing.T) {
+		if rr := serve(http.MethodDelete, "/admin/tax-rates/1", "", adminToken); rr.Code != http.StatusNoContent {
+			t.Errorf("Expected status code %d, got %d", http.StatusNoContent, rr.Code)
+		}
+
+		if rr := serve(http.MethodDelete, "/admin/tax-rates/1", "", adminToken); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package tax
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// taxRateColumns is the list of columns scanned by scanRowIntoTaxRate
+const taxRateColumns = "id, country, region, taxClass, name, rate, createdAt"
+
+// Store struct to hold the database object
+// This will be used to handle the database queries
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore function to return a reference to the Store struct
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// ErrTaxRateNotFound is returned when a tax rate does not exist
+var ErrTaxRateNotFound = errors.New("tax rate not found")
+
+// GetTaxRates function to get every tax rate ordered by destination and tax class
+func (s *Store) GetTaxRates() ([]types.TaxRate, error) {
+	rows, err := s.db.Query("SELECT " + taxRateColumns + " FROM tax_rates ORDER BY country, region, taxClass")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTaxRates(rows)
+}
+
+// GetTaxRateByID function to find the tax rate by id
+func (s *Store) GetTaxRateByID(id int) (*types.TaxRate, error) {
+	rows, err := s.db.Query("SELECT "+taxRateColumns+" FROM tax_rates WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rate := new(types.TaxRate)
+	for rows.Next() {
+		rate, err = scanRowIntoTaxRate(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if rate.ID == 0 {
+		return nil, fmt.Errorf("%w: %v", ErrTaxRateNotFound, id)
+	}
+
+	return rate, nil
+}
+
+// CreateTaxRate function to add the tax rate
+func (s *Store) CreateTaxRate(rate types.TaxRate) (int, error) {
+	result, err := s.db.Exec(
+		"INSERT INTO tax_rates (country, region, taxClass, name, rate) VALUES (?, ?, ?, ?, ?)",
+		rate.Country, rate.Region, rate.TaxClass, rate.Name, rate.Rate,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// UpdateTaxRate function to replace the tax rate
+// the taxes of the orders already placed are not changed
+func (s *Store) UpdateTaxRate(rate types.TaxRate) error {
+	_, err := s.db.Exec(
+		"UPDATE tax_rates SET country = ?, region = ?, taxClass = ?, name = ?, rate = ? WHERE id = ?",
+		rate.Country, rate.Region, rate.TaxClass, rate.Name, rate.Rate, rate.ID,
+	)
+
+	return err
+}
+
+// DeleteTaxRate function to remove the tax rate
+func (s *Store) DeleteTaxRate(id int) error {
+	result, err := s.db.Exec("DELETE FROM tax_rates WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w: %v", ErrTaxRateNotFound, id)
+	}
+
+	return nil
+}
+
+// Querier is implemented by *sql.DB and *sql.Tx
+type Querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// GetCountryTaxRates gets the tax rates of the country and of its regions
+// with the querier, the order store reads them inside its transaction
+func GetCountryTaxRates(q Querier, country string) ([]types.TaxRate, error) {
+	rows, err := q.Query("SELECT "+taxRateColumns+" FROM tax_rates WHERE country = ?", country)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTaxRates(rows)
+}
+
+func scanTaxRates(rows *sql.Rows) ([]types.TaxRate, error) {
+	rates := []types.TaxRate{}
+	for rows.Next() {
+		rate, err := scanRowIntoTaxRate(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		rates = append(rates, *rate)
+	}
+
+	return rates, rows.Err()
+}
+
+func scanRowIntoTaxRate(rows *sql.Rows) (*types.TaxRate, error) {
+	rate := new(types.TaxRate)
+
+	err := rows.Scan(
+		&rate.ID,
+		&rate.Country,
+		&rate.Region,
+		&rate.TaxClass,
+		&rate.Name,
+		&rate.Rate,
+		&rate.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// the column keeps 4 decimal places, "20.0000" reads as "20"
+	rate.Rate = types.TrimDecimal(rate.Rate)
+
+	return rate, nil
+}
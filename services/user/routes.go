@@ -1,35 +1,53 @@
 package user
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 
-	"github.com/akshtrikha/golang-ecomm/config"
 	"github.com/akshtrikha/golang-ecomm/services/auth"
+	"github.com/akshtrikha/golang-ecomm/services/session"
+	"github.com/akshtrikha/golang-ecomm/services/tax"
 	"github.com/akshtrikha/golang-ecomm/types"
 	"github.com/akshtrikha/golang-ecomm/utils"
-	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 	"github.com/maolinc/copier"
 )
 
+// errInvalidCredentials is reported for unknown emails and wrong passwords
+// alike so the login does not tell which accounts exist
+var errInvalidCredentials = utils.NewError(http.StatusUnauthorized, "invalid_credentials", "Invalid email or password")
+
 // Handler struct
 type Handler struct {
-	store types.UserStore
+	store        types.UserStore
+	cartStore    types.CartStore
+	sessionStore types.SessionStore
+	addressStore types.AddressStore
 }
 
 // NewHandler constructor takes UserStore as a dependency
 // This will allow the Handler to manage user data in the database
-func NewHandler(store types.UserStore) *Handler {
-	return &Handler{store: store}
+// CartStore is used to merge the guest cart into the user cart on login
+// SessionStore is used to store the refresh token issued on login
+// AddressStore is used to manage the address book of the user
+func NewHandler(store types.UserStore, cartStore types.CartStore, sessionStore types.SessionStore, addressStore types.AddressStore) *Handler {
+	return &Handler{store: store, cartStore: cartStore, sessionStore: sessionStore, addressStore: addressStore}
 }
 
 // RegisterRoutes func
 func (h *Handler) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/login", h.handleLogin).Methods("POST")
 	router.HandleFunc("/register", h.handleRegister).Methods("POST")
-	router.HandleFunc("/list-users", h.handleListUsers).Methods("GET")
+	router.HandleFunc("/list-users", auth.RequireRole(auth.RoleAdmin, h.handleListUsers)).Methods("GET")
+	router.HandleFunc("/admin/users/{id:[0-9]+}/tax-exempt", auth.RequireRole(auth.RoleAdmin, h.handleSetTaxExempt)).Methods("PUT")
+	router.HandleFunc("/users/me/addresses", auth.RequireAuth(h.handleGetAddresses)).Methods("GET")
+	router.HandleFunc("/users/me/addresses", auth.RequireAuth(h.handleCreateAddress)).Methods("POST")
+	router.HandleFunc("/users/me/addresses/{id:[0-9]+}", auth.RequireAuth(h.handleUpdateAddress)).Methods("PUT")
+	router.HandleFunc("/users/me/addresses/{id:[0-9]+}", auth.RequireAuth(h.handleDeleteAddress)).Methods("DELETE")
 }
 
 func (h *Handler) handleLogin(w http.ResponseWriter, r *http.Request) {
@@ -47,43 +65,58 @@ func (h *Handler) handleLogin(w http.ResponseWriter, r *http.Request) {
 
 	// validate the json payload
 	if err := utils.Validate.Struct(payload); err != nil {
-		validationErrors := err.(validator.ValidationErrors)
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("Invalid payload: %v", validationErrors))
+		utils.WriteError(w, http.StatusBadRequest, utils.ValidationError("Invalid payload", err))
 		return
 	}
 
 	// find the user
 	u, err := h.store.GetUserByEmail(payload.Email)
 	if err != nil {
-		utils.WriteError(w, http.StatusNotFound, err)
+		log.Printf("Error finding the user to log in, error: %+v", err)
+		utils.WriteError(w, http.StatusUnauthorized, errInvalidCredentials)
 		return
 	}
 
-	log.Printf("User found: %+v\n", u)
-
 	// check password
 	if ok := auth.ComparePassword(u.Password, payload.Password); !ok {
 		log.Println("Invalid Password")
-		utils.WriteError(w, http.StatusUnauthorized, fmt.Errorf("Invalid Password"))
+		utils.WriteError(w, http.StatusUnauthorized, errInvalidCredentials)
+		return
+	}
+
+	// get the roles to embed in the jwt
+	roles, err := h.store.GetUserRoles(u.ID)
+	if err != nil {
+		log.Println("Error fetching the user roles")
+		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	// generate jwt
-	secret := config.Envs.JWTSecret
-	token, err := auth.GenerateJWT(secret, u.ID)
+	// generate jwt and refresh token
+	tokens, err := session.NewSession(h.sessionStore, u.ID, roles)
 	if err != nil {
 		log.Println("Error creating jwt")
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	// merge the guest cart into the user cart
+	if cartToken := r.Header.Get("X-Cart-Token"); cartToken != "" {
+		if err := h.cartStore.MergeGuestCart(cartToken, u.ID); err != nil {
+			// the login should not fail because of the cart
+			log.Printf("Error merging the guest cart, error: %+v", err)
+		}
+	}
+
 	// respond with jwt and user in the response payload
 	var response types.LoginUserResponse
 	copier.Copy(&response, &u)
-	response.Token = token
-
-	log.Printf("Response: %+v\n", response)
+	response.Token = tokens.Token
+	response.RefreshToken = tokens.RefreshToken
+	response.ExpiresIn = tokens.ExpiresIn
 
+	// the tokens must not be kept by the caches and the idempotency keys
+	w.Header().Set("Cache-Control", "no-store")
 	utils.WriteJSON(w, http.StatusFound, response)
 }
 
@@ -102,15 +135,14 @@ func (h *Handler) handleRegister(w http.ResponseWriter, r *http.Request) {
 	log.Println("Payload parsing completed")
 	// validating the payload
 	if err := utils.Validate.Struct(payload); err != nil {
-		validationErrors := err.(validator.ValidationErrors)
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("Invalid payload: %v", validationErrors))
+		utils.WriteError(w, http.StatusBadRequest, utils.ValidationError("Invalid payload", err))
 		return
 	}
 
 	// check if the user exists
 	_, err := h.store.GetUserByEmail(payload.Email)
 	if err == nil {
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("user with email %s already exists", payload.Email))
+		utils.WriteError(w, http.StatusBadRequest, utils.NewError(http.StatusBadRequest, "email_taken", fmt.Sprintf("user with email %s already exists", payload.Email)))
 		return
 	}
 
@@ -146,25 +178,8 @@ func (h *Handler) handleRegister(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) handleListUsers(w http.ResponseWriter, r *http.Request) {
 	log.Println("handle /list-users endpoint hit")
 
-	// check the token is present or not
-	token := r.Header.Get("Authorization")
-	if token == "" {
-		log.Println("jwt token not found in the headers")
-		utils.WriteError(w, http.StatusUnauthorized, fmt.Errorf("Please register/login first"))
-		return
-	}
-
-	// get the token and verify
-	secret := config.Envs.JWTSecret
-	claims, err := auth.VerifyTokenAndClaims(token, secret)
-	if err != nil {
-		log.Printf("token verification failed, error: %+v", err)
-		utils.WriteError(w, http.StatusUnauthorized, fmt.Errorf("Invalid Token"))
-		return
-	}
-
 	// get all the users from the database
-	userID := claims["userID"]
+	userID, _ := auth.UserIDFromContext(r.Context())
 	log.Printf("Request from the user: %v", userID)
 
 	result, err := h.store.GetAllUsers()
@@ -180,3 +195,186 @@ func (h *Handler) handleListUsers(w http.ResponseWriter, r *http.Request) {
 	// respond
 	utils.WriteJSON(w, http.StatusOK, users.Users)
 }
+
+func (h *Handler) handleSetTaxExempt(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle PUT /admin/users/{id}/tax-exempt endpoint hit")
+
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid user id"))
+		return
+	}
+
+	// get the json payload
+	var payload types.SetTaxExemptPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		log.Println("Payload parsing met with an error")
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = h.store.SetUserTaxExempt(userID, payload.TaxExempt)
+	if errors.Is(err, ErrUserNotFound) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("user with id: %v not found", userID))
+		return
+	}
+	if err != nil {
+		log.Printf("Error setting the tax exemption of the user %v, error: %+v", userID, err)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, payload)
+}
+
+func (h *Handler) handleGetAddresses(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle GET /users/me/addresses endpoint hit")
+
+	userID, _ := auth.UserIDFromContext(r.Context())
+
+	addresses, err := h.addressStore.GetAddressesByUserID(userID)
+	if err != nil {
+		log.Println("Error fetching the addresses from the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, addresses)
+}
+
+func (h *Handler) handleCreateAddress(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle POST /users/me/addresses endpoint hit")
+
+	userID, _ := auth.UserIDFromContext(r.Context())
+
+	address, err := parseAddress(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	address.UserID = userID
+
+	// the first address of the user is its default one
+	addresses, err := h.addressStore.GetAddressesByUserID(userID)
+	if err != nil {
+		log.Println("Error fetching the addresses from the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if len(addresses) == 0 {
+		address.DefaultShipping, address.DefaultBilling = true, true
+	}
+
+	addressID, err := h.addressStore.CreateAddress(*address)
+	if err != nil {
+		log.Println("Error adding the address to the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	log.Printf("Address Added %v", addressID)
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]int{"id": addressID})
+}
+
+func (h *Handler) handleUpdateAddress(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle PUT /users/me/addresses/{id} endpoint hit")
+
+	existing, status, err := h.getOwnAddress(r)
+	if err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	address, err := parseAddress(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	address.ID, address.UserID, address.CreatedAt = existing.ID, existing.UserID, existing.CreatedAt
+
+	if err := h.addressStore.UpdateAddress(*address); err != nil {
+		log.Println("Error updating the address in the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, address)
+}
+
+func (h *Handler) handleDeleteAddress(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle DELETE /users/me/addresses/{id} endpoint hit")
+
+	address, status, err := h.getOwnAddress(r)
+	if err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	// the orders keep their copy of the address
+	err = h.addressStore.DeleteAddress(address.ID)
+	if errors.Is(err, ErrAddressNotFound) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("address with id: %v not found", address.ID))
+		return
+	}
+	if err != nil {
+		log.Printf("Error deleting the address %v, error: %+v", address.ID, err)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getOwnAddress finds the address of the request in the address book of the user,
+// the addresses of the other users are not found.
+// The returned status code is meant to be used when an error is returned.
+func (h *Handler) getOwnAddress(r *http.Request) (*types.Address, int, error) {
+	userID, _ := auth.UserIDFromContext(r.Context())
+
+	addressID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid address id")
+	}
+
+	address, err := h.addressStore.GetAddressByID(addressID)
+	if errors.Is(err, ErrAddressNotFound) || (err == nil && address.UserID != userID) {
+		return nil, http.StatusNotFound, fmt.Errorf("address with id: %v not found", addressID)
+	}
+	if err != nil {
+		log.Printf("Error finding the address %v, error: %+v", addressID, err)
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return address, http.StatusOK, nil
+}
+
+// parseAddress reads and validates the address of the payload
+func parseAddress(r *http.Request) (*types.Address, error) {
+	// get the json payload
+	var payload types.AddressPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		log.Println("Payload parsing met with an error")
+		return nil, err
+	}
+
+	for _, field := range []*string{&payload.Name, &payload.Line1, &payload.Line2, &payload.City, &payload.PostalCode, &payload.Phone} {
+		*field = strings.TrimSpace(*field)
+	}
+
+	// country codes and regions are case insensitive
+	payload.Country = strings.ToUpper(strings.TrimSpace(payload.Country))
+	payload.Region = tax.NormalizeRegion(payload.Region)
+
+	// validate the json payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		return nil, utils.ValidationError("Invalid payload", err)
+	}
+
+	return &types.Address{
+		PostalAddress:   payload.PostalAddress,
+		DefaultShipping: payload.DefaultShipping,
+		DefaultBilling:  payload.DefaultBilling,
+	}, nil
+}
@@ -7,8 +7,10 @@ import (
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/akshtrikha/golang-ecomm/services/auth"
 	"github.com/akshtrikha/golang-ecomm/types"
 	"github.com/gorilla/mux"
 )
@@ -16,7 +18,7 @@ import (
 type mockUserStore struct{}
 
 func (m *mockUserStore) GetUserByEmail(email string) (*types.User, error) {
-	return nil, fmt.Errorf("User not found")
+	return nil, fmt.Errorf("%w: %s", ErrUserNotFound, email)
 }
 
 func (m *mockUserStore) GetUserByID(id int) (*types.User, error) {
@@ -27,12 +29,94 @@ func (m *mockUserStore) CreateUser(u types.User) (int, error) {
 	return 0, nil
 }
 
-func (m *mockUserStore) GetAllUsers() ([]types.User, error)
+func (m *mockUserStore) GetAllUsers() ([]types.User, error) {
+	return nil, nil
+}
+
+func (m *mockUserStore) GetUserRoles(id int) ([]string, error) {
+	return []string{"customer"}, nil
+}
+
+func (m *mockUserStore) SetUserTaxExempt(userID int, exempt bool) error {
+	return nil
+}
+
+type mockCartStore struct {
+	types.CartStore
+}
+
+func (m *mockCartStore) MergeGuestCart(token string, userID int) error {
+	return nil
+}
+
+type mockSessionStore struct {
+	types.SessionStore
+}
+
+// mockAddressStore keeps the address books in memory
+type mockAddressStore struct {
+	addresses map[int]types.Address
+	nextID    int
+}
+
+func newMockAddressStore() *mockAddressStore {
+	return &mockAddressStore{addresses: map[int]types.Address{}, nextID: 1}
+}
+
+func (m *mockAddressStore) GetAddressesByUserID(userID int) ([]types.Address, error) {
+	addresses := []types.Address{}
+	for id := 1; id < m.nextID; id++ {
+		if a, ok := m.addresses[id]; ok && a.UserID == userID {
+			addresses = append(addresses, a)
+		}
+	}
+
+	return addresses, nil
+}
+
+func (m *mockAddressStore) GetAddressByID(id int) (*types.Address, error) {
+	a, ok := m.addresses[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrAddressNotFound, id)
+	}
+
+	return &a, nil
+}
+
+func (m *mockAddressStore) CreateAddress(a types.Address) (int, error) {
+	a.ID = m.nextID
+	m.nextID++
+
+	return a.ID, m.UpdateAddress(a)
+}
+
+// UpdateAddress clears the defaults the address takes from the other addresses
+func (m *mockAddressStore) UpdateAddress(a types.Address) error {
+	for id, other := range m.addresses {
+		if other.UserID == a.UserID && id != a.ID {
+			other.DefaultShipping = other.DefaultShipping && !a.DefaultShipping
+			other.DefaultBilling = other.DefaultBilling && !a.DefaultBilling
+			m.addresses[id] = other
+		}
+	}
+
+	m.addresses[a.ID] = a
+	return nil
+}
+
+func (m *mockAddressStore) DeleteAddress(id int) error {
+	if _, ok := m.addresses[id]; !ok {
+		return fmt.Errorf("%w: %v", ErrAddressNotFound, id)
+	}
+
+	delete(m.addresses, id)
+	return nil
+}
 
 // TestUserServiceHandlers functinoon to implement testing
 func TestUserServiceHandlers(t *testing.T) {
 	userStore := &mockUserStore{}
-	handler := NewHandler(userStore)
+	handler := NewHandler(userStore, &mockCartStore{}, &mockSessionStore{}, newMockAddressStore())
 
 	t.Run("Should fail if the user payload is invalid", func(t *testing.T) {
 		payload := types.RegisterUserPayload{
@@ -88,3 +172,143 @@ func TestUserServiceHandlers(t *testing.T) {
 		}
 	})
 }
+
+// TestAddressBookHandlers function to test the address book endpoints
+func TestAddressBookHandlers(t *testing.T) {
+	addressStore := newMockAddressStore()
+	handler := NewHandler(&mockUserStore{}, &mockCartStore{}, &mockSessionStore{}, addressStore)
+
+	token, err := auth.GenerateJWT(1, []string{auth.RoleCustomer})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherToken, err := auth.GenerateJWT(2, []string{auth.RoleCustomer})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(method, path string, body string, token string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+		router.Use(auth.JWTMiddleware)
+
+		handler.RegisterRoutes(router)
+		router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	home := `{"name":"Test User","line1":"Hauptstrasse 1","city":"Berlin","postalCode":"10115","country":"de"}`
+	work := `{"name":"Test User","line1":"1 Market St","city":"San Francisco","region":" ca","country":"US","defaultShipping":true}`
+
+	t.Run("Should require a login", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/users/me/addresses", nil)
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+		router.Use(auth.JWTMiddleware)
+
+		handler.RegisterRoutes(router)
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status code %d, got %d", http.StatusUnauthorized, rr.Code)
+		}
+	})
+
+	t.Run("Should fail if the address is invalid", func(t *testing.T) {
+		for _, body := range []string{
+			`{"name":"Test User","line1":"Hauptstrasse 1","city":"Berlin","country":"XX"}`,
+			`{"name":"Test User","line1":"  ","city":"Berlin","country":"DE"}`,
+			`{"line1":"Hauptstrasse 1","city":"Berlin","country":"DE"}`,
+		} {
+			rr := serve(http.MethodPost, "/users/me/addresses", body, token)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("Expected status code %d for %s, got %d", http.StatusBadRequest, body, rr.Code)
+			}
+		}
+	})
+
+	t.Run("Should make the first address the default one", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/users/me/addresses", home, token)
+
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		a := addressStore.addresses[1]
+		if a.UserID != 1 || a.Country != "DE" || !a.DefaultShipping || !a.DefaultBilling {
+			t.Errorf("Expected the default address of the user 1 in DE, got %+v", a)
+		}
+	})
+
+	t.Run("Should move the default shipping address", func(t *testing.T) {
+		rr := serve(http.MethodPost, "/users/me/addresses", work, token)
+
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		home, work := addressStore.addresses[1], addressStore.addresses[2]
+		if home.DefaultShipping || !home.DefaultBilling || !work.DefaultShipping || work.DefaultBilling || work.Region != "CA" {
+			t.Errorf("Expected to ship to work and bill to home, got %+v and %+v", home, work)
+		}
+	})
+
+	t.Run("Should list the address book of the user", func(t *testing.T) {
+		rr := serve(http.MethodGet, "/users/me/addresses", "", token)
+
+		var addresses []types.Address
+		json.NewDecoder(rr.Body).Decode(&addresses)
+
+		if len(addresses) != 2 || addresses[0].City != "Berlin" {
+			t.Errorf("Expected the 2 addresses of the user, got %+v", addresses)
+		}
+
+		rr = serve(http.MethodGet, "/users/me/addresses", "", otherToken)
+		if strings.TrimSpace(rr.Body.String()) != "[]" {
+			t.Errorf("Expected an empty address book, got %s", rr.Body.String())
+		}
+	})
+
+	t.Run("Should update the address", func(t *testing.T) {
+		rr := serve(http.MethodPut, "/users/me/addresses/1", `{"name":"Test User","line1":"Hauptstrasse 2","city":"Berlin","country":"DE","defaultBilling":true}`, token)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		if a := addressStore.addresses[1]; a.Line1 != "Hauptstrasse 2" || a.UserID != 1 {
+			t.Errorf("Expected the new street of the address, got %+v", a)
+		}
+	})
+
+	t.Run("Should not expose the addresses of other users", func(t *testing.T) {
+		if rr := serve(http.MethodPut, "/users/me/addresses/1", home, otherToken); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+
+		if rr := serve(http.MethodDelete, "/users/me/addresses/1", "", otherToken); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("Should delete the address", func(t *testing.T) {
+		if rr := serve(http.MethodDelete, "/users/me/addresses/2", "", token); rr.Code != http.StatusNoContent {
+			t.Errorf("Expected status code %d, got %d", http.StatusNoContent, rr.Code)
+		}
+
+		if _, ok := addressStore.addresses[2]; ok {
+			t.Error("Expected the address to be deleted")
+		}
+	})
+}
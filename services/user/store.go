@@ -2,9 +2,11 @@ package user
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 
+	"github.com/akshtrikha/golang-ecomm/services/auth"
 	"github.com/akshtrikha/golang-ecomm/types"
 )
 
@@ -19,6 +21,12 @@ func NewStore(db *sql.DB) *Store {
 	return &Store{db: db}
 }
 
+// ErrUserNotFound is returned when a user does not exist
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrAddressNotFound is returned when an address does not exist
+var ErrAddressNotFound = errors.New("address not found")
+
 // GetUserByEmail function to run a SQL query and find the user by email
 func (s *Store) GetUserByEmail(email string) (*types.User, error) {
 	// log.Printf("Inside store.GetUserByEmail with email: %s", email)
@@ -37,7 +45,7 @@ func (s *Store) GetUserByEmail(email string) (*types.User, error) {
 	}
 
 	if u.ID == 0 {
-		return nil, fmt.Errorf("user with email: %s not found", email)
+		return nil, fmt.Errorf("%w: %s", ErrUserNotFound, email)
 	}
 
 	log.Println("Returning the user")
@@ -61,15 +69,23 @@ func (s *Store) GetUserByID(id int) (*types.User, error) {
 	}
 
 	if u.ID == 0 {
-		return nil, fmt.Errorf("user with id: %v not found", id)
+		return nil, fmt.Errorf("%w: %v", ErrUserNotFound, id)
 	}
 
 	return u, nil
 }
 
 // CreateUser function to run a SQL query and create the user
+// new users are assigned the customer role
 func (s *Store) CreateUser(u types.User) (int, error) {
-	result, err := s.db.Exec("INSERT INTO users (firstName, lastName, email, password) VALUES (?, ?, ?, ?)", u.FirstName, u.LastName, u.Email, u.Password)
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT INTO users (firstName, lastName, email, password) VALUES (?, ?, ?, ?)", u.FirstName, u.LastName, u.Email, u.Password)
 
 	if err != nil {
 		return 0, err
@@ -81,9 +97,60 @@ func (s *Store) CreateUser(u types.User) (int, error) {
 		return 0, err
 	}
 
+	if _, err := tx.Exec("INSERT INTO user_roles (userId, role) VALUES (?, ?)", id, auth.RoleCustomer); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
 	return int(id), nil
 }
 
+// GetUserRoles function to get the roles assigned to the user
+func (s *Store) GetUserRoles(userID int) ([]string, error) {
+	rows, err := s.db.Query("SELECT role FROM user_roles WHERE userId = ? ORDER BY role", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []string{}
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
+
+// SetUserTaxExempt function to set whether the orders of the user are taxed
+func (s *Store) SetUserTaxExempt(userID int, exempt bool) error {
+	result, err := s.db.Exec("UPDATE users SET taxExempt = ? WHERE id = ?", exempt, userID)
+	if err != nil {
+		return err
+	}
+
+	// MySQL only counts the changed rows, an unchanged user still exists
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		if _, err := s.GetUserByID(userID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // GetAllUsers to get all the users from the database
 func (s *Store) GetAllUsers() ([]types.User, error) {
 	result, err := s.db.Query("SELECT * FROM users")
@@ -116,6 +183,7 @@ func scanRowIntoUser(rows *sql.Rows) (*types.User, error) {
 		&user.Email,
 		&user.Password,
 		&user.CreatedAt,
+		&user.TaxExempt,
 	)
 
 	if err != nil {
@@ -124,3 +192,172 @@ func scanRowIntoUser(rows *sql.Rows) (*types.User, error) {
 
 	return user, nil
 }
+
+// addressColumns is the list of columns scanned by scanRowIntoAddress
+const addressColumns = "id, userId, name, line1, line2, city, region, postalCode, country, phone, isDefaultShipping, isDefaultBilling, createdAt"
+
+// GetAddressesByUserID function to get the address book of the user
+func (s *Store) GetAddressesByUserID(userID int) ([]types.Address, error) {
+	rows, err := s.db.Query("SELECT "+addressColumns+" FROM user_addresses WHERE userId = ? ORDER BY id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	addresses := []types.Address{}
+	for rows.Next() {
+		a, err := scanRowIntoAddress(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		addresses = append(addresses, *a)
+	}
+
+	return addresses, rows.Err()
+}
+
+// GetAddressByID function to find the address by id
+func (s *Store) GetAddressByID(id int) (*types.Address, error) {
+	rows, err := s.db.Query("SELECT "+addressColumns+" FROM user_addresses WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	a := new(types.Address)
+	for rows.Next() {
+		a, err = scanRowIntoAddress(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if a.ID == 0 {
+		return nil, fmt.Errorf("%w: %v", ErrAddressNotFound, id)
+	}
+
+	return a, nil
+}
+
+// CreateAddress function to add the address to the address book of its user,
+// a new default address replaces the previous default of the user
+func (s *Store) CreateAddress(a types.Address) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`INSERT INTO user_addresses (userId, name, line1, line2, city, region, postalCode, country, phone, isDefaultShipping, isDefaultBilling)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		a.UserID, a.Name, a.Line1, a.Line2, a.City, a.Region, a.PostalCode, a.Country, a.Phone, a.DefaultShipping, a.DefaultBilling,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	a.ID = int(id)
+	if err := clearOtherDefaults(tx, a); err != nil {
+		return 0, err
+	}
+
+	return a.ID, tx.Commit()
+}
+
+// UpdateAddress function to replace the address, the orders
+// already placed keep their copy of the previous address
+func (s *Store) UpdateAddress(a types.Address) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`UPDATE user_addresses SET name = ?, line1 = ?, line2 = ?, city = ?, region = ?, postalCode = ?, country = ?, phone = ?,
+			isDefaultShipping = ?, isDefaultBilling = ?
+		WHERE id = ?`,
+		a.Name, a.Line1, a.Line2, a.City, a.Region, a.PostalCode, a.Country, a.Phone, a.DefaultShipping, a.DefaultBilling, a.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := clearOtherDefaults(tx, a); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// clearOtherDefaults removes the default flags the address takes
+// from the other addresses of the user
+func clearOtherDefaults(tx *sql.Tx, a types.Address) error {
+	if a.DefaultShipping {
+		if _, err := tx.Exec("UPDATE user_addresses SET isDefaultShipping = FALSE WHERE userId = ? AND id <> ?", a.UserID, a.ID); err != nil {
+			return err
+		}
+	}
+
+	if a.DefaultBilling {
+		if _, err := tx.Exec("UPDATE user_addresses SET isDefaultBilling = FALSE WHERE userId = ? AND id <> ?", a.UserID, a.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteAddress function to remove the address from the address book
+func (s *Store) DeleteAddress(id int) error {
+	result, err := s.db.Exec("DELETE FROM user_addresses WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w: %v", ErrAddressNotFound, id)
+	}
+
+	return nil
+}
+
+func scanRowIntoAddress(rows *sql.Rows) (*types.Address, error) {
+	a := new(types.Address)
+
+	err := rows.Scan(
+		&a.ID,
+		&a.UserID,
+		&a.Name,
+		&a.Line1,
+		&a.Line2,
+		&a.City,
+		&a.Region,
+		&a.PostalCode,
+		&a.Country,
+		&a.Phone,
+		&a.DefaultShipping,
+		&a.DefaultBilling,
+		&a.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
@@ -0,0 +1,250 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/akshtrikha/golang-ecomm/services/order"
+	"github.com/akshtrikha/golang-ecomm/services/payment"
+	"github.com/akshtrikha/golang-ecomm/services/refund"
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// Types of the payment events sent by the providers
+const (
+	EventPaymentCaptured = "payment.captured"
+	EventPaymentFailed   = "payment.failed"
+	EventPaymentRefunded = "payment.refunded"
+)
+
+// queueSize is the number of events waiting to be processed,
+// the events received when the queue is full stay pending
+const queueSize = 256
+
+// Processor applies the webhook events to the payments and their orders in the
+// background. The events are processed one at a time so the events of a payment
+// are applied in the order they were received
+type Processor struct {
+	store        types.WebhookEventStore
+	paymentStore types.PaymentStore
+	orderStore   types.OrderStore
+	refunder     *refund.Refunder
+	queue        chan int
+}
+
+// NewProcessor constructor takes WebhookEventStore, PaymentStore, OrderStore and Refunder as dependencies
+func NewProcessor(store types.WebhookEventStore, paymentStore types.PaymentStore, orderStore types.OrderStore, refunder *refund.Refunder) *Processor {
+	return &Processor{
+		store:        store,
+		paymentStore: paymentStore,
+		orderStore:   orderStore,
+		refunder:     refunder,
+		queue:        make(chan int, queueSize),
+	}
+}
+
+// Enqueue schedules the processing of the event, it reports false
+// and leaves the event pending when the queue is full
+func (p *Processor) Enqueue(id int) bool {
+	select {
+	case p.queue <- id:
+		return true
+	default:
+		log.Printf("Webhook queue is full, event %v stays pending", id)
+		return false
+	}
+}
+
+// Requeue schedules the events left pending, like the ones
+// received before a restart or while the queue was full
+func (p *Processor) Requeue() error {
+	events, err := p.store.GetWebhookEventsByStatus(types.WebhookEventPending)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range events {
+		if !p.Enqueue(e.ID) {
+			break
+		}
+	}
+
+	return nil
+}
+
+// Run processes the queued events, it is meant to run in its own goroutine
+func (p *Processor) Run() {
+	for id := range p.queue {
+		if err := p.ProcessEvent(id); err != nil {
+			log.Printf("Error processing the webhook event %v, error: %+v", id, err)
+		}
+	}
+}
+
+// ProcessEvent applies the event and records whether it was processed or failed,
+// the events already processed are skipped
+func (p *Processor) ProcessEvent(id int) error {
+	e, err := p.store.GetWebhookEventByID(id)
+	if err != nil {
+		return err
+	}
+
+	if e.Status == types.WebhookEventProcessed {
+		return nil
+	}
+
+	if err := p.apply(e); err != nil {
+		if updateErr := p.store.UpdateWebhookEventStatus(id, types.WebhookEventFailed, err.Error()); updateErr != nil {
+			log.Printf("Error recording the failure of the webhook event %v, error: %+v", id, updateErr)
+		}
+
+		return err
+	}
+
+	return p.store.UpdateWebhookEventStatus(id, types.WebhookEventProcessed, "")
+}
+
+// apply moves the payment of the event and its order to the status of the event
+func (p *Processor) apply(e *types.WebhookEvent) error {
+	var event types.PaymentEvent
+	if err := json.Unmarshal([]byte(e.Payload), &event); err != nil {
+		return err
+	}
+
+	switch e.Type {
+	case EventPaymentCaptured, EventPaymentFailed, EventPaymentRefunded:
+	default:
+		log.Printf("Ignoring the webhook event %v of type %s", e.ID, e.Type)
+		return nil
+	}
+
+	pay, err := p.paymentStore.GetPaymentByReference(e.Provider, event.Data.Reference)
+	if err != nil {
+		return err
+	}
+
+	switch e.Type {
+	case EventPaymentCaptured:
+		return p.captured(pay, event.Data)
+	case EventPaymentFailed:
+		return p.failed(pay, event.Data)
+	}
+
+	return p.refunded(pay, event.Data)
+}
+
+// captured records the capture of the payment and moves its order to paid,
+// a payment already captured through the api is left as it is. The capture of
+// the payment of an order that is no longer awaiting payment, like a cancelled
+// one, fails so the money taken by the provider is given back by hand
+func (p *Processor) captured(pay *types.Payment, data types.PaymentEventData) error {
+	pay, err := p.paymentStore.UpdatePayment(pay.ID, func(o *types.Order, next *types.Payment) error {
+		if next.Status != types.PaymentRequiresAction && next.Status != types.PaymentAuthorized {
+			return nil
+		}
+
+		if !order.IsAwaitingPayment(o.Status) {
+			return fmt.Errorf("%w: order %v is %s and its payment %v cannot be captured", payment.ErrNotPayable, o.ID, o.Status, next.ID)
+		}
+
+		amount := next.Amount
+		if data.Amount != nil {
+			amount = *data.Amount
+		}
+
+		// the provider captures the payments requiring an action once they are completed
+		next.Status = types.PaymentAuthorized
+		next.ActionURL = ""
+		return payment.Capture(next, amount)
+	})
+	if err != nil {
+		return err
+	}
+
+	if !payment.IsActive(*pay) {
+		return fmt.Errorf("%w: the %s payment %v cannot be captured", payment.ErrInvalidOperation, pay.Status, pay.ID)
+	}
+
+	return p.moveOrder(pay.OrderID, types.OrderStatusPaid, fmt.Sprintf("payment %v captured", pay.ID))
+}
+
+// failed records the decline of the payment and moves its order to payment_failed,
+// the failures of payments already captured are ignored
+func (p *Processor) failed(pay *types.Payment, data types.PaymentEventData) error {
+	declined := false
+	pay, err := p.paymentStore.UpdatePayment(pay.ID, func(o *types.Order, next *types.Payment) error {
+		if next.Status != types.PaymentRequiresAction && next.Status != types.PaymentAuthorized {
+			return nil
+		}
+
+		next.Status = types.PaymentDeclined
+		next.ActionURL = ""
+		next.DeclineReason = data.Reason
+		if next.DeclineReason == "" {
+			next.DeclineReason = "payment_failed"
+		}
+
+		declined = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if !declined {
+		log.Printf("Ignoring the failure of the %s payment %v", pay.Status, pay.ID)
+		return nil
+	}
+
+	return p.moveOrder(pay.OrderID, types.OrderStatusPaymentFailed, fmt.Sprintf("payment %v failed: %s", pay.ID, pay.DeclineReason))
+}
+
+// refunded records the refunds made at the provider with a credit note of whatever is
+// left of the order, which moves the order to refunded. The amount of the event is
+// the refunded amount so far, the refunds of the credit notes are not counted twice.
+// The refunds of part of what is left fail as their order items are not known
+func (p *Processor) refunded(pay *types.Payment, data types.PaymentEventData) error {
+	total := pay.Captured
+	if data.Amount != nil {
+		total = *data.Amount
+	}
+
+	cmp, err := total.Cmp(pay.Refunded)
+	if err != nil {
+		return err
+	}
+
+	if cmp > 0 {
+		amount, err := total.Sub(pay.Refunded)
+		if err != nil {
+			return err
+		}
+
+		_, err = p.refunder.Record(pay.OrderID, amount, fmt.Sprintf("payment %v refunded at the provider", pay.ID))
+		return err
+	}
+
+	if pay.Status != types.PaymentRefunded {
+		return nil
+	}
+
+	return p.moveOrder(pay.OrderID, types.OrderStatusRefunded, fmt.Sprintf("payment %v refunded", pay.ID))
+}
+
+// moveOrder moves the order to the status when the order state machine allows it,
+// the order is left as it is when it is already there or past it
+func (p *Processor) moveOrder(orderID int, status types.OrderStatus, note string) error {
+	o, err := p.orderStore.GetOrderByID(orderID)
+	if err != nil {
+		return err
+	}
+
+	if !order.CanTransition(o.Status, status) {
+		log.Printf("Order %v is %s and is not moved to %s", orderID, o.Status, status)
+		return nil
+	}
+
+	_, err = p.orderStore.UpdateOrderStatus(orderID, status, 0, note)
+	return err
+}
@@ -0,0 +1,168 @@
+package webhook
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/akshtrikha/golang-ecomm/services/payment"
+	"github.com/akshtrikha/golang-ecomm/services/refund"
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+func usd(amount int64) types.Money {
+	return types.NewMoney(amount, types.DefaultCurrency)
+}
+
+// TestProcessor function to test the payments and the orders moved by the events
+func TestProcessor(t *testing.T) {
+	store := &mockEventStore{events: map[int]types.WebhookEvent{}}
+	orderStore := &mockOrderStore{orders: map[int]types.Order{}, items: map[int][]types.OrderItem{}}
+	paymentStore := &mockPaymentStore{payments: map[int]types.Payment{}, orderStore: orderStore}
+	creditNoteStore := &mockCreditNoteStore{orderStore: orderStore, paymentStore: paymentStore}
+	processor := NewProcessor(store, paymentStore, orderStore, refund.NewRefunder(creditNoteStore, orderStore, nil))
+
+	// the orders of an item of 20.00 with a payment waiting for the provider
+	for id := 1; id <= 2; id++ {
+		orderStore.orders[id] = types.Order{ID: id, UserID: 2, Total: usd(2000), Shipping: usd(0), Refunded: usd(0), Currency: types.DefaultCurrency, Status: types.OrderStatusPending}
+		orderStore.items[id] = []types.OrderItem{{ID: id, OrderID: id, ProductID: 1, Quantity: 1, Price: usd(2000), Discount: usd(0), Tax: usd(0)}}
+		paymentStore.payments[id] = types.Payment{
+			ID:        id,
+			OrderID:   id,
+			Gateway:   "fake",
+			Reference: fmt.Sprintf("fake_%d", id),
+			Status:    types.PaymentRequiresAction,
+			Amount:    usd(2000),
+			Captured:  usd(0),
+			Refunded:  usd(0),
+		}
+	}
+
+	process := func(eventID string, eventType string, payload string) types.WebhookEvent {
+		e, _, _ := store.SaveWebhookEvent(types.WebhookEvent{Provider: "fake", EventID: eventID, Type: eventType, Payload: payload})
+		processor.ProcessEvent(e.ID)
+
+		return store.events[e.ID]
+	}
+
+	t.Run("Should capture the payment and mark the order as paid", func(t *testing.T) {
+		e := process("evt_1", EventPaymentCaptured, `{"id":"evt_1","type":"payment.captured","data":{"reference":"fake_1"}}`)
+
+		if e.Status != types.WebhookEventProcessed {
+			t.Errorf("Expected the event to be processed, got %+v", e)
+		}
+
+		if p := paymentStore.payments[1]; p.Status != types.PaymentCaptured || p.Captured != usd(2000) {
+			t.Errorf("Expected the payment to be captured, got %+v", p)
+		}
+
+		if orderStore.orders[1].Status != types.OrderStatusPaid {
+			t.Errorf("Expected the order to be paid, got %s", orderStore.orders[1].Status)
+		}
+
+		// a capture reported twice under another event id changes nothing
+		if e := process("evt_1b", EventPaymentCaptured, `{"id":"evt_1b","type":"payment.captured","data":{"reference":"fake_1"}}`); e.Status != types.WebhookEventProcessed {
+			t.Errorf("Expected the event to be processed, got %+v", e)
+		}
+	})
+
+	t.Run("Should decline the payment and mark the order as failed", func(t *testing.T) {
+		e := process("evt_2", EventPaymentFailed, `{"id":"evt_2","type":"payment.failed","data":{"reference":"fake_2","reason":"insufficient_funds"}}`)
+
+		if e.Status != types.WebhookEventProcessed {
+			t.Errorf("Expected the event to be processed, got %+v", e)
+		}
+
+		if p := paymentStore.payments[2]; p.Status != types.PaymentDeclined || p.DeclineReason != "insufficient_funds" {
+			t.Errorf("Expected the payment to be declined, got %+v", p)
+		}
+
+		if orderStore.orders[2].Status != types.OrderStatusPaymentFailed {
+			t.Errorf("Expected the payment of the order to have failed, got %s", orderStore.orders[2].Status)
+		}
+
+		// the failure of a captured payment is out of date
+		process("evt_3", EventPaymentFailed, `{"id":"evt_3","type":"payment.failed","data":{"reference":"fake_1"}}`)
+		if paymentStore.payments[1].Status != types.PaymentCaptured || orderStore.orders[1].Status != types.OrderStatusPaid {
+			t.Errorf("Expected the captured payment to be left as it is, got %+v", paymentStore.payments[1])
+		}
+	})
+
+	t.Run("Should fail the partial refunds made at the provider", func(t *testing.T) {
+		e := process("evt_4", EventPaymentRefunded, `{"id":"evt_4","type":"payment.refunded","data":{"reference":"fake_1","amount":"5.00"}}`)
+
+		if e.Status != types.WebhookEventFailed || !strings.Contains(e.LastError, refund.ErrPartialRefund.Error()) {
+			t.Errorf("Expected the event to fail, got %+v", e)
+		}
+
+		if p := paymentStore.payments[1]; p.Status != types.PaymentCaptured || !p.Refunded.IsZero() || len(creditNoteStore.notes) != 0 {
+			t.Errorf("Expected nothing to be refunded, got %+v", p)
+		}
+	})
+
+	t.Run("Should record the refund of the order with a credit note once", func(t *testing.T) {
+		process("evt_5", EventPaymentRefunded, `{"id":"evt_5","type":"payment.refunded","data":{"reference":"fake_1","amount":"20.00"}}`)
+		process("evt_6", EventPaymentRefunded, `{"id":"evt_6","type":"payment.refunded","data":{"reference":"fake_1","amount":"20.00"}}`)
+
+		if p := paymentStore.payments[1]; p.Status != types.PaymentRefunded || p.Refunded != usd(2000) {
+			t.Errorf("Expected the payment to be refunded, got %+v", p)
+		}
+
+		if len(creditNoteStore.notes) != 1 || creditNoteStore.notes[0].Total != usd(2000) || orderStore.items[1][0].RefundedQuantity != 1 {
+			t.Errorf("Expected a credit note of the item, got %+v", creditNoteStore.notes)
+		}
+
+		if o := orderStore.orders[1]; o.Status != types.OrderStatusRefunded || o.Refunded != usd(2000) {
+			t.Errorf("Expected the order to be refunded, got %+v", o)
+		}
+	})
+
+	t.Run("Should record the failure of the event and process it again", func(t *testing.T) {
+		payload := `{"id":"evt_7","type":"payment.captured","data":{"reference":"fake_3"}}`
+
+		e := process("evt_7", EventPaymentCaptured, payload)
+		if e.Status != types.WebhookEventFailed || e.LastError == "" || e.Attempts != 1 {
+			t.Errorf("Expected the event of an unknown payment to fail, got %+v", e)
+		}
+
+		// the payment is recorded after the event arrived
+		orderStore.orders[3] = types.Order{ID: 3, Status: types.OrderStatusPending}
+		paymentStore.payments[3] = types.Payment{ID: 3, OrderID: 3, Gateway: "fake", Reference: "fake_3", Status: types.PaymentAuthorized, Amount: usd(2000), Captured: usd(0), Refunded: usd(0)}
+
+		if e := process("evt_7", EventPaymentCaptured, payload); e.Status != types.WebhookEventProcessed || e.Attempts != 2 {
+			t.Errorf("Expected the event to be processed, got %+v", e)
+		}
+
+		if orderStore.orders[3].Status != types.OrderStatusPaid {
+			t.Errorf("Expected the order to be paid, got %s", orderStore.orders[3].Status)
+		}
+	})
+
+	t.Run("Should fail the capture of the payment of a cancelled order", func(t *testing.T) {
+		orderStore.orders[4] = types.Order{ID: 4, Status: types.OrderStatusCancelled}
+		paymentStore.payments[4] = types.Payment{ID: 4, OrderID: 4, Gateway: "fake", Reference: "fake_4", Status: types.PaymentAuthorized, Amount: usd(2000), Captured: usd(0), Refunded: usd(0)}
+
+		e := process("evt_10", EventPaymentCaptured, `{"id":"evt_10","type":"payment.captured","data":{"reference":"fake_4"}}`)
+		if e.Status != types.WebhookEventFailed || !strings.Contains(e.LastError, payment.ErrNotPayable.Error()) {
+			t.Errorf("Expected the event to fail, got %+v", e)
+		}
+
+		if p := paymentStore.payments[4]; p.Status != types.PaymentAuthorized || orderStore.orders[4].Status != types.OrderStatusCancelled {
+			t.Errorf("Expected the payment and the cancelled order to be left as they are, got %+v", p)
+		}
+	})
+
+	t.Run("Should ignore the unknown events", func(t *testing.T) {
+		if e := process("evt_8", "payment.disputed", `{"id":"evt_8","type":"payment.disputed","data":{"reference":"fake_1"}}`); e.Status != types.WebhookEventProcessed {
+			t.Errorf("Expected the event to be processed, got %+v", e)
+		}
+	})
+
+	t.Run("Should requeue the pending events", func(t *testing.T) {
+		store.SaveWebhookEvent(types.WebhookEvent{Provider: "fake", EventID: "evt_9", Type: EventPaymentCaptured, Payload: `{}`})
+
+		if err := processor.Requeue(); err != nil || len(processor.queue) != 1 {
+			t.Errorf("Expected the pending event to be queued, got %d queued, error: %v", len(processor.queue), err)
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/akshtrikha/golang-ecomm/config"
+	"github.com/akshtrikha/golang-ecomm/types"
+	"github.com/akshtrikha/golang-ecomm/utils"
+	"github.com/gorilla/mux"
+)
+
+// API Endpoint for the payment provider to report the
+// outcome of the payments, the requests are signed
+
+// maxBodySize is the largest webhook request body accepted
+const maxBodySize = 1 << 20
+
+// Handler to the webhook event store which will deal
+// with the database regarding the received events
+type Handler struct {
+	store     types.WebhookEventStore
+	processor *Processor
+	// the name of the gateway the events are received from
+	provider  string
+	secret    string
+	tolerance time.Duration
+}
+
+// NewHandler constructor takes WebhookEventStore, the Processor and the name of the provider as dependencies
+func NewHandler(store types.WebhookEventStore, processor *Processor, provider string) *Handler {
+	return &Handler{
+		store:     store,
+		processor: processor,
+		provider:  provider,
+		secret:    config.Envs.PaymentWebhookSecret,
+		tolerance: time.Second * time.Duration(config.Envs.WebhookToleranceInSeconds),
+	}
+}
+
+// RegisterRoutes func for webhooks
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/webhooks/payments/{provider}", h.handlePaymentWebhook).Methods("POST")
+}
+
+func (h *Handler) handlePaymentWebhook(w http.ResponseWriter, r *http.Request) {
+	log.Println("handle POST /webhooks/payments/{provider} endpoint hit")
+
+	provider := mux.Vars(r)["provider"]
+	if provider != h.provider {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("unknown payment provider %q", provider))
+		return
+	}
+
+	// the signature is computed over the body as it was sent
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := Verify(h.secret, r.Header.Get(SignatureHeader), body, time.Now(), h.tolerance); err != nil {
+		log.Printf("Rejected a webhook of %s, error: %+v", provider, err)
+		utils.WriteError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	// get the json payload
+	var event types.PaymentEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		log.Println("Payload parsing met with an error")
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// validate the json payload
+	if err := utils.Validate.Struct(event); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.ValidationError("Invalid payload", err))
+		return
+	}
+
+	e, created, err := h.store.SaveWebhookEvent(types.WebhookEvent{
+		Provider: provider,
+		EventID:  event.ID,
+		Type:     event.Type,
+		Payload:  string(body),
+	})
+	if err != nil {
+		log.Println("Error adding the webhook event to the database")
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// the providers send an event again until it is acknowledged,
+	// the events already processed are only acknowledged
+	if !created && e.Status == types.WebhookEventProcessed {
+		log.Printf("Webhook event %v of %s already processed", event.ID, provider)
+		utils.WriteJSON(w, http.StatusOK, map[string]int{"id": e.ID})
+		return
+	}
+
+	h.processor.Enqueue(e.ID)
+
+	log.Printf("Webhook event %v of %s queued as %v", event.ID, provider, e.ID)
+
+	utils.WriteJSON(w, http.StatusAccepted, map[string]int{"id": e.ID})
+}
@@ -0,0 +1,358 @@
+package webhook
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/akshtrikha/golang-ecomm/config"
+	"github.com/akshtrikha/golang-ecomm/services/order"
+	"github.com/akshtrikha/golang-ecomm/services/payment"
+	"github.com/akshtrikha/golang-ecomm/services/refund"
+	"github.com/akshtrikha/golang-ecomm/types"
+	"github.com/gorilla/mux"
+)
+
+// mockEventStore keeps the webhook events in memory
+type mockEventStore struct {
+	events map[int]types.WebhookEvent
+}
+
+func (m *mockEventStore) SaveWebhookEvent(e types.WebhookEvent) (*types.WebhookEvent, bool, error) {
+	for _, stored := range m.events {
+		if stored.Provider == e.Provider && stored.EventID == e.EventID {
+			return &stored, false, nil
+		}
+	}
+
+	e.ID = len(m.events) + 1
+	e.Status = types.WebhookEventPending
+	m.events[e.ID] = e
+
+	return &e, true, nil
+}
+
+func (m *mockEventStore) GetWebhookEventByID(id int) (*types.WebhookEvent, error) {
+	e, ok := m.events[id]
+	if !ok {
+		return nil, fmt.Errorf("webhook event with id: %v not found", id)
+	}
+
+	return &e, nil
+}
+
+func (m *mockEventStore) GetWebhookEventsByStatus(status types.WebhookEventStatus) ([]types.WebhookEvent, error) {
+	events := []types.WebhookEvent{}
+	for id := 1; id <= len(m.events); id++ {
+		if m.events[id].Status == status {
+			events = append(events, m.events[id])
+		}
+	}
+
+	return events, nil
+}
+
+func (m *mockEventStore) UpdateWebhookEventStatus(id int, status types.WebhookEventStatus, lastError string) error {
+	e := m.events[id]
+	e.Status = status
+	e.LastError = lastError
+	if status != types.WebhookEventPending {
+		e.Attempts++
+	}
+	m.events[id] = e
+
+	return nil
+}
+
+// mockPaymentStore keeps the payments in memory
+// along with the orders they pay
+type mockPaymentStore struct {
+	payments   map[int]types.Payment
+	orderStore *mockOrderStore
+}
+
+func (m *mockPaymentStore) GetPaymentByID(id int) (*types.Payment, error) {
+	p, ok := m.payments[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", payment.ErrPaymentNotFound, id)
+	}
+
+	return &p, nil
+}
+
+func (m *mockPaymentStore) GetPaymentsByOrderID(orderID int) ([]types.Payment, error) {
+	return []types.Payment{}, nil
+}
+
+func (m *mockPaymentStore) GetPaymentByReference(gateway string, reference string) (*types.Payment, error) {
+	for _, p := range m.payments {
+		if p.Gateway == gateway && p.Reference == reference {
+			return &p, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %v", payment.ErrPaymentNotFound, reference)
+}
+
+func (m *mockPaymentStore) CreatePayment(orderID int, authorize func(*types.Order, []types.Payment) (*types.Payment, error)) (*types.Payment, error) {
+	o, err := m.orderStore.GetOrderByID(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	payments, _ := m.GetPaymentsByOrderID(orderID)
+
+	p, err := authorize(o, payments)
+	if err != nil {
+		return nil, err
+	}
+
+	p.ID = len(m.payments) + 1
+	p.OrderID = orderID
+	m.payments[p.ID] = *p
+
+	return p, nil
+}
+
+func (m *mockPaymentStore) UpdatePayment(id int, update func(*types.Order, *types.Payment) error) (*types.Payment, error) {
+	p, err := m.GetPaymentByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	o, err := m.orderStore.GetOrderByID(p.OrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := update(o, p); err != nil {
+		return nil, err
+	}
+
+	m.payments[id] = *p
+	return p, nil
+}
+
+// capturedPayment returns the payment of the order refunds are
+// given back from, nil when the order has none
+func (m *mockPaymentStore) capturedPayment(orderID int) *types.Payment {
+	for _, p := range m.payments {
+		if p.OrderID == orderID && (p.Status == types.PaymentCaptured || p.Status == types.PaymentPartiallyRefunded) {
+			return &p
+		}
+	}
+
+	return nil
+}
+
+// mockOrderStore keeps the orders and their items in memory
+// and moves the orders through the order state machine
+type mockOrderStore struct {
+	orders map[int]types.Order
+	items  map[int][]types.OrderItem
+}
+
+func (m *mockOrderStore) PlaceOrder(checkout types.Checkout) (*types.Order, error) {
+	return nil, nil
+}
+
+func (m *mockOrderStore) GetOrderByID(id int) (*types.Order, error) {
+	o, ok := m.orders[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", order.ErrOrderNotFound, id)
+	}
+
+	return &o, nil
+}
+
+func (m *mockOrderStore) GetOrdersByUserID(userID int) ([]types.Order, error) {
+	return []types.Order{}, nil
+}
+
+func (m *mockOrderStore) GetAllOrders() ([]types.Order, error) {
+	return []types.Order{}, nil
+}
+
+func (m *mockOrderStore) GetOrderItems(orderID int) ([]types.OrderItem, error) {
+	return m.items[orderID], nil
+}
+
+func (m *mockOrderStore) GetOrderPromotions(orderID int) ([]types.AppliedPromotion, error) {
+	return []types.AppliedPromotion{}, nil
+}
+
+func (m *mockOrderStore) UpdateOrderStatus(orderID int, status types.OrderStatus, changedBy int, note string) (*types.Order, error) {
+	o, err := m.GetOrderByID(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := order.ValidateTransition(o.Status, status); err != nil {
+		return nil, err
+	}
+
+	o.Status = status
+	m.orders[orderID] = *o
+
+	return o, nil
+}
+
+func (m *mockOrderStore) GetOrderStatusHistory(orderID int) ([]types.OrderStatusChange, error) {
+	return []types.OrderStatusChange{}, nil
+}
+
+// mockCreditNoteStore computes the credit notes of the orders kept in memory
+// and only records them and their payment when the refund succeeds
+type mockCreditNoteStore struct {
+	orderStore   *mockOrderStore
+	paymentStore *mockPaymentStore
+	notes        []types.CreditNote
+}
+
+func (m *mockCreditNoteStore) CreateCreditNote(orderID int, createdBy int, payload types.RefundPayload, refundFn func(*types.CreditNote, *types.Payment) error) (*types.CreditNote, error) {
+	o, err := m.orderStore.GetOrderByID(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	items := m.orderStore.items[orderID]
+	note, err := refund.Build(*o, items, types.NewMoney(0, o.Currency), payload)
+	if err != nil {
+		return nil, err
+	}
+
+	pay := m.paymentStore.capturedPayment(orderID)
+	if err := refundFn(note, pay); err != nil {
+		return nil, err
+	}
+
+	if note.PaymentID != nil {
+		m.paymentStore.payments[pay.ID] = *pay
+	}
+
+	note.ID = len(m.notes) + 1
+	note.Number = fmt.Sprintf("CN-%06d", note.ID)
+	m.notes = append(m.notes, *note)
+
+	for _, line := range note.Lines {
+		for i := range items {
+			if items[i].ID == line.OrderItemID {
+				items[i].RefundedQuantity += line.Quantity
+			}
+		}
+	}
+
+	o.Refunded.Amount += note.Total.Amount
+	m.orderStore.orders[orderID] = *o
+
+	return note, nil
+}
+
+func (m *mockCreditNoteStore) GetCreditNotesByOrderID(orderID int) ([]types.CreditNote, error) {
+	return m.notes, nil
+}
+
+// TestMain signs the webhook requests of the tests with a test secret
+func TestMain(m *testing.M) {
+	config.Envs.PaymentWebhookSecret = "test-webhook-secret"
+
+	os.Exit(m.Run())
+}
+
+// TestWebhookServiceHandlers function to implement testing
+func TestWebhookServiceHandlers(t *testing.T) {
+	store := &mockEventStore{events: map[int]types.WebhookEvent{}}
+	orderStore := &mockOrderStore{orders: map[int]types.Order{}}
+	processor := NewProcessor(store, &mockPaymentStore{payments: map[int]types.Payment{}, orderStore: orderStore}, orderStore, nil)
+	handler := NewHandler(store, processor, "fake")
+
+	serve := func(path string, body string, signature string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set(SignatureHeader, signature)
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		handler.RegisterRoutes(router)
+		router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	body := `{"id":"evt_1","type":"payment.captured","data":{"reference":"fake_1_1"}}`
+	sign := func(body string) string {
+		return Sign(handler.secret, time.Now(), []byte(body))
+	}
+
+	t.Run("Should fail for an unknown provider", func(t *testing.T) {
+		rr := serve("/webhooks/payments/other", body, sign(body))
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("Should reject the requests with an invalid signature", func(t *testing.T) {
+		rr := serve("/webhooks/payments/fake", body, Sign("not-the-secret", time.Now(), []byte(body)))
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status code %d, got %d", http.StatusUnauthorized, rr.Code)
+		}
+
+		if len(store.events) != 0 {
+			t.Errorf("Expected no event to be recorded, got %+v", store.events)
+		}
+	})
+
+	t.Run("Should fail if the event is invalid", func(t *testing.T) {
+		invalid := `{"id":"evt_2","type":"payment.captured","data":{}}`
+		rr := serve("/webhooks/payments/fake", invalid, sign(invalid))
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("Should record and queue the event", func(t *testing.T) {
+		rr := serve("/webhooks/payments/fake", body, sign(body))
+
+		if rr.Code != http.StatusAccepted {
+			t.Fatalf("Expected status code %d, got %d", http.StatusAccepted, rr.Code)
+		}
+
+		if e := store.events[1]; e.EventID != "evt_1" || e.Type != EventPaymentCaptured || e.Payload != body {
+			t.Errorf("Expected the event to be recorded, got %+v", e)
+		}
+
+		if len(processor.queue) != 1 {
+			t.Errorf("Expected the event to be queued, got %d queued", len(processor.queue))
+		}
+	})
+
+	t.Run("Should only acknowledge the events already processed", func(t *testing.T) {
+		// the event is queued again until it is processed
+		if rr := serve("/webhooks/payments/fake", body, sign(body)); rr.Code != http.StatusAccepted {
+			t.Errorf("Expected status code %d, got %d", http.StatusAccepted, rr.Code)
+		}
+
+		store.UpdateWebhookEventStatus(1, types.WebhookEventProcessed, "")
+		<-processor.queue
+		<-processor.queue
+
+		if rr := serve("/webhooks/payments/fake", body, sign(body)); rr.Code != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		if len(store.events) != 1 || len(processor.queue) != 0 {
+			t.Errorf("Expected the event to be recorded and processed once, got %+v", store.events)
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader is the header the providers sign the webhook requests in,
+// its value is "t=<unix time>,v1=<hex hmac>"
+const SignatureHeader = "X-Webhook-Signature"
+
+// ErrInvalidSignature is returned when the signature of a request
+// is missing, malformed, stale or does not match its body
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Sign function to return the signature header of the body sent at the time.
+// The HMAC-SHA256 is computed with the secret over the time and the body
+func Sign(secret string, at time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", timestamp, computeSignature(secret, timestamp, body))
+}
+
+// Verify function to check the signature header of the body, the signatures
+// older or newer than the tolerance are rejected so captured requests cannot
+// be replayed later on. Every request is rejected when the secret is empty
+func Verify(secret string, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	// anyone could sign the requests with an empty secret
+	if secret == "" {
+		return fmt.Errorf("%w: no secret configured", ErrInvalidSignature)
+	}
+
+	var timestamp string
+	var signatures []string
+
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+
+	if timestamp == "" || len(signatures) == 0 {
+		return fmt.Errorf("%w: missing timestamp or signature", ErrInvalidSignature)
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: malformed timestamp", ErrInvalidSignature)
+	}
+
+	if age := now.Sub(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("%w: timestamp outside of the tolerance", ErrInvalidSignature)
+	}
+
+	expected := computeSignature(secret, timestamp, body)
+	for _, signature := range signatures {
+		// compare in constant time so the signature cannot be guessed byte by byte
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: signature mismatch", ErrInvalidSignature)
+}
+
+func computeSignature(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
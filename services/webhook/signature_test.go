@@ -0,0 +1,44 @@
+package webhook
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestVerify function to test the signatures of the webhook requests
+func TestVerify(t *testing.T) {
+	now := time.Unix(1720000000, 0)
+	body := []byte(`{"id":"evt_1"}`)
+	header := Sign("secret", now, body)
+
+	if err := Verify("secret", header, body, now.Add(time.Minute), time.Minute*5); err != nil {
+		t.Errorf("Expected the signature to be valid, got %v", err)
+	}
+
+	for name, c := range map[string]struct {
+		secret string
+		header string
+		body   string
+		now    time.Time
+	}{
+		"wrong secret":    {"other", header, string(body), now},
+		"changed body":    {"secret", header, `{"id":"evt_2"}`, now},
+		"stale signature": {"secret", header, string(body), now.Add(time.Minute * 6)},
+		"missing header":  {"secret", "", string(body), now},
+		"malformed time":  {"secret", "t=soon,v1=abc", string(body), now},
+		"empty secret":    {"", Sign("", now, body), string(body), now},
+	} {
+		if err := Verify(c.secret, c.header, []byte(c.body), c.now, time.Minute*5); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: expected the signature to be invalid, got %v", name, err)
+		}
+	}
+
+	t.Run("Should accept any of the signatures during a secret rotation", func(t *testing.T) {
+		rotated := Sign("new", now, body) + ",v1=" + computeSignature("secret", "1720000000", body)
+
+		if err := Verify("secret", rotated, body, now, time.Minute*5); err != nil {
+			t.Errorf("Expected the signature to be valid, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,163 @@
+package webhook
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/akshtrikha/golang-ecomm/types"
+)
+
+// eventColumns is the list of columns scanned by scanRowIntoEvent
+const eventColumns = "id, provider, eventId, type, payload, status, attempts, lastError, receivedAt, processedAt"
+
+// Store struct to hold the database object
+// This will be used to handle the database queries
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore function to return a reference to the Store struct
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// SaveWebhookEvent function to record an event received from a provider.
+// An event already received is not recorded again, the stored event
+// is returned with false in that case
+func (s *Store) SaveWebhookEvent(e types.WebhookEvent) (*types.WebhookEvent, bool, error) {
+	result, err := s.db.Exec(
+		"INSERT IGNORE INTO webhook_events (provider, eventId, type, payload) VALUES (?, ?, ?, ?)",
+		e.Provider, e.EventID, e.Type, e.Payload,
+	)
+	if err != nil {
+		return nil, false, err
+	}
+
+	created, err := result.RowsAffected()
+	if err != nil {
+		return nil, false, err
+	}
+
+	rows, err := s.db.Query("SELECT "+eventColumns+" FROM webhook_events WHERE provider = ? AND eventId = ?", e.Provider, e.EventID)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	stored, err := scanEvent(rows)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if stored.ID == 0 {
+		return nil, false, fmt.Errorf("webhook event %v of %v not found", e.EventID, e.Provider)
+	}
+
+	return stored, created == 1, nil
+}
+
+// GetWebhookEventByID function to find the event by id
+func (s *Store) GetWebhookEventByID(id int) (*types.WebhookEvent, error) {
+	rows, err := s.db.Query("SELECT "+eventColumns+" FROM webhook_events WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	e, err := scanEvent(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.ID == 0 {
+		return nil, fmt.Errorf("webhook event with id: %v not found", id)
+	}
+
+	return e, nil
+}
+
+// GetWebhookEventsByStatus function to get the events in the status, the oldest first
+func (s *Store) GetWebhookEventsByStatus(status types.WebhookEventStatus) ([]types.WebhookEvent, error) {
+	rows, err := s.db.Query("SELECT "+eventColumns+" FROM webhook_events WHERE status = ? ORDER BY id", status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []types.WebhookEvent{}
+	for rows.Next() {
+		e, err := scanRowIntoEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, *e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// UpdateWebhookEventStatus function to record the outcome of the processing of the event.
+// Moving the event back to pending does not count as an attempt
+func (s *Store) UpdateWebhookEventStatus(id int, status types.WebhookEventStatus, lastError string) error {
+	var query string
+	switch status {
+	case types.WebhookEventProcessed:
+		query = "UPDATE webhook_events SET status = ?, lastError = NULLIF(?, ''), attempts = attempts + 1, processedAt = CURRENT_TIMESTAMP WHERE id = ?"
+	case types.WebhookEventFailed:
+		query = "UPDATE webhook_events SET status = ?, lastError = NULLIF(?, ''), attempts = attempts + 1 WHERE id = ?"
+	default:
+		query = "UPDATE webhook_events SET status = ?, lastError = NULLIF(?, '') WHERE id = ?"
+	}
+
+	_, err := s.db.Exec(query, status, lastError, id)
+	return err
+}
+
+// scanEvent reads the single event of the rows, the
+// returned event has no ID when there is none
+func scanEvent(rows *sql.Rows) (*types.WebhookEvent, error) {
+	e := new(types.WebhookEvent)
+	for rows.Next() {
+		var err error
+		if e, err = scanRowIntoEvent(rows); err != nil {
+			return nil, err
+		}
+	}
+
+	return e, rows.Err()
+}
+
+func scanRowIntoEvent(rows *sql.Rows) (*types.WebhookEvent, error) {
+	e := new(types.WebhookEvent)
+	var lastError sql.NullString
+	var processedAt sql.NullTime
+
+	err := rows.Scan(
+		&e.ID,
+		&e.Provider,
+		&e.EventID,
+		&e.Type,
+		&e.Payload,
+		&e.Status,
+		&e.Attempts,
+		&lastError,
+		&e.ReceivedAt,
+		&processedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	e.LastError = lastError.String
+	if processedAt.Valid {
+		e.ProcessedAt = &processedAt.Time
+	}
+
+	return e, nil
+}
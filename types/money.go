@@ -0,0 +1,450 @@
+package types
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is the base currency the products are priced in,
+// the prices in other currencies are converted with the exchange rates
+const DefaultCurrency = "USD"
+
+// Errors returned by the Money operations
+var (
+	ErrCurrencyMismatch = errors.New("money: currency mismatch")
+	ErrMoneyOverflow    = errors.New("money: amount out of range")
+	ErrMoneyPrecision   = errors.New("money: too many decimal places for the currency")
+)
+
+// currencyExponents holds the number of minor unit digits of the
+// currencies that do not use 2 (cents), e.g. JPY has no minor unit
+var currencyExponents = map[string]int{
+	"BHD": 3, "CLP": 0, "ISK": 0, "JOD": 3, "JPY": 0,
+	"KRW": 0, "KWD": 3, "OMR": 3, "TND": 3, "VND": 0,
+}
+
+// CurrencyExponent returns the number of decimal places of the currency
+func CurrencyExponent(currency string) int {
+	if exp, ok := currencyExponents[currency]; ok {
+		return exp
+	}
+
+	return 2
+}
+
+// RoundingMode decides how an amount falling between two minor units is rounded
+type RoundingMode int
+
+// Rounding modes of the Money operations
+const (
+	// RoundHalfEven rounds to the nearest minor unit and ties to the even one (banker's rounding)
+	RoundHalfEven RoundingMode = iota
+	// RoundHalfUp rounds to the nearest minor unit and ties away from zero
+	RoundHalfUp
+	// RoundDown truncates toward zero
+	RoundDown
+	// RoundUp rounds away from zero
+	RoundUp
+)
+
+// Money is an exact amount of a currency held in its minor units
+// (cents for USD), so no rounding happens when adding or multiplying by a quantity.
+// It is encoded in JSON as {"amount": "12.50", "currency": "USD"}
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// NewMoney function to return an amount in the minor units of the currency
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// ParseMoney parses a decimal amount in the major units of the currency ("12.50").
+// The amount is never rounded, more decimal places than the currency has fail
+// with ErrMoneyPrecision unless they are zeros
+func ParseMoney(s string, currency string) (Money, error) {
+	m := Money{Currency: currency}
+	s = strings.TrimSpace(s)
+
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+
+	whole, fraction, _ := strings.Cut(s, ".")
+	if whole == "" && fraction == "" || !isDigits(whole) || !isDigits(fraction) {
+		return m, fmt.Errorf("money: invalid amount %q", s)
+	}
+
+	exp := CurrencyExponent(currency)
+	if len(fraction) > exp {
+		if strings.Trim(fraction[exp:], "0") != "" {
+			return m, ErrMoneyPrecision
+		}
+		fraction = fraction[:exp]
+	}
+	fraction += strings.Repeat("0", exp-len(fraction))
+
+	amount, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		return m, ErrMoneyOverflow
+	}
+
+	if negative {
+		amount = -amount
+	}
+	m.Amount = amount
+
+	return m, nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Sum adds up the amounts, all of them have to be in the currency
+func Sum(currency string, amounts ...Money) (Money, error) {
+	total := NewMoney(0, currency)
+
+	var err error
+	for _, m := range amounts {
+		if total, err = total.Add(m); err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// Decimal formats the amount in the major units of the currency, e.g. "12.50"
+func (m Money) Decimal() string {
+	exp := CurrencyExponent(m.Currency)
+
+	sign := ""
+	digits := strconv.FormatInt(m.Amount, 10)
+	if m.Amount < 0 {
+		// negated as unsigned, the absolute value of math.MinInt64 does not fit an int64
+		sign, digits = "-", strconv.FormatUint(-uint64(m.Amount), 10)
+	}
+
+	if exp == 0 {
+		return sign + digits
+	}
+
+	if len(digits) <= exp {
+		digits = strings.Repeat("0", exp-len(digits)+1) + digits
+	}
+
+	return sign + digits[:len(digits)-exp] + "." + digits[len(digits)-exp:]
+}
+
+// String formats the amount with its currency, e.g. "12.50 USD"
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+// IsZero reports whether the amount is zero
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// IsNegative reports whether the amount is below zero
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// SameCurrency reports whether both amounts are in the same currency
+func (m Money) SameCurrency(other Money) bool {
+	return m.Currency == other.Currency
+}
+
+// Cmp compares the amounts, it returns -1, 0 or +1 as m is less than,
+// equal to or greater than other
+func (m Money) Cmp(other Money) (int, error) {
+	if !m.SameCurrency(other) {
+		return 0, ErrCurrencyMismatch
+	}
+
+	switch {
+	case m.Amount < other.Amount:
+		return -1, nil
+	case m.Amount > other.Amount:
+		return 1, nil
+	}
+
+	return 0, nil
+}
+
+// Add returns the sum of the amounts
+func (m Money) Add(other Money) (Money, error) {
+	if !m.SameCurrency(other) {
+		return m, ErrCurrencyMismatch
+	}
+
+	sum := m.Amount + other.Amount
+	if (other.Amount > 0 && sum < m.Amount) || (other.Amount < 0 && sum > m.Amount) {
+		return m, ErrMoneyOverflow
+	}
+
+	return NewMoney(sum, m.Currency), nil
+}
+
+// Sub returns the difference of the amounts
+func (m Money) Sub(other Money) (Money, error) {
+	if other.Amount == math.MinInt64 {
+		return m, ErrMoneyOverflow
+	}
+
+	return m.Add(NewMoney(-other.Amount, other.Currency))
+}
+
+// Mul returns the amount multiplied by a quantity
+func (m Money) Mul(quantity int64) (Money, error) {
+	if m.Amount == 0 || quantity == 0 {
+		return NewMoney(0, m.Currency), nil
+	}
+
+	product := m.Amount * quantity
+	if product/quantity != m.Amount || (m.Amount == math.MinInt64 && quantity == -1) {
+		return m, ErrMoneyOverflow
+	}
+
+	return NewMoney(product, m.Currency), nil
+}
+
+// MulRat returns the amount multiplied by an exact rate (a tax rate,
+// a discount percentage) rounded to the minor unit with the mode
+func (m Money) MulRat(rate *big.Rat, mode RoundingMode) (Money, error) {
+	num := new(big.Int).Mul(big.NewInt(m.Amount), rate.Num())
+	den := rate.Denom()
+
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Sign() != 0 {
+		// the remainder has the sign of num, the result moves away from zero
+		away := big.NewInt(int64(num.Sign()))
+
+		// compare twice the remainder with the denominator to find the ties
+		half := new(big.Int).Abs(rem)
+		half.Lsh(half, 1)
+		cmp := half.Cmp(den)
+
+		switch mode {
+		case RoundUp:
+			quo.Add(quo, away)
+		case RoundHalfUp:
+			if cmp >= 0 {
+				quo.Add(quo, away)
+			}
+		case RoundHalfEven:
+			if cmp > 0 || (cmp == 0 && quo.Bit(0) == 1) {
+				quo.Add(quo, away)
+			}
+		}
+	}
+
+	if !quo.IsInt64() {
+		return m, ErrMoneyOverflow
+	}
+
+	return NewMoney(quo.Int64(), m.Currency), nil
+}
+
+// Convert returns the amount in another currency at the rate, the number of
+// units of the currency for one unit of the currency of m, rounded half to even
+func (m Money) Convert(currency string, rate *big.Rat) (Money, error) {
+	return m.ConvertRounded(currency, rate, RoundHalfEven)
+}
+
+// ConvertRounded returns the amount in another currency at the rate rounded with the mode
+func (m Money) ConvertRounded(currency string, rate *big.Rat, mode RoundingMode) (Money, error) {
+	// scale the rate for the minor units of both currencies
+	scaled := new(big.Rat).Mul(rate, new(big.Rat).SetFrac(
+		new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(CurrencyExponent(currency))), nil),
+		new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(CurrencyExponent(m.Currency))), nil),
+	))
+
+	return NewMoney(m.Amount, currency).MulRat(scaled, mode)
+}
+
+// ParseRate parses a positive decimal exchange rate ("0.9215") exactly,
+// at most 8 decimal places are kept by the database
+func ParseRate(s string) (*big.Rat, error) {
+	whole, fraction, _ := strings.Cut(strings.TrimSpace(s), ".")
+	if whole == "" || !isDigits(whole) || !isDigits(fraction) || len(fraction) > 8 {
+		return nil, fmt.Errorf("invalid rate %q", s)
+	}
+
+	rate, ok := new(big.Rat).SetString(whole + "." + fraction + "0")
+	if !ok || rate.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid rate %q", s)
+	}
+
+	return rate, nil
+}
+
+// Allocate splits the amount in parts proportional to the ratios without
+// losing a minor unit, the remainder goes one unit at a time to the first parts.
+// Splitting 10.00 in three gives 3.34, 3.33 and 3.33
+func (m Money) Allocate(ratios ...int64) ([]Money, error) {
+	var total int64
+	for _, ratio := range ratios {
+		if ratio < 0 {
+			return nil, errors.New("money: negative allocation ratio")
+		}
+		total += ratio
+	}
+
+	if total == 0 {
+		return nil, errors.New("money: allocation ratios add up to zero")
+	}
+
+	parts := make([]Money, len(ratios))
+	remainder := m.Amount
+
+	for i, ratio := range ratios {
+		share := new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(ratio))
+		share.Quo(share, big.NewInt(total))
+
+		parts[i] = NewMoney(share.Int64(), m.Currency)
+		remainder -= parts[i].Amount
+	}
+
+	unit := int64(1)
+	if remainder < 0 {
+		unit = -1
+	}
+
+	for i := 0; remainder != 0; i = (i + 1) % len(parts) {
+		if ratios[i] == 0 {
+			continue
+		}
+		parts[i].Amount += unit
+		remainder -= unit
+	}
+
+	return parts, nil
+}
+
+type moneyJSON struct {
+	Amount   json.RawMessage `json:"amount"`
+	Currency string          `json:"currency"`
+}
+
+// MarshalJSON encodes the amount as a decimal string so clients never parse it into a float
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+	}{m.Decimal(), m.Currency})
+}
+
+// UnmarshalJSON accepts {"amount": "12.50", "currency": "USD"}, or a bare
+// amount ("12.50" or 12.50) in the DefaultCurrency. The amount is read
+// from its text, never through a float
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	raw := moneyJSON{Amount: data, Currency: DefaultCurrency}
+	if len(data) > 0 && data[0] == '{' {
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return err
+		}
+
+		if raw.Currency == "" {
+			raw.Currency = DefaultCurrency
+		}
+	}
+
+	amount := string(raw.Amount)
+	if len(raw.Amount) > 0 && raw.Amount[0] == '"' {
+		if err := json.Unmarshal(raw.Amount, &amount); err != nil {
+			return err
+		}
+	}
+
+	parsed, err := ParseMoney(amount, strings.ToUpper(raw.Currency))
+	if err != nil {
+		return err
+	}
+
+	*m = parsed
+	return nil
+}
+
+// Scan reads a DECIMAL column, the amount keeps the currency
+// already set on m and is in the DefaultCurrency otherwise
+func (m *Money) Scan(value any) error {
+	currency := m.Currency
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+
+	var s string
+	switch v := value.(type) {
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case int64:
+		s = strconv.FormatInt(v, 10)
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Errorf("money: cannot scan %T", value)
+	}
+
+	parsed, err := ParseMoney(s, currency)
+	if err != nil {
+		return err
+	}
+
+	*m = parsed
+	return nil
+}
+
+// Value writes the amount to a DECIMAL column
+func (m Money) Value() (driver.Value, error) {
+	return m.Decimal(), nil
+}
+
+// NullMoney reads a nullable DECIMAL column, Valid is false for NULL
+type NullMoney struct {
+	Money Money
+	Valid bool
+}
+
+// Scan implements the sql.Scanner interface
+func (n *NullMoney) Scan(value any) error {
+	if value == nil {
+		n.Money, n.Valid = Money{}, false
+		return nil
+	}
+
+	n.Valid = true
+	return n.Money.Scan(value)
+}
+
+// TrimDecimal removes the trailing zeros of the fraction of a
+// DECIMAL column read as a string, "0.92150000" reads as "0.9215"
+func TrimDecimal(s string) string {
+	if !strings.Contains(s, ".") {
+		return s
+	}
+
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
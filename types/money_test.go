@@ -0,0 +1,203 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func usd(amount int64) Money {
+	return NewMoney(amount, "USD")
+}
+
+// TestParseMoney function to test the exact parsing of the decimal amounts
+func TestParseMoney(t *testing.T) {
+	for _, c := range []struct {
+		s        string
+		currency string
+		expected int64
+	}{
+		{"12.50", "USD", 1250},
+		{"12.5", "USD", 1250},
+		{"12", "USD", 1200},
+		{".99", "USD", 99},
+		{"-0.01", "USD", -1},
+		{"0.10", "USD", 10},
+		{"1.200", "USD", 120},
+		{"1500", "JPY", 1500},
+		{"1.234", "KWD", 1234},
+	} {
+		m, err := ParseMoney(c.s, c.currency)
+		if err != nil || m.Amount != c.expected || m.Currency != c.currency {
+			t.Errorf("Expected %s to parse to %d %s, got %+v, error: %v", c.s, c.expected, c.currency, m, err)
+		}
+	}
+
+	if _, err := ParseMoney("0.105", "USD"); !errors.Is(err, ErrMoneyPrecision) {
+		t.Errorf("Expected a precision error, got %v", err)
+	}
+
+	for _, s := range []string{"", ".", "1e3", "1,50", "abc", "99999999999999999999"} {
+		if _, err := ParseMoney(s, "USD"); err == nil {
+			t.Errorf("Expected %q to fail", s)
+		}
+	}
+}
+
+// TestMoneyDecimal function to test the formatting of the amounts
+func TestMoneyDecimal(t *testing.T) {
+	for _, c := range []struct {
+		m        Money
+		expected string
+	}{
+		{usd(1250), "12.50"},
+		{usd(5), "0.05"},
+		{usd(0), "0.00"},
+		{usd(-105), "-1.05"},
+		{NewMoney(1500, "JPY"), "1500"},
+		{NewMoney(1, "KWD"), "0.001"},
+	} {
+		if got := c.m.Decimal(); got != c.expected {
+			t.Errorf("Expected %s, got %s", c.expected, got)
+		}
+	}
+}
+
+// TestMoneyArithmetic function to test the operations of the amounts
+func TestMoneyArithmetic(t *testing.T) {
+	t.Run("Should add and multiply without rounding errors", func(t *testing.T) {
+		// 0.10 added ten times is not 1.00 with floats
+		total := usd(0)
+		for i := 0; i < 10; i++ {
+			total, _ = total.Add(usd(10))
+		}
+
+		line, _ := usd(1999).Mul(3)
+
+		if total != usd(100) || line != usd(5997) {
+			t.Errorf("Expected 1.00 and 59.97, got %v and %v", total, line)
+		}
+	})
+
+	t.Run("Should fail to mix currencies", func(t *testing.T) {
+		if _, err := usd(100).Add(NewMoney(100, "EUR")); !errors.Is(err, ErrCurrencyMismatch) {
+			t.Errorf("Expected a currency mismatch, got %v", err)
+		}
+	})
+
+	t.Run("Should fail on overflow", func(t *testing.T) {
+		if _, err := usd(1 << 62).Mul(4); !errors.Is(err, ErrMoneyOverflow) {
+			t.Errorf("Expected an overflow, got %v", err)
+		}
+	})
+
+	t.Run("Should round with the rounding mode", func(t *testing.T) {
+		for _, c := range []struct {
+			m        Money
+			rate     *big.Rat
+			mode     RoundingMode
+			expected int64
+		}{
+			// 2.5 cents
+			{usd(25), big.NewRat(1, 10), RoundHalfEven, 2},
+			{usd(25), big.NewRat(1, 10), RoundHalfUp, 3},
+			// 3.5 cents
+			{usd(35), big.NewRat(1, 10), RoundHalfEven, 4},
+			{usd(-25), big.NewRat(1, 10), RoundHalfUp, -3},
+			{usd(-25), big.NewRat(1, 10), RoundHalfEven, -2},
+			{usd(1999), big.NewRat(8, 100), RoundHalfEven, 160},
+			{usd(1999), big.NewRat(8, 100), RoundDown, 159},
+			{usd(1901), big.NewRat(1, 100), RoundUp, 20},
+		} {
+			got, err := c.m.MulRat(c.rate, c.mode)
+			if err != nil || got.Amount != c.expected {
+				t.Errorf("Expected %v * %v to round to %d, got %v", c.m, c.rate, c.expected, got)
+			}
+		}
+	})
+
+	t.Run("Should allocate without losing a cent", func(t *testing.T) {
+		parts, err := usd(1000).Allocate(1, 1, 1)
+		if err != nil || parts[0] != usd(334) || parts[1] != usd(333) || parts[2] != usd(333) {
+			t.Errorf("Expected 3.34, 3.33 and 3.33, got %v", parts)
+		}
+
+		parts, err = usd(5).Allocate(3, 0, 7)
+		if err != nil || parts[0] != usd(2) || parts[1] != usd(0) || parts[2] != usd(3) {
+			t.Errorf("Expected 0.02, 0.00 and 0.03, got %v", parts)
+		}
+
+		if _, err := usd(5).Allocate(0, 0); err == nil {
+			t.Errorf("Expected zero ratios to fail")
+		}
+	})
+}
+
+// TestMoneyConvert function to test the conversion between currencies
+func TestMoneyConvert(t *testing.T) {
+	for _, c := range []struct {
+		m        Money
+		currency string
+		rate     string
+		expected int64
+	}{
+		{usd(1999), "EUR", "0.9215", 1842},
+		{usd(1999), "JPY", "151.5", 3028},
+		{NewMoney(3028, "JPY"), "USD", "0.0066", 1998},
+		{usd(1000), "KWD", "0.3075", 3075},
+	} {
+		rate, err := ParseRate(c.rate)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := c.m.Convert(c.currency, rate)
+		if err != nil || got != NewMoney(c.expected, c.currency) {
+			t.Errorf("Expected %v at %s to convert to %d %s, got %v", c.m, c.rate, c.expected, c.currency, got)
+		}
+	}
+
+	for _, rate := range []string{"0", "", ".5", "1/3", "1e3", "0.000000001"} {
+		if _, err := ParseRate(rate); err == nil {
+			t.Errorf("Expected the rate %q to fail", rate)
+		}
+	}
+}
+
+// TestMoneyJSON function to test the JSON encoding of the amounts
+func TestMoneyJSON(t *testing.T) {
+	b, err := json.Marshal(usd(1250))
+	if err != nil || string(b) != `{"amount":"12.50","currency":"USD"}` {
+		t.Errorf("Expected the decimal string encoding, got %s", b)
+	}
+
+	for _, data := range []string{`{"amount":"12.50","currency":"USD"}`, `{"amount":12.5}`, `"12.50"`, `12.5`} {
+		var m Money
+		if err := json.Unmarshal([]byte(data), &m); err != nil || m != usd(1250) {
+			t.Errorf("Expected %s to decode to 12.50 USD, got %v, error: %v", data, m, err)
+		}
+	}
+
+	var m Money
+	if err := json.Unmarshal([]byte(`10.999`), &m); !errors.Is(err, ErrMoneyPrecision) {
+		t.Errorf("Expected a precision error, got %v", err)
+	}
+}
+
+// TestMoneyScan function to test reading the amounts from DECIMAL columns
+func TestMoneyScan(t *testing.T) {
+	var m Money
+	if err := m.Scan([]byte("19.99")); err != nil || m != usd(1999) {
+		t.Errorf("Expected 19.99 USD, got %v, error: %v", m, err)
+	}
+
+	var n NullMoney
+	if err := n.Scan(nil); err != nil || n.Valid {
+		t.Errorf("Expected a NULL amount, got %+v", n)
+	}
+
+	if v, _ := usd(1999).Value(); v != "19.99" {
+		t.Errorf("Expected 19.99, got %v", v)
+	}
+}
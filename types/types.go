@@ -1,6 +1,9 @@
 package types
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 // UserStore interface to hold all the methods required
 // for handling User operations with the database(store)
@@ -9,9 +12,12 @@ type UserStore interface {
 	GetUserByID(int) (*User, error)
 	CreateUser(User) (int, error)
 	GetAllUsers() ([]User, error)
+	GetUserRoles(int) ([]string, error)
+	SetUserTaxExempt(userID int, exempt bool) error
 }
 
 // User struct to hold the data regarding the user
+// no tax is charged on the orders of tax exempt users
 type User struct {
 	ID        int       `json:"id"`
 	FirstName string    `json:"firstName"`
@@ -19,6 +25,53 @@ type User struct {
 	Email     string    `json:"email"`
 	Password  string    `json:"password"`
 	CreatedAt time.Time `json:"createdAt"`
+	TaxExempt bool      `json:"taxExempt"`
+}
+
+// SetTaxExemptPayload Payload for the tax exemption api endpoint
+type SetTaxExemptPayload struct {
+	TaxExempt bool `json:"taxExempt"`
+}
+
+// AddressStore interface to hold all the methods required
+// for handling the address book of the users with the database(store)
+type AddressStore interface {
+	GetAddressesByUserID(int) ([]Address, error)
+	GetAddressByID(int) (*Address, error)
+	CreateAddress(Address) (int, error)
+	UpdateAddress(Address) error
+	DeleteAddress(int) error
+}
+
+// PostalAddress struct to hold the fields of an address,
+// orders keep a copy of the addresses they are shipped and billed to
+type PostalAddress struct {
+	Name       string `json:"name"       validate:"required,max=128"`
+	Line1      string `json:"line1"      validate:"required,max=255"`
+	Line2      string `json:"line2"      validate:"max=255"`
+	City       string `json:"city"       validate:"required,max=128"`
+	Region     string `json:"region"     validate:"max=64"`
+	PostalCode string `json:"postalCode" validate:"max=32"`
+	Country    string `json:"country"    validate:"required,iso3166_1_alpha2"`
+	Phone      string `json:"phone"      validate:"max=32"`
+}
+
+// Address struct to hold an address of the address book of a user
+// a user has at most one default shipping and one default billing address
+type Address struct {
+	ID     int `json:"id"`
+	UserID int `json:"userId"`
+	PostalAddress
+	DefaultShipping bool      `json:"defaultShipping"`
+	DefaultBilling  bool      `json:"defaultBilling"`
+	CreatedAt       time.Time `json:"createdAt"`
+}
+
+// AddressPayload Payload to create or replace an address of the address book
+type AddressPayload struct {
+	PostalAddress
+	DefaultShipping bool `json:"defaultShipping"`
+	DefaultBilling  bool `json:"defaultBilling"`
 }
 
 // RegisterUserPayload struct to hold the payload for /register user endpoint
@@ -44,12 +97,14 @@ type LoginUserPayload struct {
 
 // LoginUserResponse struct to hold the response for /login user endpoint
 type LoginUserResponse struct {
-	Token     string    `json:"jwt"`
-	ID        int       `json:"id"`
-	FirstName string    `json:"firstName"`
-	LastName  string    `json:"lastName"`
-	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"createdAt"`
+	Token        string    `json:"jwt"`
+	RefreshToken string    `json:"refreshToken"`
+	ExpiresIn    int64     `json:"expiresIn"`
+	ID           int       `json:"id"`
+	FirstName    string    `json:"firstName"`
+	LastName     string    `json:"lastName"`
+	Email        string    `json:"email"`
+	CreatedAt    time.Time `json:"createdAt"`
 }
 
 // ListUsersResponse struct to hold the response fro /list-users api endpoint
@@ -59,32 +114,1145 @@ type ListUsersResponse struct {
 		FirstName string `json:"lastName"`
 		LastName  string `json:"firstName"`
 		Email     string `json:"email"`
+		TaxExempt bool   `json:"taxExempt"`
 	}
 }
 
+// SessionStore interface to hold all the methods required
+// for handling refresh tokens with the database(store)
+type SessionStore interface {
+	CreateRefreshToken(RefreshToken) (int, error)
+	GetRefreshTokenByHash(string) (*RefreshToken, error)
+	RotateRefreshToken(oldID int, next RefreshToken) (int, error)
+	RevokeRefreshTokenFamily(string) error
+	RevokeUserRefreshTokens(int) error
+}
+
+// RefreshToken struct to hold a stored refresh token.
+// Only the hash of the token is stored. Tokens issued by rotating one
+// another share the FamilyID of the token issued on login.
+type RefreshToken struct {
+	ID         int
+	UserID     int
+	FamilyID   string
+	TokenHash  string
+	ExpiresAt  time.Time
+	RevokedAt  *time.Time
+	ReplacedBy *int
+	CreatedAt  time.Time
+}
+
+// TokenPair struct to hold the tokens issued for a session
+type TokenPair struct {
+	Token        string `json:"jwt"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int64  `json:"expiresIn"`
+}
+
+// RefreshTokenPayload Payload for the /refresh and /logout endpoints
+type RefreshTokenPayload struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
 // ProductStore interface to hold all the methods required
 // for handling Product operations with the database(store)
 type ProductStore interface {
 	AddProduct(AddProductPayload) (int, error)
 	GetProducts() ([]Product, error)
+	ListProducts(ProductListQuery) (*ProductPage, error)
+	GetProductsByIDs([]int) ([]Product, error)
+	GetProductByID(int) (*Product, error)
+	UpdateProduct(Product) error
+	DeleteProduct(int) error
 }
 
 // Product struct is used to hold the info regarding the product
+// DeletedAt is set once the product is archived.
+// Weight is in grams and the dimensions of the package in millimetres
 type Product struct {
-	ID          int       `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Image       string    `json:"image"`
-	Price       float64   `json:"price"`
-	Quantity    int       `json:"quantity"`
-	CreatedAt   time.Time `json:"createdAt"`
+	ID          int        `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Image       string     `json:"image"`
+	Price       Money      `json:"price"`
+	Quantity    int        `json:"quantity"`
+	TaxClass    string     `json:"taxClass"`
+	Weight      int        `json:"weight"`
+	Length      int        `json:"length"`
+	Width       int        `json:"width"`
+	Height      int        `json:"height"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	DeletedAt   *time.Time `json:"deletedAt,omitempty"`
+
+	// Options and Variants are only loaded for a single product
+	Options  []ProductOption  `json:"options,omitempty"`
+	Variants []ProductVariant `json:"variants,omitempty"`
 }
 
 // AddProductPayload Payload for add-product api endpoint
+// TaxClass defaults to the standard tax class
 type AddProductPayload struct {
-	Name        string  `json:"name"        validate:"required"`
-	Description string  `json:"description" validate:"required"`
-	Image       string  `json:"image"       validate:"required"`
-	Price       float64 `json:"price"       validate:"required"`
-	Quantity    int     `json:"quantity"    validate:"required"`
-}
\ No newline at end of file
+	Name        string `json:"name"        validate:"required"`
+	Description string `json:"description" validate:"required"`
+	Image       string `json:"image"       validate:"required"`
+	Price       Money  `json:"price"       validate:"required,gt=0"`
+	Quantity    int    `json:"quantity"    validate:"required"`
+	TaxClass    string `json:"taxClass"    validate:"max=32"`
+	Weight      int    `json:"weight"      validate:"gte=0"`
+	Length      int    `json:"length"      validate:"gte=0"`
+	Width       int    `json:"width"       validate:"gte=0"`
+	Height      int    `json:"height"      validate:"gte=0"`
+}
+
+// UpdateProductPayload Payload for the partial product update api endpoint
+// only the fields present in the payload are updated
+type UpdateProductPayload struct {
+	Name        *string `json:"name"        validate:"omitempty,min=1"`
+	Description *string `json:"description" validate:"omitempty,min=1"`
+	Image       *string `json:"image"       validate:"omitempty,min=1"`
+	Price       *Money  `json:"price"       validate:"omitempty,gt=0"`
+	Quantity    *int    `json:"quantity"    validate:"omitempty,gte=0"`
+	TaxClass    *string `json:"taxClass"    validate:"omitempty,min=1,max=32"`
+	Weight      *int    `json:"weight"      validate:"omitempty,gte=0"`
+	Length      *int    `json:"length"      validate:"omitempty,gte=0"`
+	Width       *int    `json:"width"       validate:"omitempty,gte=0"`
+	Height      *int    `json:"height"      validate:"omitempty,gte=0"`
+}
+
+// ProductListQuery holds the pagination, sorting and filters
+// of the product listing endpoint
+// After is set for cursor pagination, Offset is used otherwise
+type ProductListQuery struct {
+	Limit    int            `query:"limit"    validate:"min=1,max=100"`
+	Offset   int            `query:"offset"   validate:"min=0"`
+	After    *ProductCursor `query:"cursor"   validate:"-"`
+	Sort     string         `query:"sort"     validate:"oneof=id price name createdAt"`
+	Order    string         `query:"order"    validate:"oneof=asc desc"`
+	MinPrice *Money         `query:"minPrice" validate:"omitempty,gte=0"`
+	MaxPrice *Money         `query:"maxPrice" validate:"omitempty,gte=0"`
+	InStock  bool           `query:"inStock"`
+	Name     string         `query:"name"     validate:"max=255"`
+
+	// CategoryIDs limits the listing to the products of the categories
+	CategoryIDs []int `validate:"-"`
+}
+
+// ProductCursor points at the last product of a page
+// Value is the sort column of that product formatted as a string
+// and Sort the field the page was sorted by
+type ProductCursor struct {
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+	Sort  string `json:"s"`
+}
+
+// ProductPage is a page of the product listing
+// Total is the number of products matching the filters across all pages
+type ProductPage struct {
+	Items      []Product `json:"items"`
+	Total      int       `json:"total"`
+	Limit      int       `json:"limit"`
+	Offset     int       `json:"offset,omitempty"`
+	HasMore    bool      `json:"hasMore"`
+	NextCursor string    `json:"nextCursor,omitempty"`
+	Next       string    `json:"next,omitempty"`
+}
+
+// VariantStore interface to hold all the methods required
+// for handling product options and variants with the database(store)
+type VariantStore interface {
+	GetProductOptions(productID int) ([]ProductOption, error)
+	SetProductOptions(productID int, options []ProductOption) error
+	GetVariantsByProductID(productID int) ([]ProductVariant, error)
+	GetVariantBySKU(sku string) (*ProductVariant, error)
+	AddVariant(ProductVariant) (int, error)
+	UpdateVariant(ProductVariant) error
+	DeleteVariant(id int) error
+}
+
+// ProductOption struct to hold an option the variants of a product
+// are defined by (size, color, etc.) with the values it can take
+type ProductOption struct {
+	Name   string   `json:"name"   validate:"required,max=64"`
+	Values []string `json:"values" validate:"required,min=1,dive,required,max=64"`
+}
+
+// ProductVariant struct to hold a purchasable variant of a product
+// Price overrides the product price when set, Image is optional too
+type ProductVariant struct {
+	ID        int               `json:"id"`
+	ProductID int               `json:"productId"`
+	SKU       string            `json:"sku"`
+	Options   map[string]string `json:"options"`
+	Price     *Money            `json:"price"`
+	Quantity  int               `json:"quantity"`
+	Image     string            `json:"image,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+	DeletedAt *time.Time        `json:"deletedAt,omitempty"`
+}
+
+// ProductOptionsPayload Payload to define the options of a product
+type ProductOptionsPayload struct {
+	Options []ProductOption `json:"options" validate:"dive"`
+}
+
+// AddVariantPayload Payload for the add variant api endpoint
+// Options holds a value for every option of the product
+type AddVariantPayload struct {
+	SKU      string            `json:"sku"      validate:"required,max=64"`
+	Options  map[string]string `json:"options"  validate:"required"`
+	Price    *Money            `json:"price"    validate:"omitempty,gt=0"`
+	Quantity int               `json:"quantity" validate:"gte=0"`
+	Image    string            `json:"image"    validate:"max=255"`
+}
+
+// UpdateVariantPayload Payload for the partial variant update api endpoint
+// only the fields present in the payload are updated,
+// ClearPrice removes the price override of the variant
+type UpdateVariantPayload struct {
+	Price      *Money  `json:"price"      validate:"omitempty,gt=0"`
+	ClearPrice bool    `json:"clearPrice"`
+	Quantity   *int    `json:"quantity"   validate:"omitempty,gte=0"`
+	Image      *string `json:"image"      validate:"omitempty,max=255"`
+}
+
+// CategoryStore interface to hold all the methods required
+// for handling Category operations with the database(store)
+type CategoryStore interface {
+	GetCategories() ([]Category, error)
+	CreateCategory(CategoryPayload) (int, error)
+	UpdateCategory(Category) error
+	DeleteCategory(int) error
+	GetProductCategoryIDs(productID int) ([]int, error)
+	SetProductCategories(productID int, categoryIDs []int) error
+}
+
+// Category struct to hold a node of the category tree
+// root categories have no ParentID
+type Category struct {
+	ID        int        `json:"id"`
+	Name      string     `json:"name"`
+	ParentID  *int       `json:"parentId"`
+	CreatedAt time.Time  `json:"createdAt"`
+	Children  []Category `json:"children,omitempty"`
+}
+
+// CategoryPayload Payload for the create category api endpoint
+type CategoryPayload struct {
+	Name     string `json:"name"     validate:"required,max=255"`
+	ParentID *int   `json:"parentId" validate:"omitempty,gt=0"`
+}
+
+// UpdateCategoryPayload Payload for the update category api endpoint
+// only the fields present in the payload are updated,
+// MoveToRoot detaches the category from its parent
+type UpdateCategoryPayload struct {
+	Name       *string `json:"name"       validate:"omitempty,min=1,max=255"`
+	ParentID   *int    `json:"parentId"   validate:"omitempty,gt=0"`
+	MoveToRoot bool    `json:"moveToRoot"`
+}
+
+// ProductCategoriesPayload Payload to assign a product to categories
+type ProductCategoriesPayload struct {
+	CategoryIDs []int `json:"categoryIds" validate:"required,dive,gt=0"`
+}
+
+// ProductCategory is a category of a product with
+// the path from the root of the tree to the category
+type ProductCategory struct {
+	Category   Category   `json:"category"`
+	Breadcrumb []Category `json:"breadcrumb"`
+}
+
+// CategoryProductsResponse is the response of the browse category endpoint
+// the products of the descendants of the category are included
+type CategoryProductsResponse struct {
+	Category   Category     `json:"category"`
+	Breadcrumb []Category   `json:"breadcrumb"`
+	Products   *ProductPage `json:"products"`
+}
+
+// SearchStore interface to hold the methods required
+// to load the search documents from the database(store)
+type SearchStore interface {
+	GetSearchDocuments() ([]SearchDocument, error)
+}
+
+// SearchIndex is implemented by the search backends
+// Replace swaps the indexed documents for the given ones at once
+type SearchIndex interface {
+	Replace([]SearchDocument) error
+	Search(SearchQuery) (*SearchResult, error)
+}
+
+// SearchDocument holds the searchable data of an active product
+// Categories include the ancestors of the categories of the product
+type SearchDocument struct {
+	ProductID   int
+	Name        string
+	Description string
+	Price       Money
+	InStock     bool
+	Categories  []Category
+}
+
+// SearchQuery holds the text and the filters of a search
+type SearchQuery struct {
+	Text       string `query:"q"        validate:"max=255"`
+	CategoryID int    `query:"category" validate:"min=0"`
+	MinPrice   *Money `query:"minPrice" validate:"omitempty,gte=0"`
+	MaxPrice   *Money `query:"maxPrice" validate:"omitempty,gte=0"`
+	InStock    bool   `query:"inStock"`
+	Limit      int    `query:"limit"    validate:"min=1,max=100"`
+	Offset     int    `query:"offset"   validate:"min=0"`
+}
+
+// SearchResult holds a page of the products matching a search
+// ranked by relevance along with the facet counts of all the matches
+type SearchResult struct {
+	Matches []SearchMatch
+	Total   int
+	Facets  SearchFacets
+}
+
+// SearchMatch is a product matching a search with its relevance score
+type SearchMatch struct {
+	ProductID int
+	Score     float64
+}
+
+// SearchFacets holds the number of matches for each value of the facets
+// the count of a facet ignores the filter on the facet itself
+type SearchFacets struct {
+	Categories   []FacetCount `json:"categories"`
+	PriceBuckets []FacetCount `json:"priceBuckets"`
+	InStock      []FacetCount `json:"inStock"`
+}
+
+// FacetCount is the number of matches for a value of a facet
+type FacetCount struct {
+	Value string `json:"value"`
+	Label string `json:"label,omitempty"`
+	Count int    `json:"count"`
+}
+
+// SearchHit is a product of the search response with its relevance score
+type SearchHit struct {
+	Product Product `json:"product"`
+	Score   float64 `json:"score"`
+}
+
+// SearchResponse is the response of the search endpoint
+type SearchResponse struct {
+	Items  []SearchHit  `json:"items"`
+	Total  int          `json:"total"`
+	Limit  int          `json:"limit"`
+	Offset int          `json:"offset"`
+	Facets SearchFacets `json:"facets"`
+}
+
+// DefaultTaxClass is the tax class of the products without a specific one
+const DefaultTaxClass = "standard"
+
+// TaxStore interface to hold all the methods required
+// for handling the tax rates with the database(store)
+type TaxStore interface {
+	GetTaxRates() ([]TaxRate, error)
+	GetTaxRateByID(int) (*TaxRate, error)
+	CreateTaxRate(TaxRate) (int, error)
+	UpdateTaxRate(TaxRate) error
+	DeleteTaxRate(int) error
+}
+
+// TaxRate struct to hold the rate in percent of a tax class in a country,
+// or only in a region of the country when Region is set
+type TaxRate struct {
+	ID        int       `json:"id"`
+	Country   string    `json:"country"`
+	Region    string    `json:"region"`
+	TaxClass  string    `json:"taxClass"`
+	Name      string    `json:"name"`
+	Rate      string    `json:"rate"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// TaxRatePayload Payload for the tax rate api endpoints
+// an empty Region applies to the whole country
+type TaxRatePayload struct {
+	Country  string `json:"country"  validate:"required,iso3166_1_alpha2"`
+	Region   string `json:"region"   validate:"max=64"`
+	TaxClass string `json:"taxClass" validate:"max=32"`
+	Name     string `json:"name"     validate:"required,max=64"`
+	Rate     string `json:"rate"     validate:"required"`
+}
+
+// ShippingStore interface to hold all the methods required
+// for handling the shipping zones and methods with the database(store)
+type ShippingStore interface {
+	GetShippingZones() ([]ShippingZone, error)
+	GetShippingZoneByID(int) (*ShippingZone, error)
+	CreateShippingZone(ShippingZone) (int, error)
+	UpdateShippingZone(ShippingZone) error
+	DeleteShippingZone(int) error
+	GetShippingMethodByID(int) (*ShippingMethod, error)
+	CreateShippingMethod(ShippingMethod) (int, error)
+	UpdateShippingMethod(ShippingMethod) error
+	DeleteShippingMethod(int) error
+	GetShippingMethodsFor(country string, region string) ([]ShippingMethod, error)
+}
+
+// ShippingZone struct to hold a group of destinations shipped with the same methods
+// a destination belongs to a single zone
+type ShippingZone struct {
+	ID        int              `json:"id"`
+	Name      string           `json:"name"`
+	Regions   []ShippingRegion `json:"regions"`
+	Methods   []ShippingMethod `json:"methods"`
+	CreatedAt time.Time        `json:"createdAt"`
+}
+
+// ShippingRegion is a destination of a shipping zone,
+// an empty Region covers the whole country
+type ShippingRegion struct {
+	Country string `json:"country" validate:"required,iso3166_1_alpha2"`
+	Region  string `json:"region"  validate:"max=64"`
+}
+
+// ShippingMethodType is how the cost of a shipping method is computed
+type ShippingMethodType string
+
+// Types of the shipping methods
+const (
+	ShippingFlat   ShippingMethodType = "flat"
+	ShippingWeight ShippingMethodType = "weight"
+	ShippingPrice  ShippingMethodType = "price"
+)
+
+// ShippingMethod struct to hold a way of shipping to a zone and its cost.
+// Flat methods cost Amount, weight based and price tiered methods use
+// the Tiers. Shipping is free when the order reaches FreeOver.
+// The amounts are in the base currency
+type ShippingMethod struct {
+	ID        int                `json:"id"`
+	ZoneID    int                `json:"zoneId"`
+	Name      string             `json:"name"`
+	Type      ShippingMethodType `json:"type"`
+	Amount    *Money             `json:"amount,omitempty"`
+	Tiers     []ShippingTier     `json:"tiers,omitempty"`
+	FreeOver  *Money             `json:"freeOver"`
+	Active    bool               `json:"active"`
+	CreatedAt time.Time          `json:"createdAt"`
+}
+
+// ShippingTier is a step of the cost of a shipping method, MaxWeight in grams
+// is used by weight based methods and MinSubtotal by price tiered ones
+type ShippingTier struct {
+	MaxWeight   int    `json:"maxWeight,omitempty"   validate:"gte=0"`
+	MinSubtotal *Money `json:"minSubtotal,omitempty" validate:"omitempty,gte=0"`
+	Amount      Money  `json:"amount"                validate:"gte=0"`
+}
+
+// ShippingZonePayload Payload to create or replace a shipping zone
+type ShippingZonePayload struct {
+	Name    string           `json:"name"    validate:"required,max=64"`
+	Regions []ShippingRegion `json:"regions" validate:"required,min=1,dive"`
+}
+
+// ShippingMethodPayload Payload to create or replace a shipping method
+// Active defaults to true
+type ShippingMethodPayload struct {
+	Name     string             `json:"name"     validate:"required,max=64"`
+	Type     ShippingMethodType `json:"type"     validate:"oneof=flat weight price"`
+	Amount   *Money             `json:"amount"   validate:"omitempty,gte=0"`
+	Tiers    []ShippingTier     `json:"tiers"    validate:"max=20,dive"`
+	FreeOver *Money             `json:"freeOver" validate:"omitempty,gt=0"`
+	Active   *bool              `json:"active"`
+}
+
+// ShippingQuote is the cost of a shipping method for a cart or an order
+type ShippingQuote struct {
+	MethodID int                `json:"methodId"`
+	Name     string             `json:"name"`
+	Type     ShippingMethodType `json:"type"`
+	Amount   Money              `json:"amount"`
+}
+
+// ExchangeRateStore interface to hold all the methods required
+// for handling the exchange rates with the database(store)
+type ExchangeRateStore interface {
+	GetExchangeRates() ([]ExchangeRate, error)
+	GetExchangeRate(currency string) (*ExchangeRate, error)
+	SetExchangeRates([]ExchangeRate) error
+	DeleteExchangeRate(currency string) error
+}
+
+// ExchangeRate struct to hold the rate of a currency, the number of
+// its units for one unit of the base currency as a decimal string
+type ExchangeRate struct {
+	Currency  string    `json:"currency"`
+	Rate      string    `json:"rate"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// BaseExchangeRate is the rate of the base currency to itself
+var BaseExchangeRate = ExchangeRate{Currency: DefaultCurrency, Rate: "1"}
+
+// SetExchangeRatePayload Payload for the set exchange rate api endpoint
+type SetExchangeRatePayload struct {
+	Rate string `json:"rate" validate:"required"`
+}
+
+// CurrenciesResponse is the response of the currencies endpoint
+type CurrenciesResponse struct {
+	Base  string         `json:"base"`
+	Rates []ExchangeRate `json:"rates"`
+}
+
+// PromotionStore interface to hold all the methods required
+// for handling Promotion operations with the database(store)
+type PromotionStore interface {
+	GetPromotions() ([]Promotion, error)
+	GetPromotionByID(int) (*Promotion, error)
+	GetPromotionsByCodes([]string) ([]Promotion, error)
+	CreatePromotion(Promotion) (int, error)
+	UpdatePromotion(Promotion) error
+	CountUserRedemptions(promotionID int, userID int) (int, error)
+}
+
+// PromotionType is the kind of discount a promotion gives
+type PromotionType string
+
+// Types of the promotions
+const (
+	PromotionPercentage   PromotionType = "percentage"
+	PromotionFixed        PromotionType = "fixed"
+	PromotionFreeShipping PromotionType = "free_shipping"
+	PromotionBuyXGetY     PromotionType = "buy_x_get_y"
+)
+
+// Promotion struct to hold a coupon and the rules of its discount.
+// Percent is used by percentage promotions, Amount by fixed ones and
+// ProductID, BuyQuantity and GetQuantity by buy X get Y ones.
+// The amounts are in the base currency, nil limits are unlimited
+type Promotion struct {
+	ID            int           `json:"id"`
+	Code          string        `json:"code"`
+	Description   string        `json:"description"`
+	Type          PromotionType `json:"type"`
+	Percent       string        `json:"percent,omitempty"`
+	Amount        *Money        `json:"amount,omitempty"`
+	ProductID     *int          `json:"productId,omitempty"`
+	BuyQuantity   int           `json:"buyQuantity,omitempty"`
+	GetQuantity   int           `json:"getQuantity,omitempty"`
+	MinOrderValue *Money        `json:"minOrderValue,omitempty"`
+	UsageLimit    *int          `json:"usageLimit"`
+	PerUserLimit  *int          `json:"perUserLimit"`
+	UsageCount    int           `json:"usageCount"`
+	Stackable     bool          `json:"stackable"`
+	Active        bool          `json:"active"`
+	StartsAt      *time.Time    `json:"startsAt"`
+	EndsAt        *time.Time    `json:"endsAt"`
+	CreatedAt     time.Time     `json:"createdAt"`
+}
+
+// PromotionPayload Payload to create or replace a promotion
+// Active defaults to true
+type PromotionPayload struct {
+	Code          string        `json:"code"          validate:"required,max=64"`
+	Description   string        `json:"description"   validate:"max=255"`
+	Type          PromotionType `json:"type"          validate:"oneof=percentage fixed free_shipping buy_x_get_y"`
+	Percent       string        `json:"percent"`
+	Amount        *Money        `json:"amount"        validate:"omitempty,gt=0"`
+	ProductID     *int          `json:"productId"     validate:"omitempty,gt=0"`
+	BuyQuantity   int           `json:"buyQuantity"   validate:"gte=0"`
+	GetQuantity   int           `json:"getQuantity"   validate:"gte=0"`
+	MinOrderValue *Money        `json:"minOrderValue" validate:"omitempty,gte=0"`
+	UsageLimit    *int          `json:"usageLimit"    validate:"omitempty,gt=0"`
+	PerUserLimit  *int          `json:"perUserLimit"  validate:"omitempty,gt=0"`
+	Stackable     bool          `json:"stackable"`
+	Active        *bool         `json:"active"`
+	StartsAt      *time.Time    `json:"startsAt"`
+	EndsAt        *time.Time    `json:"endsAt"`
+}
+
+// DiscountLine is a priced line the promotions are applied to
+type DiscountLine struct {
+	ProductID int
+	Price     Money
+	Quantity  int
+}
+
+// AppliedPromotion is the discount a promotion gave to a cart or an order
+type AppliedPromotion struct {
+	PromotionID int           `json:"promotionId"`
+	Code        string        `json:"code"`
+	Type        PromotionType `json:"type"`
+	Amount      Money         `json:"amount"`
+}
+
+// DiscountResult holds the discounts of the promotions applied to the lines
+// Discount is the sum of the amounts of the applied promotions
+type DiscountResult struct {
+	Subtotal     Money
+	Discount     Money
+	FreeShipping bool
+	Applied      []AppliedPromotion
+}
+
+// CouponError explains why a coupon of a cart does not apply
+type CouponError struct {
+	Code   string `json:"code"`
+	Reason string `json:"reason"`
+}
+
+// ApplyCouponPayload Payload for the apply coupon api endpoint
+type ApplyCouponPayload struct {
+	Code string `json:"code" validate:"required,max=64"`
+}
+
+// OrderStore interface to hold all the methods required
+// for handling Order operations with the database(store)
+type OrderStore interface {
+	PlaceOrder(Checkout) (*Order, error)
+	GetOrderByID(int) (*Order, error)
+	GetOrdersByUserID(int) ([]Order, error)
+	GetAllOrders() ([]Order, error)
+	GetOrderItems(int) ([]OrderItem, error)
+	GetOrderPromotions(int) ([]AppliedPromotion, error)
+	UpdateOrderStatus(orderID int, status OrderStatus, changedBy int, note string) (*Order, error)
+	GetOrderStatusHistory(int) ([]OrderStatusChange, error)
+}
+
+// OrderStatus is the status of an order in its lifecycle
+type OrderStatus string
+
+// Statuses an order can be in
+const (
+	OrderStatusPending       OrderStatus = "pending"
+	OrderStatusPaymentFailed OrderStatus = "payment_failed"
+	OrderStatusPaid          OrderStatus = "paid"
+	OrderStatusFulfilled     OrderStatus = "fulfilled"
+	OrderStatusShipped       OrderStatus = "shipped"
+	OrderStatusDelivered     OrderStatus = "delivered"
+	OrderStatusCancelled     OrderStatus = "cancelled"
+	OrderStatusRefunded      OrderStatus = "refunded"
+)
+
+// Order struct to hold the data regarding an order
+// the prices are in the currency selected at checkout,
+// ExchangeRate is the rate from the base currency used then.
+// Total is after Discount, the sum of the discounts of the Promotions.
+// Tax is the sum of the taxes of the items, it is part of the prices
+// when TaxInclusive is set and added to them otherwise.
+// Shipping is the untaxed cost of the ShippingMethod, included in Total.
+// Refunded is the sum of the totals of the credit notes of the order.
+// ShippingAddress and BillingAddress are copies of the addresses chosen at
+// checkout, they are nil for orders placed with a free text Address
+type Order struct {
+	ID               int                `json:"id"`
+	UserID           int                `json:"userId"`
+	Total            Money              `json:"total"`
+	Discount         Money              `json:"discount"`
+	Tax              Money              `json:"tax"`
+	Shipping         Money              `json:"shipping"`
+	Refunded         Money              `json:"refunded"`
+	ShippingMethodID *int               `json:"shippingMethodId"`
+	ShippingMethod   string             `json:"shippingMethod"`
+	TaxInclusive     bool               `json:"taxInclusive"`
+	TaxExempt        bool               `json:"taxExempt"`
+	Currency         string             `json:"currency"`
+	ExchangeRate     string             `json:"exchangeRate"`
+	Status           OrderStatus        `json:"status"`
+	Address          string             `json:"address"`
+	Country          string             `json:"country"`
+	Region           string             `json:"region"`
+	ShippingAddress  *PostalAddress     `json:"shippingAddress,omitempty"`
+	BillingAddress   *PostalAddress     `json:"billingAddress,omitempty"`
+	CreatedAt        time.Time          `json:"createdAt"`
+	Items            []OrderItem        `json:"items,omitempty"`
+	Promotions       []AppliedPromotion `json:"promotions,omitempty"`
+}
+
+// OrderItem struct to hold a single line of an order
+// Price is the product price snapshotted at purchase time
+// converted to the currency of the order. Discount is the share of
+// the order discount of the line, Tax is computed on the line total
+// less the Discount at TaxRate percent
+type OrderItem struct {
+	ID          int    `json:"id"`
+	OrderID     int    `json:"orderId"`
+	ProductID   int    `json:"productId"`
+	ProductName string `json:"productName,omitempty"`
+	VariantID   *int   `json:"variantId,omitempty"`
+	SKU         string `json:"sku,omitempty"`
+	Quantity    int    `json:"quantity"`
+	Price       Money  `json:"price"`
+	TaxClass    string `json:"taxClass"`
+	Discount    Money  `json:"discount"`
+	TaxRate     string `json:"taxRate"`
+	Tax         Money  `json:"tax"`
+
+	// RefundedQuantity is the number of units given back by credit notes
+	RefundedQuantity int `json:"refundedQuantity"`
+}
+
+// CartItem struct to hold a product and the quantity requested
+// SKU selects the variant and is required for products with variants
+type CartItem struct {
+	ProductID int    `json:"productId" validate:"required"`
+	SKU       string `json:"sku"       validate:"max=64"`
+	Quantity  int    `json:"quantity"  validate:"required,gt=0"`
+}
+
+// PlaceOrderPayload Payload for the place order api endpoint
+// The order is shipped to the address of the address book with ShippingAddressID,
+// to the free text Address in Country and Region, or else to the default shipping
+// address of the user. It is billed to the address with BillingAddressID, the
+// default billing address or else the shipping address.
+// The destination selects the tax rates and the shipping methods of the order,
+// ShippingMethodID is required when the destination has shipping methods
+type PlaceOrderPayload struct {
+	Items   []CartItem `json:"items"   validate:"required,min=1,dive"`
+	Address string     `json:"address" validate:"max=1024"`
+	Country string     `json:"country" validate:"omitempty,iso3166_1_alpha2"`
+	Region  string     `json:"region"  validate:"max=64"`
+	Coupons []string   `json:"coupons" validate:"max=5,dive,required,max=64"`
+
+	ShippingAddressID *int `json:"shippingAddressId" validate:"omitempty,gt=0"`
+	BillingAddressID  *int `json:"billingAddressId"  validate:"omitempty,gt=0"`
+	ShippingMethodID  *int `json:"shippingMethodId"  validate:"omitempty,gt=0"`
+}
+
+// Checkout holds everything needed to place an order,
+// the items are priced in the currency of Rate.
+// TaxInclusive is set when the prices include the taxes
+type Checkout struct {
+	UserID       int
+	Address      string
+	Country      string
+	Region       string
+	Items        []CartItem
+	Rate         ExchangeRate
+	Coupons      []string
+	TaxInclusive bool
+
+	ShippingAddress  *PostalAddress
+	BillingAddress   *PostalAddress
+	ShippingMethodID *int
+}
+
+// OrderStatusChange struct to hold a single entry of the order status history
+// FromStatus is nil for the entry recorded when the order is placed
+type OrderStatusChange struct {
+	ID         int          `json:"id"`
+	OrderID    int          `json:"orderId"`
+	FromStatus *OrderStatus `json:"fromStatus"`
+	ToStatus   OrderStatus  `json:"toStatus"`
+	ChangedBy  *int         `json:"changedBy"`
+	Note       string       `json:"note"`
+	CreatedAt  time.Time    `json:"createdAt"`
+}
+
+// UpdateOrderStatusPayload Payload for the update order status api endpoint
+type UpdateOrderStatusPayload struct {
+	Status OrderStatus `json:"status" validate:"required"`
+	Note   string      `json:"note"`
+}
+
+// PlaceOrderResponse holds the response sent for the place order endpoint
+type PlaceOrderResponse struct {
+	ID       int   `json:"id"`
+	Discount Money `json:"discount"`
+	Tax      Money `json:"tax"`
+	Shipping Money `json:"shipping"`
+	Total    Money `json:"total"`
+}
+
+// PaymentGateway interface to hold the operations of a payment provider.
+// A declined payment is reported by the Status of the result,
+// the error is for the requests the gateway could not process
+type PaymentGateway interface {
+	Name() string
+	Authorize(ctx context.Context, req AuthorizeRequest) (*GatewayResult, error)
+	Confirm(ctx context.Context, reference string) (*GatewayResult, error)
+	Capture(ctx context.Context, reference string, amount Money) (*GatewayResult, error)
+	Void(ctx context.Context, reference string) (*GatewayResult, error)
+	Refund(ctx context.Context, reference string, amount Money) (*GatewayResult, error)
+}
+
+// AuthorizeRequest holds the data sent to a gateway to authorize a payment,
+// PaymentMethod is the token of the card collected by the client
+type AuthorizeRequest struct {
+	OrderID       int
+	Amount        Money
+	PaymentMethod string
+}
+
+// GatewayResult holds the outcome of a gateway operation.
+// ActionURL is where the customer completes a payment that requires action
+type GatewayResult struct {
+	Reference     string
+	Status        PaymentStatus
+	ActionURL     string
+	DeclineReason string
+}
+
+// PaymentStore interface to hold all the methods required
+// for handling Payment operations with the database(store).
+// The payments are created and updated while their order is locked
+type PaymentStore interface {
+	GetPaymentByID(int) (*Payment, error)
+	GetPaymentsByOrderID(int) ([]Payment, error)
+	GetPaymentByReference(gateway string, reference string) (*Payment, error)
+	CreatePayment(orderID int, authorize func(*Order, []Payment) (*Payment, error)) (*Payment, error)
+	UpdatePayment(id int, update func(*Order, *Payment) error) (*Payment, error)
+}
+
+// PaymentStatus is the status of a payment at the gateway
+type PaymentStatus string
+
+// Statuses a payment can be in
+const (
+	PaymentRequiresAction    PaymentStatus = "requires_action"
+	PaymentAuthorized        PaymentStatus = "authorized"
+	PaymentCaptured          PaymentStatus = "captured"
+	PaymentPartiallyRefunded PaymentStatus = "partially_refunded"
+	PaymentRefunded          PaymentStatus = "refunded"
+	PaymentVoided            PaymentStatus = "voided"
+	PaymentDeclined          PaymentStatus = "declined"
+)
+
+// Payment struct to hold a payment of an order at a gateway
+// the amounts are in the currency of the order, Amount is
+// the authorized amount, Captured and Refunded what was taken
+// and given back of it
+type Payment struct {
+	ID            int           `json:"id"`
+	OrderID       int           `json:"orderId"`
+	Gateway       string        `json:"gateway"`
+	Reference     string        `json:"reference"`
+	Status        PaymentStatus `json:"status"`
+	Amount        Money         `json:"amount"`
+	Captured      Money         `json:"capturedAmount"`
+	Refunded      Money         `json:"refundedAmount"`
+	ActionURL     string        `json:"actionUrl,omitempty"`
+	DeclineReason string        `json:"declineReason,omitempty"`
+	CreatedAt     time.Time     `json:"createdAt"`
+	UpdatedAt     time.Time     `json:"updatedAt"`
+}
+
+// AuthorizePaymentPayload Payload for the pay order api endpoint
+type AuthorizePaymentPayload struct {
+	PaymentMethod string `json:"paymentMethod" validate:"required,max=255"`
+}
+
+// PaymentAmountPayload Payload for the capture payment api endpoint,
+// the whole remaining amount is used when Amount is not set
+type PaymentAmountPayload struct {
+	Amount *Money `json:"amount" validate:"omitempty,gt=0"`
+}
+
+// CreditNoteStore interface to hold all the methods required
+// for handling the refunds of the orders with the database(store)
+type CreditNoteStore interface {
+	CreateCreditNote(orderID int, createdBy int, payload RefundPayload, refund func(*CreditNote, *Payment) error) (*CreditNote, error)
+	GetCreditNotesByOrderID(int) ([]CreditNote, error)
+}
+
+// CreditNote struct to hold a refund of an order, Number is its sequential
+// number and ReturnID the return it refunds. The amounts are in the currency of
+// the order, Total is what is given back for the Lines and the Shipping, Tax is
+// the part of it that is tax
+type CreditNote struct {
+	ID        int              `json:"id"`
+	Number    string           `json:"number"`
+	OrderID   int              `json:"orderId"`
+	PaymentID *int             `json:"paymentId"`
+	ReturnID  *int             `json:"returnId"`
+	Lines     []CreditNoteLine `json:"lines"`
+	Shipping  Money            `json:"shipping"`
+	Tax       Money            `json:"tax"`
+	Total     Money            `json:"total"`
+	Restock   bool             `json:"restock"`
+	Reason    string           `json:"reason"`
+	CreatedBy *int             `json:"createdBy"`
+	CreatedAt time.Time        `json:"createdAt"`
+}
+
+// CreditNoteLine struct to hold the refunded units of an order item,
+// Amount is what was paid for them including the Tax
+type CreditNoteLine struct {
+	ID           int   `json:"id"`
+	CreditNoteID int   `json:"creditNoteId"`
+	OrderItemID  int   `json:"orderItemId"`
+	Quantity     int   `json:"quantity"`
+	Amount       Money `json:"amount"`
+	Tax          Money `json:"tax"`
+}
+
+// RefundPayload Payload for the refund order api endpoint. Without Lines
+// whatever is left of the order is refunded including the shipping, with an
+// empty list only the shipping is. Restock returns the refunded units to the stock.
+// ReturnID is the return refunded, it is set by the returns and never decoded
+type RefundPayload struct {
+	Lines    []RefundLinePayload `json:"lines"    validate:"max=100,dive"`
+	Shipping bool                `json:"shipping"`
+	Restock  bool                `json:"restock"`
+	Reason   string              `json:"reason"   validate:"max=255"`
+	ReturnID int                 `json:"-"`
+}
+
+// RefundLinePayload holds the units of an order item to refund
+type RefundLinePayload struct {
+	OrderItemID int `json:"orderItemId" validate:"required"`
+	Quantity    int `json:"quantity"    validate:"required,gt=0"`
+}
+
+// ReturnStore interface to hold all the methods required
+// for handling the returns of the order items with the database(store)
+type ReturnStore interface {
+	CreateReturn(orderID int, userID int, payload CreateReturnPayload) (*Return, error)
+	GetReturnByID(int) (*Return, error)
+	GetReturnsByOrderID(int) ([]Return, error)
+	GetReturnsByUserID(int) ([]Return, error)
+	GetReturnsByStatus(ReturnStatus) ([]Return, error)
+	UpdateReturnStatus(id int, status ReturnStatus, changedBy int, note string) (*Return, error)
+	ReceiveReturn(id int, changedBy int, payload ReceiveReturnPayload) (*Return, error)
+	RefundReturn(id int, creditNoteID int, changedBy int, note string) (*Return, error)
+	GetReturnStatusHistory(int) ([]ReturnStatusChange, error)
+}
+
+// ReturnStatus is the status of a return in its lifecycle
+type ReturnStatus string
+
+// Statuses a return can be in
+const (
+	ReturnStatusRequested ReturnStatus = "requested"
+	ReturnStatusApproved  ReturnStatus = "approved"
+	ReturnStatusRejected  ReturnStatus = "rejected"
+	ReturnStatusReceived  ReturnStatus = "received"
+	ReturnStatusRefunded  ReturnStatus = "refunded"
+	ReturnStatusCancelled ReturnStatus = "cancelled"
+)
+
+// ReturnReason is why the customer returns an item
+type ReturnReason string
+
+// Reasons an item can be returned for
+const (
+	ReturnReasonDamaged        ReturnReason = "damaged"
+	ReturnReasonDefective      ReturnReason = "defective"
+	ReturnReasonWrongItem      ReturnReason = "wrong_item"
+	ReturnReasonNotAsDescribed ReturnReason = "not_as_described"
+	ReturnReasonNoLongerNeeded ReturnReason = "no_longer_needed"
+	ReturnReasonOther          ReturnReason = "other"
+)
+
+// ReturnResolution is what is done with a returned item once it is inspected
+type ReturnResolution string
+
+// Resolutions of the inspected items, the restocked units go back to the stock
+const (
+	ReturnResolutionRestock  ReturnResolution = "restock"
+	ReturnResolutionWriteOff ReturnResolution = "write_off"
+)
+
+// Return struct to hold a request of a customer to return order items,
+// CreditNoteID is the credit note the return was refunded with
+type Return struct {
+	ID           int          `json:"id"`
+	OrderID      int          `json:"orderId"`
+	UserID       int          `json:"userId"`
+	Status       ReturnStatus `json:"status"`
+	Note         string       `json:"note"`
+	CreditNoteID *int         `json:"creditNoteId"`
+	Items        []ReturnItem `json:"items"`
+	CreatedAt    time.Time    `json:"createdAt"`
+	UpdatedAt    time.Time    `json:"updatedAt"`
+}
+
+// ReturnItem struct to hold the returned units of an order item,
+// Resolution is empty until the returned units are inspected
+type ReturnItem struct {
+	ID          int              `json:"id"`
+	ReturnID    int              `json:"returnId"`
+	OrderItemID int              `json:"orderItemId"`
+	Quantity    int              `json:"quantity"`
+	Reason      ReturnReason     `json:"reason"`
+	Resolution  ReturnResolution `json:"resolution,omitempty"`
+}
+
+// ReturnStatusChange struct to hold a single entry of the return status history
+// FromStatus is nil for the entry recorded when the return is requested
+type ReturnStatusChange struct {
+	ID         int           `json:"id"`
+	ReturnID   int           `json:"returnId"`
+	FromStatus *ReturnStatus `json:"fromStatus"`
+	ToStatus   ReturnStatus  `json:"toStatus"`
+	ChangedBy  *int          `json:"changedBy"`
+	Note       string        `json:"note"`
+	CreatedAt  time.Time     `json:"createdAt"`
+}
+
+// CreateReturnPayload Payload for the request return api endpoint
+type CreateReturnPayload struct {
+	Items []ReturnItemPayload `json:"items" validate:"required,min=1,max=100,dive"`
+	Note  string              `json:"note"  validate:"max=1000"`
+}
+
+// ReturnItemPayload holds the units of an order item to return and why
+type ReturnItemPayload struct {
+	OrderItemID int          `json:"orderItemId" validate:"required"`
+	Quantity    int          `json:"quantity"    validate:"required,gt=0"`
+	Reason      ReturnReason `json:"reason"      validate:"oneof=damaged defective wrong_item not_as_described no_longer_needed other"`
+}
+
+// UpdateReturnStatusPayload Payload for the update return status api endpoint
+type UpdateReturnStatusPayload struct {
+	Status ReturnStatus `json:"status" validate:"required"`
+	Note   string       `json:"note"`
+}
+
+// ReceiveReturnPayload Payload for the receive return api endpoint,
+// every item of the return is given a resolution
+type ReceiveReturnPayload struct {
+	Items []ReturnInspectionPayload `json:"items" validate:"required,min=1,max=100,dive"`
+	Note  string                    `json:"note"`
+}
+
+// ReturnInspectionPayload holds the resolution of an inspected return item
+type ReturnInspectionPayload struct {
+	ReturnItemID int              `json:"returnItemId" validate:"required"`
+	Resolution   ReturnResolution `json:"resolution"   validate:"oneof=restock write_off"`
+}
+
+// WebhookEventStore interface to hold all the methods required
+// for handling the events received from the payment providers with the database(store)
+type WebhookEventStore interface {
+	SaveWebhookEvent(WebhookEvent) (*WebhookEvent, bool, error)
+	GetWebhookEventByID(int) (*WebhookEvent, error)
+	GetWebhookEventsByStatus(WebhookEventStatus) ([]WebhookEvent, error)
+	UpdateWebhookEventStatus(id int, status WebhookEventStatus, lastError string) error
+}
+
+// WebhookEventStatus is the processing status of a webhook event
+type WebhookEventStatus string
+
+// Statuses a webhook event can be in
+const (
+	WebhookEventPending   WebhookEventStatus = "pending"
+	WebhookEventProcessed WebhookEventStatus = "processed"
+	WebhookEventFailed    WebhookEventStatus = "failed"
+)
+
+// WebhookEvent struct to hold an event received from a payment provider,
+// EventID is the id given by the provider, an event is processed once.
+// Payload is the body of the request as it was received
+type WebhookEvent struct {
+	ID          int                `json:"id"`
+	Provider    string             `json:"provider"`
+	EventID     string             `json:"eventId"`
+	Type        string             `json:"type"`
+	Payload     string             `json:"payload"`
+	Status      WebhookEventStatus `json:"status"`
+	Attempts    int                `json:"attempts"`
+	LastError   string             `json:"lastError,omitempty"`
+	ReceivedAt  time.Time          `json:"receivedAt"`
+	ProcessedAt *time.Time         `json:"processedAt"`
+}
+
+// PaymentEvent holds the body of a webhook request of a payment provider
+type PaymentEvent struct {
+	ID   string           `json:"id"   validate:"required,max=128"`
+	Type string           `json:"type" validate:"required,max=64"`
+	Data PaymentEventData `json:"data"`
+}
+
+// PaymentEventData holds the payment an event is about. Amount is the
+// captured amount of payment.captured and the refunded amount so far
+// of payment.refunded, the whole payment when it is not set
+type PaymentEventData struct {
+	Reference string `json:"reference" validate:"required,max=128"`
+	Amount    *Money `json:"amount"    validate:"omitempty,gt=0"`
+	Reason    string `json:"reason"    validate:"max=255"`
+}
+
+// IdempotencyKeyStore interface to hold all the methods required
+// for handling the idempotency keys of the requests with the database(store)
+type IdempotencyKeyStore interface {
+	ReserveIdempotencyKey(IdempotencyKey) (*IdempotencyKey, bool, error)
+	SaveIdempotencyResponse(id int, status int, contentType string, body []byte) error
+	DeleteIdempotencyKey(int) error
+	DeleteExpiredIdempotencyKeys() (int64, error)
+}
+
+// IdempotencyKey struct to hold a request made with an Idempotency-Key header
+// and the response it was given. The keys are scoped to the user sending them,
+// UserID is 0 for anonymous requests. Fingerprint identifies the request
+// the key was first used with, ResponseStatus is 0 until the response is saved
+type IdempotencyKey struct {
+	ID             int
+	UserID         int
+	Key            string
+	Fingerprint    string
+	ResponseStatus int
+	ContentType    string
+	ResponseBody   []byte
+	CreatedAt      time.Time
+	ExpiresAt      time.Time
+}
+
+// CartStore interface to hold all the methods required
+// for handling Cart operations with the database(store)
+type CartStore interface {
+	GetCartByToken(string) (*Cart, error)
+	GetCartByUserID(int) (*Cart, error)
+	CreateCart(Cart) (int, error)
+	GetCartLines(int) ([]CartLine, error)
+	AddCartItem(cartID int, productID int, sku string, quantity int) error
+	UpdateCartItem(cartID int, productID int, sku string, quantity int) error
+	RemoveCartItem(cartID int, productID int, sku string) error
+	MergeGuestCart(token string, userID int) error
+	GetCartCoupons(cartID int) ([]string, error)
+	AddCartCoupon(cartID int, code string) error
+	RemoveCartCoupon(cartID int, code string) error
+}
+
+// Cart struct to hold the data regarding a cart.
+// Guest carts have no UserID and are identified by their Token.
+// Total is the Subtotal of the lines less the Discount of the coupons,
+// InvalidCoupons lists the coupons of the cart that do not apply
+type Cart struct {
+	ID             int                `json:"id"`
+	UserID         *int               `json:"userId"`
+	Token          string             `json:"cartToken,omitempty"`
+	Lines          []CartLine         `json:"items"`
+	Subtotal       Money              `json:"subtotal"`
+	Discount       Money              `json:"discount"`
+	Total          Money              `json:"total"`
+	FreeShipping   bool               `json:"freeShipping"`
+	Promotions     []AppliedPromotion `json:"promotions"`
+	InvalidCoupons []CouponError      `json:"invalidCoupons,omitempty"`
+	CreatedAt      time.Time          `json:"createdAt"`
+	UpdatedAt      time.Time          `json:"updatedAt"`
+}
+
+// CartLine struct to hold a single priced line of a cart
+// Price is the current price of the product or of its variant,
+// Weight is the weight in grams a unit is shipped as
+type CartLine struct {
+	ProductID int               `json:"productId"`
+	SKU       string            `json:"sku,omitempty"`
+	Options   map[string]string `json:"options,omitempty"`
+	Name      string            `json:"name"`
+	Image     string            `json:"image"`
+	Price     Money             `json:"price"`
+	Quantity  int               `json:"quantity"`
+	LineTotal Money             `json:"lineTotal"`
+	Weight    int               `json:"weight"`
+}
+
+// UpdateCartItemPayload Payload for the update cart item api endpoint
+type UpdateCartItemPayload struct {
+	Quantity int `json:"quantity" validate:"required,gt=0"`
+}
@@ -0,0 +1,228 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// ProblemContentType is the media type of the error responses (RFC 7807)
+const ProblemContentType = "application/problem+json"
+
+// Stable codes of the errors, clients can rely on them while the messages change
+const (
+	CodeBadRequest         = "bad_request"
+	CodeValidationFailed   = "validation_failed"
+	CodeUnauthorized       = "unauthorized"
+	CodePaymentRequired    = "payment_required"
+	CodeForbidden          = "forbidden"
+	CodeNotFound           = "not_found"
+	CodeConflict           = "conflict"
+	CodeRequestTooLarge    = "request_too_large"
+	CodeUnprocessable      = "unprocessable_entity"
+	CodeInternal           = "internal_error"
+	CodeBadGateway         = "bad_gateway"
+	CodeServiceUnavailable = "service_unavailable"
+)
+
+// internalMessage is the detail of the server errors, their cause is only logged
+const internalMessage = "An internal error occurred, please try again later and quote the request id if it persists"
+
+// Error is an error with the status and the code it is reported to the clients
+// with. Message is sent to the clients, Err is the cause and is only logged
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Fields  []FieldError
+	Err     error
+}
+
+// FieldError describes why a field of the request is invalid, Field
+// is its path in the request as the client sent it ("items[0].quantity")
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Problem is the body of the error responses (RFC 7807), Code, RequestID and
+// Errors are extension members
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"requestId,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// NewError returns an error reported with the status, the code and the message
+func NewError(status int, code string, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// ValidationError returns the error of the validation of the request, every
+// invalid field is reported with its own message. message says what part of
+// the request is invalid ("Invalid payload", "Invalid query")
+func ValidationError(message string, err error) *Error {
+	e := &Error{Status: http.StatusBadRequest, Code: CodeValidationFailed, Message: message, Err: err}
+
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		e.Fields = fieldErrors(validationErrors)
+	}
+
+	return e
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WriteError function writes the error as an application/problem+json response.
+// The typed errors are reported with their own status, code and message, the
+// validation errors with a message per field and the other errors with the code
+// of the status. The cause of the server errors is logged with the request id
+// and a generic message is sent instead so internal details do not leak
+func WriteError(w http.ResponseWriter, status int, err error) {
+	p := Problem{Type: "about:blank", Status: status, Code: CodeForStatus(status), Detail: err.Error()}
+
+	var e *Error
+	var validationErrors validator.ValidationErrors
+	switch {
+	case errors.As(err, &e):
+		if e.Status != 0 {
+			p.Status = e.Status
+		}
+		if e.Code != "" {
+			p.Code = e.Code
+		}
+		p.Detail, p.Errors = e.Message, e.Fields
+	case errors.As(err, &validationErrors):
+		p.Code, p.Detail, p.Errors = CodeValidationFailed, "Invalid request", fieldErrors(validationErrors)
+	}
+
+	p.Title = http.StatusText(p.Status)
+	p.RequestID = w.Header().Get(RequestIDHeader)
+
+	if p.Status >= http.StatusInternalServerError {
+		log.Printf("request %s failed with status %d, error: %+v", p.RequestID, p.Status, err)
+
+		if e == nil {
+			p.Code, p.Detail = CodeForStatus(p.Status), internalMessage
+		}
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(p.Status)
+
+	json.NewEncoder(w).Encode(p)
+}
+
+// CodeForStatus returns the code of the errors reported with the status
+func CodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusPaymentRequired:
+		return CodePaymentRequired
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusRequestEntityTooLarge:
+		return CodeRequestTooLarge
+	case http.StatusUnprocessableEntity:
+		return CodeUnprocessable
+	case http.StatusBadGateway:
+		return CodeBadGateway
+	case http.StatusServiceUnavailable:
+		return CodeServiceUnavailable
+	}
+
+	if status >= http.StatusInternalServerError {
+		return CodeInternal
+	}
+
+	return CodeBadRequest
+}
+
+// fieldErrors describes the failed validations of the fields
+func fieldErrors(validationErrors validator.ValidationErrors) []FieldError {
+	fields := make([]FieldError, len(validationErrors))
+	for i, fe := range validationErrors {
+		fields[i] = FieldError{Field: fieldPath(fe), Code: fe.Tag(), Message: fieldMessage(fe)}
+	}
+
+	return fields
+}
+
+// fieldPath returns the path of the field without the name of the validated struct
+func fieldPath(fe validator.FieldError) string {
+	namespace := fe.Namespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+
+	return namespace
+}
+
+// fieldMessage returns a message for the failed validation of the field
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(fe.Param()), ", ")
+	case "min":
+		return "must be at least " + fe.Param() + lengthUnit(fe)
+	case "max":
+		return "must be at most " + fe.Param() + lengthUnit(fe)
+	case "len":
+		return "must be exactly " + fe.Param() + lengthUnit(fe)
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "gte":
+		return "must be at least " + fe.Param()
+	case "lt":
+		return "must be less than " + fe.Param()
+	case "lte":
+		return "must be at most " + fe.Param()
+	}
+
+	return "is invalid"
+}
+
+// lengthUnit returns what the length of the field counts,
+// the bounds of the numbers have no unit
+func lengthUnit(fe validator.FieldError) string {
+	switch fe.Kind().String() {
+	case "string":
+		return " characters"
+	case "slice", "array", "map":
+		return " items"
+	}
+
+	return ""
+}
@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testItemPayload struct {
+	Quantity int `json:"quantity" validate:"required,gt=0"`
+}
+
+type testPayload struct {
+	Name  string            `json:"name"  validate:"required,max=8"`
+	Kind  string            `json:"kind"  validate:"oneof=a b"`
+	Items []testItemPayload `json:"items" validate:"max=2,dive"`
+}
+
+func decodeProblem(t *testing.T, rr *httptest.ResponseRecorder) Problem {
+	if ct := rr.Header().Get("Content-Type"); ct != ProblemContentType {
+		t.Errorf("Expected the content type %s, got %s", ProblemContentType, ct)
+	}
+
+	var p Problem
+	if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+// TestWriteError function to test the problem responses
+func TestWriteError(t *testing.T) {
+	t.Run("Should report the invalid fields by their json path", func(t *testing.T) {
+		err := Validate.Struct(testPayload{Name: "too long a name", Kind: "c", Items: []testItemPayload{{Quantity: 1}, {Quantity: 0}}})
+
+		rr := httptest.NewRecorder()
+		WriteError(rr, http.StatusBadRequest, ValidationError("Invalid payload", err))
+
+		p := decodeProblem(t, rr)
+		if rr.Code != http.StatusBadRequest || p.Status != http.StatusBadRequest || p.Code != CodeValidationFailed || p.Detail != "Invalid payload" {
+			t.Errorf("Expected a validation problem, got %d %+v", rr.Code, p)
+		}
+
+		expected := []FieldError{
+			{Field: "name", Code: "max", Message: "must be at most 8 characters"},
+			{Field: "kind", Code: "oneof", Message: "must be one of a, b"},
+			{Field: "items[1].quantity", Code: "required", Message: "is required"},
+		}
+		if fmt.Sprint(p.Errors) != fmt.Sprint(expected) {
+			t.Errorf("Expected the field errors %v, got %v", expected, p.Errors)
+		}
+	})
+
+	t.Run("Should find the validation errors when they are wrapped", func(t *testing.T) {
+		err := fmt.Errorf("wrapped: %w", Validate.Struct(testItemPayload{Quantity: -1}))
+
+		rr := httptest.NewRecorder()
+		WriteError(rr, http.StatusBadRequest, err)
+
+		if p := decodeProblem(t, rr); p.Code != CodeValidationFailed || len(p.Errors) != 1 || p.Errors[0].Message != "must be greater than 0" {
+			t.Errorf("Expected the wrapped validation errors, got %+v", p)
+		}
+	})
+
+	t.Run("Should use the status, the code and the message of the typed errors", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		WriteError(rr, http.StatusBadRequest, fmt.Errorf("login: %w", NewError(http.StatusUnauthorized, "invalid_credentials", "Invalid email or password")))
+
+		if p := decodeProblem(t, rr); rr.Code != http.StatusUnauthorized || p.Code != "invalid_credentials" || p.Detail != "Invalid email or password" || p.Title != "Unauthorized" {
+			t.Errorf("Expected the typed error, got %d %+v", rr.Code, p)
+		}
+	})
+
+	t.Run("Should report the other errors with the code of the status", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		WriteError(rr, http.StatusConflict, errors.New("order is cancelled"))
+
+		if p := decodeProblem(t, rr); p.Code != CodeConflict || p.Detail != "order is cancelled" || p.Type != "about:blank" {
+			t.Errorf("Expected a conflict, got %+v", p)
+		}
+	})
+
+	t.Run("Should not leak the cause of the server errors", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		rr.Header().Set(RequestIDHeader, "req-1")
+		WriteError(rr, http.StatusInternalServerError, errors.New("dial tcp 10.0.0.3:3306: connection refused"))
+
+		p := decodeProblem(t, rr)
+		if p.Code != CodeInternal || p.RequestID != "req-1" || strings.Contains(p.Detail, "3306") {
+			t.Errorf("Expected a generic internal error, got %+v", p)
+		}
+	})
+}
+
+// TestRequestIDMiddleware function to test the ids of the requests
+func TestRequestIDMiddleware(t *testing.T) {
+	var seen string
+	handler := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFromContext(r.Context())
+	}))
+
+	for _, test := range []struct {
+		header string
+		kept   bool
+	}{
+		{"", false},
+		{"abc-123", true},
+		{"bad id\n", false},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if test.header != "" {
+			req.Header.Set(RequestIDHeader, test.header)
+		}
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		id := rr.Header().Get(RequestIDHeader)
+		if id == "" || id != seen || (id == test.header) != test.kept {
+			t.Errorf("%q: expected the id to be kept %v, got %q in the response and %q in the context", test.header, test.kept, id, seen)
+		}
+	}
+}
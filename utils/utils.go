@@ -5,13 +5,39 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"reflect"
+	"strings"
 
+	"github.com/akshtrikha/golang-ecomm/types"
 	"github.com/go-playground/validator/v10"
 )
 
 // Validate is a singleton of the validator struct
 // Singleton is helpful as this package uses caching
-var Validate = validator.New()
+var Validate = newValidator()
+
+// newValidator validates the Money fields by their amount in minor units
+// so the tags like required and gt=0 apply to money the same as to numbers.
+// The fields are named in the validation errors as the clients send them,
+// by their json names or by the query parameters of the query structs
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterCustomTypeFunc(func(field reflect.Value) any {
+		return field.Interface().(types.Money).Amount
+	}, types.Money{})
+
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, tag := range []string{"json", "query"} {
+			if name, _, _ := strings.Cut(field.Tag.Get(tag), ","); name != "" && name != "-" {
+				return name
+			}
+		}
+
+		return field.Name
+	})
+
+	return v
+}
 
 // ParseJSON function to parse the json body received in request
 func ParseJSON(r *http.Request, payload any) error {
@@ -34,8 +60,3 @@ func WriteJSON(w http.ResponseWriter, status int, v any) error {
 
 	return json.NewEncoder(w).Encode(v)
 }
-
-// WriteError function
-func WriteError(w http.ResponseWriter, status int, err error) {
-	WriteJSON(w, status, map[string]string{"error": err.Error()})
-}